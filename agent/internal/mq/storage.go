@@ -75,8 +75,7 @@ func (svc *Service) StorageCheck(msg *agtmq.StorageCheck) (*agtmq.StorageCheckRe
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	// TODO UserID。应该设计两种接口，一种需要UserID，一种不需要。
-	getStg, err := coorCli.GetStorage(coormq.ReqGetStorage(cdssdk.UserID(1), msg.StorageID))
+	getStg, err := coorCli.GetStorageInfo(coormq.ReqGetStorageInfo(msg.StorageID))
 	if err != nil {
 		return mq.ReplyOK(agtmq.NewStorageCheckResp(
 			err.Error(),
@@ -135,8 +134,7 @@ func (svc *Service) StorageGC(msg *agtmq.StorageGC) (*agtmq.StorageGCResp, *mq.C
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	// TODO UserID。应该设计两种接口，一种需要UserID，一种不需要。
-	getStg, err := coorCli.GetStorage(coormq.ReqGetStorage(cdssdk.UserID(1), msg.StorageID))
+	getStg, err := coorCli.GetStorageInfo(coormq.ReqGetStorageInfo(msg.StorageID))
 	if err != nil {
 		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
 	}
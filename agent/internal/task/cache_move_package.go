@@ -78,7 +78,7 @@ func (t *CacheMovePackage) do(ctx TaskContext) error {
 		}
 	}
 
-	_, err = coorCli.CachePackageMoved(coormq.NewCachePackageMoved(t.packageID, *stgglb.Local.NodeID))
+	_, err = coorCli.CachePackageMoved(coormq.NewCachePackageMoved(t.userID, t.packageID, *stgglb.Local.NodeID))
 	if err != nil {
 		return fmt.Errorf("request to coordinator: %w", err)
 	}
@@ -63,10 +63,14 @@ func (t *CreatePackage) Execute(task *task.Task[TaskContext], ctx TaskContext, c
 		return
 	}
 
-	uploadRet, err := cmd.NewUploadObjects(t.userID, createResp.Package.PackageID, t.objIter, t.nodeAffinity).Execute(&cmd.UploadObjectsContext{
+	uploadRet, err := cmd.NewUploadObjects(t.userID, createResp.Package.PackageID, t.objIter, t.nodeAffinity, nil).Execute(&cmd.UploadObjectsContext{
 		Distlock:     ctx.distlock,
 		Connectivity: ctx.connectivity,
 	})
+	t.Result.PackageID = createResp.Package.PackageID
+	if uploadRet != nil {
+		t.Result.Objects = uploadRet.Objects
+	}
 	if err != nil {
 		err = fmt.Errorf("uploading objects: %w", err)
 		log.Error(err.Error())
@@ -76,9 +80,6 @@ func (t *CreatePackage) Execute(task *task.Task[TaskContext], ctx TaskContext, c
 		return
 	}
 
-	t.Result.PackageID = createResp.Package.PackageID
-	t.Result.Objects = uploadRet.Objects
-
 	complete(nil, CompleteOption{
 		RemovingDelay: time.Minute,
 	})
@@ -16,7 +16,6 @@ import (
 	"gitlink.org.cn/cloudream/common/utils/io2"
 	"gitlink.org.cn/cloudream/common/utils/reflect2"
 	"gitlink.org.cn/cloudream/common/utils/sort2"
-	"gitlink.org.cn/cloudream/storage/common/consts"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
@@ -95,7 +94,7 @@ func (t *StorageLoadPackage) do(task *task.Task[TaskContext], ctx TaskContext) e
 	defer mutex.Unlock()
 
 	for _, obj := range getObjectDetails.Objects {
-		err := t.downloadOne(coorCli, ipfsCli, fullLocalPath, obj)
+		err := t.downloadOne(ctx, coorCli, ipfsCli, fullLocalPath, obj)
 		if err != nil {
 			return err
 		}
@@ -110,7 +109,7 @@ func (t *StorageLoadPackage) do(task *task.Task[TaskContext], ctx TaskContext) e
 	return err
 }
 
-func (t *StorageLoadPackage) downloadOne(coorCli *coormq.Client, ipfsCli *ipfs.PoolClient, dir string, obj stgmod.ObjectDetail) error {
+func (t *StorageLoadPackage) downloadOne(ctx TaskContext, coorCli *coormq.Client, ipfsCli *ipfs.PoolClient, dir string, obj stgmod.ObjectDetail) error {
 	var file io.ReadCloser
 
 	switch red := obj.Object.Redundancy.(type) {
@@ -129,7 +128,7 @@ func (t *StorageLoadPackage) downloadOne(coorCli *coormq.Client, ipfsCli *ipfs.P
 		file = reader
 
 	case *cdssdk.ECRedundancy:
-		reader, pinnedBlocks, err := t.downloadECObject(coorCli, ipfsCli, obj, red)
+		reader, pinnedBlocks, err := t.downloadECObject(ctx, coorCli, ipfsCli, obj, red)
 		if err != nil {
 			return fmt.Errorf("downloading ec object: %w", err)
 		}
@@ -177,8 +176,8 @@ func (t *StorageLoadPackage) downloadNoneOrRepObject(ipfsCli *ipfs.PoolClient, o
 	return file, nil
 }
 
-func (t *StorageLoadPackage) downloadECObject(coorCli *coormq.Client, ipfsCli *ipfs.PoolClient, obj stgmod.ObjectDetail, ecRed *cdssdk.ECRedundancy) (io.ReadCloser, []stgmod.ObjectBlock, error) {
-	allNodes, err := t.sortDownloadNodes(coorCli, obj)
+func (t *StorageLoadPackage) downloadECObject(ctx TaskContext, coorCli *coormq.Client, ipfsCli *ipfs.PoolClient, obj stgmod.ObjectDetail, ecRed *cdssdk.ECRedundancy) (io.ReadCloser, []stgmod.ObjectBlock, error) {
+	allNodes, err := t.sortDownloadNodes(ctx, coorCli, obj)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -245,7 +244,7 @@ type downloadNodeInfo struct {
 	Distance     float64
 }
 
-func (t *StorageLoadPackage) sortDownloadNodes(coorCli *coormq.Client, obj stgmod.ObjectDetail) ([]*downloadNodeInfo, error) {
+func (t *StorageLoadPackage) sortDownloadNodes(ctx TaskContext, coorCli *coormq.Client, obj stgmod.ObjectDetail) ([]*downloadNodeInfo, error) {
 	var nodeIDs []cdssdk.NodeID
 	for _, id := range obj.PinnedAt {
 		if !lo.Contains(nodeIDs, id) {
@@ -271,7 +270,7 @@ func (t *StorageLoadPackage) sortDownloadNodes(coorCli *coormq.Client, obj stgmo
 			node = &downloadNodeInfo{
 				Node:         mod,
 				ObjectPinned: true,
-				Distance:     t.getNodeDistance(mod),
+				Distance:     t.getNodeDistance(ctx, mod),
 			}
 			downloadNodeMap[id] = node
 		}
@@ -285,7 +284,7 @@ func (t *StorageLoadPackage) sortDownloadNodes(coorCli *coormq.Client, obj stgmo
 			mod := *getNodes.GetNode(b.NodeID)
 			node = &downloadNodeInfo{
 				Node:     mod,
-				Distance: t.getNodeDistance(mod),
+				Distance: t.getNodeDistance(ctx, mod),
 			}
 			downloadNodeMap[b.NodeID] = node
 		}
@@ -340,16 +339,6 @@ func (t *StorageLoadPackage) getMinReadingObjectSolution(sortedNodes []*download
 	return dist, downloadNode
 }
 
-func (t *StorageLoadPackage) getNodeDistance(node cdssdk.Node) float64 {
-	if stgglb.Local.NodeID != nil {
-		if node.NodeID == *stgglb.Local.NodeID {
-			return consts.NodeDistanceSameNode
-		}
-	}
-
-	if node.LocationID == stgglb.Local.LocationID {
-		return consts.NodeDistanceSameLocation
-	}
-
-	return consts.NodeDistanceOther
+func (t *StorageLoadPackage) getNodeDistance(ctx TaskContext, node cdssdk.Node) float64 {
+	return ctx.connectivity.Score(node).Total
 }
@@ -8,7 +8,10 @@ import (
 )
 
 func BucketListUserBuckets(ctx CommandContext) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	buckets, err := ctx.Cmdline.Svc.BucketSvc().GetUserBuckets(userID)
 	if err != nil {
@@ -29,7 +32,10 @@ func BucketListUserBuckets(ctx CommandContext) error {
 }
 
 func BucketCreateBucket(ctx CommandContext, bucketName string) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	bucketID, err := ctx.Cmdline.Svc.BucketSvc().CreateBucket(userID, bucketName)
 	if err != nil {
@@ -41,9 +47,12 @@ func BucketCreateBucket(ctx CommandContext, bucketName string) error {
 }
 
 func BucketDeleteBucket(ctx CommandContext, bucketID cdssdk.BucketID) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
-	err := ctx.Cmdline.Svc.BucketSvc().DeleteBucket(userID, bucketID)
+	err = ctx.Cmdline.Svc.BucketSvc().DeleteBucket(userID, bucketID)
 	if err != nil {
 		return err
 	}
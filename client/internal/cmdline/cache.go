@@ -13,7 +13,12 @@ func CacheMovePackage(ctx CommandContext, packageID cdssdk.PackageID, nodeID cds
 		fmt.Printf("%v\n", time.Since(startTime).Seconds())
 	}()
 
-	taskID, err := ctx.Cmdline.Svc.CacheSvc().StartCacheMovePackage(1, packageID, nodeID)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	taskID, err := ctx.Cmdline.Svc.CacheSvc().StartCacheMovePackage(userID, packageID, nodeID)
 	if err != nil {
 		return fmt.Errorf("start cache moving package: %w", err)
 	}
@@ -35,7 +40,12 @@ func CacheMovePackage(ctx CommandContext, packageID cdssdk.PackageID, nodeID cds
 }
 
 func CacheRemovePackage(ctx CommandContext, packageID cdssdk.PackageID, nodeID cdssdk.NodeID) error {
-	return ctx.Cmdline.Svc.CacheSvc().CacheRemovePackage(packageID, nodeID)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	return ctx.Cmdline.Svc.CacheSvc().CacheRemovePackage(userID, packageID, nodeID)
 }
 
 func init() {
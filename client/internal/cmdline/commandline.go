@@ -7,6 +7,7 @@ import (
 
 	"github.com/spf13/cobra"
 	"gitlink.org.cn/cloudream/common/pkgs/cmdtrie"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/storage/client/internal/services"
 )
 
@@ -20,7 +21,8 @@ var commands cmdtrie.CommandTrie[CommandContext, error] = cmdtrie.NewCommandTrie
 var rootCmd = cobra.Command{}
 
 type Commandline struct {
-	Svc *services.Service
+	Svc    *services.Service
+	userID *cdssdk.UserID
 }
 
 func NewCommandline(svc *services.Service) (*Commandline, error) {
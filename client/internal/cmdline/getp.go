@@ -10,76 +10,99 @@ import (
 	"time"
 
 	"github.com/inhies/go-bytesize"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgcmd "gitlink.org.cn/cloudream/storage/common/pkgs/cmd"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
 )
 
 func init() {
 	var usePkgID bool
+	var verify bool
 	cmd := &cobra.Command{
 		Use:   "getp",
 		Short: "Download whole package by package id or path",
 		Args:  cobra.ExactArgs(2),
-		Run: func(cmd *cobra.Command, args []string) {
+		// 校验和不一致时需要以失败状态退出，因此返回错误
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
 			cmdCtx := GetCmdCtx(cmd)
 
 			if usePkgID {
 				id, err := strconv.ParseInt(args[0], 10, 64)
 				if err != nil {
-					fmt.Printf("Invalid package id: %s\n", args[0])
-					return
+					return fmt.Errorf("invalid package id: %s", args[0])
 				}
 
-				getpByID(cmdCtx, cdssdk.PackageID(id), args[1])
-			} else {
-				getpByPath(cmdCtx, args[0], args[1])
+				return getpByID(cmdCtx, cdssdk.PackageID(id), args[1], verify)
 			}
+
+			return getpByPath(cmdCtx, args[0], args[1], verify)
 		},
 	}
 	cmd.Flags().BoolVarP(&usePkgID, "id", "i", false, "Download with package id instead of path")
+	cmd.Flags().BoolVar(&verify, "verify", false, "Verify downloaded files with the checksums recorded at upload time")
 
 	rootCmd.AddCommand(cmd)
 }
 
-func getpByPath(cmdCtx *CommandContext, path string, output string) {
-	userID := cdssdk.UserID(1)
+func getpByPath(cmdCtx *CommandContext, path string, output string, verify bool) error {
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	comps := strings.Split(strings.Trim(path, cdssdk.ObjectPathSeparator), cdssdk.ObjectPathSeparator)
 	if len(comps) != 2 {
-		fmt.Printf("Package path must be in format of <bucket>/<package>")
-		return
+		return fmt.Errorf("package path must be in format of <bucket>/<package>")
 	}
 
 	pkg, err := cmdCtx.Cmdline.Svc.PackageSvc().GetByName(userID, comps[0], comps[1])
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
-	getpByID(cmdCtx, pkg.PackageID, output)
+	return getpByID(cmdCtx, pkg.PackageID, output, verify)
 }
 
-func getpByID(cmdCtx *CommandContext, id cdssdk.PackageID, output string) {
-	userID := cdssdk.UserID(1)
+func getpByID(cmdCtx *CommandContext, id cdssdk.PackageID, output string, verify bool) error {
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 	startTime := time.Now()
 
+	var checksums map[cdssdk.ObjectID]model.ObjectChecksum
+	if verify {
+		objs, err := cmdCtx.Cmdline.Svc.ObjectSvc().GetPackageObjects(userID, id)
+		if err != nil {
+			return err
+		}
+
+		checksums, err = cmdCtx.Cmdline.Svc.ObjectSvc().GetObjectChecksums(userID, lo.Map(objs, func(obj model.Object, idx int) cdssdk.ObjectID { return obj.ObjectID }))
+		if err != nil {
+			return err
+		}
+	}
+
 	objIter, err := cmdCtx.Cmdline.Svc.PackageSvc().DownloadPackage(userID, id)
 	if err != nil {
-		fmt.Println(err)
-		return
+		return err
 	}
 
 	err = os.MkdirAll(output, os.ModePerm)
 	if err != nil {
-		fmt.Printf("Create output directory %s failed, err: %v", output, err)
-		return
+		return fmt.Errorf("create output directory %s failed, err: %w", output, err)
 	}
 	defer objIter.Close()
 
 	madeDirs := make(map[string]bool)
 	fileCount := 0
 	totalSize := int64(0)
+	verifiedCount := 0
+	var mismatchedPaths []string
 
 	for {
 		objInfo, err := objIter.MoveNext()
@@ -87,8 +110,7 @@ func getpByID(cmdCtx *CommandContext, id cdssdk.PackageID, output string) {
 			break
 		}
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 
 		err = func() error {
@@ -112,18 +134,44 @@ func getpByID(cmdCtx *CommandContext, id cdssdk.PackageID, output string) {
 			}
 			defer outputFile.Close()
 
-			_, err = io.Copy(outputFile, objInfo.File)
+			checksum, ok := checksums[objInfo.Object.ObjectID]
+			if !ok {
+				_, err = io.Copy(outputFile, objInfo.File)
+				if err != nil {
+					return fmt.Errorf("copy object data to local file failed, err: %w", err)
+				}
+				return nil
+			}
+
+			cr := stgcmd.NewChecksumReader(objInfo.File)
+			_, err = io.Copy(outputFile, cr)
 			if err != nil {
 				return fmt.Errorf("copy object data to local file failed, err: %w", err)
 			}
 
+			verifiedCount++
+			if cr.Verify(checksum.SHA256, checksum.MD5) != nil {
+				mismatchedPaths = append(mismatchedPaths, objInfo.Object.Path)
+			}
 			return nil
 		}()
 		if err != nil {
-			fmt.Println(err)
-			return
+			return err
 		}
 	}
 
 	fmt.Printf("Get %v files (%v) to %s in %v.\n", fileCount, bytesize.ByteSize(totalSize), output, time.Since(startTime))
+
+	if verify {
+		fmt.Printf("%v files verified, %v files have no checksum.\n", verifiedCount, fileCount-verifiedCount)
+		for _, p := range mismatchedPaths {
+			fmt.Printf("Checksum mismatch: %s\n", p)
+		}
+
+		if len(mismatchedPaths) > 0 {
+			return fmt.Errorf("%w: %v files", stgcmd.ErrChecksumMismatch, len(mismatchedPaths))
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,125 @@
+package cmdline
+
+import (
+	"fmt"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+func LifecycleListRules(ctx CommandContext, bucketID cdssdk.BucketID) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	rules, err := ctx.Cmdline.Svc.LifecycleSvc().GetBucketRules(userID, bucketID)
+	if err != nil {
+		return fmt.Errorf("get lifecycle rules of bucket %d failed, err: %w", bucketID, err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"ID", "PathPrefix", "Action", "Days", "Redundancy", "CreateTime"})
+	for _, rule := range rules {
+		red := ""
+		if rule.Redundancy != nil {
+			red = fmt.Sprintf("%+v", rule.Redundancy)
+		}
+		tb.AppendRow(table.Row{rule.RuleID, rule.PathPrefix, rule.Action, rule.Days, red, rule.CreateTime})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func LifecycleAddExpireRule(ctx CommandContext, bucketID cdssdk.BucketID, pathPrefix string, days int) error {
+	return addLifecycleRule(ctx, model.LifecycleRule{
+		BucketID:   bucketID,
+		PathPrefix: pathPrefix,
+		Action:     model.LifecycleActionExpire,
+		Days:       days,
+	})
+}
+
+func LifecycleAddUnpinRule(ctx CommandContext, bucketID cdssdk.BucketID, pathPrefix string, days int) error {
+	return addLifecycleRule(ctx, model.LifecycleRule{
+		BucketID:   bucketID,
+		PathPrefix: pathPrefix,
+		Action:     model.LifecycleActionUnpin,
+		Days:       days,
+	})
+}
+
+// 目标冗余策略可以是rep、ec或lrc，使用对应的默认参数
+func LifecycleAddTransitionRule(ctx CommandContext, bucketID cdssdk.BucketID, pathPrefix string, days int, redundancy string) error {
+	red, err := parseRedundancyName(redundancy)
+	if err != nil {
+		return err
+	}
+
+	return addLifecycleRule(ctx, model.LifecycleRule{
+		BucketID:   bucketID,
+		PathPrefix: pathPrefix,
+		Action:     model.LifecycleActionTransition,
+		Days:       days,
+		Redundancy: red,
+	})
+}
+
+func LifecycleDeleteRule(ctx CommandContext, ruleID model.LifecycleRuleID) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.LifecycleSvc().DeleteRule(userID, ruleID)
+	if err != nil {
+		return fmt.Errorf("delete lifecycle rule %d failed, err: %w", ruleID, err)
+	}
+
+	return nil
+}
+
+// 将rep、ec、lrc转换为使用默认参数的冗余策略
+func parseRedundancyName(name string) (cdssdk.Redundancy, error) {
+	switch name {
+	case "rep":
+		rep := cdssdk.DefaultRepRedundancy
+		return &rep, nil
+	case "ec":
+		ec := cdssdk.DefaultECRedundancy
+		return &ec, nil
+	case "lrc":
+		lrc := cdssdk.DefaultLRCRedundancy
+		return &lrc, nil
+	default:
+		return nil, fmt.Errorf("unknown redundancy %s, must be one of rep, ec and lrc", name)
+	}
+}
+
+func addLifecycleRule(ctx CommandContext, rule model.LifecycleRule) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	ruleID, err := ctx.Cmdline.Svc.LifecycleSvc().AddRule(userID, rule)
+	if err != nil {
+		return fmt.Errorf("add lifecycle rule to bucket %d failed, err: %w", rule.BucketID, err)
+	}
+
+	fmt.Printf("Add lifecycle rule %d success\n", ruleID)
+	return nil
+}
+
+func init() {
+	commands.MustAdd(LifecycleListRules, "bucket", "lifecycle", "ls")
+
+	commands.MustAdd(LifecycleAddExpireRule, "bucket", "lifecycle", "expire")
+
+	commands.MustAdd(LifecycleAddUnpinRule, "bucket", "lifecycle", "unpin")
+
+	commands.MustAdd(LifecycleAddTransitionRule, "bucket", "lifecycle", "transition")
+
+	commands.MustAdd(LifecycleDeleteRule, "bucket", "lifecycle", "delete")
+}
@@ -41,7 +41,11 @@ func init() {
 }
 
 func loadByPath(cmdCtx *CommandContext, pkgPath string, stgName string) {
-	userID := cdssdk.UserID(1)
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	comps := strings.Split(strings.Trim(pkgPath, cdssdk.ObjectPathSeparator), cdssdk.ObjectPathSeparator)
 	if len(comps) != 2 {
@@ -65,7 +69,11 @@ func loadByPath(cmdCtx *CommandContext, pkgPath string, stgName string) {
 }
 
 func loadByID(cmdCtx *CommandContext, pkgID cdssdk.PackageID, stgID cdssdk.StorageID) {
-	userID := cdssdk.UserID(1)
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 	startTime := time.Now()
 
 	nodeID, taskID, err := cmdCtx.Cmdline.Svc.StorageSvc().StartStorageLoadPackage(userID, pkgID, stgID)
@@ -0,0 +1,159 @@
+package cmdline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+func init() {
+	var usePkgID bool
+	var opt coormq.ListObjectsOption
+	var minSize, maxSize string
+	var after, before string
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "lso <bucket>/<package> or <package id>",
+		Short: "List objects in a package",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cmdCtx := GetCmdCtx(cmd)
+
+			if minSize != "" {
+				size, err := bytesize.Parse(minSize)
+				if err != nil {
+					fmt.Printf("Invalid min size: %s\n", minSize)
+					return
+				}
+				s := int64(size)
+				opt.MinSize = &s
+			}
+			if maxSize != "" {
+				size, err := bytesize.Parse(maxSize)
+				if err != nil {
+					fmt.Printf("Invalid max size: %s\n", maxSize)
+					return
+				}
+				s := int64(size)
+				opt.MaxSize = &s
+			}
+			if after != "" {
+				t, err := time.Parse(time.RFC3339, after)
+				if err != nil {
+					fmt.Printf("Invalid time: %s, time must be in RFC3339 format\n", after)
+					return
+				}
+				opt.UpdatedAfter = &t
+			}
+			if before != "" {
+				t, err := time.Parse(time.RFC3339, before)
+				if err != nil {
+					fmt.Printf("Invalid time: %s, time must be in RFC3339 format\n", before)
+					return
+				}
+				opt.UpdatedBefore = &t
+			}
+
+			var pkgID cdssdk.PackageID
+			if usePkgID {
+				id, err := strconv.ParseInt(args[0], 10, 64)
+				if err != nil {
+					fmt.Printf("Invalid package id: %s\n", args[0])
+					return
+				}
+				pkgID = cdssdk.PackageID(id)
+			} else {
+				id, err := getPackageIDByPath(cmdCtx, args[0])
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+				pkgID = id
+			}
+
+			lso(cmdCtx, pkgID, opt, all)
+		},
+	}
+	cmd.Flags().BoolVarP(&usePkgID, "id", "i", false, "List with package id instead of path")
+	cmd.Flags().StringVarP(&opt.Prefix, "prefix", "p", "", "Only list objects whose path starts with this prefix")
+	cmd.Flags().StringVarP(&opt.Delimiter, "delimiter", "d", "", "Group objects by the first delimiter after the prefix, e.g. \"/\"")
+	cmd.Flags().StringVarP(&opt.Glob, "glob", "g", "", "Only list objects whose path matches this pattern")
+	cmd.Flags().StringVar(&minSize, "min-size", "", "Only list objects not smaller than this size, e.g. 1MB")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "Only list objects not larger than this size, e.g. 1GB")
+	cmd.Flags().StringVar(&after, "after", "", "Only list objects updated at or after this time (RFC3339)")
+	cmd.Flags().StringVar(&before, "before", "", "Only list objects updated before this time (RFC3339)")
+	cmd.Flags().StringVar(&opt.ContentType, "content-type", "", "Only list objects whose content type starts with this value")
+	cmd.Flags().StringToStringVarP(&opt.Metadata, "meta", "m", nil, "Only list objects with these metadata, e.g. -m key1=value1,key2=value2")
+	cmd.Flags().IntVarP(&opt.MaxKeys, "max-keys", "n", 0, "Max number of objects and prefixes in one page")
+	cmd.Flags().StringVarP(&opt.ContinuationToken, "token", "t", "", "Continue listing from the token returned by last listing")
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "List all pages")
+
+	rootCmd.AddCommand(cmd)
+}
+
+func getPackageIDByPath(cmdCtx *CommandContext, path string) (cdssdk.PackageID, error) {
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		return 0, err
+	}
+
+	comps := strings.Split(strings.Trim(path, cdssdk.ObjectPathSeparator), cdssdk.ObjectPathSeparator)
+	if len(comps) != 2 {
+		return 0, fmt.Errorf("package path must be in format of <bucket>/<package>")
+	}
+
+	pkg, err := cmdCtx.Cmdline.Svc.PackageSvc().GetByName(userID, comps[0], comps[1])
+	if err != nil {
+		return 0, err
+	}
+
+	return pkg.PackageID, nil
+}
+
+func lso(cmdCtx *CommandContext, pkgID cdssdk.PackageID, opt coormq.ListObjectsOption, all bool) {
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	wr := table.NewWriter()
+	wr.AppendHeader(table.Row{"ID", "Path", "Size", "UpdateTime"})
+
+	for {
+		resp, err := cmdCtx.Cmdline.Svc.ObjectSvc().List(userID, pkgID, opt)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		for _, prefix := range resp.CommonPrefixes {
+			wr.AppendRow(table.Row{"", prefix, "DIR", ""})
+		}
+		for _, obj := range resp.Objects {
+			wr.AppendRow(table.Row{obj.ObjectID, obj.Path, bytesize.ByteSize(obj.Size), obj.UpdateTime.Format(time.RFC3339)})
+		}
+
+		if resp.NextContinuationToken == "" {
+			break
+		}
+
+		if !all {
+			fmt.Println(wr.Render())
+			fmt.Printf("More results available, continue with --token %s\n", resp.NextContinuationToken)
+			return
+		}
+
+		opt.ContinuationToken = resp.NextContinuationToken
+	}
+
+	fmt.Println(wr.Render())
+}
@@ -38,7 +38,11 @@ func init() {
 }
 
 func lspByPath(cmdCtx *CommandContext, path string) {
-	userID := cdssdk.UserID(1)
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	comps := strings.Split(strings.Trim(path, cdssdk.ObjectPathSeparator), cdssdk.ObjectPathSeparator)
 	if len(comps) != 2 {
@@ -59,7 +63,11 @@ func lspByPath(cmdCtx *CommandContext, path string) {
 }
 
 func lspOneByID(cmdCtx *CommandContext, id cdssdk.PackageID) {
-	userID := cdssdk.UserID(1)
+	userID, err := cmdCtx.Cmdline.UserID()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	pkg, err := cmdCtx.Cmdline.Svc.PackageSvc().Get(userID, id)
 	if err != nil {
@@ -0,0 +1,52 @@
+package cmdline
+
+import (
+	"fmt"
+
+	"github.com/inhies/go-bytesize"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// 查看用户可用节点的评分，评分越低，上传下载时越优先选择
+func NodeScores(ctx CommandContext) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	nodes, scores, err := ctx.Cmdline.Svc.NodeSvc().GetUserNodeScores(userID)
+	if err != nil {
+		return fmt.Errorf("get node scores failed, err: %w", err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"ID", "Name", "Total", "Location", "Delay", "Throughput", "InFlight"})
+	for i, s := range scores {
+		delay := "-"
+		if s.Delay != nil {
+			delay = fmt.Sprintf("%v (+%.2f)", *s.Delay, s.DelayPenalty)
+		}
+
+		throughput := "-"
+		if s.Throughput > 0 {
+			throughput = fmt.Sprintf("%v/s (+%.2f)", bytesize.ByteSize(s.Throughput), s.ThroughputPenalty)
+		}
+
+		tb.AppendRow(table.Row{
+			nodes[i].NodeID,
+			nodes[i].Name,
+			fmt.Sprintf("%.2f", s.Total),
+			fmt.Sprintf("%.2f", s.Location),
+			delay,
+			throughput,
+			fmt.Sprintf("%d (+%.2f)", s.InFlight, s.InFlightPenalty),
+		})
+	}
+
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func init() {
+	commands.MustAdd(NodeScores, "node", "scores")
+}
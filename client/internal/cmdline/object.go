@@ -16,10 +16,13 @@ var _ = MustAddCmd(func(ctx CommandContext, packageID cdssdk.PackageID, rootPath
 		fmt.Printf("%v\n", time.Since(startTime).Seconds())
 	}()
 
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	var uploadFilePathes []string
-	err := filepath.WalkDir(rootPath, func(fname string, fi os.DirEntry, err error) error {
+	err = filepath.WalkDir(rootPath, func(fname string, fi os.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
@@ -41,7 +44,7 @@ var _ = MustAddCmd(func(ctx CommandContext, packageID cdssdk.PackageID, rootPath
 	}
 
 	objIter := iterator.NewUploadingObjectIterator(rootPath, uploadFilePathes)
-	taskID, err := ctx.Cmdline.Svc.ObjectSvc().StartUploading(userID, packageID, objIter, nodeAff)
+	taskID, err := ctx.Cmdline.Svc.ObjectSvc().StartUploading(userID, packageID, objIter, nodeAff, nil)
 	if err != nil {
 		return fmt.Errorf("update objects to package %d failed, err: %w", packageID, err)
 	}
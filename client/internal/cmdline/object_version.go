@@ -0,0 +1,96 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+func ObjectSetPackageVersioning(ctx CommandContext, packageID cdssdk.PackageID, enabled bool) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.ObjectVersionSvc().SetPackageVersioning(userID, packageID, enabled)
+	if err != nil {
+		return fmt.Errorf("set package %d versioning failed, err: %w", packageID, err)
+	}
+	return nil
+}
+
+func ObjectListVersions(ctx CommandContext, packageID cdssdk.PackageID, path string) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	versions, err := ctx.Cmdline.Svc.ObjectVersionSvc().ListVersions(userID, packageID, path)
+	if err != nil {
+		return fmt.Errorf("list versions of %s failed, err: %w", path, err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"VersionID", "Size", "FileHash", "UpdateTime", "ArchiveTime"})
+	for _, v := range versions {
+		tb.AppendRow(table.Row{v.VersionID, v.Object.Size, v.Object.FileHash, v.Object.UpdateTime, v.ArchiveTime})
+	}
+
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func ObjectDownloadVersion(ctx CommandContext, versionID model.ObjectVersionID, outputPath string) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	file, err := ctx.Cmdline.Svc.ObjectVersionSvc().Download(userID, versionID, 0, -1)
+	if err != nil {
+		return fmt.Errorf("download version %d failed, err: %w", versionID, err)
+	}
+	defer file.File.Close()
+
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("creating output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	_, err = io.Copy(outputFile, file.File)
+	if err != nil {
+		return fmt.Errorf("copy version data to local file failed, err: %w", err)
+	}
+
+	return nil
+}
+
+func ObjectRestoreVersion(ctx CommandContext, versionID model.ObjectVersionID) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	obj, err := ctx.Cmdline.Svc.ObjectVersionSvc().Restore(userID, versionID)
+	if err != nil {
+		return fmt.Errorf("restore version %d failed, err: %w", versionID, err)
+	}
+
+	fmt.Printf("%v restored to object %v\n", versionID, obj.ObjectID)
+	return nil
+}
+
+func init() {
+	commands.MustAdd(ObjectSetPackageVersioning, "obj", "versioning")
+
+	commands.MustAdd(ObjectListVersions, "obj", "versions")
+
+	commands.MustAdd(ObjectDownloadVersion, "obj", "getv")
+
+	commands.MustAdd(ObjectRestoreVersion, "obj", "restore")
+}
@@ -13,7 +13,10 @@ import (
 )
 
 func PackageListBucketPackages(ctx CommandContext, bucketID cdssdk.BucketID) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	packages, err := ctx.Cmdline.Svc.BucketSvc().GetBucketPackages(userID, bucketID)
 	if err != nil {
@@ -39,9 +42,12 @@ func PackageDownloadPackage(ctx CommandContext, packageID cdssdk.PackageID, outp
 		fmt.Printf("%v\n", time.Since(startTime).Seconds())
 	}()
 
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
-	err := os.MkdirAll(outputDir, os.ModePerm)
+	err = os.MkdirAll(outputDir, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("create output directory %s failed, err: %w", outputDir, err)
 	}
@@ -99,7 +105,10 @@ func PackageDownloadPackage(ctx CommandContext, packageID cdssdk.PackageID, outp
 }
 
 func PackageCreatePackage(ctx CommandContext, bucketID cdssdk.BucketID, name string) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 
 	pkgID, err := ctx.Cmdline.Svc.PackageSvc().Create(userID, bucketID, name)
 	if err != nil {
@@ -111,16 +120,53 @@ func PackageCreatePackage(ctx CommandContext, bucketID cdssdk.BucketID, name str
 }
 
 func PackageDeletePackage(ctx CommandContext, packageID cdssdk.PackageID) error {
-	userID := cdssdk.UserID(1)
-	err := ctx.Cmdline.Svc.PackageSvc().DeletePackage(userID, packageID)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.PackageSvc().DeletePackage(userID, packageID)
 	if err != nil {
 		return fmt.Errorf("delete package %d failed, err: %w", packageID, err)
 	}
 	return nil
 }
 
+func PackageSnapshot(ctx CommandContext, packageID cdssdk.PackageID) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	pkg, err := ctx.Cmdline.Svc.PackageSvc().Snapshot(userID, packageID)
+	if err != nil {
+		return fmt.Errorf("create snapshot of package %d failed, err: %w", packageID, err)
+	}
+
+	fmt.Printf("%v %v\n", pkg.PackageID, pkg.Name)
+	return nil
+}
+
+func PackageClone(ctx CommandContext, packageID cdssdk.PackageID, targetBucketID cdssdk.BucketID, name string) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	pkg, err := ctx.Cmdline.Svc.PackageSvc().Clone(userID, packageID, targetBucketID, name)
+	if err != nil {
+		return fmt.Errorf("clone package %d failed, err: %w", packageID, err)
+	}
+
+	fmt.Printf("%v\n", pkg.PackageID)
+	return nil
+}
+
 func PackageGetCachedNodes(ctx CommandContext, packageID cdssdk.PackageID) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 	resp, err := ctx.Cmdline.Svc.PackageSvc().GetCachedNodes(userID, packageID)
 	fmt.Printf("resp: %v\n", resp)
 	if err != nil {
@@ -130,7 +176,10 @@ func PackageGetCachedNodes(ctx CommandContext, packageID cdssdk.PackageID) error
 }
 
 func PackageGetLoadedNodes(ctx CommandContext, packageID cdssdk.PackageID) error {
-	userID := cdssdk.UserID(1)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
 	nodeIDs, err := ctx.Cmdline.Svc.PackageSvc().GetLoadedNodes(userID, packageID)
 	fmt.Printf("nodeIDs: %v\n", nodeIDs)
 	if err != nil {
@@ -148,6 +197,10 @@ func init() {
 
 	commands.MustAdd(PackageDeletePackage, "pkg", "delete")
 
+	commands.MustAdd(PackageSnapshot, "pkg", "snapshot")
+
+	commands.MustAdd(PackageClone, "pkg", "clone")
+
 	commands.MustAdd(PackageGetCachedNodes, "pkg", "cached")
 
 	commands.MustAdd(PackageGetLoadedNodes, "pkg", "loaded")
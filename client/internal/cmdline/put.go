@@ -12,11 +12,15 @@ import (
 	"gitlink.org.cn/cloudream/common/consts/errorcode"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	mytask "gitlink.org.cn/cloudream/storage/client/internal/task"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
 )
 
 func init() {
 	var nodeID int64
+	var redundancy string
+	var sha256File string
+	var md5File string
 	cmd := &cobra.Command{
 		Use:   "put",
 		Short: "Upload files to CDS",
@@ -34,8 +38,12 @@ func init() {
 			return nil
 		},
 		Run: func(cmd *cobra.Command, args []string) {
-			userID := cdssdk.UserID(1)
 			cmdCtx := GetCmdCtx(cmd)
+			userID, err := cmdCtx.Cmdline.UserID()
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
 
 			local := args[0]
 			remote := args[1]
@@ -96,29 +104,96 @@ func init() {
 				nodeAff = &id
 			}
 
+			var red cdssdk.Redundancy
+			if redundancy != "" {
+				red, err = parseRedundancyName(redundancy)
+				if err != nil {
+					fmt.Println(err)
+					return
+				}
+			}
+
 			objIter := iterator.NewUploadingObjectIterator(local, uploadFilePathes)
-			taskID, err := cmdCtx.Cmdline.Svc.ObjectSvc().StartUploading(userID, pkg.PackageID, objIter, nodeAff)
+			if sha256File != "" || md5File != "" {
+				sha256s, err := readChecksumFile(sha256File)
+				if err != nil {
+					fmt.Printf("reading sha256 checksum file: %v\n", err)
+					return
+				}
+
+				md5s, err := readChecksumFile(md5File)
+				if err != nil {
+					fmt.Printf("reading md5 checksum file: %v\n", err)
+					return
+				}
+
+				objIter.SetChecksums(sha256s, md5s)
+			}
+
+			taskID, err := cmdCtx.Cmdline.Svc.ObjectSvc().StartUploading(userID, pkg.PackageID, objIter, nodeAff, red)
 			if err != nil {
 				fmt.Printf("start uploading objects: %v\n", err)
 				return
 			}
 
+			var uploadRet *mytask.UploadObjectsResult
 			for {
-				complete, _, err := cmdCtx.Cmdline.Svc.ObjectSvc().WaitUploading(taskID, time.Second*5)
+				complete, ret, err := cmdCtx.Cmdline.Svc.ObjectSvc().WaitUploading(taskID, time.Second*5)
 				if err != nil {
 					fmt.Printf("uploading objects: %v\n", err)
+					if ret != nil {
+						fmt.Printf("%v files were added to the package before the failure.\n", len(ret.Objects))
+					}
 					return
 				}
 
 				if complete {
+					uploadRet = ret
 					break
 				}
 			}
 
 			fmt.Printf("Put %v files (%v) to %s in %v.\n", fileCount, bytesize.ByteSize(totalSize), remote, time.Since(startTime))
+			if uploadRet != nil && uploadRet.DedupedCount > 0 {
+				fmt.Printf("%v files (%v) already existed and were not uploaded again.\n", uploadRet.DedupedCount, bytesize.ByteSize(uploadRet.DedupedSize))
+			}
 		},
 	}
 	cmd.Flags().Int64VarP(&nodeID, "node", "n", 0, "node affinity")
+	cmd.Flags().StringVarP(&redundancy, "redundancy", "r", "", "encode files with the redundancy (rep, ec or lrc) when uploading")
+	cmd.Flags().StringVar(&sha256File, "sha256sums", "", "verify files with the checksums in the file, which is in the format of sha256sum output and paths are relative to the local directory")
+	cmd.Flags().StringVar(&md5File, "md5sums", "", "verify files with the checksums in the file, which is in the format of md5sum output and paths are relative to the local directory")
 
 	rootCmd.AddCommand(cmd)
 }
+
+// 读取sha256sum、md5sum等命令输出格式的校验和文件，返回的map以规范化后的文件路径为键。文件名为空时返回nil
+func readChecksumFile(fileName string) (map[string]string, error) {
+	if fileName == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		// 每一行的格式为“<校验和> <文件名>”或者“<校验和> *<文件名>”
+		sum, name, ok := strings.Cut(line, " ")
+		if !ok || len(name) < 2 {
+			return nil, fmt.Errorf("line %d: invalid format", i+1)
+		}
+
+		name = filepath.ToSlash(filepath.Clean(name[1:]))
+		ret[name] = strings.ToLower(sum)
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,124 @@
+package cmdline
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+func QuotaGetUser(ctx CommandContext) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	quota, usage, err := ctx.Cmdline.Svc.QuotaSvc().GetUserQuota(userID)
+	if err != nil {
+		return fmt.Errorf("get quota of user %d failed, err: %w", userID, err)
+	}
+
+	fmt.Printf("Quota of user %d:\n", userID)
+	fmt.Println(renderQuota(quota, usage))
+	return nil
+}
+
+func QuotaGetBucket(ctx CommandContext, bucketID cdssdk.BucketID) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	quota, usage, err := ctx.Cmdline.Svc.QuotaSvc().GetBucketQuota(userID, bucketID)
+	if err != nil {
+		return fmt.Errorf("get quota of bucket %d failed, err: %w", bucketID, err)
+	}
+
+	fmt.Printf("Quota of bucket %d:\n", bucketID)
+	fmt.Println(renderQuota(quota, usage))
+	return nil
+}
+
+// 配额的各项限制用数字表示，使用"unlimited"表示不限制
+func QuotaSetUser(ctx CommandContext, userID cdssdk.UserID, maxLogicalBytes string, maxPhysicalBytes string, maxObjectCount string) error {
+	quota, err := parseQuota(maxLogicalBytes, maxPhysicalBytes, maxObjectCount)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.QuotaSvc().SetUserQuota(userID, quota)
+	if err != nil {
+		return fmt.Errorf("set quota of user %d failed, err: %w", userID, err)
+	}
+
+	return nil
+}
+
+func QuotaSetBucket(ctx CommandContext, bucketID cdssdk.BucketID, maxLogicalBytes string, maxPhysicalBytes string, maxObjectCount string) error {
+	quota, err := parseQuota(maxLogicalBytes, maxPhysicalBytes, maxObjectCount)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.QuotaSvc().SetBucketQuota(bucketID, quota)
+	if err != nil {
+		return fmt.Errorf("set quota of bucket %d failed, err: %w", bucketID, err)
+	}
+
+	return nil
+}
+
+func parseQuota(maxLogicalBytes string, maxPhysicalBytes string, maxObjectCount string) (model.Quota, error) {
+	parse := func(name string, str string) (*int64, error) {
+		if str == "unlimited" {
+			return nil, nil
+		}
+
+		v, err := strconv.ParseInt(str, 10, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid %s: %s", name, str)
+		}
+		return &v, nil
+	}
+
+	var quota model.Quota
+	var err error
+	if quota.MaxLogicalBytes, err = parse("max logical bytes", maxLogicalBytes); err != nil {
+		return quota, err
+	}
+	if quota.MaxPhysicalBytes, err = parse("max physical bytes", maxPhysicalBytes); err != nil {
+		return quota, err
+	}
+	if quota.MaxObjectCount, err = parse("max object count", maxObjectCount); err != nil {
+		return quota, err
+	}
+	return quota, nil
+}
+
+func renderQuota(quota model.Quota, usage model.StorageUsage) string {
+	limit := func(v *int64) any {
+		if v == nil {
+			return "unlimited"
+		}
+		return *v
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"Item", "Usage", "Limit"})
+	tb.AppendRow(table.Row{"LogicalBytes", usage.LogicalBytes, limit(quota.MaxLogicalBytes)})
+	tb.AppendRow(table.Row{"PhysicalBytes", usage.PhysicalBytes, limit(quota.MaxPhysicalBytes)})
+	tb.AppendRow(table.Row{"ObjectCount", usage.ObjectCount, limit(quota.MaxObjectCount)})
+	return tb.Render()
+}
+
+func init() {
+	commands.MustAdd(QuotaGetUser, "quota", "user")
+
+	commands.MustAdd(QuotaGetBucket, "quota", "bucket")
+
+	commands.MustAdd(QuotaSetUser, "quota", "setuser")
+
+	commands.MustAdd(QuotaSetBucket, "quota", "setbucket")
+}
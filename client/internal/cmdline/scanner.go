@@ -3,14 +3,22 @@ package cmdline
 import (
 	"fmt"
 
+	"github.com/jedib0t/go-pretty/v6/table"
 	"gitlink.org.cn/cloudream/common/pkgs/cmdtrie"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/common/utils/reflect2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
 )
 
 var parseScannerEventCmdTrie cmdtrie.StaticCommandTrie[any] = cmdtrie.NewStaticCommandTrie[any]()
 
+// 在事件前加上dryrun只预览冗余策略的修改计划，加上plan则同时保存计划，比如scanner event dryrun CheckPackageRedundancy 1
 func ScannerPostEvent(ctx CommandContext, args []string) error {
+	if len(args) > 0 && (args[0] == "dryrun" || args[0] == "plan") {
+		return scannerPreviewEvent(ctx, args[1:], args[0] == "plan")
+	}
+
 	ret, err := parseScannerEventCmdTrie.Execute(args, cmdtrie.ExecuteOption{ReplaceEmptyArrayWithNil: true})
 	if err != nil {
 		return fmt.Errorf("execute parsing event command failed, err: %w", err)
@@ -24,6 +32,155 @@ func ScannerPostEvent(ctx CommandContext, args []string) error {
 	return nil
 }
 
+func scannerPreviewEvent(ctx CommandContext, args []string, save bool) error {
+	ret, err := parseScannerEventCmdTrie.Execute(args, cmdtrie.ExecuteOption{ReplaceEmptyArrayWithNil: true})
+	if err != nil {
+		return fmt.Errorf("execute parsing event command failed, err: %w", err)
+	}
+
+	evt, ok := ret.(*scevt.CheckPackageRedundancy)
+	if !ok {
+		return fmt.Errorf("only %s can be previewed", reflect2.TypeNameOf[scevt.CheckPackageRedundancy]())
+	}
+
+	plan, err := ctx.Cmdline.Svc.ScannerSvc().PreviewPackageRedundancy(evt.PackageID, save)
+	if err != nil {
+		return fmt.Errorf("preview package redundancy failed, err: %w", err)
+	}
+
+	printRedundancyPlan(plan)
+	return nil
+}
+
+func ScannerListRedundancyPlans(ctx CommandContext) error {
+	plans, err := ctx.Cmdline.Svc.ScannerSvc().ListRedundancyPlans(0, "", 0, 0)
+	if err != nil {
+		return fmt.Errorf("list redundancy plans failed, err: %w", err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"PlanID", "PackageID", "State", "Objects", "TotalSize", "Transfer", "OldStored", "NewStored", "CreateTime"})
+	for _, p := range plans {
+		tb.AppendRow(table.Row{p.PlanID, p.PackageID, p.State, p.ObjectCount, p.TotalSize, p.TransferBytes, p.OldStoredBytes, p.NewStoredBytes, p.CreateTime})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func ScannerShowRedundancyPlan(ctx CommandContext, planID model.RedundancyPlanID) error {
+	plan, err := ctx.Cmdline.Svc.ScannerSvc().GetRedundancyPlan(planID)
+	if err != nil {
+		return fmt.Errorf("get redundancy plan failed, err: %w", err)
+	}
+
+	printRedundancyPlan(plan)
+	return nil
+}
+
+func ScannerApproveRedundancyPlan(ctx CommandContext, planID model.RedundancyPlanID) error {
+	jobID, err := ctx.Cmdline.Svc.ScannerSvc().ApproveRedundancyPlan(planID)
+	if err != nil {
+		return fmt.Errorf("approve redundancy plan failed, err: %w", err)
+	}
+
+	fmt.Printf("plan will be executed by job %v\n", jobID)
+	return nil
+}
+
+func printRedundancyPlan(plan model.RedundancyPlan) {
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"ObjectID", "Path", "Size", "Redundancy", "SrcNodes", "TarNodes", "Transfer", "OldStored", "NewStored"})
+	for _, o := range plan.Objects {
+		tb.AppendRow(table.Row{
+			o.ObjectID, o.Path, o.Size,
+			fmt.Sprintf("%s -> %s", redundancyString(o.SrcRedundancy), redundancyString(o.TarRedundancy)),
+			o.SrcNodeIDs, o.TarNodeIDs, o.TransferBytes, o.OldStoredBytes, o.NewStoredBytes,
+		})
+	}
+	fmt.Println(tb.Render())
+
+	if plan.PlanID != 0 {
+		fmt.Printf("PlanID: %v, State: %s\n", plan.PlanID, plan.State)
+	}
+	fmt.Printf("%d objects, %d bytes, %d bytes to transfer, stored bytes %d -> %d",
+		plan.ObjectCount, plan.TotalSize, plan.TransferBytes, plan.OldStoredBytes, plan.NewStoredBytes)
+	if plan.TotalSize > 0 {
+		fmt.Printf(", overhead %.2fx -> %.2fx", float64(plan.OldStoredBytes)/float64(plan.TotalSize), float64(plan.NewStoredBytes)/float64(plan.TotalSize))
+	}
+	fmt.Println()
+}
+
+func redundancyString(red cdssdk.Redundancy) string {
+	switch red := red.(type) {
+	case *cdssdk.NoneRedundancy:
+		return "none"
+	case *cdssdk.RepRedundancy:
+		return fmt.Sprintf("rep(%d)", red.RepCount)
+	case *cdssdk.ECRedundancy:
+		return fmt.Sprintf("ec(%d,%d)", red.K, red.N)
+	case *cdssdk.LRCRedundancy:
+		return fmt.Sprintf("lrc(%d,%d)", red.K, red.N)
+	}
+	return fmt.Sprintf("%T", red)
+}
+
+func ScannerNodeRepairs(ctx CommandContext) error {
+	repairs, err := ctx.Cmdline.Svc.ScannerSvc().GetNodeRepairs()
+	if err != nil {
+		return fmt.Errorf("get node repairs failed, err: %w", err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"NodeID", "State", "UnavailableTime", "Total", "Repaired", "Failed", "Retries", "UpdateTime"})
+	for _, r := range repairs {
+		tb.AppendRow(table.Row{r.NodeID, r.State, r.UnavailableTime, r.TotalObjects, r.RepairedObjects, r.FailedObjects, r.RetryCount, r.UpdateTime})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func ScannerListJobs(ctx CommandContext) error {
+	return listScannerJobs(ctx, "")
+}
+
+// 只列出指定状态的任务，比如Failed
+func ScannerListJobsByState(ctx CommandContext, state string) error {
+	return listScannerJobs(ctx, state)
+}
+
+func listScannerJobs(ctx CommandContext, state string) error {
+	jobs, err := ctx.Cmdline.Svc.ScannerSvc().ListJobs(state, "", 0, 0)
+	if err != nil {
+		return fmt.Errorf("list scanner jobs failed, err: %w", err)
+	}
+
+	tb := table.NewWriter()
+	tb.AppendHeader(table.Row{"JobID", "Type", "State", "Attempts", "LastError", "CreateTime", "UpdateTime"})
+	for _, j := range jobs {
+		tb.AppendRow(table.Row{j.JobID, j.Type, j.State, j.Attempts, j.LastError, j.CreateTime, j.UpdateTime})
+	}
+	fmt.Println(tb.Render())
+	return nil
+}
+
+func ScannerRetryJob(ctx CommandContext, jobID model.ScannerJobID) error {
+	err := ctx.Cmdline.Svc.ScannerSvc().RetryJob(jobID)
+	if err != nil {
+		return fmt.Errorf("retry scanner job failed, err: %w", err)
+	}
+
+	return nil
+}
+
+func ScannerCancelJob(ctx CommandContext, jobID model.ScannerJobID) error {
+	err := ctx.Cmdline.Svc.ScannerSvc().CancelJob(jobID)
+	if err != nil {
+		return fmt.Errorf("cancel scanner job failed, err: %w", err)
+	}
+
+	return nil
+}
+
 func init() {
 	parseScannerEventCmdTrie.MustAdd(scevt.NewAgentCacheGC, reflect2.TypeNameOf[scevt.AgentCacheGC]())
 
@@ -35,11 +192,35 @@ func init() {
 
 	parseScannerEventCmdTrie.MustAdd(scevt.NewAgentCheckStorage, reflect2.TypeNameOf[scevt.AgentCheckStorage]())
 
+	parseScannerEventCmdTrie.MustAdd(scevt.NewCheckBucketLifecycle, reflect2.TypeNameOf[scevt.CheckBucketLifecycle]())
+
 	parseScannerEventCmdTrie.MustAdd(scevt.NewCheckPackage, reflect2.TypeNameOf[scevt.CheckPackage]())
 
 	parseScannerEventCmdTrie.MustAdd(scevt.NewCheckPackageRedundancy, reflect2.TypeNameOf[scevt.CheckPackageRedundancy]())
 
 	parseScannerEventCmdTrie.MustAdd(scevt.NewCleanPinned, reflect2.TypeNameOf[scevt.CleanPinned]())
 
+	parseScannerEventCmdTrie.MustAdd(scevt.NewCleanUploadSession, reflect2.TypeNameOf[scevt.CleanUploadSession]())
+
+	parseScannerEventCmdTrie.MustAdd(scevt.NewRepairNode, reflect2.TypeNameOf[scevt.RepairNode]())
+
+	parseScannerEventCmdTrie.MustAdd(scevt.NewScrubPackage, reflect2.TypeNameOf[scevt.ScrubPackage]())
+
 	commands.MustAdd(ScannerPostEvent, "scanner", "event")
+
+	commands.MustAdd(ScannerNodeRepairs, "scanner", "repair")
+
+	commands.MustAdd(ScannerListJobs, "scanner", "jobs", "ls")
+
+	commands.MustAdd(ScannerListJobsByState, "scanner", "jobs", "state")
+
+	commands.MustAdd(ScannerRetryJob, "scanner", "jobs", "retry")
+
+	commands.MustAdd(ScannerCancelJob, "scanner", "jobs", "cancel")
+
+	commands.MustAdd(ScannerListRedundancyPlans, "scanner", "plans", "ls")
+
+	commands.MustAdd(ScannerShowRedundancyPlan, "scanner", "plans", "show")
+
+	commands.MustAdd(ScannerApproveRedundancyPlan, "scanner", "plans", "approve")
 }
@@ -13,7 +13,12 @@ func StorageLoadPackage(ctx CommandContext, packageID cdssdk.PackageID, storageI
 		fmt.Printf("%v\n", time.Since(startTime).Seconds())
 	}()
 
-	nodeID, taskID, err := ctx.Cmdline.Svc.StorageSvc().StartStorageLoadPackage(1, packageID, storageID)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	nodeID, taskID, err := ctx.Cmdline.Svc.StorageSvc().StartStorageLoadPackage(userID, packageID, storageID)
 	if err != nil {
 		return fmt.Errorf("start loading package to storage: %w", err)
 	}
@@ -41,7 +46,12 @@ func StorageCreatePackage(ctx CommandContext, bucketID cdssdk.BucketID, name str
 		fmt.Printf("%v\n", time.Since(startTime).Seconds())
 	}()
 
-	nodeID, taskID, err := ctx.Cmdline.Svc.StorageSvc().StartStorageCreatePackage(1, bucketID, name, storageID, path, nil)
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	nodeID, taskID, err := ctx.Cmdline.Svc.StorageSvc().StartStorageCreatePackage(userID, bucketID, name, storageID, path, nil)
 	if err != nil {
 		return fmt.Errorf("start storage uploading package: %w", err)
 	}
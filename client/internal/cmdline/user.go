@@ -0,0 +1,150 @@
+package cmdline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+)
+
+// 登录成功后，协调端签发的登录凭证会缓存在用户主目录下的这个文件中，不会保存密码
+const credentialFileName = ".cloudream_credential.json"
+
+type cachedCredential struct {
+	UserID     cdssdk.UserID `json:"userID"`
+	Token      string        `json:"token"`
+	ExpireTime time.Time     `json:"expireTime"`
+}
+
+func UserLogin(ctx CommandContext, userID cdssdk.UserID, password string) error {
+	token, expireTime, err := ctx.Cmdline.Svc.UserSvc().IssueToken(userID, password)
+	if err != nil {
+		return err
+	}
+
+	path, err := credentialFilePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cachedCredential{
+		UserID:     userID,
+		Token:      token,
+		ExpireTime: expireTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("writing credential file: %w", err)
+	}
+
+	fmt.Printf("Login as user %d success, expire at %v\n", userID, expireTime.Format(time.DateTime))
+	return nil
+}
+
+func UserLogout(ctx CommandContext) error {
+	path, err := credentialFilePath()
+	if err != nil {
+		return err
+	}
+
+	// 凭证失效失败时仍然删除本地的凭证，凭证会在过期后自动失效
+	cred, err := readCredential(path)
+	if err == nil {
+		err = ctx.Cmdline.Svc.UserSvc().RevokeToken(cred.Token)
+		if err != nil {
+			fmt.Printf("revoking token: %s\n", err.Error())
+		}
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing credential file: %w", err)
+	}
+
+	fmt.Printf("Logout success\n")
+	return nil
+}
+
+// 修改当前登录用户的密码，修改后需要重新登录
+func UserChangePassword(ctx CommandContext, oldPassword string, newPassword string) error {
+	userID, err := ctx.Cmdline.UserID()
+	if err != nil {
+		return err
+	}
+
+	err = ctx.Cmdline.Svc.UserSvc().ChangePassword(userID, oldPassword, newPassword)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Password changed, please login again\n")
+	return nil
+}
+
+// 获取当前登录的用户ID。会使用缓存的凭证向协调端验证一次，验证通过后的结果在本次执行中会被复用
+func (c *Commandline) UserID() (cdssdk.UserID, error) {
+	if c.userID != nil {
+		return *c.userID, nil
+	}
+
+	path, err := credentialFilePath()
+	if err != nil {
+		return 0, err
+	}
+
+	cred, err := readCredential(path)
+	if err != nil {
+		return 0, err
+	}
+
+	userID, err := c.Svc.UserSvc().VerifyToken(cred.Token)
+	if err != nil {
+		return 0, fmt.Errorf("cached credential is invalid, please login again: %w", err)
+	}
+
+	c.userID = &userID
+	return userID, nil
+}
+
+func readCredential(path string) (cachedCredential, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cachedCredential{}, fmt.Errorf("not logged in, use \"login <userID> <password>\" first")
+	}
+	if err != nil {
+		return cachedCredential{}, fmt.Errorf("reading credential file: %w", err)
+	}
+
+	var cred cachedCredential
+	err = json.Unmarshal(data, &cred)
+	if err != nil {
+		return cachedCredential{}, fmt.Errorf("parsing credential file: %w", err)
+	}
+
+	return cred, nil
+}
+
+func credentialFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("getting user home directory: %w", err)
+	}
+
+	return filepath.Join(home, credentialFileName), nil
+}
+
+func init() {
+	commands.MustAdd(UserLogin, "login")
+
+	commands.MustAdd(UserLogout, "logout")
+
+	commands.MustAdd(UserChangePassword, "passwd")
+}
@@ -4,6 +4,7 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/distlock"
 	"gitlink.org.cn/cloudream/common/pkgs/ipfs"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/common/utils/config"
 	stgmodels "gitlink.org.cn/cloudream/storage/common/models"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
@@ -21,6 +22,24 @@ type Config struct {
 	DistLock     distlock.Config            `json:"distlock"`
 	Connectivity connectivity.Config        `json:"connectivity"`
 	Downloader   downloader.Config          `json:"downloader"`
+	S3           S3Config                   `json:"s3"`
+	Auth         AuthConfig                 `json:"auth"`
+}
+
+type AuthConfig struct {
+	Secret             string `json:"secret"`             // 签发访问令牌使用的密钥，为空时每次启动随机生成
+	TokenExpireSeconds int    `json:"tokenExpireSeconds"` // 访问令牌的有效期
+}
+
+type S3Config struct {
+	Region      string         `json:"region"`      // SigV4签名中使用的区域名，为空时不检查
+	Credentials []S3Credential `json:"credentials"` // S3网关可用的访问密钥，没有配置时不启用S3网关
+}
+
+type S3Credential struct {
+	AccessKey string        `json:"accessKey"`
+	SecretKey string        `json:"secretKey"`
+	UserID    cdssdk.UserID `json:"userID"` // 使用此密钥访问时所代表的用户
 }
 
 var cfg Config
@@ -0,0 +1,194 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/client/internal/config"
+)
+
+const (
+	AuthLoginPath = "/auth/login"
+
+	// 通过了验证的请求，在gin.Context中保存用户ID以及是否为管理员使用的键
+	authUserIDKey = "authUserID"
+	authAdminKey  = "authAdmin"
+
+	defaultTokenExpire = 24 * time.Hour
+)
+
+// 访问令牌的格式为：base64url(载荷JSON).base64url(HMAC-SHA256(载荷JSON))
+type authTokenPayload struct {
+	UserID   cdssdk.UserID `json:"userID"`
+	Admin    bool          `json:"admin,omitempty"`
+	ExpireAt int64         `json:"expireAt"`
+}
+
+type AuthService struct {
+	*Server
+}
+
+func (s *Server) Auth() *AuthService {
+	return &AuthService{
+		Server: s,
+	}
+}
+
+type AuthLoginReq struct {
+	UserID   *cdssdk.UserID `json:"userID" binding:"required"`
+	Password string         `json:"password"`
+}
+type AuthLoginResp struct {
+	Token    string    `json:"token"`
+	ExpireAt time.Time `json:"expireAt"`
+}
+
+func (s *AuthService) Login(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Auth.Login")
+
+	var req AuthLoginReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	admin, err := s.svc.UserSvc().Auth(*req.UserID, req.Password)
+	if err != nil {
+		log.WithField("UserID", *req.UserID).Warnf("authenticating user: %s", err.Error())
+		ctx.JSON(http.StatusUnauthorized, Failed(errorcode.OperationFailed, "invalid user id or password"))
+		return
+	}
+
+	expireAt := time.Now().Add(s.tokenExpire)
+	token, err := s.issueToken(authTokenPayload{
+		UserID:   *req.UserID,
+		Admin:    admin,
+		ExpireAt: expireAt.Unix(),
+	})
+	if err != nil {
+		log.Warnf("issuing token: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "issue token failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(AuthLoginResp{
+		Token:    token,
+		ExpireAt: expireAt,
+	}))
+}
+
+// 检查请求的访问令牌，并记录令牌中的用户ID。后续的处理函数只使用getAuthUserID获取用户ID，
+// 请求参数中调用者自己填写的userID会被忽略
+func (s *Server) authMiddleware(ctx *gin.Context) {
+	path := ctx.Request.URL.Path
+	// S3网关有自己的签名验证方式
+	if path == AuthLoginPath || path == S3PathPrefix || strings.HasPrefix(path, S3PathPrefix+"/") {
+		ctx.Next()
+		return
+	}
+
+	token, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, Failed(errorcode.OperationFailed, "missing access token"))
+		return
+	}
+
+	payload, err := s.verifyToken(token)
+	if err != nil {
+		logger.WithField("HTTP", "Auth").Debugf("verifying token: %s", err.Error())
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, Failed(errorcode.OperationFailed, "invalid access token"))
+		return
+	}
+
+	ctx.Set(authUserIDKey, payload.UserID)
+	ctx.Set(authAdminKey, payload.Admin)
+	ctx.Next()
+}
+
+// 获取通过了令牌验证的用户ID，只能在经过了authMiddleware的处理函数中使用
+func getAuthUserID(ctx *gin.Context) cdssdk.UserID {
+	return ctx.MustGet(authUserIDKey).(cdssdk.UserID)
+}
+
+// 通过了令牌验证的用户是否为管理员
+func isAuthAdmin(ctx *gin.Context) bool {
+	return ctx.GetBool(authAdminKey)
+}
+
+// 调用者不是管理员时返回403，并且返回false
+func requireAdmin(ctx *gin.Context) bool {
+	if isAuthAdmin(ctx) {
+		return true
+	}
+
+	ctx.JSON(http.StatusForbidden, Failed(errorcode.OperationFailed, "only admin can do this"))
+	return false
+}
+
+func (s *Server) issueToken(p authTokenPayload) (string, error) {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+
+	sig := hmacSHA256(s.authSecret, payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func (s *Server) verifyToken(token string) (authTokenPayload, error) {
+	payloadStr, sigStr, ok := strings.Cut(token, ".")
+	if !ok {
+		return authTokenPayload{}, fmt.Errorf("malformed token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadStr)
+	if err != nil {
+		return authTokenPayload{}, fmt.Errorf("decoding payload: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(sigStr)
+	if err != nil {
+		return authTokenPayload{}, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	if !hmac.Equal(sig, hmacSHA256(s.authSecret, payload)) {
+		return authTokenPayload{}, fmt.Errorf("signature mismatch")
+	}
+
+	var p authTokenPayload
+	err = json.Unmarshal(payload, &p)
+	if err != nil {
+		return authTokenPayload{}, fmt.Errorf("parsing payload: %w", err)
+	}
+
+	if time.Now().Unix() > p.ExpireAt {
+		return authTokenPayload{}, fmt.Errorf("token expired")
+	}
+
+	return p, nil
+}
+
+func loadAuthSecret(cfg *config.AuthConfig) ([]byte, error) {
+	if cfg.Secret != "" {
+		return []byte(cfg.Secret), nil
+	}
+
+	logger.Warnf("auth secret is not set, a random one will be used, and tokens will be invalid after restart")
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -29,7 +29,7 @@ func (s *BucketService) GetByName(ctx *gin.Context) {
 		return
 	}
 
-	bucket, err := s.svc.BucketSvc().GetBucketByName(req.UserID, req.Name)
+	bucket, err := s.svc.BucketSvc().GetBucketByName(getAuthUserID(ctx), req.Name)
 	if err != nil {
 		log.Warnf("getting bucket by name: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get bucket by name failed"))
@@ -51,7 +51,7 @@ func (s *BucketService) Create(ctx *gin.Context) {
 		return
 	}
 
-	bucket, err := s.svc.BucketSvc().CreateBucket(req.UserID, req.Name)
+	bucket, err := s.svc.BucketSvc().CreateBucket(getAuthUserID(ctx), req.Name)
 	if err != nil {
 		log.Warnf("creating bucket: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "create bucket failed"))
@@ -73,7 +73,7 @@ func (s *BucketService) Delete(ctx *gin.Context) {
 		return
 	}
 
-	if err := s.svc.BucketSvc().DeleteBucket(req.UserID, req.BucketID); err != nil {
+	if err := s.svc.BucketSvc().DeleteBucket(getAuthUserID(ctx), req.BucketID); err != nil {
 		log.Warnf("deleting bucket: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "delete bucket failed"))
 		return
@@ -92,7 +92,7 @@ func (s *BucketService) ListUserBuckets(ctx *gin.Context) {
 		return
 	}
 
-	buckets, err := s.svc.BucketSvc().GetUserBuckets(req.UserID)
+	buckets, err := s.svc.BucketSvc().GetUserBuckets(getAuthUserID(ctx))
 	if err != nil {
 		log.Warnf("getting user buckets: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get user buckets failed"))
@@ -21,7 +21,6 @@ func (s *Server) Cache() *CacheService {
 }
 
 type CacheMovePackageReq struct {
-	UserID    *cdssdk.UserID    `json:"userID" binding:"required"`
 	PackageID *cdssdk.PackageID `json:"packageID" binding:"required"`
 	NodeID    *cdssdk.NodeID    `json:"nodeID" binding:"required"`
 }
@@ -37,7 +36,7 @@ func (s *CacheService) MovePackage(ctx *gin.Context) {
 		return
 	}
 
-	taskID, err := s.svc.CacheSvc().StartCacheMovePackage(*req.UserID, *req.PackageID, *req.NodeID)
+	taskID, err := s.svc.CacheSvc().StartCacheMovePackage(getAuthUserID(ctx), *req.PackageID, *req.NodeID)
 	if err != nil {
 		log.Warnf("start cache move package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "cache move package failed"))
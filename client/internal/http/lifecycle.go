@@ -0,0 +1,128 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+const (
+	BucketAddLifecycleRulePath    = "/bucket/addLifecycleRule"
+	BucketListLifecycleRulesPath  = "/bucket/listLifecycleRules"
+	BucketDeleteLifecycleRulePath = "/bucket/deleteLifecycleRule"
+)
+
+type LifecycleService struct {
+	*Server
+}
+
+func (s *Server) Lifecycle() *LifecycleService {
+	return &LifecycleService{
+		Server: s,
+	}
+}
+
+type BucketAddLifecycleRuleReq struct {
+	BucketID   *cdssdk.BucketID `json:"bucketID" binding:"required"`
+	PathPrefix string           `json:"pathPrefix"`
+	Action     string           `json:"action" binding:"required"`
+	Days       int              `json:"days"`
+	Redundancy json.RawMessage  `json:"redundancy"` // 只有Transition规则需要此字段
+}
+type BucketAddLifecycleRuleResp struct {
+	RuleID model.LifecycleRuleID `json:"ruleID"`
+}
+
+func (s *LifecycleService) AddRule(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Lifecycle.AddRule")
+
+	var req BucketAddLifecycleRuleReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	rule := model.LifecycleRule{
+		BucketID:   *req.BucketID,
+		PathPrefix: req.PathPrefix,
+		Action:     req.Action,
+		Days:       req.Days,
+	}
+	// 冗余策略是接口类型，需要根据其中的类型字段来反序列化
+	if len(req.Redundancy) > 0 && string(req.Redundancy) != "null" {
+		red, err := serder.JSONToObjectEx[cdssdk.Redundancy](req.Redundancy)
+		if err != nil {
+			log.Warnf("parsing redundancy: %s", err.Error())
+			ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "invalid redundancy"))
+			return
+		}
+		rule.Redundancy = red
+	}
+
+	ruleID, err := s.svc.LifecycleSvc().AddRule(getAuthUserID(ctx), rule)
+	if err != nil {
+		log.Warnf("adding lifecycle rule: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "add lifecycle rule failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(BucketAddLifecycleRuleResp{RuleID: ruleID}))
+}
+
+type BucketListLifecycleRulesReq struct {
+	BucketID *cdssdk.BucketID `form:"bucketID" binding:"required"`
+}
+type BucketListLifecycleRulesResp struct {
+	Rules []model.LifecycleRule `json:"rules"`
+}
+
+func (s *LifecycleService) ListRules(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Lifecycle.ListRules")
+
+	var req BucketListLifecycleRulesReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	rules, err := s.svc.LifecycleSvc().GetBucketRules(getAuthUserID(ctx), *req.BucketID)
+	if err != nil {
+		log.Warnf("getting bucket lifecycle rules: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list lifecycle rules failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(BucketListLifecycleRulesResp{Rules: rules}))
+}
+
+type BucketDeleteLifecycleRuleReq struct {
+	RuleID *model.LifecycleRuleID `json:"ruleID" binding:"required"`
+}
+
+func (s *LifecycleService) DeleteRule(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Lifecycle.DeleteRule")
+
+	var req BucketDeleteLifecycleRuleReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.LifecycleSvc().DeleteRule(getAuthUserID(ctx), *req.RuleID)
+	if err != nil {
+		log.Warnf("deleting lifecycle rule: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "delete lifecycle rule failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(nil))
+}
@@ -7,6 +7,11 @@ import (
 	"gitlink.org.cn/cloudream/common/consts/errorcode"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
+)
+
+const (
+	NodeGetScoresPath = "/node/scores"
 )
 
 type NodeService struct {
@@ -43,3 +48,31 @@ func (s *ObjectService) GetNodes(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, OK(GetNodesResp{Nodes: nodes}))
 }
+
+type NodeGetScoresReq struct {
+}
+type NodeGetScoresResp struct {
+	Nodes  []cdssdk.Node            `json:"nodes"`
+	Scores []connectivity.NodeScore `json:"scores"`
+}
+
+// 查看用户可用节点的评分，用于调试节点选择
+func (s *NodeService) GetScores(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Node.GetScores")
+
+	var req NodeGetScoresReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	nodes, scores, err := s.svc.NodeSvc().GetUserNodeScores(getAuthUserID(ctx))
+	if err != nil {
+		log.Warnf("getting node scores: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get node scores failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(NodeGetScoresResp{Nodes: nodes, Scores: scores}))
+}
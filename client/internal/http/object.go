@@ -7,6 +7,8 @@ import (
 	"net/http"
 	ul "net/url"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -14,7 +16,21 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	myhttp "gitlink.org.cn/cloudream/common/utils/http"
+	"gitlink.org.cn/cloudream/common/utils/math2"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/downloader"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+const (
+	ObjectListPath = "/object/list"
+)
+
+const (
+	ObjectContentTypeHeader = "X-Object-Content-Type"
+	ObjectMetaHeaderPrefix  = "X-Object-Meta-"
 )
 
 type ObjectService struct {
@@ -30,6 +46,22 @@ func (s *Server) Object() *ObjectService {
 type ObjectUploadReq struct {
 	Info  cdssdk.ObjectUploadInfo `form:"info" binding:"required"`
 	Files []*multipart.FileHeader `form:"files"`
+	// 可选，JSON格式的冗余策略，上传时就按此策略编码，不填写则使用NoneRedundancy
+	Redundancy string `form:"redundancy"`
+	// 可选，JSON格式的文件路径到校验和的映射，上传时会检查文件内容是否与校验和一致
+	Checksums string `form:"checksums"`
+	// 可选，JSON格式的文件路径到对象元数据的映射
+	Metadatas string `form:"metadatas"`
+}
+
+type UploadingMetadata struct {
+	ContentType  string            `json:"contentType"`
+	UserMetadata map[string]string `json:"userMetadata"`
+}
+
+type UploadingChecksum struct {
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5"`
 }
 
 func (s *ObjectService) Upload(ctx *gin.Context) {
@@ -42,11 +74,45 @@ func (s *ObjectService) Upload(ctx *gin.Context) {
 		return
 	}
 
+	// 表单中的用户ID由调用者填写，必须使用访问令牌中的用户ID
+	req.Info.UserID = getAuthUserID(ctx)
+
+	var red cdssdk.Redundancy
+	if req.Redundancy != "" {
+		r, err := serder.JSONToObjectEx[cdssdk.Redundancy]([]byte(req.Redundancy))
+		if err != nil {
+			log.Warnf("parsing redundancy: %s", err.Error())
+			ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "invalid redundancy"))
+			return
+		}
+		red = r
+	}
+
+	var checksums map[string]UploadingChecksum
+	if req.Checksums != "" {
+		err := serder.JSONToObject([]byte(req.Checksums), &checksums)
+		if err != nil {
+			log.Warnf("parsing checksums: %s", err.Error())
+			ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "invalid checksums"))
+			return
+		}
+	}
+
+	var metadatas map[string]UploadingMetadata
+	if req.Metadatas != "" {
+		err := serder.JSONToObject([]byte(req.Metadatas), &metadatas)
+		if err != nil {
+			log.Warnf("parsing metadatas: %s", err.Error())
+			ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "invalid metadatas"))
+			return
+		}
+	}
+
 	var err error
 
-	objIter := mapMultiPartFileToUploadingObject(req.Files)
+	objIter := mapMultiPartFileToUploadingObject(req.Files, checksums, metadatas)
 
-	taskID, err := s.svc.ObjectSvc().StartUploading(req.Info.UserID, req.Info.PackageID, objIter, req.Info.NodeAffinity)
+	taskID, err := s.svc.ObjectSvc().StartUploading(req.Info.UserID, req.Info.PackageID, objIter, req.Info.NodeAffinity, red)
 
 	if err != nil {
 		log.Warnf("start uploading object task: %s", err.Error())
@@ -88,23 +154,33 @@ func (s *ObjectService) Upload(ctx *gin.Context) {
 	}
 }
 
+type ObjectDownloadReq struct {
+	cdssdk.ObjectDownload
+	Raw bool `form:"raw"` // 为true时直接返回文件内容，不使用multipart包装，此时支持Range请求头
+}
+
 func (s *ObjectService) Download(ctx *gin.Context) {
 	log := logger.WithField("HTTP", "Object.Download")
 
-	var req cdssdk.ObjectDownload
+	var req ObjectDownloadReq
 	if err := ctx.ShouldBindQuery(&req); err != nil {
 		log.Warnf("binding body: %s", err.Error())
 		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
 		return
 	}
 
+	if req.Raw {
+		s.downloadRaw(ctx, req.ObjectDownload)
+		return
+	}
+
 	off := req.Offset
 	len := int64(-1)
 	if req.Length != nil {
 		len = *req.Length
 	}
 
-	file, err := s.svc.ObjectSvc().Download(req.UserID, downloader.DownloadReqeust{
+	file, err := s.svc.ObjectSvc().Download(getAuthUserID(ctx), downloader.DownloadReqeust{
 		ObjectID: req.ObjectID,
 		Offset:   off,
 		Length:   len,
@@ -119,6 +195,10 @@ func (s *ObjectService) Download(ctx *gin.Context) {
 	defer mw.Close()
 
 	ctx.Writer.Header().Set("Content-Type", fmt.Sprintf("%s;boundary=%s", myhttp.ContentTypeMultiPart, mw.Boundary()))
+	s.setChecksumHeaders(ctx, *file.Object)
+	if meta := s.getObjectMetadata(getAuthUserID(ctx), file.Object.ObjectID); meta != nil {
+		setMetadataHeaders(ctx.Writer.Header(), *meta)
+	}
 	ctx.Writer.WriteHeader(http.StatusOK)
 
 	if req.PartSize == 0 {
@@ -131,6 +211,127 @@ func (s *ObjectService) Download(ctx *gin.Context) {
 	}
 }
 
+func (s *ObjectService) downloadRaw(ctx *gin.Context, req cdssdk.ObjectDownload) {
+	log := logger.WithField("HTTP", "Object.Download")
+
+	dlReq := downloader.DownloadReqeust{
+		ObjectID: req.ObjectID,
+		Offset:   req.Offset,
+		Length:   -1,
+	}
+	if req.Length != nil {
+		dlReq.Length = *req.Length
+	}
+
+	// 有Range头时需要先知道对象的大小才能确定范围，此时忽略Offset和Length参数
+	var rng *httpRange
+	var obj *cdssdk.Object
+	var meta *stgmod.ObjectMetadata
+	if hdr := ctx.GetHeader("Range"); hdr != "" {
+		detail, err := s.svc.ObjectSvc().GetObjectDetail(getAuthUserID(ctx), req.ObjectID)
+		if err != nil {
+			log.Warnf("getting object detail: %s", err.Error())
+			ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get object detail failed"))
+			return
+		}
+		if detail == nil {
+			ctx.JSON(http.StatusOK, Failed(errorcode.DataNotFound, "object not found"))
+			return
+		}
+		obj = &detail.Object
+		meta = detail.Metadata
+
+		if checkIfRange(ctx.Request, objectETag(*obj), obj.UpdateTime) {
+			rng, err = parseHTTPRange(hdr, obj.Size)
+			if err == errRangeNotSatisfiable {
+				ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", obj.Size))
+				ctx.Status(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+		}
+
+		dlReq.Offset = 0
+		dlReq.Length = -1
+		if rng != nil {
+			dlReq.Offset = rng.Offset
+			dlReq.Length = rng.Length
+		}
+	}
+
+	file, err := s.svc.ObjectSvc().Download(getAuthUserID(ctx), dlReq)
+	if err != nil {
+		log.Warnf("downloading object: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "download object failed"))
+		return
+	}
+	defer file.File.Close()
+
+	if obj == nil {
+		obj = file.Object
+		meta = s.getObjectMetadata(getAuthUserID(ctx), obj.ObjectID)
+	}
+
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+ul.PathEscape(path.Base(obj.Path)))
+	setObjectHeaders(ctx.Writer.Header(), *obj)
+	s.setChecksumHeaders(ctx, *obj)
+	if meta != nil {
+		setMetadataHeaders(ctx.Writer.Header(), *meta)
+		if meta.ContentType != "" {
+			ctx.Header("Content-Type", meta.ContentType)
+		}
+	}
+
+	if rng != nil {
+		ctx.Header("Content-Range", rng.ContentRange(obj.Size))
+		ctx.Header("Content-Length", strconv.FormatInt(rng.Length, 10))
+		ctx.Status(http.StatusPartialContent)
+	} else {
+		length := obj.Size - math2.Min(dlReq.Offset, obj.Size)
+		if dlReq.Length >= 0 {
+			length = math2.Min(length, dlReq.Length)
+		}
+		ctx.Header("Content-Length", strconv.FormatInt(length, 10))
+		ctx.Status(http.StatusOK)
+	}
+
+	_, err = io.Copy(ctx.Writer, file.File)
+	if err != nil {
+		log.Warnf("copying file: %s", err.Error())
+	}
+}
+
+// 返回的校验和总是针对完整的对象，即使只下载了对象的一部分。查询失败时不影响下载
+func (s *ObjectService) setChecksumHeaders(ctx *gin.Context, obj cdssdk.Object) {
+	checksums, err := s.svc.ObjectSvc().GetObjectChecksums(getAuthUserID(ctx), []cdssdk.ObjectID{obj.ObjectID})
+	if err != nil {
+		logger.WithField("ObjectID", obj.ObjectID).Warnf("getting object checksums: %s", err.Error())
+		return
+	}
+
+	c, ok := checksums[obj.ObjectID]
+	if !ok {
+		return
+	}
+
+	ctx.Header("X-Checksum-Sha256", c.SHA256)
+	ctx.Header("X-Checksum-Md5", c.MD5)
+}
+
+// 查询失败时不影响下载，只是不返回元数据
+func (s *ObjectService) getObjectMetadata(userID cdssdk.UserID, objectID cdssdk.ObjectID) *stgmod.ObjectMetadata {
+	detail, err := s.svc.ObjectSvc().GetObjectDetail(userID, objectID)
+	if err != nil {
+		logger.WithField("ObjectID", objectID).Warnf("getting object detail: %s", err.Error())
+		return nil
+	}
+	if detail == nil {
+		return nil
+	}
+
+	return detail.Metadata
+}
+
 func sendFileMultiPart(muWriter *multipart.Writer, fieldName, fileName string, file io.ReadCloser, partSize int64) error {
 	for {
 		w, err := muWriter.CreateFormFile(fieldName, ul.PathEscape(fileName))
@@ -159,17 +360,22 @@ func sendFileOnePart(muWriter *multipart.Writer, fieldName, fileName string, fil
 	return err
 }
 
+type ObjectUpdateInfoReq struct {
+	cdssdk.ObjectUpdateInfo
+	Metadatas []coormq.UpdatingObjectMetadata `json:"metadatas"`
+}
+
 func (s *ObjectService) UpdateInfo(ctx *gin.Context) {
 	log := logger.WithField("HTTP", "Object.UpdateInfo")
 
-	var req cdssdk.ObjectUpdateInfo
+	var req ObjectUpdateInfoReq
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		log.Warnf("binding body: %s", err.Error())
 		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
 		return
 	}
 
-	sucs, err := s.svc.ObjectSvc().UpdateInfo(req.UserID, req.Updatings)
+	sucs, err := s.svc.ObjectSvc().UpdateInfo(getAuthUserID(ctx), req.Updatings, req.Metadatas)
 	if err != nil {
 		log.Warnf("updating objects: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "update objects failed"))
@@ -189,7 +395,7 @@ func (s *ObjectService) Move(ctx *gin.Context) {
 		return
 	}
 
-	sucs, err := s.svc.ObjectSvc().Move(req.UserID, req.Movings)
+	sucs, err := s.svc.ObjectSvc().Move(getAuthUserID(ctx), req.Movings)
 	if err != nil {
 		log.Warnf("moving objects: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "move objects failed"))
@@ -209,7 +415,7 @@ func (s *ObjectService) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err := s.svc.ObjectSvc().Delete(req.UserID, req.ObjectIDs)
+	err := s.svc.ObjectSvc().Delete(getAuthUserID(ctx), req.ObjectIDs)
 	if err != nil {
 		log.Warnf("deleting objects: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "delete objects failed"))
@@ -219,6 +425,11 @@ func (s *ObjectService) Delete(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, OK(nil))
 }
 
+type ObjectGetPackageObjectsResp struct {
+	cdssdk.ObjectGetPackageObjectsResp
+	Metadatas []model.ObjectMetadata `json:"metadatas"`
+}
+
 func (s *ObjectService) GetPackageObjects(ctx *gin.Context) {
 	log := logger.WithField("HTTP", "Object.GetPackageObjects")
 
@@ -229,12 +440,88 @@ func (s *ObjectService) GetPackageObjects(ctx *gin.Context) {
 		return
 	}
 
-	objs, err := s.svc.ObjectSvc().GetPackageObjects(req.UserID, req.PackageID)
+	objs, metas, err := s.svc.ObjectSvc().GetPackageObjectsWithMetadatas(getAuthUserID(ctx), req.PackageID)
 	if err != nil {
 		log.Warnf("getting package objects: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get package object failed"))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, OK(cdssdk.ObjectGetPackageObjectsResp{Objects: objs}))
+	ctx.JSON(http.StatusOK, OK(ObjectGetPackageObjectsResp{
+		ObjectGetPackageObjectsResp: cdssdk.ObjectGetPackageObjectsResp{Objects: objs},
+		Metadatas:                   metas,
+	}))
+}
+
+type ObjectListReq struct {
+	PackageID *cdssdk.PackageID `form:"packageID" binding:"required"`
+	Prefix    string            `form:"prefix"`
+	Delimiter string            `form:"delimiter"`
+	Glob      string            `form:"glob"`
+	MinSize   *int64            `form:"minSize"`
+	MaxSize   *int64            `form:"maxSize"`
+	// RFC3339格式的时间
+	UpdatedAfter  *time.Time `form:"updatedAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore *time.Time `form:"updatedBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+	ContentType   string     `form:"contentType"`
+	// 每一项的格式为key=value
+	Metadata          []string `form:"metadata"`
+	MaxKeys           int      `form:"maxKeys"`
+	ContinuationToken string   `form:"continuationToken"`
+}
+type ObjectListResp struct {
+	Objects               []model.Object         `json:"objects"`
+	Metadatas             []model.ObjectMetadata `json:"metadatas"`
+	CommonPrefixes        []string               `json:"commonPrefixes"`
+	NextContinuationToken string                 `json:"nextContinuationToken"`
+}
+
+func (s *ObjectService) List(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Object.List")
+
+	var req ObjectListReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	var meta map[string]string
+	for _, kv := range req.Metadata {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "metadata must be in format of key=value"))
+			return
+		}
+		if meta == nil {
+			meta = make(map[string]string)
+		}
+		meta[k] = v
+	}
+
+	resp, err := s.svc.ObjectSvc().List(getAuthUserID(ctx), *req.PackageID, coormq.ListObjectsOption{
+		Prefix:            req.Prefix,
+		Delimiter:         req.Delimiter,
+		Glob:              req.Glob,
+		MinSize:           req.MinSize,
+		MaxSize:           req.MaxSize,
+		UpdatedAfter:      req.UpdatedAfter,
+		UpdatedBefore:     req.UpdatedBefore,
+		ContentType:       req.ContentType,
+		Metadata:          meta,
+		MaxKeys:           req.MaxKeys,
+		ContinuationToken: req.ContinuationToken,
+	})
+	if err != nil {
+		log.Warnf("listing objects: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list objects failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ObjectListResp{
+		Objects:               resp.Objects,
+		Metadatas:             resp.Metadatas,
+		CommonPrefixes:        resp.CommonPrefixes,
+		NextContinuationToken: resp.NextContinuationToken,
+	}))
 }
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	ul "net/url"
+	"path"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/math2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+const (
+	PackageSetVersioningPath  = "/package/setVersioning"
+	ObjectListVersionsPath    = "/object/listVersions"
+	ObjectDownloadVersionPath = "/object/downloadVersion"
+	ObjectRestoreVersionPath  = "/object/restoreVersion"
+)
+
+type ObjectVersionService struct {
+	*Server
+}
+
+func (s *Server) ObjectVersion() *ObjectVersionService {
+	return &ObjectVersionService{
+		Server: s,
+	}
+}
+
+type PackageSetVersioningReq struct {
+	PackageID *cdssdk.PackageID `json:"packageID" binding:"required"`
+	Enabled   bool              `json:"enabled"`
+}
+
+func (s *ObjectVersionService) SetPackageVersioning(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "ObjectVersion.SetPackageVersioning")
+
+	var req PackageSetVersioningReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.ObjectVersionSvc().SetPackageVersioning(getAuthUserID(ctx), *req.PackageID, req.Enabled)
+	if err != nil {
+		log.Warnf("setting package versioning: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "set package versioning failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(nil))
+}
+
+type ObjectListVersionsReq struct {
+	PackageID *cdssdk.PackageID `form:"packageID" binding:"required"`
+	Path      string            `form:"path" binding:"required"`
+}
+type ObjectListVersionsResp struct {
+	Versions []model.ObjectVersion `json:"versions"`
+}
+
+func (s *ObjectVersionService) ListVersions(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "ObjectVersion.ListVersions")
+
+	var req ObjectListVersionsReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	versions, err := s.svc.ObjectVersionSvc().ListVersions(getAuthUserID(ctx), *req.PackageID, req.Path)
+	if err != nil {
+		log.Warnf("listing object versions: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list object versions failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ObjectListVersionsResp{Versions: versions}))
+}
+
+// 直接返回历史版本的文件内容
+type ObjectDownloadVersionReq struct {
+	VersionID *model.ObjectVersionID `form:"versionID" binding:"required"`
+	Offset    int64                  `form:"offset"`
+	Length    *int64                 `form:"length"`
+}
+
+func (s *ObjectVersionService) DownloadVersion(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "ObjectVersion.DownloadVersion")
+
+	var req ObjectDownloadVersionReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	len := int64(-1)
+	if req.Length != nil {
+		len = *req.Length
+	}
+
+	file, err := s.svc.ObjectVersionSvc().Download(getAuthUserID(ctx), *req.VersionID, req.Offset, len)
+	if err != nil {
+		log.Warnf("downloading object version: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "download object version failed"))
+		return
+	}
+	defer file.File.Close()
+
+	obj := file.Object
+	length := obj.Size - math2.Min(req.Offset, obj.Size)
+	if len >= 0 {
+		length = math2.Min(length, len)
+	}
+
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", "attachment; filename*=UTF-8''"+ul.PathEscape(path.Base(obj.Path)))
+	ctx.Header("ETag", objectETag(*obj))
+	ctx.Header("Content-Length", strconv.FormatInt(length, 10))
+	ctx.Status(http.StatusOK)
+
+	_, err = io.Copy(ctx.Writer, file.File)
+	if err != nil {
+		log.Warnf("copying file: %s", err.Error())
+	}
+}
+
+type ObjectRestoreVersionReq struct {
+	VersionID *model.ObjectVersionID `json:"versionID" binding:"required"`
+}
+type ObjectRestoreVersionResp struct {
+	Object cdssdk.Object `json:"object"`
+}
+
+func (s *ObjectVersionService) RestoreVersion(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "ObjectVersion.RestoreVersion")
+
+	var req ObjectRestoreVersionReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	obj, err := s.svc.ObjectVersionSvc().Restore(getAuthUserID(ctx), *req.VersionID)
+	if err != nil {
+		log.Warnf("restoring object version: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "restore object version failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ObjectRestoreVersionResp{Object: *obj}))
+}
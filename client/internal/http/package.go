@@ -34,7 +34,7 @@ func (s *PackageService) Get(ctx *gin.Context) {
 		return
 	}
 
-	pkg, err := s.svc.PackageSvc().Get(req.UserID, req.PackageID)
+	pkg, err := s.svc.PackageSvc().Get(getAuthUserID(ctx), req.PackageID)
 	if err != nil {
 		log.Warnf("getting package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get package failed"))
@@ -54,7 +54,7 @@ func (s *PackageService) GetByName(ctx *gin.Context) {
 		return
 	}
 
-	pkg, err := s.svc.PackageSvc().GetByName(req.UserID, req.BucketName, req.PackageName)
+	pkg, err := s.svc.PackageSvc().GetByName(getAuthUserID(ctx), req.BucketName, req.PackageName)
 	if err != nil {
 		log.Warnf("getting package by name: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get package by name failed"))
@@ -73,7 +73,7 @@ func (s *PackageService) Create(ctx *gin.Context) {
 		return
 	}
 
-	pkg, err := s.svc.PackageSvc().Create(req.UserID, req.BucketID, req.Name)
+	pkg, err := s.svc.PackageSvc().Create(getAuthUserID(ctx), req.BucketID, req.Name)
 	if err != nil {
 		log.Warnf("creating package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "create package failed"))
@@ -95,7 +95,7 @@ func (s *PackageService) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err := s.svc.PackageSvc().DeletePackage(req.UserID, req.PackageID)
+	err := s.svc.PackageSvc().DeletePackage(getAuthUserID(ctx), req.PackageID)
 	if err != nil {
 		log.Warnf("deleting package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "delete package failed"))
@@ -105,6 +105,67 @@ func (s *PackageService) Delete(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, OK(nil))
 }
 
+const (
+	PackageSnapshotPath = "/package/snapshot"
+	PackageClonePath    = "/package/clone"
+)
+
+type PackageSnapshotReq struct {
+	PackageID *cdssdk.PackageID `json:"packageID" binding:"required"`
+}
+type PackageSnapshotResp struct {
+	Package cdssdk.Package `json:"package"`
+}
+
+func (s *PackageService) Snapshot(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Package.Snapshot")
+
+	var req PackageSnapshotReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	pkg, err := s.svc.PackageSvc().Snapshot(getAuthUserID(ctx), *req.PackageID)
+	if err != nil {
+		log.Warnf("creating package snapshot: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "create package snapshot failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(PackageSnapshotResp{Package: pkg}))
+}
+
+type PackageCloneReq struct {
+	PackageID *cdssdk.PackageID `json:"packageID" binding:"required"`
+	BucketID  *cdssdk.BucketID  `json:"bucketID" binding:"required"`
+	Name      string            `json:"name" binding:"required"`
+}
+type PackageCloneResp struct {
+	Package cdssdk.Package `json:"package"`
+}
+
+func (s *PackageService) Clone(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Package.Clone")
+
+	var req PackageCloneReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	pkg, err := s.svc.PackageSvc().Clone(getAuthUserID(ctx), *req.PackageID, *req.BucketID, req.Name)
+	if err != nil {
+		log.Warnf("cloning package: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "clone package failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(PackageCloneResp{Package: pkg}))
+}
+
 func (s *PackageService) ListBucketPackages(ctx *gin.Context) {
 	log := logger.WithField("HTTP", "Package.ListBucketPackages")
 
@@ -115,7 +176,7 @@ func (s *PackageService) ListBucketPackages(ctx *gin.Context) {
 		return
 	}
 
-	pkgs, err := s.svc.PackageSvc().GetBucketPackages(req.UserID, req.BucketID)
+	pkgs, err := s.svc.PackageSvc().GetBucketPackages(getAuthUserID(ctx), req.BucketID)
 	if err != nil {
 		log.Warnf("getting bucket packages: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get bucket packages failed"))
@@ -137,7 +198,7 @@ func (s *PackageService) GetCachedNodes(ctx *gin.Context) {
 		return
 	}
 
-	resp, err := s.svc.PackageSvc().GetCachedNodes(req.UserID, req.PackageID)
+	resp, err := s.svc.PackageSvc().GetCachedNodes(getAuthUserID(ctx), req.PackageID)
 	if err != nil {
 		log.Warnf("get package cached nodes failed: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get package cached nodes failed"))
@@ -157,7 +218,7 @@ func (s *PackageService) GetLoadedNodes(ctx *gin.Context) {
 		return
 	}
 
-	nodeIDs, err := s.svc.PackageSvc().GetLoadedNodes(req.UserID, req.PackageID)
+	nodeIDs, err := s.svc.PackageSvc().GetLoadedNodes(getAuthUserID(ctx), req.PackageID)
 	if err != nil {
 		log.Warnf("get package loaded nodes failed: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get package loaded nodes failed"))
@@ -169,7 +230,8 @@ func (s *PackageService) GetLoadedNodes(ctx *gin.Context) {
 	}))
 }
 
-func mapMultiPartFileToUploadingObject(files []*multipart.FileHeader) stgiter.UploadingObjectIterator {
+// checksums和metadatas为用户提供的各个文件的校验和与元数据，以文件路径为键，可以为nil
+func mapMultiPartFileToUploadingObject(files []*multipart.FileHeader, checksums map[string]UploadingChecksum, metadatas map[string]UploadingMetadata) stgiter.UploadingObjectIterator {
 	return iterator.Map[*multipart.FileHeader](
 		iterator.Array(files...),
 		func(file *multipart.FileHeader) (*stgiter.IterUploadingObject, error) {
@@ -183,10 +245,24 @@ func mapMultiPartFileToUploadingObject(files []*multipart.FileHeader) stgiter.Up
 				return nil, err
 			}
 
+			checksum := checksums[fileName]
+			meta := metadatas[fileName]
+			// 没有指定MIME类型时，使用表单中文件自带的类型
+			if meta.ContentType == "" {
+				ct := file.Header.Get("Content-Type")
+				if ct != "application/octet-stream" {
+					meta.ContentType = ct
+				}
+			}
+
 			return &stgiter.IterUploadingObject{
-				Path: fileName,
-				Size: file.Size,
-				File: stream,
+				Path:         fileName,
+				Size:         file.Size,
+				File:         stream,
+				SHA256:       checksum.SHA256,
+				MD5:          checksum.MD5,
+				ContentType:  meta.ContentType,
+				UserMetadata: meta.UserMetadata,
 			}, nil
 		},
 	)
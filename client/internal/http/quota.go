@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+const (
+	QuotaGetUserPath   = "/quota/getUser"
+	QuotaGetBucketPath = "/quota/getBucket"
+	QuotaSetUserPath   = "/quota/setUser"
+	QuotaSetBucketPath = "/quota/setBucket"
+)
+
+type QuotaService struct {
+	*Server
+}
+
+func (s *Server) Quota() *QuotaService {
+	return &QuotaService{
+		Server: s,
+	}
+}
+
+type QuotaGetUserReq struct {
+}
+type QuotaGetResp struct {
+	Quota model.Quota        `json:"quota"`
+	Usage model.StorageUsage `json:"usage"`
+}
+
+func (s *QuotaService) GetUser(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Quota.GetUser")
+
+	var req QuotaGetUserReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	quota, usage, err := s.svc.QuotaSvc().GetUserQuota(getAuthUserID(ctx))
+	if err != nil {
+		log.Warnf("getting user quota: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get user quota failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(QuotaGetResp{Quota: quota, Usage: usage}))
+}
+
+type QuotaGetBucketReq struct {
+	BucketID *cdssdk.BucketID `form:"bucketID" binding:"required"`
+}
+
+func (s *QuotaService) GetBucket(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Quota.GetBucket")
+
+	var req QuotaGetBucketReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	quota, usage, err := s.svc.QuotaSvc().GetBucketQuota(getAuthUserID(ctx), *req.BucketID)
+	if err != nil {
+		log.Warnf("getting bucket quota: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get bucket quota failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(QuotaGetResp{Quota: quota, Usage: usage}))
+}
+
+type QuotaSetUserReq struct {
+	UserID *cdssdk.UserID `json:"userID" binding:"required"`
+	Quota  model.Quota    `json:"quota"`
+}
+type QuotaSetResp struct{}
+
+func (s *QuotaService) SetUser(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Quota.SetUser")
+
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	var req QuotaSetUserReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.QuotaSvc().SetUserQuota(*req.UserID, req.Quota)
+	if err != nil {
+		log.Warnf("setting user quota: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "set user quota failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(QuotaSetResp{}))
+}
+
+type QuotaSetBucketReq struct {
+	BucketID *cdssdk.BucketID `json:"bucketID" binding:"required"`
+	Quota    model.Quota      `json:"quota"`
+}
+
+func (s *QuotaService) SetBucket(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Quota.SetBucket")
+
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	var req QuotaSetBucketReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.QuotaSvc().SetBucketQuota(*req.BucketID, req.Quota)
+	if err != nil {
+		log.Warnf("setting bucket quota: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "set bucket quota failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(QuotaSetResp{}))
+}
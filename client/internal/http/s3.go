@@ -0,0 +1,892 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/iterator"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/cmd"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/downloader"
+	stgiter "gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+// S3网关的路由前缀，只支持path-style的访问方式，即<S3PathPrefix>/<bucket>/<package>/<path>
+const S3PathPrefix = "/s3"
+
+const (
+	s3XMLNamespace     = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3DefaultMaxKeys   = 1000
+	s3StorageClass     = "STANDARD"
+	s3MaxDeleteObjects = 1000
+	s3MetaHeaderPrefix = "X-Amz-Meta-"
+	s3ListMaxCalls     = 10 // ListObjectsV2一次请求中最多向协调端查询的次数
+)
+
+type s3Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *s3Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *s3Error) WithMessage(msg string) *s3Error {
+	return &s3Error{
+		Status:  e.Status,
+		Code:    e.Code,
+		Message: msg,
+	}
+}
+
+var (
+	errS3AccessDenied          = &s3Error{http.StatusForbidden, "AccessDenied", "Access Denied"}
+	errS3AuthHeaderMalformed   = &s3Error{http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header is malformed"}
+	errS3AuthQueryParamsError  = &s3Error{http.StatusBadRequest, "AuthorizationQueryParametersError", "Error parsing the query-string authentication parameters"}
+	errS3InvalidAccessKeyID    = &s3Error{http.StatusForbidden, "InvalidAccessKeyId", "The access key you provided does not exist"}
+	errS3SignatureDoesNotMatch = &s3Error{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature does not match"}
+	errS3RequestTimeTooSkewed  = &s3Error{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large"}
+	errS3ContentSHA256Mismatch = &s3Error{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 does not match"}
+	errS3NoSuchBucket          = &s3Error{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist"}
+	errS3NoSuchKey             = &s3Error{http.StatusNotFound, "NoSuchKey", "The specified key does not exist"}
+	errS3BucketAlreadyOwned    = &s3Error{http.StatusConflict, "BucketAlreadyOwnedByYou", "The bucket already exists"}
+	errS3InvalidArgument       = &s3Error{http.StatusBadRequest, "InvalidArgument", "Invalid argument"}
+	errS3InvalidRange          = &s3Error{http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "The requested range is not satisfiable"}
+	errS3NoSuchUpload          = &s3Error{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist"}
+	errS3InvalidPart           = &s3Error{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found"}
+	errS3MalformedXML          = &s3Error{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed"}
+	errS3MissingContentLength  = &s3Error{http.StatusLengthRequired, "MissingContentLength", "You must provide the Content-Length HTTP header"}
+	errS3NotImplemented        = &s3Error{http.StatusNotImplemented, "NotImplemented", "The requested functionality is not implemented"}
+	errS3InternalError         = &s3Error{http.StatusInternalServerError, "InternalError", "We encountered an internal error, please try again"}
+	errS3InvalidDigest         = &s3Error{http.StatusBadRequest, "InvalidDigest", "The Content-MD5 or checksum value that you specified is not valid"}
+	errS3BadDigest             = &s3Error{http.StatusBadRequest, "BadDigest", "The Content-MD5 or checksum value that you specified did not match what the server received"}
+)
+
+type s3ErrorResp struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+type s3Owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type s3ListAllMyBucketsResult struct {
+	XMLName xml.Name   `xml:"ListAllMyBucketsResult"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	Owner   s3Owner    `xml:"Owner"`
+	Buckets []s3Bucket `xml:"Buckets>Bucket"`
+}
+
+type s3Bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type s3ListBucketResult struct {
+	XMLName               xml.Name         `xml:"ListBucketResult"`
+	Xmlns                 string           `xml:"xmlns,attr"`
+	Name                  string           `xml:"Name"`
+	Prefix                string           `xml:"Prefix"`
+	Delimiter             string           `xml:"Delimiter,omitempty"`
+	MaxKeys               int              `xml:"MaxKeys"`
+	IsTruncated           bool             `xml:"IsTruncated"`
+	Marker                *string          `xml:"Marker,omitempty"`
+	NextMarker            string           `xml:"NextMarker,omitempty"`
+	KeyCount              *int             `xml:"KeyCount,omitempty"`
+	ContinuationToken     string           `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string           `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string           `xml:"StartAfter,omitempty"`
+	Contents              []s3Content      `xml:"Contents"`
+	CommonPrefixes        []s3CommonPrefix `xml:"CommonPrefixes"`
+}
+
+type s3Content struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type s3CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type s3Delete struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+type s3DeleteResult struct {
+	XMLName xml.Name        `xml:"DeleteResult"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Deleted []s3DeletedKey  `xml:"Deleted"`
+	Errors  []s3DeleteError `xml:"Error"`
+}
+
+type s3DeletedKey struct {
+	Key string `xml:"Key"`
+}
+
+type s3DeleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// ListObjectsV2的ContinuationToken，记录下一页从哪个Package的什么位置开始列举
+type s3ListToken struct {
+	Package string `json:"package"`
+	// 在这个Package中列举时使用的ContinuationToken，为空代表从头开始
+	Token string `json:"token,omitempty"`
+}
+
+type S3Service struct {
+	*Server
+}
+
+func (s *Server) S3() *S3Service {
+	return &S3Service{
+		Server: s,
+	}
+}
+
+// 所有S3请求的入口，根据请求方法和路径分派到具体的操作
+func (s *S3Service) Dispatch(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "S3")
+
+	auth, serr := s.authenticate(ctx.Request)
+	if serr != nil {
+		log.Warnf("authenticating request %s %s: %s", ctx.Request.Method, ctx.Request.URL.Path, serr.Error())
+		s.replyError(ctx, serr)
+		return
+	}
+
+	bucketName, key, _ := strings.Cut(strings.TrimPrefix(ctx.Param("path"), "/"), "/")
+	query := ctx.Request.URL.Query()
+
+	var err *s3Error
+	switch {
+	case bucketName == "":
+		if ctx.Request.Method != http.MethodGet {
+			err = errS3NotImplemented
+			break
+		}
+		err = s.listBuckets(ctx, auth)
+
+	case key == "":
+		switch ctx.Request.Method {
+		case http.MethodGet:
+			err = s.listObjects(ctx, auth, bucketName)
+		case http.MethodHead:
+			err = s.headBucket(ctx, auth, bucketName)
+		case http.MethodPut:
+			err = s.createBucket(ctx, auth, bucketName)
+		case http.MethodPost:
+			if !query.Has("delete") {
+				err = errS3NotImplemented
+				break
+			}
+			err = s.deleteObjects(ctx, auth, bucketName)
+		default:
+			err = errS3NotImplemented
+		}
+
+	default:
+		switch ctx.Request.Method {
+		case http.MethodGet:
+			if query.Has("uploadId") {
+				err = s.listParts(ctx, auth, bucketName, key)
+				break
+			}
+			err = s.getObject(ctx, auth, bucketName, key)
+		case http.MethodHead:
+			err = s.headObject(ctx, auth, bucketName, key)
+		case http.MethodPut:
+			if ctx.GetHeader("X-Amz-Copy-Source") != "" {
+				err = errS3NotImplemented
+				break
+			}
+			if query.Has("uploadId") {
+				err = s.uploadPart(ctx, auth, bucketName, key)
+				break
+			}
+			err = s.putObject(ctx, auth, bucketName, key)
+		case http.MethodPost:
+			if query.Has("uploads") {
+				err = s.createMultipartUpload(ctx, auth, bucketName, key)
+				break
+			}
+			if query.Has("uploadId") {
+				err = s.completeMultipartUpload(ctx, auth, bucketName, key)
+				break
+			}
+			err = errS3NotImplemented
+		case http.MethodDelete:
+			if query.Has("uploadId") {
+				err = s.abortMultipartUpload(ctx, auth, bucketName, key)
+				break
+			}
+			err = s.deleteObject(ctx, auth, bucketName, key)
+		default:
+			err = errS3NotImplemented
+		}
+	}
+
+	if err != nil {
+		log.Warnf("%s %s: %s", ctx.Request.Method, ctx.Request.URL.Path, err.Error())
+		s.replyError(ctx, err)
+	}
+}
+
+func (s *S3Service) replyError(ctx *gin.Context, err *s3Error) {
+	if ctx.Request.Method == http.MethodHead {
+		ctx.Status(err.Status)
+		return
+	}
+
+	ctx.XML(err.Status, s3ErrorResp{
+		Code:     err.Code,
+		Message:  err.Message,
+		Resource: ctx.Request.URL.Path,
+	})
+}
+
+func (s *S3Service) listBuckets(ctx *gin.Context, auth *s3AuthInfo) *s3Error {
+	bkts, err := s.svc.BucketSvc().GetUserBucketDetails(auth.UserID)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	userID := strconv.FormatInt(int64(auth.UserID), 10)
+	resp := s3ListAllMyBucketsResult{
+		Xmlns: s3XMLNamespace,
+		Owner: s3Owner{ID: userID, DisplayName: userID},
+	}
+	for _, bkt := range bkts {
+		resp.Buckets = append(resp.Buckets, s3Bucket{
+			Name:         bkt.Name,
+			CreationDate: s3FormatTime(bkt.CreateTime),
+		})
+	}
+
+	ctx.XML(http.StatusOK, resp)
+	return nil
+}
+
+func (s *S3Service) headBucket(ctx *gin.Context, auth *s3AuthInfo, bucketName string) *s3Error {
+	_, err := s.getBucket(auth, bucketName)
+	if err != nil {
+		return err
+	}
+
+	ctx.Status(http.StatusOK)
+	return nil
+}
+
+func (s *S3Service) createBucket(ctx *gin.Context, auth *s3AuthInfo, bucketName string) *s3Error {
+	_, err := s.getBucket(auth, bucketName)
+	if err == nil {
+		return errS3BucketAlreadyOwned
+	}
+	if err != errS3NoSuchBucket {
+		return err
+	}
+
+	_, cerr := s.svc.BucketSvc().CreateBucket(auth.UserID, bucketName)
+	if cerr != nil {
+		return errS3InternalError.WithMessage(cerr.Error())
+	}
+
+	ctx.Header("Location", "/"+bucketName)
+	ctx.Status(http.StatusOK)
+	return nil
+}
+
+// 同时支持ListObjects和ListObjectsV2，通过list-type=2区分
+func (s *S3Service) listObjects(ctx *gin.Context, auth *s3AuthInfo, bucketName string) *s3Error {
+	query := ctx.Request.URL.Query()
+	isV2 := query.Get("list-type") == "2"
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	maxKeys := s3DefaultMaxKeys
+	if str := query.Get("max-keys"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 0 {
+			return errS3InvalidArgument.WithMessage("invalid max-keys")
+		}
+		if n < maxKeys {
+			maxKeys = n
+		}
+	}
+
+	marker := query.Get("marker")
+	var token *s3ListToken
+	if isV2 {
+		marker = query.Get("start-after")
+		if str := query.Get("continuation-token"); str != "" {
+			token = &s3ListToken{}
+			data, err := base64.RawURLEncoding.DecodeString(str)
+			if err != nil || serder.JSONToObject(data, token) != nil {
+				return errS3InvalidArgument.WithMessage("invalid continuation-token")
+			}
+		}
+	}
+	// 上一页最后返回的是CommonPrefix时，需要跳过这个前缀下的所有对象
+	markerIsPrefix := delimiter != "" && strings.HasSuffix(marker, delimiter)
+
+	pkgs, serr := s.listBucketPackages(auth, bucketName, prefix)
+	if serr != nil {
+		return serr
+	}
+
+	resp := s3ListBucketResult{
+		Xmlns:     s3XMLNamespace,
+		Name:      bucketName,
+		Prefix:    prefix,
+		Delimiter: delimiter,
+		MaxKeys:   maxKeys,
+	}
+
+	// 每个Package分页列举，不会一次性加载所有对象
+	lastKey := ""
+	cnt := 0
+	listCalls := 0
+	var nextToken *s3ListToken
+pkgLoop:
+	for _, pkg := range pkgs {
+		pkgKey := pkg.Name + cdssdk.ObjectPathSeparator
+
+		var opt coormq.ListObjectsOption
+		if token != nil {
+			if pkgKey < token.Package+cdssdk.ObjectPathSeparator {
+				continue
+			}
+			if pkg.Name == token.Package {
+				opt.ContinuationToken = token.Token
+			}
+		} else if marker != "" {
+			if markerIsPrefix && strings.HasPrefix(pkgKey, marker) {
+				continue
+			}
+
+			if strings.HasPrefix(marker, pkgKey) {
+				opt.StartAfter = marker[len(pkgKey):]
+				if markerIsPrefix {
+					opt.StartAfter += string(utf8.MaxRune)
+				}
+			} else if marker > pkgKey {
+				// 这个Package中所有对象的Key都比marker小
+				continue
+			}
+		}
+
+		if cnt >= maxKeys {
+			resp.IsTruncated = true
+			nextToken = &s3ListToken{Package: pkg.Name, Token: opt.ContinuationToken}
+			break
+		}
+
+		// 分隔符出现在Package名中时，整个Package合并为一个CommonPrefix
+		if delimiter != "" && len(prefix) < len(pkgKey) {
+			if idx := strings.Index(pkgKey[len(prefix):], delimiter); idx >= 0 {
+				commonPrefix := pkgKey[:len(prefix)+idx+len(delimiter)]
+				if commonPrefix == lastKey {
+					continue
+				}
+
+				// 空的Package不会产生CommonPrefix
+				listCalls++
+				ret, err := s.svc.ObjectSvc().List(auth.UserID, pkg.PackageID, coormq.ListObjectsOption{MaxKeys: 1})
+				if err != nil {
+					return errS3InternalError.WithMessage(err.Error())
+				}
+				if len(ret.Objects) == 0 {
+					continue
+				}
+
+				resp.CommonPrefixes = append(resp.CommonPrefixes, s3CommonPrefix{Prefix: commonPrefix})
+				lastKey = commonPrefix
+				cnt++
+				continue
+			}
+		}
+
+		if strings.HasPrefix(prefix, pkgKey) {
+			opt.Prefix = prefix[len(pkgKey):]
+		}
+		opt.Delimiter = delimiter
+
+		for {
+			// V2允许返回少于max-keys的结果，因此限制一次请求中查询的次数，防止扫描过多的对象
+			if cnt >= maxKeys || (isV2 && listCalls >= s3ListMaxCalls) {
+				resp.IsTruncated = true
+				nextToken = &s3ListToken{Package: pkg.Name, Token: opt.ContinuationToken}
+				break pkgLoop
+			}
+
+			opt.MaxKeys = maxKeys - cnt
+			listCalls++
+			ret, err := s.svc.ObjectSvc().List(auth.UserID, pkg.PackageID, opt)
+			if err != nil {
+				return errS3InternalError.WithMessage(err.Error())
+			}
+
+			for _, obj := range ret.Objects {
+				key := pkgKey + obj.Path
+				resp.Contents = append(resp.Contents, s3Content{
+					Key:          key,
+					LastModified: s3FormatTime(obj.UpdateTime),
+					ETag:         objectETag(obj),
+					Size:         obj.Size,
+					StorageClass: s3StorageClass,
+				})
+				if key > lastKey {
+					lastKey = key
+				}
+			}
+			for _, p := range ret.CommonPrefixes {
+				key := pkgKey + p
+				resp.CommonPrefixes = append(resp.CommonPrefixes, s3CommonPrefix{Prefix: key})
+				if key > lastKey {
+					lastKey = key
+				}
+			}
+			cnt += len(ret.Objects) + len(ret.CommonPrefixes)
+
+			if ret.NextContinuationToken == "" {
+				break
+			}
+			opt.ContinuationToken = ret.NextContinuationToken
+			opt.StartAfter = ""
+		}
+	}
+
+	if isV2 {
+		resp.KeyCount = &cnt
+		resp.StartAfter = query.Get("start-after")
+		resp.ContinuationToken = query.Get("continuation-token")
+		if nextToken != nil {
+			data, err := serder.ObjectToJSON(nextToken)
+			if err != nil {
+				return errS3InternalError.WithMessage(err.Error())
+			}
+			resp.NextContinuationToken = base64.RawURLEncoding.EncodeToString(data)
+		}
+	} else {
+		resp.Marker = &marker
+		if resp.IsTruncated {
+			resp.NextMarker = lastKey
+		}
+	}
+
+	ctx.XML(http.StatusOK, resp)
+	return nil
+}
+
+func (s *S3Service) getObject(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	obj, serr := s.findObject(auth, bucketName, key)
+	if serr != nil {
+		return serr
+	}
+
+	var rng *httpRange
+	if hdr := ctx.GetHeader("Range"); hdr != "" && checkIfRange(ctx.Request, objectETag(*obj), obj.UpdateTime) {
+		var err error
+		rng, err = parseHTTPRange(hdr, obj.Size)
+		if err == errRangeNotSatisfiable {
+			ctx.Header("Content-Range", fmt.Sprintf("bytes */%d", obj.Size))
+			return errS3InvalidRange
+		}
+	}
+
+	req := downloader.DownloadReqeust{
+		ObjectID: obj.ObjectID,
+		Offset:   0,
+		Length:   -1,
+	}
+	if rng != nil {
+		req.Offset = rng.Offset
+		req.Length = rng.Length
+	}
+
+	file, err := s.svc.ObjectSvc().Download(auth.UserID, req)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+	defer file.File.Close()
+
+	s.setS3ObjectMetadataHeaders(ctx, auth.UserID, obj.ObjectID)
+	setObjectHeaders(ctx.Writer.Header(), *obj)
+	if rng != nil {
+		ctx.Header("Content-Range", rng.ContentRange(obj.Size))
+		ctx.Header("Content-Length", strconv.FormatInt(rng.Length, 10))
+		ctx.Status(http.StatusPartialContent)
+	} else {
+		ctx.Header("Content-Length", strconv.FormatInt(obj.Size, 10))
+		ctx.Status(http.StatusOK)
+	}
+
+	_, err = io.Copy(ctx.Writer, file.File)
+	if err != nil {
+		logger.WithField("HTTP", "S3").Warnf("copying object %v: %s", obj.ObjectID, err.Error())
+	}
+	return nil
+}
+
+func (s *S3Service) headObject(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	obj, err := s.findObject(auth, bucketName, key)
+	if err != nil {
+		return err
+	}
+
+	s.setS3ObjectMetadataHeaders(ctx, auth.UserID, obj.ObjectID)
+	setObjectHeaders(ctx.Writer.Header(), *obj)
+	ctx.Header("Content-Length", strconv.FormatInt(obj.Size, 10))
+	ctx.Status(http.StatusOK)
+	return nil
+}
+
+func (s *S3Service) putObject(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	pkgName, objPath := splitS3Key(key)
+
+	pkg, serr := s.getOrCreatePackage(auth, bucketName, pkgName)
+	if serr != nil {
+		return serr
+	}
+
+	// 只有Package名的Key视为目录，只需要保证Package存在
+	if objPath == "" {
+		ctx.Status(http.StatusOK)
+		return nil
+	}
+
+	size, err := auth.bodySize(ctx.Request)
+	if err != nil {
+		return errS3MissingContentLength
+	}
+
+	md5, err := decodeS3Digest(ctx.GetHeader("Content-MD5"))
+	if err != nil {
+		return errS3InvalidDigest
+	}
+	sha256, err := decodeS3Digest(ctx.GetHeader("x-amz-checksum-sha256"))
+	if err != nil {
+		return errS3InvalidDigest
+	}
+
+	userMeta := make(map[string]string)
+	for k, v := range ctx.Request.Header {
+		if strings.HasPrefix(k, s3MetaHeaderPrefix) && len(v) > 0 {
+			userMeta[strings.ToLower(strings.TrimPrefix(k, s3MetaHeaderPrefix))] = v[0]
+		}
+	}
+
+	body := auth.wrapBody(ctx.Request.Body)
+	objIter := iterator.Array(&stgiter.IterUploadingObject{
+		Path:         objPath,
+		Size:         size,
+		File:         body,
+		SHA256:       sha256,
+		MD5:          md5,
+		ContentType:  ctx.GetHeader("Content-Type"),
+		UserMetadata: userMeta,
+	})
+
+	taskID, err := s.svc.ObjectSvc().StartUploading(auth.UserID, pkg.PackageID, objIter, nil, nil)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	for {
+		complete, ret, err := s.svc.ObjectSvc().WaitUploading(taskID, time.Second*5)
+		if !complete {
+			continue
+		}
+
+		if err != nil {
+			return s3UploadError(err)
+		}
+		if len(ret.Objects) == 0 {
+			return errS3InternalError
+		}
+		if ret.Objects[0].Error != nil {
+			return s3UploadError(ret.Objects[0].Error)
+		}
+
+		ctx.Header("ETag", objectETag(ret.Objects[0].Object))
+		ctx.Status(http.StatusOK)
+		return nil
+	}
+}
+
+func (s *S3Service) deleteObject(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	obj, serr := s.findObject(auth, bucketName, key)
+	if serr == errS3NoSuchKey {
+		// 删除不存在的对象也视为成功
+		ctx.Status(http.StatusNoContent)
+		return nil
+	}
+	if serr != nil {
+		return serr
+	}
+
+	err := s.svc.ObjectSvc().Delete(auth.UserID, []cdssdk.ObjectID{obj.ObjectID})
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	ctx.Status(http.StatusNoContent)
+	return nil
+}
+
+func (s *S3Service) deleteObjects(ctx *gin.Context, auth *s3AuthInfo, bucketName string) *s3Error {
+	body := auth.wrapBody(ctx.Request.Body)
+	defer body.Close()
+
+	var req s3Delete
+	if err := xml.NewDecoder(body).Decode(&req); err != nil {
+		return errS3MalformedXML
+	}
+	if len(req.Objects) > s3MaxDeleteObjects {
+		return errS3MalformedXML.WithMessage("too many objects")
+	}
+
+	if _, err := s.getBucket(auth, bucketName); err != nil {
+		return err
+	}
+
+	resp := s3DeleteResult{Xmlns: s3XMLNamespace}
+
+	// 只查询需要删除的Key，同一个Package只查询一次
+	pkgs := make(map[string]*cdssdk.Package)
+	var objIDs []cdssdk.ObjectID
+	var deleting []string
+	for _, o := range req.Objects {
+		pkgName, objPath := splitS3Key(o.Key)
+
+		pkg, ok := pkgs[pkgName]
+		if !ok {
+			p, err := s.svc.PackageSvc().GetByName(auth.UserID, bucketName, pkgName)
+			if err != nil && !isDataNotFound(err) {
+				resp.Errors = append(resp.Errors, s3DeleteError{Key: o.Key, Code: errS3InternalError.Code, Message: err.Error()})
+				continue
+			}
+			if err == nil {
+				pkg = p
+			}
+			pkgs[pkgName] = pkg
+		}
+
+		// 不存在的Key也视为删除成功
+		if pkg != nil && objPath != "" {
+			obj, err := s.findPackageObject(auth, pkg.PackageID, objPath)
+			if err != nil && err != errS3NoSuchKey {
+				resp.Errors = append(resp.Errors, s3DeleteError{Key: o.Key, Code: err.Code, Message: err.Message})
+				continue
+			}
+			if obj != nil {
+				objIDs = append(objIDs, obj.ObjectID)
+			}
+		}
+		deleting = append(deleting, o.Key)
+	}
+
+	if len(objIDs) > 0 {
+		err := s.svc.ObjectSvc().Delete(auth.UserID, objIDs)
+		if err != nil {
+			for _, key := range deleting {
+				resp.Errors = append(resp.Errors, s3DeleteError{Key: key, Code: errS3InternalError.Code, Message: err.Error()})
+			}
+			deleting = nil
+		}
+	}
+
+	if !req.Quiet {
+		for _, key := range deleting {
+			resp.Deleted = append(resp.Deleted, s3DeletedKey{Key: key})
+		}
+	}
+
+	ctx.XML(http.StatusOK, resp)
+	return nil
+}
+
+// 查询元数据失败时只记录日志，仍然返回对象数据
+func (s *S3Service) setS3ObjectMetadataHeaders(ctx *gin.Context, userID cdssdk.UserID, objectID cdssdk.ObjectID) {
+	ctx.Header("Content-Type", "application/octet-stream")
+
+	detail, err := s.svc.ObjectSvc().GetObjectDetail(userID, objectID)
+	if err != nil {
+		logger.WithField("HTTP", "S3").Warnf("getting object %v detail: %s", objectID, err.Error())
+		return
+	}
+	if detail == nil || detail.Metadata == nil {
+		return
+	}
+
+	if detail.Metadata.ContentType != "" {
+		ctx.Header("Content-Type", detail.Metadata.ContentType)
+	}
+	for k, v := range detail.Metadata.UserMetadata {
+		ctx.Header(s3MetaHeaderPrefix+k, v)
+	}
+}
+
+func (s *S3Service) getBucket(auth *s3AuthInfo, bucketName string) (model.Bucket, *s3Error) {
+	bkt, err := s.svc.BucketSvc().GetBucketByName(auth.UserID, bucketName)
+	if err != nil {
+		if isDataNotFound(err) {
+			return model.Bucket{}, errS3NoSuchBucket
+		}
+		return model.Bucket{}, errS3InternalError.WithMessage(err.Error())
+	}
+
+	return bkt, nil
+}
+
+// Package不存在时会自动创建
+func (s *S3Service) getOrCreatePackage(auth *s3AuthInfo, bucketName string, pkgName string) (*cdssdk.Package, *s3Error) {
+	bkt, serr := s.getBucket(auth, bucketName)
+	if serr != nil {
+		return nil, serr
+	}
+
+	pkg, err := s.svc.PackageSvc().GetByName(auth.UserID, bucketName, pkgName)
+	if err == nil {
+		return pkg, nil
+	}
+	if !isDataNotFound(err) {
+		return nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	newPkg, err := s.svc.PackageSvc().Create(auth.UserID, bkt.BucketID, pkgName)
+	if err != nil {
+		return nil, errS3InternalError.WithMessage(err.Error())
+	}
+	return &newPkg, nil
+}
+
+// 查询桶中Key可能以prefix开头的Package，按Key的顺序排列
+func (s *S3Service) listBucketPackages(auth *s3AuthInfo, bucketName string, prefix string) ([]model.Package, *s3Error) {
+	bkt, serr := s.getBucket(auth, bucketName)
+	if serr != nil {
+		return nil, serr
+	}
+
+	pkgs, err := s.svc.BucketSvc().GetBucketPackages(auth.UserID, bkt.BucketID)
+	if err != nil {
+		return nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	pkgPrefix, _, hasSep := strings.Cut(prefix, cdssdk.ObjectPathSeparator)
+	pkgs = lo.Filter(pkgs, func(pkg model.Package, idx int) bool {
+		if hasSep {
+			return pkg.Name == pkgPrefix
+		}
+		return strings.HasPrefix(pkg.Name, pkgPrefix)
+	})
+
+	// Key是<package>/<path>，所以要按加上分隔符后的名字排序
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].Name+cdssdk.ObjectPathSeparator < pkgs[j].Name+cdssdk.ObjectPathSeparator
+	})
+	return pkgs, nil
+}
+
+func (s *S3Service) findObject(auth *s3AuthInfo, bucketName string, key string) (*model.Object, *s3Error) {
+	pkgName, objPath := splitS3Key(key)
+	if objPath == "" {
+		return nil, errS3NoSuchKey
+	}
+
+	pkg, err := s.svc.PackageSvc().GetByName(auth.UserID, bucketName, pkgName)
+	if err != nil {
+		if isDataNotFound(err) {
+			return nil, errS3NoSuchKey
+		}
+		return nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	return s.findPackageObject(auth, pkg.PackageID, objPath)
+}
+
+// 只查询Package中指定路径的对象。以objPath为前缀的路径中objPath本身最小，所以取前缀查询的第一个结果即可
+func (s *S3Service) findPackageObject(auth *s3AuthInfo, pkgID cdssdk.PackageID, objPath string) (*model.Object, *s3Error) {
+	ret, err := s.svc.ObjectSvc().List(auth.UserID, pkgID, coormq.ListObjectsOption{Prefix: objPath, MaxKeys: 1})
+	if err != nil {
+		return nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	if len(ret.Objects) == 0 || ret.Objects[0].Path != objPath {
+		return nil, errS3NoSuchKey
+	}
+	return &ret.Objects[0], nil
+}
+
+// Key的第一段为Package名，剩余部分为对象在Package内的路径
+func splitS3Key(key string) (string, string) {
+	pkgName, objPath, _ := strings.Cut(key, cdssdk.ObjectPathSeparator)
+	return pkgName, objPath
+}
+
+func s3FormatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func s3UploadError(err error) *s3Error {
+	var serr *s3Error
+	if errors.As(err, &serr) {
+		return serr
+	}
+	if errors.Is(err, cmd.ErrChecksumMismatch) {
+		return errS3BadDigest
+	}
+	return errS3InternalError.WithMessage(err.Error())
+}
+
+// 将Content-MD5等请求头中base64格式的摘要转换为十六进制，请求头为空时返回空字符串
+func decodeS3Digest(val string) (string, error) {
+	if val == "" {
+		return "", nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+func isDataNotFound(err error) bool {
+	var codeMsg *mq.CodeMessageError
+	return errors.As(err, &codeMsg) && codeMsg.Code == errorcode.DataNotFound
+}
@@ -0,0 +1,523 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/client/internal/config"
+)
+
+const (
+	s3SignAlgorithm      = "AWS4-HMAC-SHA256"
+	s3ChunkSignAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	s3TimeFormat         = "20060102T150405Z"
+	s3MaxClockSkew       = 15 * time.Minute
+	// 预签名链接的有效期最长为7天
+	s3MaxPresignExpires = 604800
+
+	s3UnsignedPayload          = "UNSIGNED-PAYLOAD"
+	s3StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	s3StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	s3EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// 一次通过了SigV4验证的请求的信息
+type s3AuthInfo struct {
+	UserID      cdssdk.UserID
+	AmzDate     string
+	Scope       string
+	SigningKey  []byte
+	Signature   string
+	PayloadHash string
+}
+
+type s3Credential struct {
+	AccessKey string
+	Date      string
+	Region    string
+	Service   string
+}
+
+func (c s3Credential) Scope() string {
+	return strings.Join([]string{c.Date, c.Region, c.Service, "aws4_request"}, "/")
+}
+
+// 检查请求的SigV4签名，支持Authorization头和预签名URL两种方式
+func (s *S3Service) authenticate(req *http.Request) (*s3AuthInfo, *s3Error) {
+	if req.URL.Query().Get("X-Amz-Algorithm") != "" {
+		return s.authenticatePresigned(req)
+	}
+
+	authHeader := req.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, errS3AccessDenied
+	}
+
+	if !strings.HasPrefix(authHeader, s3SignAlgorithm+" ") {
+		return nil, errS3AuthHeaderMalformed
+	}
+
+	var cred s3Credential
+	var signedHeaders []string
+	var signature string
+	for _, field := range strings.Split(strings.TrimPrefix(authHeader, s3SignAlgorithm+" "), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, errS3AuthHeaderMalformed
+		}
+
+		switch k {
+		case "Credential":
+			c, err := parseS3Credential(v)
+			if err != nil {
+				return nil, err
+			}
+			cred = c
+		case "SignedHeaders":
+			signedHeaders = strings.Split(v, ";")
+		case "Signature":
+			signature = v
+		}
+	}
+	if cred.AccessKey == "" || len(signedHeaders) == 0 || signature == "" {
+		return nil, errS3AuthHeaderMalformed
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = req.Header.Get("Date")
+	}
+	reqTime, err := time.Parse(s3TimeFormat, amzDate)
+	if err != nil {
+		return nil, errS3AccessDenied
+	}
+	if time.Since(reqTime) > s3MaxClockSkew || time.Until(reqTime) > s3MaxClockSkew {
+		return nil, errS3RequestTimeTooSkewed
+	}
+
+	payloadHash := req.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = s3UnsignedPayload
+	}
+
+	return s.verifySignature(req, cred, amzDate, signedHeaders, payloadHash, signature, false)
+}
+
+func (s *S3Service) authenticatePresigned(req *http.Request) (*s3AuthInfo, *s3Error) {
+	query := req.URL.Query()
+
+	if query.Get("X-Amz-Algorithm") != s3SignAlgorithm {
+		return nil, errS3AuthQueryParamsError.WithMessage("X-Amz-Algorithm only supports \"AWS4-HMAC-SHA256\"")
+	}
+
+	cred, serr := parseS3Credential(query.Get("X-Amz-Credential"))
+	if serr != nil {
+		return nil, serr
+	}
+
+	amzDate := query.Get("X-Amz-Date")
+	reqTime, err := time.Parse(s3TimeFormat, amzDate)
+	if err != nil {
+		return nil, errS3AuthQueryParamsError.WithMessage("X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+	}
+	// 签名时间不能晚于当前时间太多，否则可以签出在很久之后才开始生效的链接
+	if time.Until(reqTime) > s3MaxClockSkew {
+		return nil, errS3AccessDenied.WithMessage("Request is not valid yet")
+	}
+
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 {
+		return nil, errS3AuthQueryParamsError.WithMessage("X-Amz-Expires should be a number")
+	}
+	if expires > s3MaxPresignExpires {
+		return nil, errS3AuthQueryParamsError.WithMessage("X-Amz-Expires must be less than a week (in seconds) that is 604800")
+	}
+	if time.Now().After(reqTime.Add(time.Duration(expires) * time.Second)) {
+		return nil, errS3AccessDenied.WithMessage("Request has expired")
+	}
+
+	signedHeaders := strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	signature := query.Get("X-Amz-Signature")
+	if signature == "" {
+		return nil, errS3AuthQueryParamsError.WithMessage("Query-string authentication requires the X-Amz-Signature parameter")
+	}
+
+	return s.verifySignature(req, cred, amzDate, signedHeaders, s3UnsignedPayload, signature, true)
+}
+
+func (s *S3Service) verifySignature(req *http.Request, cred s3Credential, amzDate string, signedHeaders []string, payloadHash string, signature string, presigned bool) (*s3AuthInfo, *s3Error) {
+	cfg := config.Cfg().S3
+
+	if cred.Service != "s3" || (cfg.Region != "" && cred.Region != cfg.Region) {
+		return nil, errS3AuthHeaderMalformed
+	}
+	if !strings.HasPrefix(amzDate, cred.Date) {
+		return nil, errS3AuthHeaderMalformed
+	}
+
+	var userCred *config.S3Credential
+	for i := range cfg.Credentials {
+		if cfg.Credentials[i].AccessKey == cred.AccessKey {
+			userCred = &cfg.Credentials[i]
+			break
+		}
+	}
+	if userCred == nil {
+		return nil, errS3InvalidAccessKeyID
+	}
+
+	canonicalReq := strings.Join([]string{
+		req.Method,
+		s3URIEncode(req.URL.Path, false),
+		s3CanonicalQuery(req.URL.Query(), presigned),
+		s3CanonicalHeaders(req, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		s3SignAlgorithm,
+		amzDate,
+		cred.Scope(),
+		hexSHA256([]byte(canonicalReq)),
+	}, "\n")
+
+	signingKey := s3SigningKey(userCred.SecretKey, cred)
+	expected := hex.EncodeToString(hmacSHA256(signingKey, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errS3SignatureDoesNotMatch
+	}
+
+	return &s3AuthInfo{
+		UserID:      userCred.UserID,
+		AmzDate:     amzDate,
+		Scope:       cred.Scope(),
+		SigningKey:  signingKey,
+		Signature:   signature,
+		PayloadHash: payloadHash,
+	}, nil
+}
+
+// 根据请求的x-amz-content-sha256包装请求体，在读取的同时校验数据
+func (a *s3AuthInfo) wrapBody(body io.ReadCloser) io.ReadCloser {
+	switch a.PayloadHash {
+	case s3UnsignedPayload:
+		return body
+	case s3StreamingPayload:
+		return newS3ChunkedReader(body, a)
+	case s3StreamingUnsignedTrailer:
+		return newS3ChunkedReader(body, nil)
+	default:
+		return &s3HashingReader{
+			body:     body,
+			hash:     sha256.New(),
+			expected: a.PayloadHash,
+		}
+	}
+}
+
+// 请求体的实际长度，分块上传时需要使用x-amz-decoded-content-length
+func (a *s3AuthInfo) bodySize(req *http.Request) (int64, error) {
+	if a.PayloadHash == s3StreamingPayload || a.PayloadHash == s3StreamingUnsignedTrailer {
+		return strconv.ParseInt(req.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+	}
+
+	if req.ContentLength < 0 {
+		return 0, fmt.Errorf("missing content length")
+	}
+	return req.ContentLength, nil
+}
+
+func parseS3Credential(str string) (s3Credential, *s3Error) {
+	comps := strings.Split(str, "/")
+	if len(comps) != 5 || comps[4] != "aws4_request" {
+		return s3Credential{}, errS3AuthHeaderMalformed
+	}
+
+	return s3Credential{
+		AccessKey: comps[0],
+		Date:      comps[1],
+		Region:    comps[2],
+		Service:   comps[3],
+	}, nil
+}
+
+func s3SigningKey(secretKey string, cred s3Credential) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretKey), []byte(cred.Date))
+	key = hmacSHA256(key, []byte(cred.Region))
+	key = hmacSHA256(key, []byte(cred.Service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func s3CanonicalQuery(query url.Values, presigned bool) string {
+	var keys []string
+	for k := range query {
+		if presigned && k == "X-Amz-Signature" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string{}, query[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func s3CanonicalHeaders(req *http.Request, signedHeaders []string) string {
+	var sb strings.Builder
+	for _, h := range signedHeaders {
+		var vals []string
+		switch h {
+		case "host":
+			vals = []string{req.Host}
+		case "content-length":
+			vals = []string{strconv.FormatInt(req.ContentLength, 10)}
+		default:
+			vals = req.Header.Values(h)
+		}
+
+		for i := range vals {
+			vals[i] = strings.Join(strings.Fields(vals[i]), " ")
+		}
+
+		sb.WriteString(h)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(vals, ","))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// 按SigV4的规则编码，除了未保留字符外都需要编码
+func s3URIEncode(str string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(str); i++ {
+		c := str[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+		} else {
+			sb.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// 读取到结尾时检查数据的SHA256是否与签名时声明的一致
+type s3HashingReader struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (r *s3HashingReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if hex.EncodeToString(r.hash.Sum(nil)) != r.expected {
+			return n, errS3ContentSHA256Mismatch
+		}
+	}
+	return n, err
+}
+
+func (r *s3HashingReader) Close() error {
+	return r.body.Close()
+}
+
+// 解码aws-chunked格式的请求体。auth不为nil时会逐块检查签名
+type s3ChunkedReader struct {
+	body    io.ReadCloser
+	rd      *bufio.Reader
+	auth    *s3AuthInfo
+	prevSig string
+	curSig  string
+	remain  int64
+	hash    hash.Hash
+	err     error
+}
+
+func newS3ChunkedReader(body io.ReadCloser, auth *s3AuthInfo) *s3ChunkedReader {
+	r := &s3ChunkedReader{
+		body: body,
+		rd:   bufio.NewReader(body),
+		auth: auth,
+		hash: sha256.New(),
+	}
+	if auth != nil {
+		r.prevSig = auth.Signature
+	}
+	return r
+}
+
+func (r *s3ChunkedReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.remain == 0 {
+		size, err := r.readChunkHeader()
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+
+		if size == 0 {
+			r.err = r.finish()
+			return 0, r.err
+		}
+
+		r.remain = size
+		r.hash.Reset()
+	}
+
+	if int64(len(p)) > r.remain {
+		p = p[:r.remain]
+	}
+
+	n, err := r.rd.Read(p)
+	r.hash.Write(p[:n])
+	r.remain -= int64(n)
+
+	if err == io.EOF && r.remain > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		r.err = err
+		return n, err
+	}
+
+	if r.remain == 0 {
+		if err := r.readCRLF(); err != nil {
+			r.err = err
+			return n, err
+		}
+
+		if err := r.checkChunkSignature(); err != nil {
+			r.err = err
+			return n, err
+		}
+	}
+
+	return n, nil
+}
+
+func (r *s3ChunkedReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *s3ChunkedReader) readChunkHeader() (int64, error) {
+	line, err := r.rd.ReadString('\n')
+	if err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+
+	sizeStr, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeStr, 16, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid chunk size: %s", sizeStr)
+	}
+
+	r.curSig = strings.TrimPrefix(ext, "chunk-signature=")
+	return size, nil
+}
+
+func (r *s3ChunkedReader) readCRLF() error {
+	var buf [2]byte
+	if _, err := io.ReadFull(r.rd, buf[:]); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if !bytes.Equal(buf[:], []byte("\r\n")) {
+		return fmt.Errorf("malformed chunk")
+	}
+	return nil
+}
+
+// 最后一个空块，需要检查签名，并跳过可能存在的trailer
+func (r *s3ChunkedReader) finish() error {
+	r.hash.Reset()
+	if err := r.checkChunkSignature(); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.rd.ReadString('\n')
+		if err != nil || line == "\r\n" {
+			return io.EOF
+		}
+	}
+}
+
+func (r *s3ChunkedReader) checkChunkSignature() error {
+	if r.auth == nil {
+		return nil
+	}
+
+	stringToSign := strings.Join([]string{
+		s3ChunkSignAlgorithm,
+		r.auth.AmzDate,
+		r.auth.Scope,
+		r.prevSig,
+		s3EmptySHA256,
+		hex.EncodeToString(r.hash.Sum(nil)),
+	}, "\n")
+
+	expected := hex.EncodeToString(hmacSHA256(r.auth.SigningKey, []byte(stringToSign)))
+	if !hmac.Equal([]byte(expected), []byte(r.curSig)) {
+		return errS3SignatureDoesNotMatch
+	}
+
+	r.prevSig = r.curSig
+	return nil
+}
+
+// 检查配置的S3访问密钥：每个密钥都必须完整填写，且AccessKey不能重复
+func checkS3Credentials(creds []config.S3Credential) error {
+	accessKeys := make(map[string]bool)
+	for i, cred := range creds {
+		if cred.AccessKey == "" || cred.SecretKey == "" {
+			return fmt.Errorf("credential %d: access key and secret key must not be empty", i)
+		}
+		if accessKeys[cred.AccessKey] {
+			return fmt.Errorf("credential %d: duplicated access key %s", i, cred.AccessKey)
+		}
+		accessKeys[cred.AccessKey] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,217 @@
+package http
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+// S3的分段上传直接对应到分段上传会话，UploadId即会话ID
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type s3ListPartsResult struct {
+	XMLName     xml.Name `xml:"ListPartsResult"`
+	Xmlns       string   `xml:"xmlns,attr"`
+	Bucket      string   `xml:"Bucket"`
+	Key         string   `xml:"Key"`
+	UploadID    string   `xml:"UploadId"`
+	IsTruncated bool     `xml:"IsTruncated"`
+	Parts       []s3Part `xml:"Part"`
+}
+
+type s3Part struct {
+	PartNumber   int    `xml:"PartNumber"`
+	LastModified string `xml:"LastModified,omitempty"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size,omitempty"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []s3Part `xml:"Part"`
+}
+
+type s3CompleteMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+func (s *S3Service) createMultipartUpload(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	pkgName, objPath := splitS3Key(key)
+	if objPath == "" {
+		return errS3InvalidArgument.WithMessage("key must be in format of <package>/<path>")
+	}
+
+	pkg, serr := s.getOrCreatePackage(auth, bucketName, pkgName)
+	if serr != nil {
+		return serr
+	}
+
+	session, err := s.svc.UploadSessionSvc().Initiate(auth.UserID, pkg.PackageID, objPath, nil)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	ctx.XML(http.StatusOK, s3InitiateMultipartUploadResult{
+		Xmlns:    s3XMLNamespace,
+		Bucket:   bucketName,
+		Key:      key,
+		UploadID: strconv.FormatInt(int64(session.SessionID), 10),
+	})
+	return nil
+}
+
+func (s *S3Service) uploadPart(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	session, _, serr := s.getUploadSession(ctx, auth, bucketName, key)
+	if serr != nil {
+		return serr
+	}
+
+	partNumber, err := strconv.Atoi(ctx.Query("partNumber"))
+	if err != nil || partNumber < 1 {
+		return errS3InvalidArgument.WithMessage("invalid partNumber")
+	}
+
+	size, err := auth.bodySize(ctx.Request)
+	if err != nil {
+		return errS3MissingContentLength
+	}
+
+	body := auth.wrapBody(ctx.Request.Body)
+	part, err := s.svc.UploadSessionSvc().UploadPart(auth.UserID, session.SessionID, partNumber, body, size)
+	if err != nil {
+		return s3UploadError(err)
+	}
+
+	ctx.Header("ETag", s3PartETag(*part))
+	ctx.Status(http.StatusOK)
+	return nil
+}
+
+func (s *S3Service) listParts(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	session, parts, serr := s.getUploadSession(ctx, auth, bucketName, key)
+	if serr != nil {
+		return serr
+	}
+
+	resp := s3ListPartsResult{
+		Xmlns:    s3XMLNamespace,
+		Bucket:   bucketName,
+		Key:      key,
+		UploadID: strconv.FormatInt(int64(session.SessionID), 10),
+	}
+	for _, p := range parts {
+		resp.Parts = append(resp.Parts, s3Part{
+			PartNumber:   p.PartNumber,
+			LastModified: s3FormatTime(p.UploadTime),
+			ETag:         s3PartETag(p),
+			Size:         p.Size,
+		})
+	}
+
+	ctx.XML(http.StatusOK, resp)
+	return nil
+}
+
+func (s *S3Service) completeMultipartUpload(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	session, parts, serr := s.getUploadSession(ctx, auth, bucketName, key)
+	if serr != nil {
+		return serr
+	}
+
+	body := auth.wrapBody(ctx.Request.Body)
+	defer body.Close()
+
+	var req s3CompleteMultipartUpload
+	if err := xml.NewDecoder(body).Decode(&req); err != nil {
+		return errS3MalformedXML
+	}
+
+	// 会话会使用所有已上传的分段，因此请求中列出的分段必须与已上传的分段完全一致
+	if len(req.Parts) != len(parts) {
+		return errS3InvalidPart
+	}
+	for i, p := range req.Parts {
+		if p.PartNumber != parts[i].PartNumber || p.ETag != s3PartETag(parts[i]) {
+			return errS3InvalidPart
+		}
+	}
+
+	obj, err := s.svc.UploadSessionSvc().Complete(auth.UserID, session.SessionID)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	ctx.XML(http.StatusOK, s3CompleteMultipartUploadResult{
+		Xmlns:    s3XMLNamespace,
+		Location: ctx.Request.URL.Path,
+		Bucket:   bucketName,
+		Key:      key,
+		ETag:     objectETag(*obj),
+	})
+	return nil
+}
+
+func (s *S3Service) abortMultipartUpload(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) *s3Error {
+	session, _, serr := s.getUploadSession(ctx, auth, bucketName, key)
+	if serr != nil {
+		return serr
+	}
+
+	err := s.svc.UploadSessionSvc().Abort(auth.UserID, session.SessionID)
+	if err != nil {
+		return errS3InternalError.WithMessage(err.Error())
+	}
+
+	ctx.Status(http.StatusNoContent)
+	return nil
+}
+
+// 根据uploadId获取会话，并检查会话是否属于请求的Key
+func (s *S3Service) getUploadSession(ctx *gin.Context, auth *s3AuthInfo, bucketName string, key string) (*model.UploadSession, []model.UploadSessionPart, *s3Error) {
+	id, err := strconv.ParseInt(ctx.Query("uploadId"), 10, 64)
+	if err != nil {
+		return nil, nil, errS3NoSuchUpload
+	}
+
+	session, parts, err := s.svc.UploadSessionSvc().ListParts(auth.UserID, model.UploadSessionID(id))
+	if err != nil {
+		if isDataNotFound(err) {
+			return nil, nil, errS3NoSuchUpload
+		}
+		return nil, nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	pkgName, objPath := splitS3Key(key)
+	pkg, err := s.svc.PackageSvc().GetByName(auth.UserID, bucketName, pkgName)
+	if err != nil {
+		if isDataNotFound(err) {
+			return nil, nil, errS3NoSuchUpload
+		}
+		return nil, nil, errS3InternalError.WithMessage(err.Error())
+	}
+
+	if session.PackageID != pkg.PackageID || session.Path != objPath {
+		return nil, nil, errS3NoSuchUpload
+	}
+
+	return session, parts, nil
+}
+
+func s3PartETag(part model.UploadSessionPart) string {
+	return "\"" + part.FileHash + "\""
+}
@@ -0,0 +1,282 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+const (
+	ScannerListJobsPath  = "/scanner/jobs"
+	ScannerRetryJobPath  = "/scanner/jobs/retry"
+	ScannerCancelJobPath = "/scanner/jobs/cancel"
+
+	ScannerPreviewRedundancyPath     = "/scanner/redundancy/preview"
+	ScannerGetRedundancyPlanPath     = "/scanner/redundancy/plan"
+	ScannerListRedundancyPlansPath   = "/scanner/redundancy/plans"
+	ScannerApproveRedundancyPlanPath = "/scanner/redundancy/approve"
+)
+
+type ScannerService struct {
+	*Server
+}
+
+func (s *Server) Scanner() *ScannerService {
+	return &ScannerService{
+		Server: s,
+	}
+}
+
+type ScannerListJobsReq struct {
+	State  string `form:"state"`
+	Type   string `form:"type"`
+	Offset int    `form:"offset"`
+	Limit  int    `form:"limit"`
+}
+type ScannerListJobsResp struct {
+	Jobs []model.ScannerJob `json:"jobs"`
+}
+
+func (s *ScannerService) ListJobs(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.ListJobs")
+
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	var req ScannerListJobsReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	jobs, err := s.svc.ScannerSvc().ListJobs(req.State, req.Type, req.Offset, req.Limit)
+	if err != nil {
+		log.Warnf("listing jobs: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list jobs failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerListJobsResp{Jobs: jobs}))
+}
+
+type ScannerRetryJobReq struct {
+	JobID *model.ScannerJobID `json:"jobID" binding:"required"`
+}
+type ScannerRetryJobResp struct{}
+
+func (s *ScannerService) RetryJob(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.RetryJob")
+
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	var req ScannerRetryJobReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.ScannerSvc().RetryJob(*req.JobID)
+	if err != nil {
+		log.Warnf("retrying job: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "retry job failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerRetryJobResp{}))
+}
+
+type ScannerCancelJobReq struct {
+	JobID *model.ScannerJobID `json:"jobID" binding:"required"`
+}
+type ScannerCancelJobResp struct{}
+
+func (s *ScannerService) CancelJob(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.CancelJob")
+
+	if !requireAdmin(ctx) {
+		return
+	}
+
+	var req ScannerCancelJobReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.ScannerSvc().CancelJob(*req.JobID)
+	if err != nil {
+		log.Warnf("canceling job: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "cancel job failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerCancelJobResp{}))
+}
+
+type ScannerPreviewRedundancyReq struct {
+	PackageID *cdssdk.PackageID `json:"packageID" binding:"required"`
+	Save      bool              `json:"save"`
+}
+type ScannerPreviewRedundancyResp struct {
+	Plan model.RedundancyPlan `json:"plan"`
+}
+
+// 预览Package的冗余策略修改计划，save为true时保存计划，之后可以批准执行
+func (s *ScannerService) PreviewRedundancy(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.PreviewRedundancy")
+
+	var req ScannerPreviewRedundancyReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	if !s.checkPackageAccess(ctx, *req.PackageID) {
+		return
+	}
+
+	plan, err := s.svc.ScannerSvc().PreviewPackageRedundancy(*req.PackageID, req.Save)
+	if err != nil {
+		log.Warnf("previewing package redundancy: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "preview package redundancy failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerPreviewRedundancyResp{Plan: plan}))
+}
+
+type ScannerGetRedundancyPlanReq struct {
+	PlanID *model.RedundancyPlanID `form:"planID" binding:"required"`
+}
+type ScannerGetRedundancyPlanResp struct {
+	Plan model.RedundancyPlan `json:"plan"`
+}
+
+func (s *ScannerService) GetRedundancyPlan(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.GetRedundancyPlan")
+
+	var req ScannerGetRedundancyPlanReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	plan, err := s.svc.ScannerSvc().GetRedundancyPlan(*req.PlanID)
+	if err != nil {
+		log.Warnf("getting plan: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get plan failed"))
+		return
+	}
+
+	if !s.checkPackageAccess(ctx, plan.PackageID) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerGetRedundancyPlanResp{Plan: plan}))
+}
+
+type ScannerListRedundancyPlansReq struct {
+	PackageID cdssdk.PackageID `form:"packageID"` // 只有管理员可以不指定PackageID
+	State     string           `form:"state"`
+	Offset    int              `form:"offset"`
+	Limit     int              `form:"limit"`
+}
+type ScannerListRedundancyPlansResp struct {
+	Plans []model.RedundancyPlan `json:"plans"`
+}
+
+func (s *ScannerService) ListRedundancyPlans(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.ListRedundancyPlans")
+
+	var req ScannerListRedundancyPlansReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	if req.PackageID == 0 {
+		if !requireAdmin(ctx) {
+			return
+		}
+	} else if !s.checkPackageAccess(ctx, req.PackageID) {
+		return
+	}
+
+	plans, err := s.svc.ScannerSvc().ListRedundancyPlans(req.PackageID, req.State, req.Offset, req.Limit)
+	if err != nil {
+		log.Warnf("listing plans: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list plans failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerListRedundancyPlansResp{Plans: plans}))
+}
+
+type ScannerApproveRedundancyPlanReq struct {
+	PlanID *model.RedundancyPlanID `json:"planID" binding:"required"`
+}
+type ScannerApproveRedundancyPlanResp struct {
+	JobID model.ScannerJobID `json:"jobID"`
+}
+
+func (s *ScannerService) ApproveRedundancyPlan(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "Scanner.ApproveRedundancyPlan")
+
+	var req ScannerApproveRedundancyPlanReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	plan, err := s.svc.ScannerSvc().GetRedundancyPlan(*req.PlanID)
+	if err != nil {
+		log.Warnf("getting plan: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get plan failed"))
+		return
+	}
+
+	if !s.checkPackageAccess(ctx, plan.PackageID) {
+		return
+	}
+
+	jobID, err := s.svc.ScannerSvc().ApproveRedundancyPlan(*req.PlanID)
+	if err != nil {
+		log.Warnf("approving plan: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "approve plan failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(ScannerApproveRedundancyPlanResp{JobID: jobID}))
+}
+
+// 修改冗余策略会移动大量数据，只有管理员和Package的所有者可以操作。没有权限时返回403，并且返回false
+func (s *ScannerService) checkPackageAccess(ctx *gin.Context, packageID cdssdk.PackageID) bool {
+	if isAuthAdmin(ctx) {
+		return true
+	}
+
+	_, err := s.svc.PackageSvc().Get(getAuthUserID(ctx), packageID)
+	if err != nil {
+		logger.WithField("HTTP", "Scanner").
+			WithField("PackageID", packageID).
+			Debugf("checking package access: %s", err.Error())
+		ctx.JSON(http.StatusForbidden, Failed(errorcode.OperationFailed, "no permission to access the package"))
+		return false
+	}
+
+	return true
+}
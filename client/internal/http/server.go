@@ -1,25 +1,49 @@
 package http
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/client/internal/config"
 	"gitlink.org.cn/cloudream/storage/client/internal/services"
 )
 
 type Server struct {
-	engine     *gin.Engine
-	listenAddr string
-	svc        *services.Service
+	engine      *gin.Engine
+	listenAddr  string
+	svc         *services.Service
+	authSecret  []byte
+	tokenExpire time.Duration
 }
 
 func NewServer(listenAddr string, svc *services.Service) (*Server, error) {
 	engine := gin.New()
 
+	authCfg := &config.Cfg().Auth
+	secret, err := loadAuthSecret(authCfg)
+	if err != nil {
+		return nil, fmt.Errorf("loading auth secret: %w", err)
+	}
+
+	err = checkS3Credentials(config.Cfg().S3.Credentials)
+	if err != nil {
+		return nil, fmt.Errorf("checking s3 credentials: %w", err)
+	}
+
+	tokenExpire := defaultTokenExpire
+	if authCfg.TokenExpireSeconds > 0 {
+		tokenExpire = time.Duration(authCfg.TokenExpireSeconds) * time.Second
+	}
+
 	return &Server{
-		engine:     engine,
-		listenAddr: listenAddr,
-		svc:        svc,
+		engine:      engine,
+		listenAddr:  listenAddr,
+		svc:         svc,
+		authSecret:  secret,
+		tokenExpire: tokenExpire,
 	}, nil
 }
 
@@ -39,13 +63,16 @@ func (s *Server) Serve() error {
 }
 
 func (s *Server) initRouters() {
-	rt := s.engine.Use()
+	rt := s.engine.Use(s.authMiddleware)
+
+	rt.POST(AuthLoginPath, s.Auth().Login)
 
 	initTemp(rt, s)
 
 	rt.GET(cdssdk.ObjectDownloadPath, s.Object().Download)
 	rt.POST(cdssdk.ObjectUploadPath, s.Object().Upload)
 	rt.GET(cdssdk.ObjectGetPackageObjectsPath, s.Object().GetPackageObjects)
+	rt.GET(ObjectListPath, s.Object().List)
 	rt.POST(cdssdk.ObjectUpdateInfoPath, s.Object().UpdateInfo)
 	rt.POST(cdssdk.ObjectMovePath, s.Object().Move)
 	rt.POST(cdssdk.ObjectDeletePath, s.Object().Delete)
@@ -57,6 +84,10 @@ func (s *Server) initRouters() {
 	rt.GET(cdssdk.PackageListBucketPackagesPath, s.Package().ListBucketPackages)
 	rt.GET(cdssdk.PackageGetCachedNodesPath, s.Package().GetCachedNodes)
 	rt.GET(cdssdk.PackageGetLoadedNodesPath, s.Package().GetLoadedNodes)
+	rt.POST(PackageSnapshotPath, s.Package().Snapshot)
+	rt.POST(PackageClonePath, s.Package().Clone)
+
+	rt.GET(NodeGetScoresPath, s.NodeSvc().GetScores)
 
 	rt.POST(cdssdk.StorageLoadPackagePath, s.Storage().LoadPackage)
 	rt.POST(cdssdk.StorageCreatePackagePath, s.Storage().CreatePackage)
@@ -68,4 +99,38 @@ func (s *Server) initRouters() {
 	rt.POST(cdssdk.BucketCreatePath, s.Bucket().Create)
 	rt.POST(cdssdk.BucketDeletePath, s.Bucket().Delete)
 	rt.GET(cdssdk.BucketListUserBucketsPath, s.Bucket().ListUserBuckets)
+
+	rt.POST(BucketAddLifecycleRulePath, s.Lifecycle().AddRule)
+	rt.GET(BucketListLifecycleRulesPath, s.Lifecycle().ListRules)
+	rt.POST(BucketDeleteLifecycleRulePath, s.Lifecycle().DeleteRule)
+
+	rt.POST(PackageSetVersioningPath, s.ObjectVersion().SetPackageVersioning)
+	rt.GET(ObjectListVersionsPath, s.ObjectVersion().ListVersions)
+	rt.GET(ObjectDownloadVersionPath, s.ObjectVersion().DownloadVersion)
+	rt.POST(ObjectRestoreVersionPath, s.ObjectVersion().RestoreVersion)
+
+	rt.GET(QuotaGetUserPath, s.Quota().GetUser)
+	rt.GET(QuotaGetBucketPath, s.Quota().GetBucket)
+	rt.POST(QuotaSetUserPath, s.Quota().SetUser)
+	rt.POST(QuotaSetBucketPath, s.Quota().SetBucket)
+
+	rt.POST(UploadSessionInitiatePath, s.UploadSession().Initiate)
+	rt.POST(UploadSessionUploadPartPath, s.UploadSession().UploadPart)
+	rt.GET(UploadSessionListPartsPath, s.UploadSession().ListParts)
+	rt.POST(UploadSessionCompletePath, s.UploadSession().Complete)
+	rt.POST(UploadSessionAbortPath, s.UploadSession().Abort)
+
+	rt.GET(ScannerListJobsPath, s.Scanner().ListJobs)
+	rt.POST(ScannerRetryJobPath, s.Scanner().RetryJob)
+	rt.POST(ScannerCancelJobPath, s.Scanner().CancelJob)
+	rt.POST(ScannerPreviewRedundancyPath, s.Scanner().PreviewRedundancy)
+	rt.GET(ScannerGetRedundancyPlanPath, s.Scanner().GetRedundancyPlan)
+	rt.GET(ScannerListRedundancyPlansPath, s.Scanner().ListRedundancyPlans)
+	rt.POST(ScannerApproveRedundancyPlanPath, s.Scanner().ApproveRedundancyPlan)
+
+	if len(config.Cfg().S3.Credentials) > 0 {
+		rt.Any(S3PathPrefix+"/*path", s.S3().Dispatch)
+	} else {
+		logger.Infof("no s3 credentials configured, s3 gateway is disabled")
+	}
 }
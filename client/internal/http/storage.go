@@ -31,7 +31,7 @@ func (s *StorageService) LoadPackage(ctx *gin.Context) {
 		return
 	}
 
-	nodeID, taskID, err := s.svc.StorageSvc().StartStorageLoadPackage(req.UserID, req.PackageID, req.StorageID)
+	nodeID, taskID, err := s.svc.StorageSvc().StartStorageLoadPackage(getAuthUserID(ctx), req.PackageID, req.StorageID)
 	if err != nil {
 		log.Warnf("start storage load package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "storage load package failed"))
@@ -75,7 +75,7 @@ func (s *StorageService) CreatePackage(ctx *gin.Context) {
 	}
 
 	nodeID, taskID, err := s.svc.StorageSvc().StartStorageCreatePackage(
-		req.UserID, req.BucketID, req.Name, req.StorageID, req.Path, req.NodeAffinity)
+		getAuthUserID(ctx), req.BucketID, req.Name, req.StorageID, req.Path, req.NodeAffinity)
 	if err != nil {
 		log.Warnf("start storage create package: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "storage create package failed"))
@@ -115,7 +115,7 @@ func (s *StorageService) Get(ctx *gin.Context) {
 		return
 	}
 
-	info, err := s.svc.StorageSvc().Get(req.UserID, req.StorageID)
+	info, err := s.svc.StorageSvc().Get(getAuthUserID(ctx), req.StorageID)
 	if err != nil {
 		log.Warnf("getting info: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get storage inf failed"))
@@ -32,7 +32,9 @@ type BucketDetail struct {
 func (s *TempService) ListDetails(ctx *gin.Context) {
 	log := logger.WithField("HTTP", "Bucket.ListBucketsDetails")
 
-	bkts, err := s.svc.BucketSvc().GetUserBuckets(1)
+	userID := getAuthUserID(ctx)
+
+	bkts, err := s.svc.BucketSvc().GetUserBuckets(userID)
 	if err != nil {
 		log.Warnf("getting user buckets: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get user buckets failed"))
@@ -43,7 +45,7 @@ func (s *TempService) ListDetails(ctx *gin.Context) {
 	for i := range bkts {
 		details[i].BucketID = bkts[i].BucketID
 		details[i].Name = bkts[i].Name
-		objs, err := s.getBucketObjects(bkts[i].BucketID)
+		objs, err := s.getBucketObjects(userID, bkts[i].BucketID)
 		if err != nil {
 			log.Warnf("getting bucket objects: %s", err.Error())
 			ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get bucket objects failed"))
@@ -74,7 +76,7 @@ func (s *TempService) GetObjects(ctx *gin.Context) {
 		return
 	}
 
-	objs, err := s.getBucketObjects(req.BucketID)
+	objs, err := s.getBucketObjects(getAuthUserID(ctx), req.BucketID)
 	if err != nil {
 		log.Warnf("getting bucket objects: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get bucket objects failed"))
@@ -110,7 +112,7 @@ func (s *TempService) GetObjectDetail(ctx *gin.Context) {
 		return
 	}
 
-	details, err := s.svc.ObjectSvc().GetObjectDetail(req.ObjectID)
+	details, err := s.svc.ObjectSvc().GetObjectDetail(getAuthUserID(ctx), req.ObjectID)
 	if err != nil {
 		log.Warnf("getting object detail: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get object detail failed"))
@@ -122,7 +124,7 @@ func (s *TempService) GetObjectDetail(ctx *gin.Context) {
 		return
 	}
 
-	loadedNodeIDs, err := s.svc.PackageSvc().GetLoadedNodes(1, details.Object.PackageID)
+	loadedNodeIDs, err := s.svc.PackageSvc().GetLoadedNodes(getAuthUserID(ctx), details.Object.PackageID)
 	if err != nil {
 		log.Warnf("getting loaded nodes: %s", err.Error())
 		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get loaded nodes failed"))
@@ -211,15 +213,15 @@ func (s *TempService) GetObjectDetail(ctx *gin.Context) {
 	}))
 }
 
-func (s *TempService) getBucketObjects(bktID cdssdk.BucketID) ([]cdssdk.Object, error) {
-	pkgs, err := s.svc.PackageSvc().GetBucketPackages(1, bktID)
+func (s *TempService) getBucketObjects(userID cdssdk.UserID, bktID cdssdk.BucketID) ([]cdssdk.Object, error) {
+	pkgs, err := s.svc.PackageSvc().GetBucketPackages(userID, bktID)
 	if err != nil {
 		return nil, err
 	}
 
 	var allObjs []cdssdk.Object
 	for _, pkg := range pkgs {
-		objs, err := s.svc.ObjectSvc().GetPackageObjects(1, pkg.PackageID)
+		objs, err := s.svc.ObjectSvc().GetPackageObjects(userID, pkg.PackageID)
 		if err != nil {
 			return nil, err
 		}
@@ -282,7 +284,7 @@ func (s *TempService) GetDatabaseAll(ctx *gin.Context) {
 			Loaded:  make([]cdssdk.Node, 0),
 		}
 
-		loaded, err := s.svc.PackageSvc().GetLoadedNodes(1, pkg.PackageID)
+		loaded, err := s.svc.PackageSvc().GetLoadedNodes(getAuthUserID(ctx), pkg.PackageID)
 		if err != nil {
 			log.Warnf("getting loaded nodes: %s", err.Error())
 			ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "get loaded nodes failed"))
@@ -382,10 +384,3 @@ func initTemp(rt gin.IRoutes, s *Server) {
 	rt.GET("/object/getDetail", s.Temp().GetObjectDetail)
 	rt.GET("/temp/getDatabaseAll", s.Temp().GetDatabaseAll)
 }
-
-func auth(ctx *gin.Context) {
-	token := ctx.Request.Header.Get("X-CDS-Auth")
-	if token != "cloudream@123" {
-		ctx.AbortWithStatus(http.StatusUnauthorized)
-	}
-}
@@ -0,0 +1,171 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+const (
+	UploadSessionInitiatePath   = "/uploadSession/initiate"
+	UploadSessionUploadPartPath = "/uploadSession/uploadPart"
+	UploadSessionListPartsPath  = "/uploadSession/listParts"
+	UploadSessionCompletePath   = "/uploadSession/complete"
+	UploadSessionAbortPath      = "/uploadSession/abort"
+)
+
+type UploadSessionService struct {
+	*Server
+}
+
+func (s *Server) UploadSession() *UploadSessionService {
+	return &UploadSessionService{
+		Server: s,
+	}
+}
+
+type UploadSessionInitiateReq struct {
+	PackageID    *cdssdk.PackageID `json:"packageID" binding:"required"`
+	Path         string            `json:"path" binding:"required"`
+	NodeAffinity *cdssdk.NodeID    `json:"nodeAffinity"`
+}
+type UploadSessionInitiateResp struct {
+	Session model.UploadSession `json:"session"`
+}
+
+func (s *UploadSessionService) Initiate(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "UploadSession.Initiate")
+
+	var req UploadSessionInitiateReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	session, err := s.svc.UploadSessionSvc().Initiate(getAuthUserID(ctx), *req.PackageID, req.Path, req.NodeAffinity)
+	if err != nil {
+		log.Warnf("initiating upload session: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "initiate upload session failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(UploadSessionInitiateResp{Session: *session}))
+}
+
+// 分段的数据直接放在请求体中，必须设置Content-Length
+type UploadSessionUploadPartReq struct {
+	SessionID  *model.UploadSessionID `form:"sessionID" binding:"required"`
+	PartNumber int                    `form:"partNumber" binding:"required,min=1"`
+}
+type UploadSessionUploadPartResp struct {
+	Part model.UploadSessionPart `json:"part"`
+}
+
+func (s *UploadSessionService) UploadPart(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "UploadSession.UploadPart")
+
+	var req UploadSessionUploadPartReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	if ctx.Request.ContentLength < 0 {
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing content length"))
+		return
+	}
+
+	part, err := s.svc.UploadSessionSvc().UploadPart(getAuthUserID(ctx), *req.SessionID, req.PartNumber, ctx.Request.Body, ctx.Request.ContentLength)
+	if err != nil {
+		log.Warnf("uploading part: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "upload part failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(UploadSessionUploadPartResp{Part: *part}))
+}
+
+type UploadSessionListPartsReq struct {
+	SessionID *model.UploadSessionID `form:"sessionID" binding:"required"`
+}
+type UploadSessionListPartsResp struct {
+	Session model.UploadSession       `json:"session"`
+	Parts   []model.UploadSessionPart `json:"parts"`
+}
+
+func (s *UploadSessionService) ListParts(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "UploadSession.ListParts")
+
+	var req UploadSessionListPartsReq
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		log.Warnf("binding query: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	session, parts, err := s.svc.UploadSessionSvc().ListParts(getAuthUserID(ctx), *req.SessionID)
+	if err != nil {
+		log.Warnf("listing parts: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "list parts failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(UploadSessionListPartsResp{Session: *session, Parts: parts}))
+}
+
+type UploadSessionCompleteReq struct {
+	SessionID *model.UploadSessionID `json:"sessionID" binding:"required"`
+}
+type UploadSessionCompleteResp struct {
+	Object cdssdk.Object `json:"object"`
+}
+
+func (s *UploadSessionService) Complete(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "UploadSession.Complete")
+
+	var req UploadSessionCompleteReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	obj, err := s.svc.UploadSessionSvc().Complete(getAuthUserID(ctx), *req.SessionID)
+	if err != nil {
+		log.Warnf("completing upload session: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "complete upload session failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(UploadSessionCompleteResp{Object: *obj}))
+}
+
+type UploadSessionAbortReq struct {
+	SessionID *model.UploadSessionID `json:"sessionID" binding:"required"`
+}
+
+func (s *UploadSessionService) Abort(ctx *gin.Context) {
+	log := logger.WithField("HTTP", "UploadSession.Abort")
+
+	var req UploadSessionAbortReq
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		log.Warnf("binding body: %s", err.Error())
+		ctx.JSON(http.StatusBadRequest, Failed(errorcode.BadArgument, "missing argument or invalid argument"))
+		return
+	}
+
+	err := s.svc.UploadSessionSvc().Abort(getAuthUserID(ctx), *req.SessionID)
+	if err != nil {
+		log.Warnf("aborting upload session: %s", err.Error())
+		ctx.JSON(http.StatusOK, Failed(errorcode.OperationFailed, "abort upload session failed"))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, OK(nil))
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"fmt"
 
+	"github.com/samber/lo"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
@@ -38,6 +39,16 @@ func (svc *BucketService) GetBucketByName(userID cdssdk.UserID, bucketName strin
 }
 
 func (svc *BucketService) GetUserBuckets(userID cdssdk.UserID) ([]model.Bucket, error) {
+	details, err := svc.GetUserBucketDetails(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(details, func(d model.BucketDetail, idx int) model.Bucket { return d.Bucket }), nil
+}
+
+// 与GetUserBuckets相同，但同时返回桶的创建时间
+func (svc *BucketService) GetUserBucketDetails(userID cdssdk.UserID) ([]model.BucketDetail, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return nil, fmt.Errorf("new coordinator client: %w", err)
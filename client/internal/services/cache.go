@@ -57,14 +57,14 @@ func (svc *CacheService) WaitCacheMovePackage(nodeID cdssdk.NodeID, taskID strin
 	return true, nil
 }
 
-func (svc *CacheService) CacheRemovePackage(packageID cdssdk.PackageID, nodeID cdssdk.NodeID) error {
+func (svc *CacheService) CacheRemovePackage(userID cdssdk.UserID, packageID cdssdk.PackageID, nodeID cdssdk.NodeID) error {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return fmt.Errorf("new agent client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	_, err = coorCli.CacheRemovePackage(coormq.ReqCacheRemoveMovedPackage(packageID, nodeID))
+	_, err = coorCli.CacheRemovePackage(coormq.ReqCacheRemoveMovedPackage(userID, packageID, nodeID))
 	if err != nil {
 		return fmt.Errorf("requesting to coordinator: %w", err)
 	}
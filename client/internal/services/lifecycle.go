@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+type LifecycleService struct {
+	*Service
+}
+
+func (svc *Service) LifecycleSvc() *LifecycleService {
+	return &LifecycleService{Service: svc}
+}
+
+func (svc *LifecycleService) AddRule(userID cdssdk.UserID, rule model.LifecycleRule) (model.LifecycleRuleID, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return 0, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.AddLifecycleRule(coormq.ReqAddLifecycleRule(userID, rule))
+	if err != nil {
+		return 0, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.RuleID, nil
+}
+
+func (svc *LifecycleService) GetBucketRules(userID cdssdk.UserID, bucketID cdssdk.BucketID) ([]model.LifecycleRule, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.GetBucketLifecycleRules(coormq.ReqGetBucketLifecycleRules(userID, bucketID))
+	if err != nil {
+		return nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Rules, nil
+}
+
+func (svc *LifecycleService) DeleteRule(userID cdssdk.UserID, ruleID model.LifecycleRuleID) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.DeleteLifecycleRule(coormq.ReqDeleteLifecycleRule(userID, ruleID))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
 	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
 )
 
@@ -30,3 +31,19 @@ func (svc *NodeService) GetNodes(nodeIDs []cdssdk.NodeID) ([]cdssdk.Node, error)
 
 	return getResp.Nodes, nil
 }
+
+// 获取用户可用节点的评分，评分越低，上传下载时越优先选择
+func (svc *NodeService) GetUserNodeScores(userID cdssdk.UserID) ([]cdssdk.Node, []connectivity.NodeScore, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getResp, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(userID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return getResp.Nodes, svc.Connectivity.ScoreNodes(getResp.Nodes), nil
+}
@@ -22,8 +22,9 @@ func (svc *Service) ObjectSvc() *ObjectService {
 	return &ObjectService{Service: svc}
 }
 
-func (svc *ObjectService) StartUploading(userID cdssdk.UserID, packageID cdssdk.PackageID, objIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID) (string, error) {
-	tsk := svc.TaskMgr.StartNew(mytask.NewUploadObjects(userID, packageID, objIter, nodeAffinity))
+// redundancy为nil时使用NoneRedundancy上传
+func (svc *ObjectService) StartUploading(userID cdssdk.UserID, packageID cdssdk.PackageID, objIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID, redundancy cdssdk.Redundancy) (string, error) {
+	tsk := svc.TaskMgr.StartNew(mytask.NewUploadObjects(userID, packageID, objIter, nodeAffinity, redundancy))
 	return tsk.ID(), nil
 }
 
@@ -36,14 +37,14 @@ func (svc *ObjectService) WaitUploading(taskID string, waitTimeout time.Duration
 	return false, nil, nil
 }
 
-func (svc *ObjectService) UpdateInfo(userID cdssdk.UserID, updatings []cdssdk.UpdatingObject) ([]cdssdk.ObjectID, error) {
+func (svc *ObjectService) UpdateInfo(userID cdssdk.UserID, updatings []cdssdk.UpdatingObject, metadatas []coormq.UpdatingObjectMetadata) ([]cdssdk.ObjectID, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return nil, fmt.Errorf("new coordinator client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	resp, err := coorCli.UpdateObjectInfos(coormq.ReqUpdateObjectInfos(userID, updatings))
+	resp, err := coorCli.UpdateObjectInfos(coormq.ReqUpdateObjectInfos(userID, updatings, metadatas))
 	if err != nil {
 		return nil, fmt.Errorf("requsting to coodinator: %w", err)
 	}
@@ -67,16 +68,15 @@ func (svc *ObjectService) Move(userID cdssdk.UserID, movings []cdssdk.MovingObje
 }
 
 func (svc *ObjectService) Download(userID cdssdk.UserID, req downloader.DownloadReqeust) (*downloader.Downloading, error) {
-	// TODO 检查用户ID
-	iter := svc.Downloader.DownloadObjects([]downloader.DownloadReqeust{req})
+	iter := svc.Downloader.DownloadUserObjects(userID, []downloader.DownloadReqeust{req})
 
 	downloading, err := iter.MoveNext()
-	if downloading.Object == nil {
-		return nil, fmt.Errorf("object not found")
-	}
 	if err != nil {
 		return nil, err
 	}
+	if downloading.Object == nil {
+		return nil, fmt.Errorf("object not found")
+	}
 
 	return downloading, nil
 }
@@ -97,31 +97,73 @@ func (svc *ObjectService) Delete(userID cdssdk.UserID, objectIDs []cdssdk.Object
 }
 
 func (svc *ObjectService) GetPackageObjects(userID cdssdk.UserID, packageID cdssdk.PackageID) ([]model.Object, error) {
+	objs, _, err := svc.GetPackageObjectsWithMetadatas(userID, packageID)
+	return objs, err
+}
+
+func (svc *ObjectService) List(userID cdssdk.UserID, packageID cdssdk.PackageID, opt coormq.ListObjectsOption) (*coormq.ListObjectsResp, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return nil, fmt.Errorf("new coordinator client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	getResp, err := coorCli.GetPackageObjects(coormq.NewGetPackageObjects(userID, packageID))
+	resp, err := coorCli.ListObjects(coormq.ReqListObjects(userID, packageID, opt))
 	if err != nil {
 		return nil, fmt.Errorf("requsting to coodinator: %w", err)
 	}
 
-	return getResp.Objects, nil
+	return resp, nil
 }
 
-func (svc *ObjectService) GetObjectDetail(objectID cdssdk.ObjectID) (*stgmod.ObjectDetail, error) {
+// 同时返回Package中设置了元数据的对象的元数据
+func (svc *ObjectService) GetPackageObjectsWithMetadatas(userID cdssdk.UserID, packageID cdssdk.PackageID) ([]model.Object, []model.ObjectMetadata, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getResp, err := coorCli.GetPackageObjects(coormq.NewGetPackageObjects(userID, packageID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return getResp.Objects, getResp.Metadatas, nil
+}
+
+// 获取对象的详细信息，对象不存在或者用户无权访问时返回nil
+func (svc *ObjectService) GetObjectDetail(userID cdssdk.UserID, objectID cdssdk.ObjectID) (*stgmod.ObjectDetail, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return nil, fmt.Errorf("new coordinator client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	getResp, err := coorCli.GetObjectDetails(coormq.ReqGetObjectDetails([]cdssdk.ObjectID{objectID}))
+	getResp, err := coorCli.GetObjectDetails(coormq.ReqGetUserObjectDetails(userID, []cdssdk.ObjectID{objectID}))
 	if err != nil {
 		return nil, fmt.Errorf("requsting to coodinator: %w", err)
 	}
 
 	return getResp.Objects[0], nil
 }
+
+// 查询对象上传时计算的校验和，没有记录校验和的对象不会出现在结果中
+func (svc *ObjectService) GetObjectChecksums(userID cdssdk.UserID, objectIDs []cdssdk.ObjectID) (map[cdssdk.ObjectID]model.ObjectChecksum, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getResp, err := coorCli.GetObjectChecksums(coormq.ReqGetObjectChecksums(userID, objectIDs))
+	if err != nil {
+		return nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	ret := make(map[cdssdk.ObjectID]model.ObjectChecksum)
+	for _, c := range getResp.Checksums {
+		ret[c.ObjectID] = c
+	}
+	return ret, nil
+}
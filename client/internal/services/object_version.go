@@ -0,0 +1,72 @@
+package services
+
+import (
+	"fmt"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/downloader"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+type ObjectVersionService struct {
+	*Service
+}
+
+func (svc *Service) ObjectVersionSvc() *ObjectVersionService {
+	return &ObjectVersionService{Service: svc}
+}
+
+func (svc *ObjectVersionService) SetPackageVersioning(userID cdssdk.UserID, packageID cdssdk.PackageID, enabled bool) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.SetPackageVersioning(coormq.ReqSetPackageVersioning(userID, packageID, enabled))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *ObjectVersionService) ListVersions(userID cdssdk.UserID, packageID cdssdk.PackageID, path string) ([]model.ObjectVersion, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.GetObjectVersions(coormq.ReqGetObjectVersions(userID, packageID, path))
+	if err != nil {
+		return nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Versions, nil
+}
+
+func (svc *ObjectVersionService) Download(userID cdssdk.UserID, versionID model.ObjectVersionID, offset int64, length int64) (*downloader.Downloading, error) {
+	return svc.ObjectSvc().Download(userID, downloader.DownloadReqeust{
+		VersionID: versionID,
+		Offset:    offset,
+		Length:    length,
+	})
+}
+
+func (svc *ObjectVersionService) Restore(userID cdssdk.UserID, versionID model.ObjectVersionID) (*cdssdk.Object, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.RestoreObjectVersion(coormq.ReqRestoreObjectVersion(userID, versionID))
+	if err != nil {
+		return nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return &resp.Object, nil
+}
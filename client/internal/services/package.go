@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"time"
 
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 
@@ -79,8 +80,51 @@ func (svc *PackageService) Create(userID cdssdk.UserID, bucketID cdssdk.BucketID
 	return resp.Package, nil
 }
 
+// 在同一个Bucket中创建Package的只读快照，快照的名称由原Package的名称加上创建时间组成
+func (svc *PackageService) Snapshot(userID cdssdk.UserID, packageID cdssdk.PackageID) (cdssdk.Package, error) {
+	pkg, err := svc.Get(userID, packageID)
+	if err != nil {
+		return cdssdk.Package{}, err
+	}
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return cdssdk.Package{}, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	name := fmt.Sprintf("%s@%s", pkg.Name, time.Now().Format("20060102150405"))
+	resp, err := coorCli.ClonePackage(coormq.ReqSnapshotPackage(userID, packageID, pkg.BucketID, name))
+	if err != nil {
+		return cdssdk.Package{}, fmt.Errorf("creating snapshot: %w", err)
+	}
+
+	return resp.Package, nil
+}
+
+// 将Package复制到指定的Bucket中，只复制元数据，新Package与原Package共用同样的文件
+func (svc *PackageService) Clone(userID cdssdk.UserID, packageID cdssdk.PackageID, targetBucketID cdssdk.BucketID, name string) (cdssdk.Package, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return cdssdk.Package{}, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.ClonePackage(coormq.ReqClonePackage(userID, packageID, targetBucketID, name))
+	if err != nil {
+		return cdssdk.Package{}, fmt.Errorf("cloning package: %w", err)
+	}
+
+	return resp.Package, nil
+}
+
 func (svc *PackageService) DownloadPackage(userID cdssdk.UserID, packageID cdssdk.PackageID) (downloader.DownloadIterator, error) {
-	// TODO 检查用户ID
+	// 只能下载用户自己的Package
+	_, err := svc.Get(userID, packageID)
+	if err != nil {
+		return nil, err
+	}
+
 	return svc.Downloader.DownloadPackage(packageID), nil
 }
 
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+type QuotaService struct {
+	*Service
+}
+
+func (svc *Service) QuotaSvc() *QuotaService {
+	return &QuotaService{Service: svc}
+}
+
+func (svc *QuotaService) GetUserQuota(userID cdssdk.UserID) (model.Quota, model.StorageUsage, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return model.Quota{}, model.StorageUsage{}, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.GetUserQuota(coormq.ReqGetUserQuota(userID))
+	if err != nil {
+		return model.Quota{}, model.StorageUsage{}, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Quota, resp.Usage, nil
+}
+
+func (svc *QuotaService) GetBucketQuota(userID cdssdk.UserID, bucketID cdssdk.BucketID) (model.Quota, model.StorageUsage, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return model.Quota{}, model.StorageUsage{}, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.GetBucketQuota(coormq.ReqGetBucketQuota(userID, bucketID))
+	if err != nil {
+		return model.Quota{}, model.StorageUsage{}, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Quota, resp.Usage, nil
+}
+
+func (svc *QuotaService) SetUserQuota(userID cdssdk.UserID, quota model.Quota) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.SetUserQuota(coormq.ReqSetUserQuota(userID, quota))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *QuotaService) SetBucketQuota(bucketID cdssdk.BucketID, quota model.Quota) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.SetBucketQuota(coormq.ReqSetBucketQuota(bucketID, quota))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
@@ -3,7 +3,9 @@ package services
 import (
 	"fmt"
 
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	scmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner"
 	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
 )
@@ -30,3 +32,123 @@ func (svc *ScannerService) PostEvent(event scevt.Event, isEmergency bool, dontMe
 
 	return nil
 }
+
+func (svc *ScannerService) GetNodeRepairs() ([]model.NodeRepair, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.GetNodeRepairs(scmq.ReqGetNodeRepairs())
+	if err != nil {
+		return nil, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.Repairs, nil
+}
+
+func (svc *ScannerService) ListJobs(state string, typ string, offset int, limit int) ([]model.ScannerJob, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.ListJobs(scmq.ReqListJobs(state, typ, offset, limit))
+	if err != nil {
+		return nil, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.Jobs, nil
+}
+
+func (svc *ScannerService) RetryJob(jobID model.ScannerJobID) error {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	_, err = scCli.RetryJob(scmq.ReqRetryJob(jobID))
+	if err != nil {
+		return fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *ScannerService) CancelJob(jobID model.ScannerJobID) error {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	_, err = scCli.CancelJob(scmq.ReqCancelJob(jobID))
+	if err != nil {
+		return fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *ScannerService) PreviewPackageRedundancy(packageID cdssdk.PackageID, save bool) (model.RedundancyPlan, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return model.RedundancyPlan{}, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.PreviewPackageRedundancy(scmq.ReqPreviewPackageRedundancy(packageID, save))
+	if err != nil {
+		return model.RedundancyPlan{}, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.Plan, nil
+}
+
+func (svc *ScannerService) GetRedundancyPlan(planID model.RedundancyPlanID) (model.RedundancyPlan, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return model.RedundancyPlan{}, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.GetRedundancyPlan(scmq.ReqGetRedundancyPlan(planID))
+	if err != nil {
+		return model.RedundancyPlan{}, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.Plan, nil
+}
+
+func (svc *ScannerService) ListRedundancyPlans(packageID cdssdk.PackageID, state string, offset int, limit int) ([]model.RedundancyPlan, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.ListRedundancyPlans(scmq.ReqListRedundancyPlans(packageID, state, offset, limit))
+	if err != nil {
+		return nil, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.Plans, nil
+}
+
+func (svc *ScannerService) ApproveRedundancyPlan(planID model.RedundancyPlanID) (model.ScannerJobID, error) {
+	scCli, err := stgglb.ScannerMQPool.Acquire()
+	if err != nil {
+		return 0, fmt.Errorf("new scacnner client: %w", err)
+	}
+	defer stgglb.ScannerMQPool.Release(scCli)
+
+	resp, err := scCli.ApproveRedundancyPlan(scmq.ReqApproveRedundancyPlan(planID))
+	if err != nil {
+		return 0, fmt.Errorf("request to scanner failed, err: %w", err)
+	}
+
+	return resp.JobID, nil
+}
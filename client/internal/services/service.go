@@ -3,19 +3,22 @@ package services
 import (
 	"gitlink.org.cn/cloudream/common/pkgs/distlock"
 	"gitlink.org.cn/cloudream/storage/client/internal/task"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/downloader"
 )
 
 type Service struct {
-	DistLock   *distlock.Service
-	TaskMgr    *task.Manager
-	Downloader *downloader.Downloader
+	DistLock     *distlock.Service
+	TaskMgr      *task.Manager
+	Downloader   *downloader.Downloader
+	Connectivity *connectivity.Collector
 }
 
-func NewService(distlock *distlock.Service, taskMgr *task.Manager, downloader *downloader.Downloader) (*Service, error) {
+func NewService(distlock *distlock.Service, taskMgr *task.Manager, downloader *downloader.Downloader, conn *connectivity.Collector) (*Service, error) {
 	return &Service{
-		DistLock:   distlock,
-		TaskMgr:    taskMgr,
-		Downloader: downloader,
+		DistLock:     distlock,
+		TaskMgr:      taskMgr,
+		Downloader:   downloader,
+		Connectivity: conn,
 	}, nil
 }
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"io"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/cmd"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+type UploadSessionService struct {
+	*Service
+}
+
+func (svc *Service) UploadSessionSvc() *UploadSessionService {
+	return &UploadSessionService{Service: svc}
+}
+
+func (svc *UploadSessionService) cmdContext() *cmd.UploadObjectsContext {
+	return &cmd.UploadObjectsContext{
+		Distlock:     svc.DistLock,
+		Connectivity: svc.Connectivity,
+	}
+}
+
+func (svc *UploadSessionService) Initiate(userID cdssdk.UserID, packageID cdssdk.PackageID, path string, nodeAffinity *cdssdk.NodeID) (*model.UploadSession, error) {
+	return cmd.NewCreateUploadSession(userID, packageID, path, nodeAffinity).Execute(svc.cmdContext())
+}
+
+func (svc *UploadSessionService) UploadPart(userID cdssdk.UserID, sessionID model.UploadSessionID, partNumber int, file io.Reader, size int64) (*model.UploadSessionPart, error) {
+	return cmd.NewUploadPart(userID, sessionID, partNumber, file, size).Execute(svc.cmdContext())
+}
+
+func (svc *UploadSessionService) ListParts(userID cdssdk.UserID, sessionID model.UploadSessionID) (*model.UploadSession, []model.UploadSessionPart, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.GetUploadSession(coormq.ReqGetUploadSession(userID, sessionID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return &resp.Session, resp.Parts, nil
+}
+
+func (svc *UploadSessionService) Complete(userID cdssdk.UserID, sessionID model.UploadSessionID) (*cdssdk.Object, error) {
+	return cmd.NewCompleteUploadSession(userID, sessionID).Execute(svc.cmdContext())
+}
+
+func (svc *UploadSessionService) Abort(userID cdssdk.UserID, sessionID model.UploadSessionID) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.DeleteUploadSession(coormq.ReqDeleteUploadSession(userID, sessionID))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
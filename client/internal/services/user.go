@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+type UserService struct {
+	*Service
+}
+
+func (svc *Service) UserSvc() *UserService {
+	return &UserService{Service: svc}
+}
+
+// 校验用户的ID与密码，校验不通过时返回错误。返回用户是否为管理员
+func (svc *UserService) Auth(userID cdssdk.UserID, password string) (bool, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return false, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.AuthUser(coormq.ReqAuthUser(userID, password))
+	if err != nil {
+		return false, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Admin, nil
+}
+
+// 校验用户的ID与密码，通过后获取一个可以代替密码使用的登录凭证
+func (svc *UserService) IssueToken(userID cdssdk.UserID, password string) (string, time.Time, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.IssueUserToken(coormq.ReqIssueUserToken(userID, password, 0))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.Token, resp.ExpireTime, nil
+}
+
+// 检查登录凭证，返回凭证所属的用户
+func (svc *UserService) VerifyToken(token string) (cdssdk.UserID, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return 0, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.VerifyUserToken(coormq.ReqVerifyUserToken(token))
+	if err != nil {
+		return 0, fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return resp.UserID, nil
+}
+
+func (svc *UserService) RevokeToken(token string) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.RevokeUserToken(coormq.ReqRevokeUserToken(token))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
+
+func (svc *UserService) ChangePassword(userID cdssdk.UserID, oldPassword string, newPassword string) error {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	_, err = coorCli.ChangeUserPassword(coormq.ReqChangeUserPassword(userID, oldPassword, newPassword))
+	if err != nil {
+		return fmt.Errorf("requsting to coodinator: %w", err)
+	}
+
+	return nil
+}
@@ -17,9 +17,9 @@ type UploadObjects struct {
 	Result *UploadObjectsResult
 }
 
-func NewUploadObjects(userID cdssdk.UserID, packageID cdssdk.PackageID, objectIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID) *UploadObjects {
+func NewUploadObjects(userID cdssdk.UserID, packageID cdssdk.PackageID, objectIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID, redundancy cdssdk.Redundancy) *UploadObjects {
 	return &UploadObjects{
-		cmd: *cmd.NewUploadObjects(userID, packageID, objectIter, nodeAffinity),
+		cmd: *cmd.NewUploadObjects(userID, packageID, objectIter, nodeAffinity, redundancy),
 	}
 }
 
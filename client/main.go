@@ -86,8 +86,9 @@ func main() {
 	taskMgr := task.NewManager(distlockSvc, &conCol)
 
 	dlder := downloader.NewDownloader(config.Cfg().Downloader, &conCol)
+	defer dlder.Close()
 
-	svc, err := services.NewService(distlockSvc, &taskMgr, &dlder)
+	svc, err := services.NewService(distlockSvc, &taskMgr, &dlder, &conCol)
 	if err != nil {
 		logger.Warnf("new services failed, err: %s", err.Error())
 		os.Exit(1)
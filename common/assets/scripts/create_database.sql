@@ -63,9 +63,17 @@ create table NodeConnectivity (
 
 create table User (
   UserID int not null primary key comment '用户ID',
-  Password varchar(100) not null comment '用户密码'
+  PasswordHash varchar(100) not null comment '加盐的用户密码哈希(bcrypt)',
+  Admin boolean not null default false comment '是否为管理员'
 ) comment = '用户密码表';
 
+create table UserToken (
+  TokenHash char(64) not null primary key comment '登录凭证的SHA256哈希',
+  UserID int not null comment '用户ID',
+  ExpireTime timestamp not null comment '过期时间',
+  index UserID (UserID)
+) comment = '用户登录凭证表';
+
 create table UserBucket (
   UserID int not null comment '用户ID',
   BucketID int not null comment '用户可访问的桶ID',
@@ -102,7 +110,8 @@ values
 create table Bucket (
   BucketID int not null auto_increment primary key comment '桶ID',
   Name varchar(100) not null comment '桶名',
-  CreatorID int not null comment '创建者ID'
+  CreatorID int not null comment '创建者ID',
+  CreateTime timestamp not null default current_timestamp comment '创建时间'
 ) comment = '桶表';
 
 insert into
@@ -137,6 +146,12 @@ create table ObjectBlock (
   primary key(ObjectID, `Index`, NodeID)
 ) comment = '对象编码块表';
 
+create table ObjectMetadata (
+  ObjectID int not null primary key comment '对象ID',
+  ContentType varchar(255) not null comment '对象的MIME类型',
+  UserMetadata JSON not null comment '用户自定义的键值对'
+) comment = '对象元数据表';
+
 create table Cache (
   FileHash varchar(100) not null comment '编码块块ID',
   NodeID int not null comment '节点ID',
@@ -145,6 +160,13 @@ create table Cache (
   primary key(FileHash, NodeID)
 ) comment = '缓存表';
 
+create table ObjectChecksum (
+  ObjectID int not null primary key comment '对象ID',
+  SHA256 char(64) not null comment '对象内容的SHA-256，十六进制小写',
+  MD5 char(32) not null comment '对象内容的MD5，十六进制小写',
+  CreateTime timestamp not null comment '创建时间'
+) comment = '对象校验和表';
+
 create table PinnedObject (
   NodeID int not null comment '节点ID',
   ObjectID int not null comment '对象ID',
@@ -167,6 +189,175 @@ create table StoragePackageLog (
   CreateTime timestamp not null comment '加载Package完成的时间'
 );
 
+create table UploadSession (
+  SessionID int not null auto_increment primary key comment '会话ID',
+  UserID int not null comment '创建会话的用户ID',
+  PackageID int not null comment '上传的目标包ID',
+  Path varchar(500) not null comment '上传完成后的对象路径',
+  NodeID int not null comment '暂存分段的节点ID',
+  CreateTime timestamp not null comment '创建时间',
+  UpdateTime timestamp not null comment '最后一次上传分段的时间'
+) comment = '分段上传会话表';
+
+create table UploadSessionPart (
+  SessionID int not null comment '会话ID',
+  PartNumber int not null comment '分段序号',
+  Size bigint not null comment '分段大小(Byte)',
+  FileHash varchar(100) not null comment '分段的FileHash',
+  UploadTime timestamp not null comment '上传时间',
+  primary key(SessionID, PartNumber)
+) comment = '分段上传的分段表';
+
+create table PackageSnapshot (
+  PackageID int not null primary key comment '快照的包ID',
+  SourcePackageID int not null comment '创建快照的原包ID',
+  CreateTime timestamp not null comment '快照创建时间'
+) comment = '包快照表，快照中的对象只读';
+
+create table PackageVersioning (
+  PackageID int not null primary key comment '开启了多版本的包ID',
+  CreateTime timestamp not null comment '开启时间'
+) comment = '包多版本设置表';
+
+create table ObjectVersion (
+  VersionID int not null auto_increment primary key comment '版本ID',
+  ObjectID int not null comment '归档时的对象ID',
+  PackageID int not null comment '包ID',
+  Path varchar(500) not null comment '对象路径',
+  Size bigint not null comment '对象大小(Byte)',
+  FileHash varchar(100) not null comment '完整对象的FileHash',
+  Redundancy JSON not null comment '冗余策略',
+  CreateTime timestamp not null comment '对象创建时间',
+  UpdateTime timestamp not null comment '此版本的上传时间',
+  ArchiveTime timestamp not null comment '此版本被覆盖或删除的时间',
+  Degraded boolean not null default false comment '此版本是否有编码块丢失或损坏，已损坏的版本不能恢复',
+  index PackagePath (PackageID, Path)
+) comment = '对象历史版本表';
+
+create table ObjectVersionBlock (
+  VersionID int not null comment '版本ID',
+  ObjectID int not null comment '归档时的对象ID',
+  `Index` int not null comment '编码块在条带内的排序',
+  NodeID int not null comment '此编码块应该存在的节点',
+  FileHash varchar(100) not null comment '编码块哈希值',
+  primary key(VersionID, `Index`, NodeID)
+) comment = '对象历史版本编码块表';
+
+create table ObjectVersionMetadata (
+  VersionID int not null primary key comment '版本ID',
+  ContentType varchar(255) not null comment '归档时对象的MIME类型',
+  UserMetadata JSON not null comment '归档时对象的用户自定义键值对'
+) comment = '对象历史版本元数据表';
+
+create table ObjectVersionChecksum (
+  VersionID int not null primary key comment '版本ID',
+  SHA256 char(64) not null comment '此版本内容的SHA-256，十六进制小写',
+  MD5 char(32) not null comment '此版本内容的MD5，十六进制小写',
+  CreateTime timestamp not null comment '校验和的创建时间'
+) comment = '对象历史版本校验和表';
+
+create table UserQuota (
+  UserID int not null primary key comment '用户ID',
+  MaxLogicalBytes bigint comment '对象大小之和的上限，为null代表不限制',
+  MaxPhysicalBytes bigint comment '按冗余策略存储后实际占用空间的上限，为null代表不限制',
+  MaxObjectCount bigint comment '对象数量的上限，为null代表不限制'
+) comment = '用户配额表';
+
+create table BucketQuota (
+  BucketID int not null primary key comment '桶ID',
+  MaxLogicalBytes bigint comment '对象大小之和的上限，为null代表不限制',
+  MaxPhysicalBytes bigint comment '按冗余策略存储后实际占用空间的上限，为null代表不限制',
+  MaxObjectCount bigint comment '对象数量的上限，为null代表不限制'
+) comment = '桶配额表';
+
+create table PackageUsage (
+  PackageID int not null primary key comment '包ID',
+  LogicalBytes bigint not null comment '对象以及对象历史版本的大小之和',
+  PhysicalBytes bigint not null comment '对象以及对象历史版本按冗余策略存储后实际占用的大小之和',
+  ObjectCount bigint not null comment '对象数量，不包括历史版本'
+) comment = '包的用量计数表，包中的对象变化时更新，用来计算桶和用户的用量';
+
+create table NodeRepair (
+  NodeID int not null primary key comment '节点ID',
+  State varchar(100) not null comment '修复状态，Waiting、Running、Done或Failed',
+  UnavailableTime timestamp not null comment '节点被设置为不可用的时间',
+  TotalObjects int not null comment '需要修复的对象数量',
+  RepairedObjects int not null comment '已经修复的对象数量',
+  FailedObjects int not null comment '修复失败的对象数量',
+  RetryCount int not null comment '因为有对象修复失败而重试的次数',
+  UpdateTime timestamp not null comment '最后一次更新进度的时间'
+) comment = '不可用节点的数据修复任务表';
+
+create table CorruptBlock (
+  ObjectID int not null comment '对象ID',
+  `Index` int not null comment '编码块在条带内的排序',
+  NodeID int not null comment '编码块所在的节点',
+  FileHash varchar(100) not null comment '编码块记录的哈希值',
+  DetectTime timestamp not null comment '发现损坏的时间',
+  index ObjectID (ObjectID)
+) comment = '巡检发现的损坏编码块表';
+
+create table LifecycleRule (
+  RuleID int not null auto_increment primary key comment '规则ID',
+  BucketID int not null comment '桶ID',
+  PathPrefix varchar(500) not null comment '规则作用的对象路径前缀，为空代表整个桶',
+  Action varchar(100) not null comment '动作，Expire、Transition或Unpin',
+  Days int not null comment '对象超过多少天后执行动作',
+  Redundancy JSON comment 'Transition的目标冗余策略，其他动作为null',
+  CreateTime timestamp not null comment '创建时间',
+  index BucketID (BucketID)
+) comment = '桶生命周期规则表';
+
+create table ScannerJob (
+  JobID bigint not null auto_increment primary key comment '任务ID',
+  Type varchar(100) not null comment '事件类型',
+  Content JSON not null comment '序列化后的事件内容',
+  IsEmergency boolean not null comment '是否优先执行',
+  DontMerge boolean not null comment '是否不允许与其他任务合并',
+  Shard int not null comment '任务所属的分片，-1代表由Leader执行',
+  Owner varchar(100) not null comment '最后一次执行任务的Scanner实例',
+  Epoch bigint not null comment '最后一次执行任务时，执行者拥有的分片租约的纪元',
+  State varchar(100) not null comment '任务状态，Pending、Running、Succeeded、Failed、Canceled或Merged',
+  Attempts int not null comment '已经执行的次数',
+  LastError text not null comment '最后一次执行失败的原因',
+  CreateTime timestamp not null comment '创建时间',
+  UpdateTime timestamp not null comment '最后一次更新状态的时间',
+  index State (State, Shard)
+) comment = 'Scanner的持久化任务表';
+
+create table ScannerCursor (
+  Name varchar(100) not null primary key comment '定时任务名称',
+  LastID bigint not null comment '上一次扫描到的最后一个ID，下一次从比它大的ID开始扫描',
+  UpdateTime timestamp not null comment '更新时间'
+) comment = 'Scanner定时扫描任务的进度表';
+
+create table ScannerInstance (
+  InstanceID varchar(100) not null primary key comment 'Scanner实例ID',
+  HeartbeatTime timestamp not null comment '最后一次心跳的时间'
+) comment = 'Scanner实例表，用于计算每个实例应该负责的分片数量';
+
+create table ScannerShard (
+  Shard int not null primary key comment '分片编号，-1代表Leader',
+  Owner varchar(100) not null comment '拥有分片的Scanner实例',
+  Epoch bigint not null comment '纪元，分片每次被获得时加一，用于拒绝之前的拥有者的操作',
+  LeaseExpire timestamp not null comment '租约的到期时间'
+) comment = 'Scanner分片的租约表';
+
+create table RedundancyPlan (
+  PlanID bigint not null auto_increment primary key comment '计划ID',
+  PackageID int not null comment '包ID',
+  State varchar(100) not null comment '计划状态',
+  ObjectCount int not null comment '需要修改冗余策略的对象数量',
+  TotalSize bigint not null comment '这些对象的总大小',
+  TransferBytes bigint not null comment '预计需要传输的数据量',
+  OldStoredBytes bigint not null comment '修改前占用的存储空间',
+  NewStoredBytes bigint not null comment '修改后占用的存储空间',
+  Objects JSON not null comment '每个对象的修改计划',
+  CreateTime timestamp not null comment '创建时间',
+  UpdateTime timestamp not null comment '状态更新时间',
+  index PackageID (PackageID)
+) comment = '冗余策略修改计划表，批准后才会执行';
+
 create table Location (
   LocationID int not null auto_increment primary key comment 'ID',
   Name varchar(128) not null comment '名称'
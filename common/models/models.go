@@ -1,6 +1,11 @@
 package stgmod
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
 	"github.com/samber/lo"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/common/utils/sort2"
@@ -17,6 +22,7 @@ type ObjectDetail struct {
 	Object   cdssdk.Object   `json:"object"`
 	PinnedAt []cdssdk.NodeID `json:"pinnedAt"`
 	Blocks   []ObjectBlock   `json:"blocks"`
+	Metadata *ObjectMetadata `json:"metadata"` // 对象没有设置元数据时为nil
 }
 
 func NewObjectDetail(object cdssdk.Object, pinnedAt []cdssdk.NodeID, blocks []ObjectBlock) ObjectDetail {
@@ -27,6 +33,37 @@ func NewObjectDetail(object cdssdk.Object, pinnedAt []cdssdk.NodeID, blocks []Ob
 	}
 }
 
+// 用户为对象设置的元数据
+type ObjectMetadata struct {
+	ObjectID     cdssdk.ObjectID `db:"ObjectID" json:"objectID"`
+	ContentType  string          `db:"ContentType" json:"contentType"`
+	UserMetadata UserMetadata    `db:"UserMetadata" json:"userMetadata"`
+}
+
+// 用户自定义的键值对，在数据库中保存为JSON
+type UserMetadata map[string]string
+
+func (m UserMetadata) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(map[string]string(m))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (m *UserMetadata) Scan(src interface{}) error {
+	data, ok := src.([]uint8)
+	if !ok {
+		return fmt.Errorf("unknow src type: %v", reflect.TypeOf(src))
+	}
+
+	return json.Unmarshal(data, (*map[string]string)(m))
+}
+
 type GrouppedObjectBlock struct {
 	ObjectID cdssdk.ObjectID
 	Index    int
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
+)
+
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// 在读取文件的同时计算文件的SHA-256和MD5
+type ChecksumReader struct {
+	reader io.Reader
+	sha256 hash.Hash
+	md5    hash.Hash
+}
+
+func NewChecksumReader(r io.Reader) *ChecksumReader {
+	c := &ChecksumReader{
+		sha256: sha256.New(),
+		md5:    md5.New(),
+	}
+	c.reader = io.TeeReader(r, io.MultiWriter(c.sha256, c.md5))
+	return c
+}
+
+func (c *ChecksumReader) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *ChecksumReader) SHA256() string {
+	return hex.EncodeToString(c.sha256.Sum(nil))
+}
+
+func (c *ChecksumReader) MD5() string {
+	return hex.EncodeToString(c.md5.Sum(nil))
+}
+
+// 检查读取到的数据的校验和是否与期望的相同，为空的校验和不检查
+func (c *ChecksumReader) Verify(sha256 string, md5 string) error {
+	if sha256 != "" && !strings.EqualFold(sha256, c.SHA256()) {
+		return fmt.Errorf("%w: sha256 is %s, but expected %s", ErrChecksumMismatch, c.SHA256(), sha256)
+	}
+
+	if md5 != "" && !strings.EqualFold(md5, c.MD5()) {
+		return fmt.Errorf("%w: md5 is %s, but expected %s", ErrChecksumMismatch, c.MD5(), md5)
+	}
+
+	return nil
+}
+
+func verifyUploadingChecksums(objInfo *iterator.IterUploadingObject, cr *ChecksumReader) error {
+	err := cr.Verify(objInfo.SHA256, objInfo.MD5)
+	if err != nil {
+		return fmt.Errorf("file %s: %w", objInfo.Path, err)
+	}
+	return nil
+}
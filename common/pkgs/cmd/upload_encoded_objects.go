@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/sort2"
+
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/filehash"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitchlrc"
+	lrcparser "gitlink.org.cn/cloudream/storage/common/pkgs/ioswitchlrc/parser"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+// 上传文件时按照指定的冗余策略直接编码，将编码块写入到多个节点，不再需要Scanner重新读取文件进行编码
+func uploadEncodedAndUpdatePackage(userID cdssdk.UserID, packageID cdssdk.PackageID, objectIter iterator.UploadingObjectIterator, userNodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID, red cdssdk.Redundancy, conn *connectivity.Collector) ([]ObjectUploadResult, error) {
+	var nodeCnt int
+	switch red := red.(type) {
+	case *cdssdk.RepRedundancy:
+		nodeCnt = red.RepCount
+	case *cdssdk.ECRedundancy:
+		nodeCnt = red.N
+	case *cdssdk.LRCRedundancy:
+		nodeCnt = red.N
+	default:
+		return nil, fmt.Errorf("unsupported redundancy type: %T", red)
+	}
+
+	// 为所有文件选择相同的一组上传节点
+	uploadNodes := chooseUploadNodes(userNodes, nodeAffinity, nodeCnt)
+
+	return uploadObjectsParallel(userID, packageID, red, objectIter, func(objInfo *iterator.IterUploadingObject, file io.Reader) (coormq.AddObjectEntry, error) {
+		for _, node := range uploadNodes {
+			conn.BeginTransfer(node.Node.NodeID)
+		}
+		defer func() {
+			for _, node := range uploadNodes {
+				conn.EndTransfer(node.Node.NodeID)
+			}
+		}()
+
+		uploadTime := time.Now()
+		fileHash, blocks, err := uploadEncodedFile(file, red, uploadNodes)
+		if err != nil {
+			return coormq.AddObjectEntry{}, err
+		}
+
+		return coormq.NewAddEncodedObjectEntry(objInfo.Path, objInfo.Size, fileHash, uploadTime, red, blocks), nil
+	})
+}
+
+// chooseUploadNodes 选择count个上传节点，优先级与chooseUploadNode相同，节点不够时会重复使用
+func chooseUploadNodes(nodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID, count int) []UploadNodeInfo {
+	sortedNodes := sort2.Sort(nodes, func(e1, e2 UploadNodeInfo) int {
+		if nodeAffinity != nil {
+			v := sort2.CmpBool(e2.Node.NodeID == *nodeAffinity, e1.Node.NodeID == *nodeAffinity)
+			if v != 0 {
+				return v
+			}
+		}
+
+		return sort2.Cmp(e1.Score, e2.Score)
+	})
+
+	chosen := make([]UploadNodeInfo, count)
+	for i := 0; i < count; i++ {
+		chosen[i] = sortedNodes[i%len(sortedNodes)]
+	}
+	return chosen
+}
+
+// 返回完整文件的哈希值以及各个编码块的信息。
+// 对于EC和LRC，完整文件的哈希值是在上传的同时在本地计算的，不需要将完整文件写入到节点上
+func uploadEncodedFile(file io.Reader, red cdssdk.Redundancy, uploadNodes []UploadNodeInfo) (string, []stgmod.ObjectBlock, error) {
+	plans := exec.NewPlanBuilder()
+	var hd *exec.DriverWriteStream
+	var blockCnt int
+
+	switch red := red.(type) {
+	case *cdssdk.RepRedundancy:
+		// 如果选择的备份节点都是同一个，那么就只要上传一次
+		uploadNodes = lo.UniqBy(uploadNodes, func(item UploadNodeInfo) cdssdk.NodeID { return item.Node.NodeID })
+
+		ft := ioswitch2.NewFromTo()
+		var fromExec *ioswitch2.FromDriver
+		fromExec, hd = ioswitch2.NewFromDriver(-1)
+		ft.AddFrom(fromExec)
+		for i, node := range uploadNodes {
+			ft.AddTo(ioswitch2.NewToNode(node.Node, -1, fmt.Sprintf("%d", i)))
+		}
+		blockCnt = len(uploadNodes)
+
+		err := parser.NewParser(cdssdk.DefaultECRedundancy).Parse(ft, plans)
+		if err != nil {
+			return "", nil, fmt.Errorf("parsing plan: %w", err)
+		}
+
+	case *cdssdk.ECRedundancy:
+		ft := ioswitch2.NewFromTo()
+		var fromExec *ioswitch2.FromDriver
+		fromExec, hd = ioswitch2.NewFromDriver(-1)
+		ft.AddFrom(fromExec)
+		for i := 0; i < red.N; i++ {
+			ft.AddTo(ioswitch2.NewToNode(uploadNodes[i].Node, i, fmt.Sprintf("%d", i)))
+		}
+		blockCnt = red.N
+
+		err := parser.NewParser(*red).Parse(ft, plans)
+		if err != nil {
+			return "", nil, fmt.Errorf("parsing plan: %w", err)
+		}
+
+	case *cdssdk.LRCRedundancy:
+		var fromExec *ioswitchlrc.FromDriver
+		fromExec, hd = ioswitchlrc.NewFromDriver(-1)
+		var toes []ioswitchlrc.To
+		for i := 0; i < red.N; i++ {
+			toes = append(toes, ioswitchlrc.NewToNode(uploadNodes[i].Node, i, fmt.Sprintf("%d", i)))
+		}
+		blockCnt = red.N
+
+		err := lrcparser.Encode(fromExec, toes, plans)
+		if err != nil {
+			return "", nil, fmt.Errorf("parsing plan: %w", err)
+		}
+
+	default:
+		return "", nil, fmt.Errorf("unsupported redundancy type: %T", red)
+	}
+
+	// Rep模式下每个节点上保存的都是完整的文件，编码块的哈希值就是完整文件的哈希值
+	_, isRep := red.(*cdssdk.RepRedundancy)
+	var hasher *fileHasher
+	if !isRep {
+		hasher = newFileHasher(file)
+		file = hasher
+	}
+
+	exec := plans.Execute()
+	exec.BeginWrite(io.NopCloser(file), hd)
+	ret, err := exec.Wait(context.TODO())
+	if err != nil {
+		if hasher != nil {
+			hasher.Abort(err)
+		}
+		return "", nil, err
+	}
+
+	var blocks []stgmod.ObjectBlock
+	for i := 0; i < blockCnt; i++ {
+		index := i
+		if isRep {
+			index = 0
+		}
+
+		blocks = append(blocks, stgmod.ObjectBlock{
+			Index:    index,
+			NodeID:   uploadNodes[i].Node.NodeID,
+			FileHash: ret[fmt.Sprintf("%d", i)].(string),
+		})
+	}
+
+	if isRep {
+		return blocks[0].FileHash, blocks, nil
+	}
+
+	fileHash, err := hasher.Finish()
+	if err != nil {
+		return "", nil, fmt.Errorf("calculating file hash: %w", err)
+	}
+
+	return fileHash, blocks, nil
+}
+
+// 在文件数据被读取的同时，在另一个协程中计算文件的IPFS哈希值
+type fileHasher struct {
+	reader io.Reader
+	pw     *io.PipeWriter
+	retCh  chan fileHashResult
+}
+
+type fileHashResult struct {
+	hash string
+	err  error
+}
+
+func newFileHasher(r io.Reader) *fileHasher {
+	pr, pw := io.Pipe()
+	h := &fileHasher{
+		reader: io.TeeReader(r, pw),
+		pw:     pw,
+		retCh:  make(chan fileHashResult, 1),
+	}
+
+	go func() {
+		hash, err := filehash.CalculateIPFSHash(pr)
+		// 计算失败时让写入也失败，避免读取文件的一方一直阻塞
+		pr.CloseWithError(err)
+		h.retCh <- fileHashResult{hash: hash, err: err}
+	}()
+
+	return h
+}
+
+func (h *fileHasher) Read(p []byte) (int, error) {
+	return h.reader.Read(p)
+}
+
+// 文件已经读取完毕，返回计算出的哈希值
+func (h *fileHasher) Finish() (string, error) {
+	h.pw.Close()
+	ret := <-h.retCh
+	return ret.hash, ret.err
+}
+
+// 放弃计算，等待计算哈希值的协程退出
+func (h *fileHasher) Abort(err error) {
+	h.pw.CloseWithError(err)
+	<-h.retCh
+}
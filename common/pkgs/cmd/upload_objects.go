@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/samber/lo"
@@ -14,11 +14,11 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
-	"gitlink.org.cn/cloudream/common/utils/sort2"
 
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/filehash"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/iterator"
@@ -26,27 +26,42 @@ import (
 	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
 )
 
+const (
+	// 同时上传的文件数
+	UploadWorkerCount = 8
+	// 每上传完成这么多个文件就更新一次Package
+	UpdatePackageBatchSize = 500
+	// 距离上次更新Package超过这个时间，也会更新一次
+	UpdatePackageInterval = time.Second * 10
+)
+
 type UploadObjects struct {
 	userID       cdssdk.UserID
 	packageID    cdssdk.PackageID
 	objectIter   iterator.UploadingObjectIterator
 	nodeAffinity *cdssdk.NodeID
+	redundancy   cdssdk.Redundancy
 }
 
 type UploadObjectsResult struct {
 	Objects []ObjectUploadResult
+	// 因为文件已经存在而不需要上传的文件数以及它们的总大小
+	DedupedCount int
+	DedupedSize  int64
 }
 
 type ObjectUploadResult struct {
-	Info   *iterator.IterUploadingObject
-	Error  error
-	Object cdssdk.Object
+	Info    *iterator.IterUploadingObject
+	Error   error
+	Object  cdssdk.Object
+	Deduped bool
 }
 
 type UploadNodeInfo struct {
 	Node           cdssdk.Node
 	Delay          time.Duration
 	IsSameLocation bool
+	Score          float64 // 获取节点列表时节点的评分，越低越好
 }
 
 type UploadObjectsContext struct {
@@ -54,45 +69,24 @@ type UploadObjectsContext struct {
 	Connectivity *connectivity.Collector
 }
 
-func NewUploadObjects(userID cdssdk.UserID, packageID cdssdk.PackageID, objIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID) *UploadObjects {
+// redundancy为上传时就使用的冗余策略，支持Rep、EC和LRC，为nil时则使用NoneRedundancy，由Scanner之后再调整
+func NewUploadObjects(userID cdssdk.UserID, packageID cdssdk.PackageID, objIter iterator.UploadingObjectIterator, nodeAffinity *cdssdk.NodeID, redundancy cdssdk.Redundancy) *UploadObjects {
 	return &UploadObjects{
 		userID:       userID,
 		packageID:    packageID,
 		objectIter:   objIter,
 		nodeAffinity: nodeAffinity,
+		redundancy:   redundancy,
 	}
 }
 
+// 返回错误时，如果已经有文件被添加到了Package中，结果也不为nil
 func (t *UploadObjects) Execute(ctx *UploadObjectsContext) (*UploadObjectsResult, error) {
 	defer t.objectIter.Close()
 
-	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	userNodes, err := getUserUploadNodes(t.userID, ctx.Connectivity)
 	if err != nil {
-		return nil, fmt.Errorf("new coordinator client: %w", err)
-	}
-
-	getUserNodesResp, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(t.userID))
-	if err != nil {
-		return nil, fmt.Errorf("getting user nodes: %w", err)
-	}
-
-	cons := ctx.Connectivity.GetAll()
-	userNodes := lo.Map(getUserNodesResp.Nodes, func(node cdssdk.Node, index int) UploadNodeInfo {
-		delay := time.Duration(math.MaxInt64)
-
-		con, ok := cons[node.NodeID]
-		if ok && con.Delay != nil {
-			delay = *con.Delay
-		}
-
-		return UploadNodeInfo{
-			Node:           node,
-			Delay:          delay,
-			IsSameLocation: node.LocationID == stgglb.Local.LocationID,
-		}
-	})
-	if len(userNodes) == 0 {
-		return nil, fmt.Errorf("user no available nodes")
+		return nil, err
 	}
 
 	// 给上传节点的IPFS加锁
@@ -116,20 +110,68 @@ func (t *UploadObjects) Execute(ctx *UploadObjectsContext) (*UploadObjectsResult
 	}
 	defer ipfsMutex.Unlock()
 
-	rets, err := uploadAndUpdatePackage(t.packageID, t.objectIter, userNodes, t.nodeAffinity)
-	if err != nil {
+	// 上传失败时，也会返回已经添加到Package中的对象
+	var rets []ObjectUploadResult
+	if t.redundancy != nil {
+		rets, err = uploadEncodedAndUpdatePackage(t.userID, t.packageID, t.objectIter, userNodes, t.nodeAffinity, t.redundancy, ctx.Connectivity)
+	} else {
+		rets, err = uploadAndUpdatePackage(t.userID, t.packageID, t.objectIter, userNodes, t.nodeAffinity, ctx.Connectivity)
+	}
+	if err != nil && len(rets) == 0 {
 		return nil, err
 	}
 
-	return &UploadObjectsResult{
+	ret := &UploadObjectsResult{
 		Objects: rets,
-	}, nil
+	}
+	for _, r := range rets {
+		if r.Deduped {
+			ret.DedupedCount++
+			ret.DedupedSize += r.Info.Size
+		}
+	}
+	return ret, err
+}
+
+// 获取用户可用的节点，以及它们与当前客户端之间的延迟和节点的评分
+func getUserUploadNodes(userID cdssdk.UserID, conn *connectivity.Collector) ([]UploadNodeInfo, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getUserNodesResp, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(userID))
+	if err != nil {
+		return nil, fmt.Errorf("getting user nodes: %w", err)
+	}
+
+	cons := conn.GetAll()
+	userNodes := lo.Map(getUserNodesResp.Nodes, func(node cdssdk.Node, index int) UploadNodeInfo {
+		delay := time.Duration(math.MaxInt64)
+
+		con, ok := cons[node.NodeID]
+		if ok && con.Delay != nil {
+			delay = *con.Delay
+		}
+
+		return UploadNodeInfo{
+			Node:           node,
+			Delay:          delay,
+			IsSameLocation: node.LocationID == stgglb.Local.LocationID,
+			Score:          conn.Score(node).Total,
+		}
+	})
+	if len(userNodes) == 0 {
+		return nil, fmt.Errorf("user no available nodes")
+	}
+
+	return userNodes, nil
 }
 
 // chooseUploadNode 选择一个上传文件的节点
 // 1. 选择设置了亲和性的节点
-// 2. 从与当前客户端相同地域的节点中随机选一个
-// 3. 没有的话从所有节点选择延迟最低的节点
+// 2. 没有的话选择评分最低的节点
 func chooseUploadNode(nodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID) UploadNodeInfo {
 	if nodeAffinity != nil {
 		aff, ok := lo.Find(nodes, func(node UploadNodeInfo) bool { return node.Node.NodeID == *nodeAffinity })
@@ -138,60 +180,193 @@ func chooseUploadNode(nodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID) Uploa
 		}
 	}
 
-	sameLocationNodes := lo.Filter(nodes, func(e UploadNodeInfo, i int) bool { return e.IsSameLocation })
-	if len(sameLocationNodes) > 0 {
-		return sameLocationNodes[rand.Intn(len(sameLocationNodes))]
-	}
+	return lo.MinBy(nodes, func(a, b UploadNodeInfo) bool { return a.Score < b.Score })
+}
+
+func uploadAndUpdatePackage(userID cdssdk.UserID, packageID cdssdk.PackageID, objectIter iterator.UploadingObjectIterator, userNodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID, conn *connectivity.Collector) ([]ObjectUploadResult, error) {
+	picker := newUploadNodePicker(userNodes, nodeAffinity, conn)
 
-	// 选择延迟最低的节点
-	nodes = sort2.Sort(nodes, func(e1, e2 UploadNodeInfo) int { return sort2.Cmp(e1.Delay, e2.Delay) })
+	return uploadObjectsParallel(userID, packageID, cdssdk.NewNoneRedundancy(), objectIter, func(objInfo *iterator.IterUploadingObject, file io.Reader) (coormq.AddObjectEntry, error) {
+		uploadNode := picker.Acquire()
+		defer picker.Release(uploadNode)
+
+		uploadTime := time.Now()
+		fileHash, err := uploadFile(file, uploadNode)
+		if err != nil {
+			return coormq.AddObjectEntry{}, err
+		}
+		conn.RecordThroughput(uploadNode.Node.NodeID, objInfo.Size, time.Since(uploadTime))
 
-	return nodes[0]
+		return coormq.NewAddObjectEntry(objInfo.Path, objInfo.Size, fileHash, uploadTime, uploadNode.Node.NodeID), nil
+	})
 }
 
-func uploadAndUpdatePackage(packageID cdssdk.PackageID, objectIter iterator.UploadingObjectIterator, userNodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID) ([]ObjectUploadResult, error) {
-	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
-	if err != nil {
-		return nil, fmt.Errorf("new coordinator client: %w", err)
-	}
-	defer stgglb.CoordinatorMQPool.Release(coorCli)
+// file为需要上传的文件数据，不要直接读取objInfo.File
+type uploadObjectFn func(objInfo *iterator.IterUploadingObject, file io.Reader) (coormq.AddObjectEntry, error)
 
-	// 为所有文件选择相同的上传节点
-	uploadNode := chooseUploadNode(userNodes, nodeAffinity)
+type uploadedObject struct {
+	Info    *iterator.IterUploadingObject
+	Add     coormq.AddObjectEntry
+	Deduped bool
+	Err     error
+}
 
-	var uploadRets []ObjectUploadResult
-	//上传文件夹
-	var adds []coormq.AddObjectEntry
-	for {
-		objInfo, err := objectIter.MoveNext()
-		if err == iterator.ErrNoMoreItem {
-			break
+// 使用多个协程同时上传文件，上传完成的文件会分批更新到Package中，这样即使中途失败，已经上传的文件也不会丢失。
+// 任意一个文件上传失败后，会停止上传剩下的文件，但仍然会把已经上传完成的文件更新到Package中，并与错误一起返回。
+// 已经存在于用户可用节点上、并且冗余策略与red相同的文件不会再上传，只添加对象记录
+func uploadObjectsParallel(userID cdssdk.UserID, packageID cdssdk.PackageID, red cdssdk.Redundancy, objectIter iterator.UploadingObjectIterator, upload uploadObjectFn) ([]ObjectUploadResult, error) {
+	// 客户端不能被多个协程同时使用，因此每个上传协程以及更新Package时都使用各自的客户端
+	coorClis := make([]*coormq.Client, 0, UploadWorkerCount+1)
+	defer func() {
+		for _, cli := range coorClis {
+			stgglb.CoordinatorMQPool.Release(cli)
 		}
+	}()
+	for i := 0; i < UploadWorkerCount+1; i++ {
+		cli, err := stgglb.CoordinatorMQPool.Acquire()
 		if err != nil {
-			return nil, fmt.Errorf("reading object: %w", err)
+			return nil, fmt.Errorf("new coordinator client: %w", err)
 		}
-		err = func() error {
-			defer objInfo.File.Close()
+		coorClis = append(coorClis, cli)
+	}
+	coorCli := coorClis[UploadWorkerCount]
+
+	objCh := make(chan *iterator.IterUploadingObject)
+	retCh := make(chan uploadedObject)
+	readErrCh := make(chan error, 1)
+	stopCh := make(chan struct{})
+
+	go func() {
+		defer close(objCh)
 
-			uploadTime := time.Now()
-			fileHash, err := uploadFile(objInfo.File, uploadNode)
+		for {
+			objInfo, err := objectIter.MoveNext()
+			if err == iterator.ErrNoMoreItem {
+				readErrCh <- nil
+				return
+			}
 			if err != nil {
-				return fmt.Errorf("uploading file: %w", err)
+				readErrCh <- fmt.Errorf("reading object: %w", err)
+				return
 			}
 
-			uploadRets = append(uploadRets, ObjectUploadResult{
-				Info:  objInfo,
-				Error: err,
-			})
+			select {
+			case objCh <- objInfo:
+			case <-stopCh:
+				objInfo.File.Close()
+				readErrCh <- nil
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < UploadWorkerCount; i++ {
+		wg.Add(1)
+		go func(workerCli *coormq.Client) {
+			defer wg.Done()
+
+			for objInfo := range objCh {
+				add, deduped, err := func() (coormq.AddObjectEntry, bool, error) {
+					defer objInfo.File.Close()
+
+					add, ok, err := tryDedupObject(workerCli, userID, red, objInfo)
+					if err != nil || ok {
+						return add, ok, err
+					}
+
+					cr := NewChecksumReader(objInfo.File)
+					add, err = upload(objInfo, cr)
+					if err != nil {
+						return add, false, err
+					}
+
+					// 数据已经上传，但不会添加对象记录，之后会被回收
+					err = verifyUploadingChecksums(objInfo, cr)
+					if err != nil {
+						return add, false, err
+					}
+
+					add.SHA256 = cr.SHA256()
+					add.MD5 = cr.MD5()
+					return add, false, nil
+				}()
+				add.ContentType = objInfo.ContentType
+				add.UserMetadata = objInfo.UserMetadata
+				retCh <- uploadedObject{Info: objInfo, Add: add, Deduped: deduped, Err: err}
+			}
+		}(coorClis[i])
+	}
+	go func() {
+		wg.Wait()
+		close(retCh)
+	}()
+
+	var uploadRets []ObjectUploadResult
+	var pendings []uploadedObject
+	var firstErr error
+	setErr := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+			close(stopCh)
+		}
+	}
+	flush := func() {
+		if len(pendings) == 0 {
+			return
+		}
 
-			adds = append(adds, coormq.NewAddObjectEntry(objInfo.Path, objInfo.Size, fileHash, uploadTime, uploadNode.Node.NodeID))
-			return nil
-		}()
+		rets, err := updatePackageObjects(coorCli, packageID, pendings)
+		pendings = nil
 		if err != nil {
-			return nil, err
+			setErr(err)
+			return
+		}
+		uploadRets = append(uploadRets, rets...)
+	}
+
+	ticker := time.NewTicker(UpdatePackageInterval)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case ret, ok := <-retCh:
+			if !ok {
+				break loop
+			}
+
+			if ret.Err != nil {
+				setErr(fmt.Errorf("uploading file %s: %w", ret.Info.Path, ret.Err))
+				continue
+			}
+
+			pendings = append(pendings, ret)
+			if len(pendings) >= UpdatePackageBatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
 		}
 	}
+	flush()
 
+	if readErr := <-readErrCh; readErr != nil && firstErr == nil {
+		firstErr = readErr
+	}
+	if firstErr != nil {
+		if len(uploadRets) > 0 {
+			logger.Warnf("%d objects were added to package %v before uploading failed", len(uploadRets), packageID)
+		}
+		return uploadRets, firstErr
+	}
+
+	return uploadRets, nil
+}
+
+func updatePackageObjects(coorCli *coormq.Client, packageID cdssdk.PackageID, uploadeds []uploadedObject) ([]ObjectUploadResult, error) {
+	adds := lo.Map(uploadeds, func(u uploadedObject, idx int) coormq.AddObjectEntry { return u.Add })
 	updateResp, err := coorCli.UpdatePackage(coormq.NewUpdatePackage(packageID, adds, nil))
 	if err != nil {
 		return nil, fmt.Errorf("updating package: %w", err)
@@ -203,10 +378,14 @@ func uploadAndUpdatePackage(packageID cdssdk.PackageID, objectIter iterator.Uplo
 		updatedObjs[obj.Path] = &o
 	}
 
-	for i := range uploadRets {
-		obj := updatedObjs[uploadRets[i].Info.Path]
+	uploadRets := make([]ObjectUploadResult, len(uploadeds))
+	for i, u := range uploadeds {
+		uploadRets[i].Info = u.Info
+		uploadRets[i].Deduped = u.Deduped
+
+		obj := updatedObjs[u.Info.Path]
 		if obj == nil {
-			uploadRets[i].Error = fmt.Errorf("object %s not found in package", uploadRets[i].Info.Path)
+			uploadRets[i].Error = fmt.Errorf("object %s not found in package", u.Info.Path)
 			continue
 		}
 		uploadRets[i].Object = *obj
@@ -215,6 +394,92 @@ func uploadAndUpdatePackage(packageID cdssdk.PackageID, objectIter iterator.Uplo
 	return uploadRets, nil
 }
 
+// 如果文件可以Seek，则先在本地计算文件的哈希值，查询文件是否已经存在于用户可用的节点上，
+// 存在的话就直接复用已有的编码块，不需要上传文件。red为上传时要使用的冗余策略。无法去重时返回false，此时文件会回到开头
+func tryDedupObject(coorCli *coormq.Client, userID cdssdk.UserID, red cdssdk.Redundancy, objInfo *iterator.IterUploadingObject) (coormq.AddObjectEntry, bool, error) {
+	seeker, ok := objInfo.File.(io.Seeker)
+	if !ok {
+		return coormq.AddObjectEntry{}, false, nil
+	}
+
+	uploadTime := time.Now()
+	cr := NewChecksumReader(objInfo.File)
+	fileHash, err := filehash.CalculateIPFSHash(cr)
+	if _, seekErr := seeker.Seek(0, io.SeekStart); seekErr != nil {
+		return coormq.AddObjectEntry{}, false, fmt.Errorf("seeking file: %w", seekErr)
+	}
+	if err != nil {
+		logger.Warnf("calculating hash of file %s: %s", objInfo.Path, err.Error())
+		return coormq.AddObjectEntry{}, false, nil
+	}
+
+	// 已经读取了完整的文件，可以在上传前就发现校验和不一致
+	err = verifyUploadingChecksums(objInfo, cr)
+	if err != nil {
+		return coormq.AddObjectEntry{}, false, err
+	}
+
+	findResp, err := coorCli.FindExistingFiles(coormq.ReqFindExistingFiles(userID, []string{fileHash}, red))
+	if err != nil {
+		logger.Warnf("finding existing files: %s", err.Error())
+		return coormq.AddObjectEntry{}, false, nil
+	}
+	if len(findResp.Files) == 0 {
+		return coormq.AddObjectEntry{}, false, nil
+	}
+
+	file := findResp.Files[0]
+	add := coormq.NewAddEncodedObjectEntry(objInfo.Path, objInfo.Size, fileHash, uploadTime, file.Redundancy, file.Blocks)
+	add.SHA256 = cr.SHA256()
+	add.MD5 = cr.MD5()
+	return add, true, nil
+}
+
+// 为每个文件选择上传节点。设置了亲和性时只使用亲和节点，
+// 否则每次都选择当前评分最低的节点，评分中包含了正在进行的上传数量，因此文件会被分散到多个较优的节点上
+type uploadNodePicker struct {
+	lock       sync.Mutex
+	candidates []UploadNodeInfo
+	conn       *connectivity.Collector
+}
+
+func newUploadNodePicker(nodes []UploadNodeInfo, nodeAffinity *cdssdk.NodeID, conn *connectivity.Collector) *uploadNodePicker {
+	candidates := nodes
+	if nodeAffinity != nil {
+		aff, ok := lo.Find(nodes, func(node UploadNodeInfo) bool { return node.Node.NodeID == *nodeAffinity })
+		if ok {
+			candidates = []UploadNodeInfo{aff}
+		}
+	}
+
+	return &uploadNodePicker{
+		candidates: candidates,
+		conn:       conn,
+	}
+}
+
+func (p *uploadNodePicker) Acquire() UploadNodeInfo {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	chosen := p.candidates[0]
+	chosenScore := p.conn.Score(chosen.Node).Total
+	for _, node := range p.candidates[1:] {
+		score := p.conn.Score(node.Node).Total
+		if score < chosenScore {
+			chosen = node
+			chosenScore = score
+		}
+	}
+
+	p.conn.BeginTransfer(chosen.Node.NodeID)
+	return chosen
+}
+
+func (p *uploadNodePicker) Release(node UploadNodeInfo) {
+	p.conn.EndTransfer(node.Node.NodeID)
+}
+
 func uploadFile(file io.Reader, uploadNode UploadNodeInfo) (string, error) {
 	// 本地有IPFS，则直接从本地IPFS上传
 	if stgglb.IPFSPool != nil {
@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/distlock"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+// 创建分段上传会话，会在此时选择好暂存分段的节点
+type CreateUploadSession struct {
+	userID       cdssdk.UserID
+	packageID    cdssdk.PackageID
+	path         string
+	nodeAffinity *cdssdk.NodeID
+}
+
+func NewCreateUploadSession(userID cdssdk.UserID, packageID cdssdk.PackageID, path string, nodeAffinity *cdssdk.NodeID) *CreateUploadSession {
+	return &CreateUploadSession{
+		userID:       userID,
+		packageID:    packageID,
+		path:         path,
+		nodeAffinity: nodeAffinity,
+	}
+}
+
+func (t *CreateUploadSession) Execute(ctx *UploadObjectsContext) (*model.UploadSession, error) {
+	userNodes, err := getUserUploadNodes(t.userID, ctx.Connectivity)
+	if err != nil {
+		return nil, err
+	}
+
+	uploadNode := chooseUploadNode(userNodes, t.nodeAffinity)
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	resp, err := coorCli.CreateUploadSession(coormq.ReqCreateUploadSession(t.userID, t.packageID, t.path, uploadNode.Node.NodeID))
+	if err != nil {
+		return nil, fmt.Errorf("creating upload session: %w", err)
+	}
+
+	return &resp.Session, nil
+}
+
+// 上传一个分段到会话的暂存节点。相同序号的分段重复上传时，以最后一次为准
+type UploadPart struct {
+	userID     cdssdk.UserID
+	sessionID  model.UploadSessionID
+	partNumber int
+	file       io.Reader
+	size       int64
+}
+
+func NewUploadPart(userID cdssdk.UserID, sessionID model.UploadSessionID, partNumber int, file io.Reader, size int64) *UploadPart {
+	return &UploadPart{
+		userID:     userID,
+		sessionID:  sessionID,
+		partNumber: partNumber,
+		file:       file,
+		size:       size,
+	}
+}
+
+func (t *UploadPart) Execute(ctx *UploadObjectsContext) (*model.UploadSessionPart, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getSession, err := coorCli.GetUploadSession(coormq.ReqGetUploadSession(t.userID, t.sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("getting upload session: %w", err)
+	}
+
+	node, err := getSessionNode(coorCli, getSession.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	// 防止上传的分段在记录到数据库之前被清除
+	ipfsMutex, err := lockSessionNode(ctx, node.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer ipfsMutex.Unlock()
+
+	uploadTime := time.Now()
+	// 大小不符时在读取过程中就返回错误，让上传中止，避免在节点上留下不会被记录的分段
+	fileHash, err := uploadFile(&sizeCheckingReader{reader: t.file, size: t.size}, UploadNodeInfo{Node: node})
+	if err != nil {
+		return nil, fmt.Errorf("uploading part: %w", err)
+	}
+
+	part := model.UploadSessionPart{
+		SessionID:  t.sessionID,
+		PartNumber: t.partNumber,
+		Size:       t.size,
+		FileHash:   fileHash,
+		UploadTime: uploadTime,
+	}
+	_, err = coorCli.AddUploadSessionPart(coormq.ReqAddUploadSessionPart(t.userID, part))
+	if err != nil {
+		return nil, fmt.Errorf("adding part: %w", err)
+	}
+
+	return &part, nil
+}
+
+// 完成分段上传。分段会在暂存节点上按序号拼接成完整的文件，然后才会把对象添加到Package中。
+// 拼接或添加对象失败时会保留会话，调用者可以重试或者放弃上传。一直没有完成的会话由扫描器定期清理
+type CompleteUploadSession struct {
+	userID    cdssdk.UserID
+	sessionID model.UploadSessionID
+}
+
+func NewCompleteUploadSession(userID cdssdk.UserID, sessionID model.UploadSessionID) *CompleteUploadSession {
+	return &CompleteUploadSession{
+		userID:    userID,
+		sessionID: sessionID,
+	}
+}
+
+func (t *CompleteUploadSession) Execute(ctx *UploadObjectsContext) (*cdssdk.Object, error) {
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return nil, fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getSession, err := coorCli.GetUploadSession(coormq.ReqGetUploadSession(t.userID, t.sessionID))
+	if err != nil {
+		return nil, fmt.Errorf("getting upload session: %w", err)
+	}
+
+	parts := getSession.Parts
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no part uploaded")
+	}
+	// 分段序号必须从1开始连续
+	var totalSize int64
+	for i, p := range parts {
+		if p.PartNumber != i+1 {
+			return nil, fmt.Errorf("part %d is missing", i+1)
+		}
+		totalSize += p.Size
+	}
+
+	node, err := getSessionNode(coorCli, getSession.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	ipfsMutex, err := lockSessionNode(ctx, node.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer ipfsMutex.Unlock()
+
+	uploadTime := time.Now()
+
+	// 只有一个分段时，分段本身就是完整的文件
+	fileHash := parts[0].FileHash
+	if len(parts) > 1 {
+		fileHash, err = joinParts(node, parts, totalSize)
+		if err != nil {
+			return nil, fmt.Errorf("joining parts: %w", err)
+		}
+	}
+
+	entry := coormq.NewAddObjectEntry(getSession.Session.Path, totalSize, fileHash, uploadTime, node.NodeID)
+	resp, err := coorCli.CompleteUploadSession(coormq.ReqCompleteUploadSession(t.userID, t.sessionID, entry))
+	if err != nil {
+		return nil, fmt.Errorf("completing upload session: %w", err)
+	}
+
+	return &resp.Object, nil
+}
+
+func getSessionNode(coorCli *coormq.Client, session model.UploadSession) (cdssdk.Node, error) {
+	getNodes, err := coorCli.GetNodes(coormq.NewGetNodes([]cdssdk.NodeID{session.NodeID}))
+	if err != nil {
+		return cdssdk.Node{}, fmt.Errorf("getting nodes: %w", err)
+	}
+	if len(getNodes.Nodes) == 0 {
+		return cdssdk.Node{}, fmt.Errorf("node %v not found", session.NodeID)
+	}
+
+	return getNodes.Nodes[0], nil
+}
+
+func lockSessionNode(ctx *UploadObjectsContext, nodeID cdssdk.NodeID) (*distlock.Mutex, error) {
+	ipfsReqBlder := reqbuilder.NewBuilder()
+	// 如果本地的IPFS也是存储系统的一个节点，那么从本地上传时，需要加锁
+	if stgglb.Local.NodeID != nil && *stgglb.Local.NodeID != nodeID {
+		ipfsReqBlder.IPFS().Buzy(*stgglb.Local.NodeID)
+	}
+	ipfsReqBlder.IPFS().Buzy(nodeID)
+
+	mutex, err := ipfsReqBlder.MutexLock(ctx.Distlock)
+	if err != nil {
+		return nil, fmt.Errorf("acquire locks failed, err: %w", err)
+	}
+
+	return mutex, nil
+}
+
+// 检查读取到的数据量是否与声明的大小一致，不一致时返回错误
+type sizeCheckingReader struct {
+	reader io.Reader
+	size   int64
+	count  int64
+}
+
+func (r *sizeCheckingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	if r.count > r.size {
+		return n, fmt.Errorf("part size mismatch, expected %d, actual more than %d", r.size, r.size)
+	}
+	if err == io.EOF && r.count != r.size {
+		return n, fmt.Errorf("part size mismatch, expected %d, actual %d", r.size, r.count)
+	}
+	return n, err
+}
+
+// 在暂存节点上按顺序拼接所有分段，返回拼接后的文件哈希
+func joinParts(node cdssdk.Node, parts []model.UploadSessionPart, totalSize int64) (string, error) {
+	fileHashes := make([]string, len(parts))
+	for i, p := range parts {
+		fileHashes[i] = p.FileHash
+	}
+
+	parser := parser.NewParser(cdssdk.DefaultECRedundancy)
+	plans := exec.NewPlanBuilder()
+	err := parser.JoinFiles(node, fileHashes, totalSize, "fileHash", plans)
+	if err != nil {
+		return "", fmt.Errorf("parsing plan: %w", err)
+	}
+
+	ret, err := plans.Execute().Wait(context.TODO())
+	if err != nil {
+		return "", err
+	}
+
+	return ret["fileHash"].(string), nil
+}
@@ -24,6 +24,8 @@ type Collector struct {
 	close          chan any
 	connectivities map[cdssdk.NodeID]Connectivity
 	lock           *sync.RWMutex
+	transfers      map[cdssdk.NodeID]*transferStat
+	transLock      *sync.Mutex
 }
 
 func NewCollector(cfg *Config, onCollected func(collector *Collector)) Collector {
@@ -33,6 +35,8 @@ func NewCollector(cfg *Config, onCollected func(collector *Collector)) Collector
 		close:          make(chan any),
 		connectivities: make(map[cdssdk.NodeID]Connectivity),
 		lock:           &sync.RWMutex{},
+		transfers:      make(map[cdssdk.NodeID]*transferStat),
+		transLock:      &sync.Mutex{},
 		onCollected:    onCollected,
 	}
 	go rpt.serve()
@@ -46,6 +50,8 @@ func NewCollectorWithInitData(cfg *Config, onCollected func(collector *Collector
 		close:          make(chan any),
 		connectivities: initData,
 		lock:           &sync.RWMutex{},
+		transfers:      make(map[cdssdk.NodeID]*transferStat),
+		transLock:      &sync.Mutex{},
 		onCollected:    onCollected,
 	}
 	go rpt.serve()
@@ -1,5 +1,17 @@
 package connectivity
 
 type Config struct {
-	TestInterval int `json:"testInterval"` // 进行测试的间隔
+	TestInterval int         `json:"testInterval"` // 进行测试的间隔
+	Score        ScoreConfig `json:"score"`        // 节点评分的参数，不填时使用默认值
+}
+
+type ScoreConfig struct {
+	// 每毫秒的Ping延迟增加的分数
+	DelayPenaltyPerMs float64 `json:"delayPenaltyPerMs"`
+	// 实测吞吐量等于这个值时，吞吐量项的分数为1，吞吐量越低分数越高，单位：MB/s
+	RefThroughputMBs float64 `json:"refThroughputMBs"`
+	// 吞吐量项的最高分数
+	MaxThroughputPenalty float64 `json:"maxThroughputPenalty"`
+	// 每个正在进行的传输增加的分数
+	InFlightPenalty float64 `json:"inFlightPenalty"`
 }
@@ -0,0 +1,189 @@
+package connectivity
+
+import (
+	"math"
+	"time"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/consts"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+)
+
+const (
+	DefaultDelayPenaltyPerMs    = 0.05
+	DefaultRefThroughputMBs     = 10
+	DefaultMaxThroughputPenalty = 10
+	DefaultInFlightPenalty      = 0.5
+)
+
+const (
+	// 新的观测值在加权平均中所占的比重
+	transferStatEWMAWeight = 0.3
+	// 超过这个时间没有更新的传输统计不再参与评分
+	transferStatExpireTime = 10 * time.Minute
+)
+
+// 传输数据时实际观测到的节点情况
+type transferStat struct {
+	Latency    time.Duration // 从发起读取到收到第一批数据的时间
+	Throughput float64       // 单位：字节/秒
+	UpdateTime time.Time
+	InFlight   int // 正在进行的传输数量
+}
+
+// 节点的评分，分数越低越优先选择。Total为其他各项分数之和
+type NodeScore struct {
+	NodeID            cdssdk.NodeID  `json:"nodeID"`
+	Total             float64        `json:"total"`
+	Location          float64        `json:"location"` // 由节点的位置决定的基础分数，取值为consts.NodeDistance*
+	DelayPenalty      float64        `json:"delayPenalty"`
+	ThroughputPenalty float64        `json:"throughputPenalty"`
+	InFlightPenalty   float64        `json:"inFlightPenalty"`
+	Delay             *time.Duration `json:"delay"`      // 评分使用的延迟，没有Ping的结果时使用实测的延迟
+	Throughput        float64        `json:"throughput"` // 实测吞吐量，单位：字节/秒，为0表示没有数据
+	InFlight          int            `json:"inFlight"`
+}
+
+// 记录一次传输中收到第一批数据的延迟
+func (r *Collector) RecordLatency(nodeID cdssdk.NodeID, latency time.Duration) {
+	r.transLock.Lock()
+	defer r.transLock.Unlock()
+
+	st := r.getTransferStat(nodeID)
+	if st.Latency == 0 {
+		st.Latency = latency
+	} else {
+		st.Latency = time.Duration(float64(st.Latency)*(1-transferStatEWMAWeight) + float64(latency)*transferStatEWMAWeight)
+	}
+	st.UpdateTime = time.Now()
+}
+
+// 记录一次传输了size字节，花费了dur的时间
+func (r *Collector) RecordThroughput(nodeID cdssdk.NodeID, size int64, dur time.Duration) {
+	if dur <= 0 {
+		return
+	}
+
+	r.transLock.Lock()
+	defer r.transLock.Unlock()
+
+	tp := float64(size) / dur.Seconds()
+
+	st := r.getTransferStat(nodeID)
+	if st.Throughput == 0 {
+		st.Throughput = tp
+	} else {
+		st.Throughput = st.Throughput*(1-transferStatEWMAWeight) + tp*transferStatEWMAWeight
+	}
+	st.UpdateTime = time.Now()
+}
+
+// 开始一次与节点之间的传输，必须与EndTransfer成对调用
+func (r *Collector) BeginTransfer(nodeID cdssdk.NodeID) {
+	r.transLock.Lock()
+	defer r.transLock.Unlock()
+
+	r.getTransferStat(nodeID).InFlight++
+}
+
+func (r *Collector) EndTransfer(nodeID cdssdk.NodeID) {
+	r.transLock.Lock()
+	defer r.transLock.Unlock()
+
+	st := r.getTransferStat(nodeID)
+	if st.InFlight > 0 {
+		st.InFlight--
+	}
+}
+
+// 综合节点的位置、Ping延迟、实测吞吐量以及正在进行的传输数量，计算节点的评分
+func (r *Collector) Score(node cdssdk.Node) NodeScore {
+	cfg := r.scoreConfig()
+
+	score := NodeScore{
+		NodeID: node.NodeID,
+	}
+
+	r.transLock.Lock()
+	var stat transferStat
+	if st, ok := r.transfers[node.NodeID]; ok {
+		stat = *st
+	}
+	r.transLock.Unlock()
+
+	statValid := time.Since(stat.UpdateTime) <= transferStatExpireTime
+	if statValid {
+		score.Throughput = stat.Throughput
+	}
+	score.InFlight = stat.InFlight
+
+	isSameNode := stgglb.Local.NodeID != nil && node.NodeID == *stgglb.Local.NodeID
+	isSameLocation := node.LocationID == stgglb.Local.LocationID
+
+	if c := r.Get(node.NodeID); c != nil && c.Delay != nil {
+		score.Delay = c.Delay
+	} else if statValid && stat.Latency > 0 {
+		score.Delay = &stat.Latency
+	}
+
+	switch {
+	case isSameNode:
+		score.Location = consts.NodeDistanceSameNode
+	case isSameLocation:
+		score.Location = consts.NodeDistanceSameLocation
+	default:
+		score.Location = consts.NodeDistanceOther
+	}
+
+	if !isSameNode {
+		if score.Delay != nil {
+			score.DelayPenalty = float64(*score.Delay) / float64(time.Millisecond) * cfg.DelayPenaltyPerMs
+		} else if !isSameLocation {
+			// 连不上或者还没有测试过的远程节点按高延迟节点处理
+			score.DelayPenalty = consts.NodeDistanceHighLatencyNode - consts.NodeDistanceOther
+		}
+	}
+
+	if score.Throughput > 0 {
+		score.ThroughputPenalty = math.Min(cfg.RefThroughputMBs*1024*1024/score.Throughput, cfg.MaxThroughputPenalty)
+	}
+
+	score.InFlightPenalty = float64(score.InFlight) * cfg.InFlightPenalty
+
+	score.Total = score.Location + score.DelayPenalty + score.ThroughputPenalty + score.InFlightPenalty
+	return score
+}
+
+func (r *Collector) ScoreNodes(nodes []cdssdk.Node) []NodeScore {
+	scores := make([]NodeScore, len(nodes))
+	for i, n := range nodes {
+		scores[i] = r.Score(n)
+	}
+	return scores
+}
+
+func (r *Collector) scoreConfig() ScoreConfig {
+	cfg := r.cfg.Score
+	if cfg.DelayPenaltyPerMs == 0 {
+		cfg.DelayPenaltyPerMs = DefaultDelayPenaltyPerMs
+	}
+	if cfg.RefThroughputMBs == 0 {
+		cfg.RefThroughputMBs = DefaultRefThroughputMBs
+	}
+	if cfg.MaxThroughputPenalty == 0 {
+		cfg.MaxThroughputPenalty = DefaultMaxThroughputPenalty
+	}
+	if cfg.InFlightPenalty == 0 {
+		cfg.InFlightPenalty = DefaultInFlightPenalty
+	}
+	return cfg
+}
+
+func (r *Collector) getTransferStat(nodeID cdssdk.NodeID) *transferStat {
+	st, ok := r.transfers[nodeID]
+	if !ok {
+		st = &transferStat{}
+		r.transfers[nodeID] = st
+	}
+	return st
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
@@ -18,9 +19,12 @@ func (db *DB) Bucket() *BucketDB {
 	return &BucketDB{DB: db}
 }
 
+// cdssdk.Bucket中没有的列不能查询出来，否则无法绑定到结构体上
+const bucketColumns = "Bucket.BucketID, Bucket.Name, Bucket.CreatorID"
+
 func (db *BucketDB) GetByID(ctx SQLContext, bucketID cdssdk.BucketID) (cdssdk.Bucket, error) {
 	var ret cdssdk.Bucket
-	err := sqlx.Get(ctx, &ret, "select * from Bucket where BucketID = ?", bucketID)
+	err := sqlx.Get(ctx, &ret, "select "+bucketColumns+" from Bucket where BucketID = ?", bucketID)
 	return ret, err
 }
 
@@ -57,7 +61,7 @@ func (db *BucketDB) IsAvailable(ctx SQLContext, bucketID cdssdk.BucketID, userID
 func (*BucketDB) GetUserBucket(ctx SQLContext, userID cdssdk.UserID, bucketID cdssdk.BucketID) (model.Bucket, error) {
 	var ret model.Bucket
 	err := sqlx.Get(ctx, &ret,
-		"select Bucket.* from UserBucket, Bucket where UserID = ? and"+
+		"select "+bucketColumns+" from UserBucket, Bucket where UserID = ? and"+
 			" UserBucket.BucketID = Bucket.BucketID and"+
 			" Bucket.BucketID = ?", userID, bucketID)
 	return ret, err
@@ -66,7 +70,7 @@ func (*BucketDB) GetUserBucket(ctx SQLContext, userID cdssdk.UserID, bucketID cd
 func (*BucketDB) GetUserBucketByName(ctx SQLContext, userID cdssdk.UserID, bucketName string) (model.Bucket, error) {
 	var ret model.Bucket
 	err := sqlx.Get(ctx, &ret,
-		"select Bucket.* from UserBucket, Bucket where UserID = ? and"+
+		"select "+bucketColumns+" from UserBucket, Bucket where UserID = ? and"+
 			" UserBucket.BucketID = Bucket.BucketID and"+
 			" Bucket.Name = ?", userID, bucketName)
 	return ret, err
@@ -74,6 +78,12 @@ func (*BucketDB) GetUserBucketByName(ctx SQLContext, userID cdssdk.UserID, bucke
 
 func (*BucketDB) GetUserBuckets(ctx SQLContext, userID cdssdk.UserID) ([]model.Bucket, error) {
 	var ret []model.Bucket
+	err := sqlx.Select(ctx, &ret, "select "+bucketColumns+" from UserBucket, Bucket where UserID = ? and UserBucket.BucketID = Bucket.BucketID", userID)
+	return ret, err
+}
+
+func (*BucketDB) GetUserBucketDetails(ctx SQLContext, userID cdssdk.UserID) ([]model.BucketDetail, error) {
+	var ret []model.BucketDetail
 	err := sqlx.Select(ctx, &ret, "select Bucket.* from UserBucket, Bucket where UserID = ? and UserBucket.BucketID = Bucket.BucketID", userID)
 	return ret, err
 }
@@ -89,7 +99,7 @@ func (db *BucketDB) Create(ctx SQLContext, userID cdssdk.UserID, bucketName stri
 		return 0, err
 	}
 
-	ret, err := ctx.Exec("insert into Bucket(Name,CreatorID) values(?,?)", bucketName, userID)
+	ret, err := ctx.Exec("insert into Bucket(Name,CreatorID,CreateTime) values(?,?,?)", bucketName, userID, time.Now())
 	if err != nil {
 		return 0, fmt.Errorf("insert bucket failed, err: %w", err)
 	}
@@ -118,6 +128,16 @@ func (db *BucketDB) Delete(ctx SQLContext, bucketID cdssdk.BucketID) error {
 		return fmt.Errorf("delete bucket failed, err: %w", err)
 	}
 
+	err = db.Quota().DeleteBucketQuota(ctx, bucketID)
+	if err != nil {
+		return fmt.Errorf("delete bucket quota failed, err: %w", err)
+	}
+
+	err = db.Lifecycle().DeleteInBucket(ctx, bucketID)
+	if err != nil {
+		return fmt.Errorf("delete bucket lifecycle rules failed, err: %w", err)
+	}
+
 	// 删除Bucket内的Package
 	var pkgIDs []cdssdk.PackageID
 	err = sqlx.Select(ctx, &pkgIDs, "select PackageID from Package where BucketID = ?", bucketID)
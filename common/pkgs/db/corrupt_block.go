@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type CorruptBlockDB struct {
+	*DB
+}
+
+func (db *DB) CorruptBlock() *CorruptBlockDB {
+	return &CorruptBlockDB{DB: db}
+}
+
+// 查询对象所有被标记为损坏的块，包括之前修复失败而留下的记录
+func (*CorruptBlockDB) GetByObjectID(ctx SQLContext, objectID cdssdk.ObjectID) ([]model.CorruptBlock, error) {
+	var ret []model.CorruptBlock
+	err := sqlx.Select(ctx, &ret, "select * from CorruptBlock where ObjectID = ? order by DetectTime asc", objectID)
+	return ret, err
+}
+
+// 记录损坏的编码块，同时将它们从ObjectBlock表中删除，使得下载时不会再选中这些块
+func (db *CorruptBlockDB) MarkCorrupt(ctx SQLContext, blocks []model.CorruptBlock) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	err := BatchNamedExec(ctx,
+		"insert into CorruptBlock(ObjectID, `Index`, NodeID, FileHash, DetectTime) values(:ObjectID, :Index, :NodeID, :FileHash, :DetectTime)",
+		5,
+		blocks,
+		nil,
+	)
+	if err != nil {
+		return fmt.Errorf("inserting corrupt blocks: %w", err)
+	}
+
+	for _, b := range blocks {
+		_, err := ctx.Exec("delete from ObjectBlock where ObjectID = ? and `Index` = ? and NodeID = ?", b.ObjectID, b.Index, b.NodeID)
+		if err != nil {
+			return fmt.Errorf("deleting object block: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// 对象修复完成或被删除后，清除它的损坏块记录
+func (*CorruptBlockDB) BatchDeleteByObjectID(ctx SQLContext, objectIDs []cdssdk.ObjectID) error {
+	if len(objectIDs) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("delete from CorruptBlock where ObjectID in (?)", objectIDs)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Exec(query, args...)
+	return err
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type LifecycleDB struct {
+	*DB
+}
+
+func (db *DB) Lifecycle() *LifecycleDB {
+	return &LifecycleDB{DB: db}
+}
+
+func (*LifecycleDB) Create(ctx SQLContext, rule model.LifecycleRule) (model.LifecycleRuleID, error) {
+	ret, err := ctx.Exec("insert into LifecycleRule(BucketID, PathPrefix, Action, Days, Redundancy, CreateTime) values(?,?,?,?,?,?)",
+		rule.BucketID, rule.PathPrefix, rule.Action, rule.Days, rule.Redundancy, rule.CreateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return model.LifecycleRuleID(id), nil
+}
+
+func (*LifecycleDB) GetByID(ctx SQLContext, ruleID model.LifecycleRuleID) (model.LifecycleRule, error) {
+	var ret model.TempLifecycleRule
+	err := sqlx.Get(ctx, &ret, "select * from LifecycleRule where RuleID = ?", ruleID)
+	return ret.ToLifecycleRule(), err
+}
+
+func (*LifecycleDB) GetByBucketID(ctx SQLContext, bucketID cdssdk.BucketID) ([]model.LifecycleRule, error) {
+	var ret []model.TempLifecycleRule
+	err := sqlx.Select(ctx, &ret, "select * from LifecycleRule where BucketID = ? order by RuleID asc", bucketID)
+	return lo.Map(ret, func(r model.TempLifecycleRule, idx int) model.LifecycleRule { return r.ToLifecycleRule() }), err
+}
+
+// 查询所有设置了生命周期规则的桶
+func (*LifecycleDB) GetAllBucketIDs(ctx SQLContext) ([]cdssdk.BucketID, error) {
+	var ret []cdssdk.BucketID
+	err := sqlx.Select(ctx, &ret, "select distinct BucketID from LifecycleRule")
+	return ret, err
+}
+
+func (*LifecycleDB) Delete(ctx SQLContext, ruleID model.LifecycleRuleID) error {
+	_, err := ctx.Exec("delete from LifecycleRule where RuleID = ?", ruleID)
+	return err
+}
+
+func (*LifecycleDB) DeleteInBucket(ctx SQLContext, bucketID cdssdk.BucketID) error {
+	_, err := ctx.Exec("delete from LifecycleRule where BucketID = ?", bucketID)
+	return err
+}
+
+// 查询桶内路径以prefix开头、最后一次更新早于before的对象，只会查询正常状态的Package中的对象
+func (*LifecycleDB) BatchGetObjectsBefore(ctx SQLContext, bucketID cdssdk.BucketID, prefix string, before time.Time, count int) ([]cdssdk.Object, error) {
+	var ret []model.TempObject
+	err := sqlx.Select(ctx, &ret,
+		"select Object.* from Object, Package where Object.PackageID = Package.PackageID and"+
+			" Package.BucketID = ? and Package.State = ? and Object.Path like ? and Object.UpdateTime < ? and"+
+			" not exists(select PackageID from PackageSnapshot where PackageSnapshot.PackageID = Package.PackageID)"+
+			" order by Object.ObjectID asc limit ?",
+		bucketID, cdssdk.PackageStateNormal, likePrefix(prefix), before, count)
+	return lo.Map(ret, func(o model.TempObject, idx int) cdssdk.Object { return o.ToObject() }), err
+}
+
+// 查询桶内存在路径以prefix开头、最后一次更新早于before的对象的Package
+func (*LifecycleDB) GetPackageIDsBefore(ctx SQLContext, bucketID cdssdk.BucketID, prefix string, before time.Time) ([]cdssdk.PackageID, error) {
+	var ret []cdssdk.PackageID
+	err := sqlx.Select(ctx, &ret,
+		"select distinct Package.PackageID from Object, Package where Object.PackageID = Package.PackageID and"+
+			" Package.BucketID = ? and Package.State = ? and Object.Path like ? and Object.UpdateTime < ?",
+		bucketID, cdssdk.PackageStateNormal, likePrefix(prefix), before)
+	return ret, err
+}
+
+// 删除桶内路径以prefix开头的对象的、创建时间早于before的临时副本，返回删除的数量
+func (*LifecycleDB) DeletePinnedObjectsBefore(ctx SQLContext, bucketID cdssdk.BucketID, prefix string, before time.Time) (int64, error) {
+	ret, err := ctx.Exec(
+		"delete PinnedObject from PinnedObject, Object, Package where PinnedObject.ObjectID = Object.ObjectID and"+
+			" Object.PackageID = Package.PackageID and Package.BucketID = ? and Object.Path like ? and PinnedObject.CreateTime < ?",
+		bucketID, likePrefix(prefix), before)
+	if err != nil {
+		return 0, err
+	}
+
+	return ret.RowsAffected()
+}
+
+// 生成匹配以prefix开头的字符串的like语句参数
+func likePrefix(prefix string) string {
+	prefix = strings.ReplaceAll(prefix, `\`, `\\`)
+	prefix = strings.ReplaceAll(prefix, "%", `\%`)
+	prefix = strings.ReplaceAll(prefix, "_", `\_`)
+	return prefix + "%"
+}
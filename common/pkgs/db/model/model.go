@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
@@ -14,8 +15,15 @@ import (
 type Storage = cdssdk.Storage
 
 type User struct {
-	UserID   cdssdk.UserID `db:"UserID" json:"userID"`
-	Password string        `db:"Password" json:"password"`
+	UserID       cdssdk.UserID `db:"UserID" json:"userID"`
+	PasswordHash string        `db:"PasswordHash" json:"-"`
+	Admin        bool          `db:"Admin" json:"admin"` // 管理员可以管理所有用户的数据以及Scanner的任务
+}
+
+type UserToken struct {
+	TokenHash  string        `db:"TokenHash" json:"tokenHash"`
+	UserID     cdssdk.UserID `db:"UserID" json:"userID"`
+	ExpireTime time.Time     `db:"ExpireTime" json:"expireTime"`
 }
 
 type UserBucket struct {
@@ -35,6 +43,12 @@ type UserStorage struct {
 
 type Bucket = cdssdk.Bucket
 
+// 带有创建时间的桶信息
+type BucketDetail struct {
+	Bucket
+	CreateTime time.Time `db:"CreateTime" json:"createTime"`
+}
+
 type Package = cdssdk.Package
 
 type Object = cdssdk.Object
@@ -75,6 +89,8 @@ func (o *RedundancyWarpper) Scan(src interface{}) error {
 
 type ObjectBlock = stgmod.ObjectBlock
 
+type ObjectMetadata = stgmod.ObjectMetadata
+
 type Cache struct {
 	FileHash   string        `db:"FileHash" json:"fileHash"`
 	NodeID     cdssdk.NodeID `db:"NodeID" json:"nodeID"`
@@ -82,6 +98,14 @@ type Cache struct {
 	Priority   int           `db:"Priority" json:"priority"`
 }
 
+// 上传对象时根据经过的数据计算出的校验和。校验和由上传者提供，因此按对象保存，只对这个对象有效
+type ObjectChecksum struct {
+	ObjectID   cdssdk.ObjectID `db:"ObjectID" json:"objectID"`
+	SHA256     string          `db:"SHA256" json:"sha256"`
+	MD5        string          `db:"MD5" json:"md5"`
+	CreateTime time.Time       `db:"CreateTime" json:"createTime"`
+}
+
 const (
 	StoragePackageStateNormal   = "Normal"
 	StoragePackageStateDeleted  = "Deleted"
@@ -103,7 +127,274 @@ type StoragePackageLog struct {
 	CreateTime time.Time        `db:"CreateTime" json:"createTime"`
 }
 
+type UploadSessionID int64
+
+// 分段上传会话，上传完成之前分段都暂存在NodeID指定的节点上
+type UploadSession struct {
+	SessionID  UploadSessionID  `db:"SessionID" json:"sessionID"`
+	UserID     cdssdk.UserID    `db:"UserID" json:"userID"`
+	PackageID  cdssdk.PackageID `db:"PackageID" json:"packageID"`
+	Path       string           `db:"Path" json:"path"`
+	NodeID     cdssdk.NodeID    `db:"NodeID" json:"nodeID"`
+	CreateTime time.Time        `db:"CreateTime" json:"createTime"`
+	UpdateTime time.Time        `db:"UpdateTime" json:"updateTime"`
+}
+
+type UploadSessionPart struct {
+	SessionID  UploadSessionID `db:"SessionID" json:"sessionID"`
+	PartNumber int             `db:"PartNumber" json:"partNumber"`
+	Size       int64           `db:"Size" json:"size,string"`
+	FileHash   string          `db:"FileHash" json:"fileHash"`
+	UploadTime time.Time       `db:"UploadTime" json:"uploadTime"`
+}
+
+type ObjectVersionID int64
+
+// 对象的历史版本，Object中的字段为对象被覆盖、修改、移动或删除之前的信息
+type ObjectVersion struct {
+	VersionID   ObjectVersionID `json:"versionID"`
+	ArchiveTime time.Time       `json:"archiveTime"`
+	// 有编码块丢失或者损坏的版本会被标记，并且不能再恢复
+	Degraded bool          `json:"degraded"`
+	Object   cdssdk.Object `json:"object"`
+}
+
+// 与TempObject同理，查询ObjectVersion时需要先scan成TempObjectVersion
+type TempObjectVersion struct {
+	VersionID   ObjectVersionID `db:"VersionID"`
+	ArchiveTime time.Time       `db:"ArchiveTime"`
+	Degraded    bool            `db:"Degraded"`
+	TempObject
+}
+
+func (v *TempObjectVersion) ToObjectVersion() ObjectVersion {
+	return ObjectVersion{
+		VersionID:   v.VersionID,
+		ArchiveTime: v.ArchiveTime,
+		Degraded:    v.Degraded,
+		Object:      v.ToObject(),
+	}
+}
+
 type Location struct {
 	LocationID cdssdk.LocationID `db:"LocationID" json:"locationID"`
 	Name       string            `db:"Name" json:"name"`
 }
+
+// 配额限制，字段为nil代表不限制此项
+type Quota struct {
+	MaxLogicalBytes  *int64 `db:"MaxLogicalBytes" json:"maxLogicalBytes"`
+	MaxPhysicalBytes *int64 `db:"MaxPhysicalBytes" json:"maxPhysicalBytes"`
+	MaxObjectCount   *int64 `db:"MaxObjectCount" json:"maxObjectCount"`
+}
+
+// 存储空间的使用量。LogicalBytes为对象本身的大小之和，PhysicalBytes为按冗余策略存储后实际占用的大小之和，
+// 两者都包括对象的历史版本
+type StorageUsage struct {
+	LogicalBytes  int64 `db:"LogicalBytes" json:"logicalBytes"`
+	PhysicalBytes int64 `db:"PhysicalBytes" json:"physicalBytes"`
+	ObjectCount   int64 `db:"ObjectCount" json:"objectCount"`
+}
+
+const (
+	NodeRepairStateWaiting = "Waiting" // 等待节点不可用的时间超过宽限期
+	NodeRepairStateRunning = "Running"
+	NodeRepairStateDone    = "Done"
+	NodeRepairStateFailed  = "Failed" // 重试次数用完后仍有对象修复失败
+)
+
+// 节点不可用后，将它上面的数据修复到其他节点的任务
+type NodeRepair struct {
+	NodeID          cdssdk.NodeID `db:"NodeID" json:"nodeID"`
+	State           string        `db:"State" json:"state"`
+	UnavailableTime time.Time     `db:"UnavailableTime" json:"unavailableTime"`
+	TotalObjects    int           `db:"TotalObjects" json:"totalObjects"`
+	RepairedObjects int           `db:"RepairedObjects" json:"repairedObjects"`
+	FailedObjects   int           `db:"FailedObjects" json:"failedObjects"`
+	RetryCount      int           `db:"RetryCount" json:"retryCount"`
+	UpdateTime      time.Time     `db:"UpdateTime" json:"updateTime"`
+}
+
+// 巡检时发现的损坏的编码块，损坏的块会从ObjectBlock表中删除，这里只作为记录
+type CorruptBlock struct {
+	ObjectID   cdssdk.ObjectID `db:"ObjectID" json:"objectID"`
+	Index      int             `db:"Index" json:"index"`
+	NodeID     cdssdk.NodeID   `db:"NodeID" json:"nodeID"`
+	FileHash   string          `db:"FileHash" json:"fileHash"`
+	DetectTime time.Time       `db:"DetectTime" json:"detectTime"`
+}
+
+type LifecycleRuleID int64
+
+const (
+	LifecycleActionExpire     = "Expire"     // 删除超过天数没有更新的对象
+	LifecycleActionTransition = "Transition" // 将超过天数没有更新的对象转换为指定的冗余策略
+	LifecycleActionUnpin      = "Unpin"      // 删除超过天数的临时副本
+)
+
+// 桶的生命周期规则，只作用于路径以PathPrefix开头的对象
+type LifecycleRule struct {
+	RuleID     LifecycleRuleID   `json:"ruleID"`
+	BucketID   cdssdk.BucketID   `json:"bucketID"`
+	PathPrefix string            `json:"pathPrefix"`
+	Action     string            `json:"action"`
+	Days       int               `json:"days"`
+	Redundancy cdssdk.Redundancy `json:"redundancy"` // 只有Transition规则有此字段
+	CreateTime time.Time         `json:"createTime"`
+}
+
+// 判断对象是否在规则的作用范围内，并且最后一次更新的时间距离now已经超过了规则的天数
+func (r *LifecycleRule) Matches(obj cdssdk.Object, now time.Time) bool {
+	return strings.HasPrefix(obj.Path, r.PathPrefix) && !obj.UpdateTime.After(r.Before(now))
+}
+
+// 规则作用的时间点，早于这个时间的对象才会被处理
+func (r *LifecycleRule) Before(now time.Time) time.Time {
+	return now.AddDate(0, 0, -r.Days)
+}
+
+// Redundancy字段可能为null，所以需要先scan成TempLifecycleRule
+type TempLifecycleRule struct {
+	RuleID     LifecycleRuleID    `db:"RuleID"`
+	BucketID   cdssdk.BucketID    `db:"BucketID"`
+	PathPrefix string             `db:"PathPrefix"`
+	Action     string             `db:"Action"`
+	Days       int                `db:"Days"`
+	Redundancy *RedundancyWarpper `db:"Redundancy"`
+	CreateTime time.Time          `db:"CreateTime"`
+}
+
+func (r *TempLifecycleRule) ToLifecycleRule() LifecycleRule {
+	rule := LifecycleRule{
+		RuleID:     r.RuleID,
+		BucketID:   r.BucketID,
+		PathPrefix: r.PathPrefix,
+		Action:     r.Action,
+		Days:       r.Days,
+		CreateTime: r.CreateTime,
+	}
+	if r.Redundancy != nil {
+		rule.Redundancy = r.Redundancy.Value
+	}
+	return rule
+}
+
+type ScannerJobID int64
+
+const (
+	ScannerJobStatePending   = "Pending"
+	ScannerJobStateRunning   = "Running"
+	ScannerJobStateSucceeded = "Succeeded"
+	ScannerJobStateFailed    = "Failed"
+	ScannerJobStateCanceled  = "Canceled"
+	ScannerJobStateMerged    = "Merged" // 被合并到了其他任务中，由其他任务一起执行
+)
+
+// Scanner中持久化的任务，Content为序列化后的scanner事件
+type ScannerJob struct {
+	JobID       ScannerJobID `db:"JobID" json:"jobID"`
+	Type        string       `db:"Type" json:"type"`
+	Content     string       `db:"Content" json:"content"`
+	IsEmergency bool         `db:"IsEmergency" json:"isEmergency"`
+	DontMerge   bool         `db:"DontMerge" json:"dontMerge"`
+	Shard       int          `db:"Shard" json:"shard"`
+	Owner       string       `db:"Owner" json:"owner"`
+	Epoch       int64        `db:"Epoch" json:"epoch"`
+	State       string       `db:"State" json:"state"`
+	Attempts    int          `db:"Attempts" json:"attempts"`
+	LastError   string       `db:"LastError" json:"lastError"`
+	CreateTime  time.Time    `db:"CreateTime" json:"createTime"`
+	UpdateTime  time.Time    `db:"UpdateTime" json:"updateTime"`
+}
+
+// Scanner定时扫描任务的进度，重启后从记录的位置继续扫描
+type ScannerCursor struct {
+	Name       string    `db:"Name" json:"name"`
+	LastID     int64     `db:"LastID" json:"lastID"`
+	UpdateTime time.Time `db:"UpdateTime" json:"updateTime"`
+}
+
+type ScannerInstance struct {
+	InstanceID    string    `db:"InstanceID" json:"instanceID"`
+	HeartbeatTime time.Time `db:"HeartbeatTime" json:"heartbeatTime"`
+}
+
+// Scanner分片的租约
+type ScannerShard struct {
+	Shard       int       `db:"Shard" json:"shard"`
+	Owner       string    `db:"Owner" json:"owner"`
+	Epoch       int64     `db:"Epoch" json:"epoch"`
+	LeaseExpire time.Time `db:"LeaseExpire" json:"leaseExpire"`
+}
+
+type RedundancyPlanID int64
+
+const (
+	RedundancyPlanStatePending  = "Pending"  // 等待批准
+	RedundancyPlanStateApproved = "Approved" // 已经批准，等待执行
+	RedundancyPlanStateExecuted = "Executed" // 已经执行
+	RedundancyPlanStateFailed   = "Failed"   // 执行时有对象没有修改成功，可以重新批准执行
+)
+
+// 修改一个Package中对象的冗余策略的计划，用于在执行之前预览修改的内容
+type RedundancyPlan struct {
+	PlanID         RedundancyPlanID       `db:"PlanID" json:"planID"`
+	PackageID      cdssdk.PackageID       `db:"PackageID" json:"packageID"`
+	State          string                 `db:"State" json:"state"`
+	ObjectCount    int                    `db:"ObjectCount" json:"objectCount"`
+	TotalSize      int64                  `db:"TotalSize" json:"totalSize"`
+	TransferBytes  int64                  `db:"TransferBytes" json:"transferBytes"`
+	OldStoredBytes int64                  `db:"OldStoredBytes" json:"oldStoredBytes"`
+	NewStoredBytes int64                  `db:"NewStoredBytes" json:"newStoredBytes"`
+	Objects        []ObjectRedundancyPlan `db:"-" json:"objects"`
+	CreateTime     time.Time              `db:"CreateTime" json:"createTime"`
+	UpdateTime     time.Time              `db:"UpdateTime" json:"updateTime"`
+}
+
+// 一个对象的冗余策略的修改计划
+type ObjectRedundancyPlan struct {
+	ObjectID      cdssdk.ObjectID   `json:"objectID"`
+	Path          string            `json:"path"`
+	Size          int64             `json:"size"`
+	SrcRedundancy cdssdk.Redundancy `json:"srcRedundancy"`
+	TarRedundancy cdssdk.Redundancy `json:"tarRedundancy"`
+	// 修改前保存了数据块的节点
+	SrcNodeIDs []cdssdk.NodeID `json:"srcNodeIDs"`
+	// 修改后保存数据块的节点。对于EC和LRC，第i个节点保存第i块
+	TarNodeIDs []cdssdk.NodeID `json:"tarNodeIDs"`
+	// 需要写入到节点上的数据量，是根据块大小得到的估算值
+	TransferBytes  int64 `json:"transferBytes"`
+	OldStoredBytes int64 `json:"oldStoredBytes"`
+	NewStoredBytes int64 `json:"newStoredBytes"`
+}
+
+// Objects字段需要单独反序列化，所以查询时先scan成TempRedundancyPlan
+type TempRedundancyPlan struct {
+	RedundancyPlan
+	Objects ObjectRedundancyPlansWarpper `db:"Objects"`
+}
+
+func (p *TempRedundancyPlan) ToRedundancyPlan() RedundancyPlan {
+	plan := p.RedundancyPlan
+	plan.Objects = p.Objects.Value
+	return plan
+}
+
+type ObjectRedundancyPlansWarpper struct {
+	Value []ObjectRedundancyPlan
+}
+
+func (o *ObjectRedundancyPlansWarpper) Scan(src interface{}) error {
+	data, ok := src.([]uint8)
+	if !ok {
+		return fmt.Errorf("unknow src type: %v", reflect.TypeOf(data))
+	}
+
+	plans, err := serder.JSONToObjectEx[[]ObjectRedundancyPlan](data)
+	if err != nil {
+		return err
+	}
+
+	o.Value = plans
+	return nil
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"database/sql"
+	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
@@ -34,6 +36,21 @@ func (db *NodeDB) GetUserNodes(ctx SQLContext, userID cdssdk.UserID) ([]cdssdk.N
 	return nodes, err
 }
 
+// IsAvailable 判断用户是否有使用指定节点的权限
+func (db *NodeDB) IsAvailable(ctx SQLContext, userID cdssdk.UserID, nodeID cdssdk.NodeID) (bool, error) {
+	var id cdssdk.NodeID
+	err := sqlx.Get(ctx, &id, "select NodeID from UserNode where UserID = ? and NodeID = ?", userID, nodeID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("find node failed, err: %w", err)
+	}
+
+	return true, nil
+}
+
 // UpdateState 更新状态，并且设置上次上报时间为现在
 func (db *NodeDB) UpdateState(ctx SQLContext, nodeID cdssdk.NodeID, state string) error {
 	_, err := ctx.Exec("update Node set State = ?, LastReportTime = ? where NodeID = ?", state, time.Now(), nodeID)
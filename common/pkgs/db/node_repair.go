@@ -0,0 +1,77 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type NodeRepairDB struct {
+	*DB
+}
+
+func (db *DB) NodeRepair() *NodeRepairDB {
+	return &NodeRepairDB{DB: db}
+}
+
+func (*NodeRepairDB) GetByNodeID(ctx SQLContext, nodeID cdssdk.NodeID) (model.NodeRepair, error) {
+	var ret model.NodeRepair
+	err := sqlx.Get(ctx, &ret, "select * from NodeRepair where NodeID = ?", nodeID)
+	return ret, err
+}
+
+func (*NodeRepairDB) GetAll(ctx SQLContext) ([]model.NodeRepair, error) {
+	var ret []model.NodeRepair
+	err := sqlx.Select(ctx, &ret, "select * from NodeRepair order by UnavailableTime desc")
+	return ret, err
+}
+
+// 节点变为不可用时调用，创建一个等待中的修复任务。如果节点已经有未结束的任务，则不做任何操作
+func (db *NodeRepairDB) Start(ctx SQLContext, nodeID cdssdk.NodeID, unavailableTime time.Time) error {
+	repair, err := db.GetByNodeID(ctx, nodeID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == nil && repair.State != model.NodeRepairStateDone && repair.State != model.NodeRepairStateFailed {
+		return nil
+	}
+
+	_, err = ctx.Exec("replace into NodeRepair(NodeID, State, UnavailableTime, TotalObjects, RepairedObjects, FailedObjects, RetryCount, UpdateTime) values(?,?,?,0,0,0,0,?)",
+		nodeID, model.NodeRepairStateWaiting, unavailableTime, unavailableTime)
+	return err
+}
+
+// 查询节点不可用时间早于before的等待中的任务，以及在before之后就没有更新过进度的进行中的任务（比如执行时Scanner重启了）。
+// 等待重试的任务的更新时间是上一次修复结束的时间，因此同样要等待before之后才会被查询到
+func (*NodeRepairDB) GetPending(ctx SQLContext, before time.Time) ([]model.NodeRepair, error) {
+	var ret []model.NodeRepair
+	err := sqlx.Select(ctx, &ret, "select * from NodeRepair where (State = ? and UnavailableTime < ? and UpdateTime < ?) or (State = ? and UpdateTime < ?)",
+		model.NodeRepairStateWaiting, before, before, model.NodeRepairStateRunning, before)
+	return ret, err
+}
+
+func (*NodeRepairDB) UpdateState(ctx SQLContext, nodeID cdssdk.NodeID, state string) error {
+	_, err := ctx.Exec("update NodeRepair set State = ?, UpdateTime = ? where NodeID = ?", state, time.Now(), nodeID)
+	return err
+}
+
+// 修复结束但有对象失败时，重新设置为等待中，同时增加重试次数
+func (*NodeRepairDB) Retry(ctx SQLContext, nodeID cdssdk.NodeID) error {
+	_, err := ctx.Exec("update NodeRepair set State = ?, RetryCount = RetryCount + 1, UpdateTime = ? where NodeID = ?", model.NodeRepairStateWaiting, time.Now(), nodeID)
+	return err
+}
+
+func (*NodeRepairDB) UpdateProgress(ctx SQLContext, nodeID cdssdk.NodeID, total int, repaired int, failed int) error {
+	_, err := ctx.Exec("update NodeRepair set TotalObjects = ?, RepairedObjects = ?, FailedObjects = ?, UpdateTime = ? where NodeID = ?",
+		total, repaired, failed, time.Now(), nodeID)
+	return err
+}
+
+// 节点在修复开始之前恢复了，则取消修复任务
+func (*NodeRepairDB) DeleteWaiting(ctx SQLContext, nodeID cdssdk.NodeID) error {
+	_, err := ctx.Exec("delete from NodeRepair where NodeID = ? and State = ?", nodeID, model.NodeRepairStateWaiting)
+	return err
+}
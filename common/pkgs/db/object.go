@@ -49,6 +49,20 @@ func (db *ObjectDB) BatchGet(ctx SQLContext, objectIDs []cdssdk.ObjectID) ([]mod
 	return lo.Map(objs, func(o model.TempObject, idx int) cdssdk.Object { return o.ToObject() }), nil
 }
 
+// 查询用户可以访问的、文件哈希为fileHash的对象，最多返回count个
+func (db *ObjectDB) GetUserObjectsByFileHash(ctx SQLContext, userID cdssdk.UserID, fileHash string, count int) ([]cdssdk.Object, error) {
+	var ret []model.TempObject
+	err := sqlx.Select(ctx, &ret,
+		"select Object.* from Object, Package, UserBucket where"+
+			" Object.FileHash = ? and"+
+			" Object.PackageID = Package.PackageID and"+
+			" Package.BucketID = UserBucket.BucketID and"+
+			" UserBucket.UserID = ?"+
+			" order by Object.ObjectID asc limit ?",
+		fileHash, userID, count)
+	return lo.Map(ret, func(o model.TempObject, idx int) cdssdk.Object { return o.ToObject() }), err
+}
+
 func (db *ObjectDB) BatchGetByPackagePath(ctx SQLContext, pkgID cdssdk.PackageID, pathes []string) ([]cdssdk.Object, error) {
 	if len(pathes) == 0 {
 		return nil, nil
@@ -119,6 +133,54 @@ func (*ObjectDB) GetPackageObjects(ctx SQLContext, packageID cdssdk.PackageID) (
 	return lo.Map(ret, func(o model.TempObject, idx int) model.Object { return o.ToObject() }), err
 }
 
+type ObjectListFilter struct {
+	Prefix        string // 为空时不限制
+	Marker        string // 只返回路径大于Marker的对象，为空时不限制
+	MinSize       *int64
+	MaxSize       *int64
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+}
+
+// 按照路径升序查询Package中满足条件的对象，最多返回limit个。
+// 注：数据库的排序规则可能不区分大小写，因此Prefix条件只能用于缩小范围，调用者需要自己再检查一次
+func (*ObjectDB) ListPackageObjects(ctx SQLContext, packageID cdssdk.PackageID, filter ObjectListFilter, limit int) ([]model.Object, error) {
+	stmt := "select * from Object force index(PackagePath) where PackageID = ?"
+	args := []any{packageID}
+
+	if filter.Prefix != "" {
+		stmt += " and Path like ?"
+		args = append(args, likePrefix(filter.Prefix))
+	}
+	// 按字节比较，与Go中字符串的比较规则保持一致，不受列的排序规则影响
+	if filter.Marker != "" {
+		stmt += " and binary Path > ?"
+		args = append(args, filter.Marker)
+	}
+	if filter.MinSize != nil {
+		stmt += " and Size >= ?"
+		args = append(args, *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		stmt += " and Size <= ?"
+		args = append(args, *filter.MaxSize)
+	}
+	if filter.UpdatedAfter != nil {
+		stmt += " and UpdateTime >= ?"
+		args = append(args, *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		stmt += " and UpdateTime < ?"
+		args = append(args, *filter.UpdatedBefore)
+	}
+	stmt += " order by binary Path asc limit ?"
+	args = append(args, limit)
+
+	var ret []model.TempObject
+	err := sqlx.Select(ctx, &ret, stmt, args...)
+	return lo.Map(ret, func(o model.TempObject, idx int) model.Object { return o.ToObject() }), err
+}
+
 func (db *ObjectDB) GetPackageObjectDetails(ctx SQLContext, packageID cdssdk.PackageID) ([]stgmod.ObjectDetail, error) {
 	var objs []model.TempObject
 	err := sqlx.Select(ctx, &objs, "select * from Object where PackageID = ? order by ObjectID asc", packageID)
@@ -140,8 +202,14 @@ func (db *ObjectDB) GetPackageObjectDetails(ctx SQLContext, packageID cdssdk.Pac
 		return nil, fmt.Errorf("getting all pinned objects: %w", err)
 	}
 
+	allMetas, err := db.ObjectMetadata().GetPackageMetadatas(ctx, packageID)
+	if err != nil {
+		return nil, fmt.Errorf("getting all object metadatas: %w", err)
+	}
+
 	blksCur := 0
 	pinnedsCur := 0
+	metasCur := 0
 	for _, temp := range objs {
 		detail := stgmod.ObjectDetail{
 			Object: temp.ToObject(),
@@ -165,6 +233,11 @@ func (db *ObjectDB) GetPackageObjectDetails(ctx SQLContext, packageID cdssdk.Pac
 			detail.PinnedAt = append(detail.PinnedAt, allPinnedObjs[pinnedsCur].NodeID)
 		}
 
+		if metasCur < len(allMetas) && allMetas[metasCur].ObjectID == temp.ObjectID {
+			detail.Metadata = &allMetas[metasCur]
+			metasCur++
+		}
+
 		rets = append(rets, detail)
 	}
 
@@ -178,26 +251,48 @@ func (db *ObjectDB) BatchAdd(ctx SQLContext, packageID cdssdk.PackageID, adds []
 
 	objs := make([]cdssdk.Object, 0, len(adds))
 	for _, add := range adds {
+		// 首次上传默认使用不分块的none模式
+		var red cdssdk.Redundancy = cdssdk.NewNoneRedundancy()
+		if add.Redundancy != nil {
+			red = add.Redundancy
+		}
+
 		objs = append(objs, cdssdk.Object{
 			PackageID:  packageID,
 			Path:       add.Path,
 			Size:       add.Size,
 			FileHash:   add.FileHash,
-			Redundancy: cdssdk.NewNoneRedundancy(), // 首次上传默认使用不分块的none模式
+			Redundancy: red,
 			CreateTime: add.UploadTime,
 			UpdateTime: add.UploadTime,
 		})
 	}
 
-	err := db.BatchUpsertByPackagePath(ctx, objs)
+	pathes := make([]string, 0, len(adds))
+	for _, add := range adds {
+		pathes = append(pathes, add.Path)
+	}
+
+	// 如果Package开启了多版本，那么被覆盖的对象需要先保存为历史版本
+	oldObjs, err := db.BatchGetByPackagePath(ctx, packageID, pathes)
+	if err != nil {
+		return nil, fmt.Errorf("batch get old objects: %w", err)
+	}
+	err = db.ObjectVersion().ArchiveObjects(ctx, oldObjs)
+	if err != nil {
+		return nil, fmt.Errorf("archiving old objects: %w", err)
+	}
+
+	err = db.BatchUpsertByPackagePath(ctx, objs)
 	if err != nil {
 		return nil, fmt.Errorf("batch create or update objects: %w", err)
 	}
 
-	pathes := make([]string, 0, len(adds))
-	for _, add := range adds {
-		pathes = append(pathes, add.Path)
+	err = db.Quota().AddObjectsUsage(ctx, objs, oldObjs)
+	if err != nil {
+		return nil, fmt.Errorf("updating package usage: %w", err)
 	}
+
 	// 这里可以不用检查查询结果是否与pathes的数量相同
 	addedObjs, err := db.BatchGetByPackagePath(ctx, packageID, pathes)
 	if err != nil {
@@ -223,25 +318,56 @@ func (db *ObjectDB) BatchAdd(ctx SQLContext, packageID cdssdk.PackageID, adds []
 		return nil, fmt.Errorf("batch delete pinned objects: %w", err)
 	}
 
-	objBlocks := make([]stgmod.ObjectBlock, 0, len(adds))
+	// 覆盖对象时不保留原对象的元数据
+	err = db.ObjectMetadata().BatchDeleteByObjectID(ctx, addedObjIDs)
+	if err != nil {
+		return nil, fmt.Errorf("batch delete object metadatas: %w", err)
+	}
+
+	var metas []model.ObjectMetadata
 	for i, add := range adds {
-		objBlocks = append(objBlocks, stgmod.ObjectBlock{
-			ObjectID: addedObjIDs[i],
-			Index:    0,
-			NodeID:   add.NodeID,
-			FileHash: add.FileHash,
+		if add.ContentType == "" && len(add.UserMetadata) == 0 {
+			continue
+		}
+
+		metas = append(metas, model.ObjectMetadata{
+			ObjectID:     addedObjIDs[i],
+			ContentType:  add.ContentType,
+			UserMetadata: add.UserMetadata,
 		})
 	}
+	err = db.ObjectMetadata().BatchUpsert(ctx, metas)
+	if err != nil {
+		return nil, fmt.Errorf("batch create object metadatas: %w", err)
+	}
+
+	objBlocks := make([]stgmod.ObjectBlock, 0, len(adds))
+	for i, add := range adds {
+		if len(add.Blocks) == 0 {
+			objBlocks = append(objBlocks, stgmod.ObjectBlock{
+				ObjectID: addedObjIDs[i],
+				Index:    0,
+				NodeID:   add.NodeID,
+				FileHash: add.FileHash,
+			})
+			continue
+		}
+
+		for _, blk := range add.Blocks {
+			blk.ObjectID = addedObjIDs[i]
+			objBlocks = append(objBlocks, blk)
+		}
+	}
 	err = db.ObjectBlock().BatchCreate(ctx, objBlocks)
 	if err != nil {
 		return nil, fmt.Errorf("batch create object blocks: %w", err)
 	}
 
-	caches := make([]model.Cache, 0, len(adds))
-	for _, add := range adds {
+	caches := make([]model.Cache, 0, len(objBlocks))
+	for _, blk := range objBlocks {
 		caches = append(caches, model.Cache{
-			FileHash:   add.FileHash,
-			NodeID:     add.NodeID,
+			FileHash:   blk.FileHash,
+			NodeID:     blk.NodeID,
 			CreateTime: time.Now(),
 			Priority:   0,
 		})
@@ -251,6 +377,28 @@ func (db *ObjectDB) BatchAdd(ctx SQLContext, packageID cdssdk.PackageID, adds []
 		return nil, fmt.Errorf("batch create caches: %w", err)
 	}
 
+	// 覆盖对象时，原对象的校验和也不再有效
+	err = db.ObjectChecksum().BatchDeleteByObjectID(ctx, addedObjIDs)
+	if err != nil {
+		return nil, fmt.Errorf("batch delete object checksums: %w", err)
+	}
+
+	var checksums []model.ObjectChecksum
+	for i, add := range adds {
+		if add.SHA256 != "" && add.MD5 != "" {
+			checksums = append(checksums, model.ObjectChecksum{
+				ObjectID:   addedObjIDs[i],
+				SHA256:     add.SHA256,
+				MD5:        add.MD5,
+				CreateTime: time.Now(),
+			})
+		}
+	}
+	err = db.ObjectChecksum().BatchUpsert(ctx, checksums)
+	if err != nil {
+		return nil, fmt.Errorf("batch create object checksums: %w", err)
+	}
+
 	return addedObjs, nil
 }
 
@@ -272,8 +420,23 @@ func (db *ObjectDB) BatchUpdateRedundancy(ctx SQLContext, objs []coormq.Updating
 		})
 	}
 
+	// 冗余策略变化会改变对象实际占用的空间，需要根据原对象计算用量的变化
+	oldObjs, err := db.BatchGet(ctx, objIDs)
+	if err != nil {
+		return fmt.Errorf("batch get objects: %w", err)
+	}
+	redMap := make(map[cdssdk.ObjectID]cdssdk.Redundancy, len(objs))
+	for _, obj := range objs {
+		redMap[obj.ObjectID] = obj.Redundancy
+	}
+	newObjs := make([]cdssdk.Object, 0, len(oldObjs))
+	for _, obj := range oldObjs {
+		obj.Redundancy = redMap[obj.ObjectID]
+		newObjs = append(newObjs, obj)
+	}
+
 	// 目前只能使用这种方式来同时更新大量数据
-	err := BatchNamedExec(ctx,
+	err = BatchNamedExec(ctx,
 		"insert into Object(ObjectID, PackageID, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime)"+
 			" values(:ObjectID, :PackageID, :Path, :Size, :FileHash, :Redundancy, :CreateTime, :UpdateTime) as new"+
 			" on duplicate key update Redundancy=new.Redundancy", 8, dummyObjs, nil)
@@ -281,6 +444,11 @@ func (db *ObjectDB) BatchUpdateRedundancy(ctx SQLContext, objs []coormq.Updating
 		return fmt.Errorf("batch update object redundancy: %w", err)
 	}
 
+	err = db.Quota().AddObjectsUsage(ctx, newObjs, oldObjs)
+	if err != nil {
+		return fmt.Errorf("updating package usage: %w", err)
+	}
+
 	// 删除原本所有的编码块记录，重新添加
 	err = db.ObjectBlock().BatchDeleteByObjectID(ctx, objIDs)
 	if err != nil {
@@ -350,6 +518,68 @@ func (*ObjectDB) BatchDelete(ctx SQLContext, ids []cdssdk.ObjectID) error {
 	return err
 }
 
+// 将一个Package中的所有对象的元数据（Object、ObjectBlock、PinnedObject、ObjectMetadata、ObjectChecksum）复制到另一个Package中，
+// 复制出来的对象与原对象共用同样的文件，不会产生新的文件
+func (*ObjectDB) CopyPackageObjects(ctx SQLContext, srcPackageID cdssdk.PackageID, dstPackageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("insert into Object(PackageID, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime)"+
+		" select ?, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime from Object where PackageID = ?",
+		dstPackageID, srcPackageID)
+	if err != nil {
+		return fmt.Errorf("copying objects: %w", err)
+	}
+
+	// 同一个Package内Path唯一，因此通过Path就可以找到原对象与新对象的对应关系
+	_, err = ctx.Exec("insert into ObjectBlock(ObjectID, `Index`, NodeID, FileHash)"+
+		" select Dst.ObjectID, ObjectBlock.`Index`, ObjectBlock.NodeID, ObjectBlock.FileHash"+
+		" from ObjectBlock, Object as Src, Object as Dst where"+
+		" ObjectBlock.ObjectID = Src.ObjectID and"+
+		" Src.PackageID = ? and"+
+		" Dst.PackageID = ? and"+
+		" Dst.Path = Src.Path",
+		srcPackageID, dstPackageID)
+	if err != nil {
+		return fmt.Errorf("copying object blocks: %w", err)
+	}
+
+	_, err = ctx.Exec("insert into PinnedObject(NodeID, ObjectID, CreateTime)"+
+		" select PinnedObject.NodeID, Dst.ObjectID, PinnedObject.CreateTime"+
+		" from PinnedObject, Object as Src, Object as Dst where"+
+		" PinnedObject.ObjectID = Src.ObjectID and"+
+		" Src.PackageID = ? and"+
+		" Dst.PackageID = ? and"+
+		" Dst.Path = Src.Path",
+		srcPackageID, dstPackageID)
+	if err != nil {
+		return fmt.Errorf("copying pinned objects: %w", err)
+	}
+
+	_, err = ctx.Exec("insert into ObjectMetadata(ObjectID, ContentType, UserMetadata)"+
+		" select Dst.ObjectID, ObjectMetadata.ContentType, ObjectMetadata.UserMetadata"+
+		" from ObjectMetadata, Object as Src, Object as Dst where"+
+		" ObjectMetadata.ObjectID = Src.ObjectID and"+
+		" Src.PackageID = ? and"+
+		" Dst.PackageID = ? and"+
+		" Dst.Path = Src.Path",
+		srcPackageID, dstPackageID)
+	if err != nil {
+		return fmt.Errorf("copying object metadatas: %w", err)
+	}
+
+	_, err = ctx.Exec("insert into ObjectChecksum(ObjectID, SHA256, MD5, CreateTime)"+
+		" select Dst.ObjectID, ObjectChecksum.SHA256, ObjectChecksum.MD5, ObjectChecksum.CreateTime"+
+		" from ObjectChecksum, Object as Src, Object as Dst where"+
+		" ObjectChecksum.ObjectID = Src.ObjectID and"+
+		" Src.PackageID = ? and"+
+		" Dst.PackageID = ? and"+
+		" Dst.Path = Src.Path",
+		srcPackageID, dstPackageID)
+	if err != nil {
+		return fmt.Errorf("copying object checksums: %w", err)
+	}
+
+	return nil
+}
+
 func (*ObjectDB) DeleteInPackage(ctx SQLContext, packageID cdssdk.PackageID) error {
 	_, err := ctx.Exec("delete from Object where PackageID = ?", packageID)
 	return err
@@ -1,7 +1,6 @@
 package db
 
 import (
-	"database/sql"
 	"strconv"
 	"strings"
 
@@ -100,20 +99,6 @@ func (db *ObjectBlockDB) NodeBatchDelete(ctx SQLContext, nodeID cdssdk.NodeID, f
 	return err
 }
 
-func (db *ObjectBlockDB) CountBlockWithHash(ctx SQLContext, fileHash string) (int, error) {
-	var cnt int
-	err := sqlx.Get(ctx, &cnt,
-		"select count(FileHash) from ObjectBlock, Object, Package where FileHash = ? and"+
-			" ObjectBlock.ObjectID = Object.ObjectID and"+
-			" Object.PackageID = Package.PackageID and"+
-			" Package.State = ?", fileHash, cdssdk.PackageStateNormal)
-	if err == sql.ErrNoRows {
-		return 0, nil
-	}
-
-	return cnt, err
-}
-
 // 按逗号切割字符串，并将每一个部分解析为一个int64的ID。
 // 注：需要外部保证分隔的每一个部分都是正确的10进制数字格式
 func splitConcatedNodeID(idStr string) []cdssdk.NodeID {
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type ObjectChecksumDB struct {
+	*DB
+}
+
+func (db *DB) ObjectChecksum() *ObjectChecksumDB {
+	return &ObjectChecksumDB{DB: db}
+}
+
+func (*ObjectChecksumDB) BatchGetByObjectID(ctx SQLContext, objectIDs []cdssdk.ObjectID) ([]model.ObjectChecksum, error) {
+	if len(objectIDs) == 0 {
+		return nil, nil
+	}
+
+	stmt, args, err := sqlx.In("select * from ObjectChecksum where ObjectID in (?) order by ObjectID asc", objectIDs)
+	if err != nil {
+		return nil, err
+	}
+	stmt = ctx.Rebind(stmt)
+
+	var ret []model.ObjectChecksum
+	err = sqlx.Select(ctx, &ret, stmt, args...)
+	return ret, err
+}
+
+func (*ObjectChecksumDB) BatchUpsert(ctx SQLContext, checksums []model.ObjectChecksum) error {
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	sql := "insert into ObjectChecksum(ObjectID, SHA256, MD5, CreateTime)" +
+		" values(:ObjectID, :SHA256, :MD5, :CreateTime) as new" +
+		" on duplicate key update SHA256 = new.SHA256, MD5 = new.MD5, CreateTime = new.CreateTime"
+
+	return BatchNamedExec(ctx, sql, 4, checksums, nil)
+}
+
+func (*ObjectChecksumDB) BatchDeleteByObjectID(ctx SQLContext, objectIDs []cdssdk.ObjectID) error {
+	if len(objectIDs) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("delete from ObjectChecksum where ObjectID in (?)", objectIDs)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Exec(query, args...)
+	return err
+}
+
+func (*ObjectChecksumDB) DeleteInPackage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("delete ObjectChecksum from ObjectChecksum inner join Object on ObjectChecksum.ObjectID = Object.ObjectID where PackageID = ?", packageID)
+	return err
+}
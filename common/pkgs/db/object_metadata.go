@@ -0,0 +1,67 @@
+package db
+
+import (
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type ObjectMetadataDB struct {
+	*DB
+}
+
+func (db *DB) ObjectMetadata() *ObjectMetadataDB {
+	return &ObjectMetadataDB{DB: db}
+}
+
+func (*ObjectMetadataDB) BatchGetByObjectID(ctx SQLContext, objectIDs []cdssdk.ObjectID) ([]model.ObjectMetadata, error) {
+	if len(objectIDs) == 0 {
+		return nil, nil
+	}
+
+	stmt, args, err := sqlx.In("select * from ObjectMetadata where ObjectID in (?) order by ObjectID asc", objectIDs)
+	if err != nil {
+		return nil, err
+	}
+	stmt = ctx.Rebind(stmt)
+
+	var ret []model.ObjectMetadata
+	err = sqlx.Select(ctx, &ret, stmt, args...)
+	return ret, err
+}
+
+func (*ObjectMetadataDB) GetPackageMetadatas(ctx SQLContext, packageID cdssdk.PackageID) ([]model.ObjectMetadata, error) {
+	var ret []model.ObjectMetadata
+	err := sqlx.Select(ctx, &ret, "select ObjectMetadata.* from ObjectMetadata, Object where PackageID = ? and ObjectMetadata.ObjectID = Object.ObjectID order by ObjectMetadata.ObjectID asc", packageID)
+	return ret, err
+}
+
+func (*ObjectMetadataDB) BatchUpsert(ctx SQLContext, metas []model.ObjectMetadata) error {
+	if len(metas) == 0 {
+		return nil
+	}
+
+	sql := "insert into ObjectMetadata(ObjectID, ContentType, UserMetadata)" +
+		" values(:ObjectID, :ContentType, :UserMetadata) as new" +
+		" on duplicate key update ContentType = new.ContentType, UserMetadata = new.UserMetadata"
+
+	return BatchNamedExec(ctx, sql, 3, metas, nil)
+}
+
+func (*ObjectMetadataDB) BatchDeleteByObjectID(ctx SQLContext, objectIDs []cdssdk.ObjectID) error {
+	if len(objectIDs) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("delete from ObjectMetadata where ObjectID in (?)", objectIDs)
+	if err != nil {
+		return err
+	}
+	_, err = ctx.Exec(query, args...)
+	return err
+}
+
+func (*ObjectMetadataDB) DeleteInPackage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("delete ObjectMetadata from ObjectMetadata inner join Object on ObjectMetadata.ObjectID = Object.ObjectID where PackageID = ?", packageID)
+	return err
+}
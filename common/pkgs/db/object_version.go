@@ -0,0 +1,376 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+var ErrObjectVersionDegraded = errors.New("object version is degraded")
+
+type ObjectVersionDB struct {
+	*DB
+}
+
+func (db *DB) ObjectVersion() *ObjectVersionDB {
+	return &ObjectVersionDB{DB: db}
+}
+
+func (*ObjectVersionDB) IsPackageVersioning(ctx SQLContext, packageID cdssdk.PackageID) (bool, error) {
+	var pkgID cdssdk.PackageID
+	err := sqlx.Get(ctx, &pkgID, "select PackageID from PackageVersioning where PackageID = ?", packageID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// 开启或关闭Package的多版本功能。关闭时不会删除已有的历史版本
+func (*ObjectVersionDB) SetPackageVersioning(ctx SQLContext, packageID cdssdk.PackageID, enabled bool) error {
+	if enabled {
+		_, err := ctx.Exec("insert ignore into PackageVersioning(PackageID, CreateTime) values(?,?)", packageID, time.Now())
+		return err
+	}
+
+	_, err := ctx.Exec("delete from PackageVersioning where PackageID = ?", packageID)
+	return err
+}
+
+// 将对象当前的信息、编码块、元数据以及校验和保存为历史版本，并计入Package的用量。只有开启了多版本的Package中的对象才会被保存
+func (db *ObjectVersionDB) ArchiveObjects(ctx SQLContext, objs []cdssdk.Object) error {
+	if len(objs) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("select PackageID from PackageVersioning where PackageID in (?)",
+		lo.Uniq(lo.Map(objs, func(obj cdssdk.Object, idx int) cdssdk.PackageID { return obj.PackageID })))
+	if err != nil {
+		return err
+	}
+	var versioningPkgIDs []cdssdk.PackageID
+	err = sqlx.Select(ctx, &versioningPkgIDs, ctx.Rebind(query), args...)
+	if err != nil {
+		return fmt.Errorf("getting versioning packages: %w", err)
+	}
+
+	archived := lo.Filter(objs, func(obj cdssdk.Object, idx int) bool { return lo.Contains(versioningPkgIDs, obj.PackageID) })
+	if len(archived) == 0 {
+		return nil
+	}
+
+	archiveTime := time.Now()
+	vers := lo.Map(archived, func(obj cdssdk.Object, idx int) archivingObject {
+		return archivingObject{Object: obj, ArchiveTime: archiveTime}
+	})
+
+	// 一条插入语句生成的自增ID一定大于等于这条语句的LastInsertId，因此用第一批的LastInsertId加上ObjectID就能找到所有新的历史版本。
+	// 同一个对象不会同时被多个事务归档，所以不会找到其他事务产生的历史版本
+	var firstVersionID int64
+	var idErr error
+	err = BatchNamedExec(ctx,
+		"insert into ObjectVersion(ObjectID, PackageID, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime, ArchiveTime)"+
+			" values(:ObjectID, :PackageID, :Path, :Size, :FileHash, :Redundancy, :CreateTime, :UpdateTime, :ArchiveTime)", 9, vers,
+		func(ret sql.Result) bool {
+			if firstVersionID == 0 {
+				firstVersionID, idErr = ret.LastInsertId()
+			}
+			return idErr == nil
+		})
+	if err != nil {
+		return fmt.Errorf("inserting object versions: %w", err)
+	}
+	if idErr != nil {
+		return fmt.Errorf("getting id of inserted object versions: %w", idErr)
+	}
+
+	objIDs := lo.Map(archived, func(obj cdssdk.Object, idx int) cdssdk.ObjectID { return obj.ObjectID })
+
+	err = db.copyToNewVersions(ctx, "insert into ObjectVersionBlock(VersionID, ObjectID, `Index`, NodeID, FileHash)"+
+		" select ObjectVersion.VersionID, ObjectBlock.ObjectID, ObjectBlock.`Index`, ObjectBlock.NodeID, ObjectBlock.FileHash"+
+		" from ObjectVersion inner join ObjectBlock on ObjectVersion.ObjectID = ObjectBlock.ObjectID", firstVersionID, objIDs)
+	if err != nil {
+		return fmt.Errorf("copying object blocks: %w", err)
+	}
+
+	err = db.copyToNewVersions(ctx, "insert into ObjectVersionMetadata(VersionID, ContentType, UserMetadata)"+
+		" select ObjectVersion.VersionID, ObjectMetadata.ContentType, ObjectMetadata.UserMetadata"+
+		" from ObjectVersion inner join ObjectMetadata on ObjectVersion.ObjectID = ObjectMetadata.ObjectID", firstVersionID, objIDs)
+	if err != nil {
+		return fmt.Errorf("copying object metadata: %w", err)
+	}
+
+	err = db.copyToNewVersions(ctx, "insert into ObjectVersionChecksum(VersionID, SHA256, MD5, CreateTime)"+
+		" select ObjectVersion.VersionID, ObjectChecksum.SHA256, ObjectChecksum.MD5, ObjectChecksum.CreateTime"+
+		" from ObjectVersion inner join ObjectChecksum on ObjectVersion.ObjectID = ObjectChecksum.ObjectID", firstVersionID, objIDs)
+	if err != nil {
+		return fmt.Errorf("copying object checksum: %w", err)
+	}
+
+	err = db.Quota().AddVersionsUsage(ctx, archived)
+	if err != nil {
+		return fmt.Errorf("adding object versions usage: %w", err)
+	}
+
+	return nil
+}
+
+type archivingObject struct {
+	cdssdk.Object
+	ArchiveTime time.Time `db:"ArchiveTime"`
+}
+
+// 执行insert ... select语句，只复制到ArchiveObjects中新产生的历史版本上
+func (*ObjectVersionDB) copyToNewVersions(ctx SQLContext, insertSelect string, firstVersionID int64, objIDs []cdssdk.ObjectID) error {
+	query, args, err := sqlx.In(insertSelect+" where ObjectVersion.VersionID >= ? and ObjectVersion.ObjectID in (?)", firstVersionID, objIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.Exec(ctx.Rebind(query), args...)
+	return err
+}
+
+// 将一个Package中的所有历史版本复制到另一个Package中，与原历史版本共用同样的文件
+func (*ObjectVersionDB) CopyPackageVersions(ctx SQLContext, srcPackageID cdssdk.PackageID, dstPackageID cdssdk.PackageID) error {
+	var verIDs []model.ObjectVersionID
+	err := sqlx.Select(ctx, &verIDs, "select VersionID from ObjectVersion where PackageID = ? order by VersionID asc", srcPackageID)
+	if err != nil {
+		return fmt.Errorf("getting object versions: %w", err)
+	}
+
+	for _, verID := range verIDs {
+		ret, err := ctx.Exec("insert into ObjectVersion(ObjectID, PackageID, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime, ArchiveTime, Degraded)"+
+			" select ObjectID, ?, Path, Size, FileHash, Redundancy, CreateTime, UpdateTime, ArchiveTime, Degraded from ObjectVersion where VersionID = ?",
+			dstPackageID, verID)
+		if err != nil {
+			return fmt.Errorf("copying object version: %w", err)
+		}
+
+		newVerID, err := ret.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("getting id of inserted object version: %w", err)
+		}
+
+		_, err = ctx.Exec("insert into ObjectVersionBlock(VersionID, ObjectID, `Index`, NodeID, FileHash)"+
+			" select ?, ObjectID, `Index`, NodeID, FileHash from ObjectVersionBlock where VersionID = ?", newVerID, verID)
+		if err != nil {
+			return fmt.Errorf("copying object version blocks: %w", err)
+		}
+
+		_, err = ctx.Exec("insert into ObjectVersionMetadata(VersionID, ContentType, UserMetadata)"+
+			" select ?, ContentType, UserMetadata from ObjectVersionMetadata where VersionID = ?", newVerID, verID)
+		if err != nil {
+			return fmt.Errorf("copying object version metadata: %w", err)
+		}
+
+		_, err = ctx.Exec("insert into ObjectVersionChecksum(VersionID, SHA256, MD5, CreateTime)"+
+			" select ?, SHA256, MD5, CreateTime from ObjectVersionChecksum where VersionID = ?", newVerID, verID)
+		if err != nil {
+			return fmt.Errorf("copying object version checksum: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (*ObjectVersionDB) GetByID(ctx SQLContext, versionID model.ObjectVersionID) (model.ObjectVersion, error) {
+	var ret model.TempObjectVersion
+	err := sqlx.Get(ctx, &ret, "select * from ObjectVersion where VersionID = ?", versionID)
+	return ret.ToObjectVersion(), err
+}
+
+// 查询指定路径的所有历史版本，结果按照版本从新到旧排序
+func (*ObjectVersionDB) GetByPackagePath(ctx SQLContext, packageID cdssdk.PackageID, path string) ([]model.ObjectVersion, error) {
+	var ret []model.TempObjectVersion
+	err := sqlx.Select(ctx, &ret, "select * from ObjectVersion where PackageID = ? and Path = ? order by VersionID desc", packageID, path)
+	return lo.Map(ret, func(v model.TempObjectVersion, idx int) model.ObjectVersion { return v.ToObjectVersion() }), err
+}
+
+func (*ObjectVersionDB) GetBlocks(ctx SQLContext, versionID model.ObjectVersionID) ([]stgmod.ObjectBlock, error) {
+	var ret []stgmod.ObjectBlock
+	err := sqlx.Select(ctx, &ret, "select ObjectID, `Index`, NodeID, FileHash from ObjectVersionBlock where VersionID = ? order by `Index` asc", versionID)
+	return ret, err
+}
+
+func (*ObjectVersionDB) GetBlocksByNodeID(ctx SQLContext, nodeID cdssdk.NodeID) ([]stgmod.ObjectBlock, error) {
+	var ret []stgmod.ObjectBlock
+	err := sqlx.Select(ctx, &ret, "select ObjectID, `Index`, NodeID, FileHash from ObjectVersionBlock where NodeID = ?", nodeID)
+	return ret, err
+}
+
+// 将在节点上有编码块的历史版本标记为已损坏，用于节点不可用时。历史版本的编码块不会被修复
+func (*ObjectVersionDB) MarkDegradedByNode(ctx SQLContext, nodeID cdssdk.NodeID) error {
+	_, err := ctx.Exec("update ObjectVersion inner join ObjectVersionBlock on ObjectVersion.VersionID = ObjectVersionBlock.VersionID"+
+		" set ObjectVersion.Degraded = true where ObjectVersionBlock.NodeID = ?", nodeID)
+	return err
+}
+
+// 将节点上这些文件所对应的编码块所属的历史版本标记为已损坏，用于发现节点上的文件丢失或者损坏时
+func (*ObjectVersionDB) MarkDegradedByNodeFiles(ctx SQLContext, nodeID cdssdk.NodeID, fileHashes []string) error {
+	if len(fileHashes) == 0 {
+		return nil
+	}
+
+	query, args, err := sqlx.In("update ObjectVersion inner join ObjectVersionBlock on ObjectVersion.VersionID = ObjectVersionBlock.VersionID"+
+		" set ObjectVersion.Degraded = true where ObjectVersionBlock.NodeID = ? and ObjectVersionBlock.FileHash in (?)", nodeID, fileHashes)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.Exec(ctx.Rebind(query), args...)
+	return err
+}
+
+func (db *ObjectVersionDB) GetDetail(ctx SQLContext, versionID model.ObjectVersionID) (stgmod.ObjectDetail, error) {
+	ver, err := db.GetByID(ctx, versionID)
+	if err != nil {
+		return stgmod.ObjectDetail{}, err
+	}
+
+	blocks, err := db.GetBlocks(ctx, versionID)
+	if err != nil {
+		return stgmod.ObjectDetail{}, fmt.Errorf("getting object version blocks: %w", err)
+	}
+
+	return stgmod.NewObjectDetail(ver.Object, nil, blocks), nil
+}
+
+func (*ObjectVersionDB) DeleteInPackage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("delete ObjectVersionBlock from ObjectVersionBlock inner join ObjectVersion on ObjectVersionBlock.VersionID = ObjectVersion.VersionID where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("deleting object version blocks: %w", err)
+	}
+
+	_, err = ctx.Exec("delete ObjectVersionMetadata from ObjectVersionMetadata inner join ObjectVersion on ObjectVersionMetadata.VersionID = ObjectVersion.VersionID where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("deleting object version metadatas: %w", err)
+	}
+
+	_, err = ctx.Exec("delete ObjectVersionChecksum from ObjectVersionChecksum inner join ObjectVersion on ObjectVersionChecksum.VersionID = ObjectVersion.VersionID where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("deleting object version checksums: %w", err)
+	}
+
+	_, err = ctx.Exec("delete from ObjectVersion where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("deleting object versions: %w", err)
+	}
+
+	_, err = ctx.Exec("delete from PackageVersioning where PackageID = ?", packageID)
+	return err
+}
+
+// 将历史版本恢复为对象的当前版本，恢复前对象的当前版本会被保存为历史版本。
+// 如果对象已经被删除，则会重新创建它。已损坏的版本不能恢复，此时返回ErrObjectVersionDegraded
+func (db *ObjectVersionDB) Restore(ctx SQLContext, versionID model.ObjectVersionID) (cdssdk.Object, error) {
+	ver, err := db.GetByID(ctx, versionID)
+	if err != nil {
+		return cdssdk.Object{}, err
+	}
+	if ver.Degraded {
+		return cdssdk.Object{}, ErrObjectVersionDegraded
+	}
+
+	blocks, err := db.GetBlocks(ctx, versionID)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("getting object version blocks: %w", err)
+	}
+
+	curObjs, err := db.Object().BatchGetByPackagePath(ctx, ver.Object.PackageID, []string{ver.Object.Path})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("getting current object: %w", err)
+	}
+
+	err = db.ArchiveObjects(ctx, curObjs)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("archiving current object: %w", err)
+	}
+
+	restored := ver.Object
+	restored.UpdateTime = time.Now()
+	err = db.Object().BatchUpsertByPackagePath(ctx, []cdssdk.Object{restored})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("upserting object: %w", err)
+	}
+
+	err = db.Quota().AddObjectsUsage(ctx, []cdssdk.Object{restored}, curObjs)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("updating package usage: %w", err)
+	}
+
+	objs, err := db.Object().BatchGetByPackagePath(ctx, ver.Object.PackageID, []string{ver.Object.Path})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("getting restored object: %w", err)
+	}
+	if len(objs) == 0 {
+		return cdssdk.Object{}, fmt.Errorf("restored object not found")
+	}
+	obj := objs[0]
+
+	err = db.ObjectBlock().DeleteByObjectID(ctx, obj.ObjectID)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("deleting object blocks: %w", err)
+	}
+
+	err = db.PinnedObject().BatchDeleteByObjectID(ctx, []cdssdk.ObjectID{obj.ObjectID})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("deleting pinned objects: %w", err)
+	}
+
+	// 元数据和校验和也恢复为历史版本中保存的，历史版本中没有的则删除
+	err = db.ObjectMetadata().BatchDeleteByObjectID(ctx, []cdssdk.ObjectID{obj.ObjectID})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("deleting object metadata: %w", err)
+	}
+
+	_, err = ctx.Exec("insert into ObjectMetadata(ObjectID, ContentType, UserMetadata)"+
+		" select ?, ContentType, UserMetadata from ObjectVersionMetadata where VersionID = ?", obj.ObjectID, versionID)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("restoring object metadata: %w", err)
+	}
+
+	err = db.ObjectChecksum().BatchDeleteByObjectID(ctx, []cdssdk.ObjectID{obj.ObjectID})
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("deleting object checksum: %w", err)
+	}
+
+	_, err = ctx.Exec("insert into ObjectChecksum(ObjectID, SHA256, MD5, CreateTime)"+
+		" select ?, SHA256, MD5, CreateTime from ObjectVersionChecksum where VersionID = ?", obj.ObjectID, versionID)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("restoring object checksum: %w", err)
+	}
+
+	caches := make([]model.Cache, 0, len(blocks))
+	for i := range blocks {
+		blocks[i].ObjectID = obj.ObjectID
+		caches = append(caches, model.Cache{
+			FileHash:   blocks[i].FileHash,
+			NodeID:     blocks[i].NodeID,
+			CreateTime: time.Now(),
+			Priority:   0,
+		})
+	}
+
+	err = db.ObjectBlock().BatchCreate(ctx, blocks)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("creating object blocks: %w", err)
+	}
+
+	err = db.Cache().BatchCreate(ctx, caches)
+	if err != nil {
+		return cdssdk.Object{}, fmt.Errorf("creating caches: %w", err)
+	}
+
+	return obj, nil
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 
@@ -37,6 +38,14 @@ func (*PackageDB) BatchGetAllPackageIDs(ctx SQLContext, start int, count int) ([
 	return ret, err
 }
 
+// 按PackageID的顺序分批查询属于一个分片的Package，PackageID除以shardCount的余数为shard的Package属于这个分片。
+// 只查询PackageID大于afterID的Package
+func (*PackageDB) BatchGetPackageIDsInShard(ctx SQLContext, shard int, shardCount int, afterID cdssdk.PackageID, count int) ([]cdssdk.PackageID, error) {
+	var ret []cdssdk.PackageID
+	err := sqlx.Select(ctx, &ret, "select PackageID from Package where PackageID > ? and PackageID % ? = ? order by PackageID limit ?", afterID, shardCount, shard, count)
+	return ret, err
+}
+
 func (db *PackageDB) GetBucketPackages(ctx SQLContext, userID cdssdk.UserID, bucketID cdssdk.BucketID) ([]model.Package, error) {
 	var ret []model.Package
 	err := sqlx.Select(ctx, &ret, "select Package.* from UserBucket, Package where UserID = ? and UserBucket.BucketID = ? and UserBucket.BucketID = Package.BucketID", userID, bucketID)
@@ -145,10 +154,30 @@ func (db *PackageDB) SoftDelete(ctx SQLContext, packageID cdssdk.PackageID) erro
 		return fmt.Errorf("deleting pinned objects in package: %w", err)
 	}
 
+	if err := db.ObjectMetadata().DeleteInPackage(ctx, packageID); err != nil {
+		return fmt.Errorf("deleting object metadatas in package: %w", err)
+	}
+
+	if err := db.ObjectChecksum().DeleteInPackage(ctx, packageID); err != nil {
+		return fmt.Errorf("deleting object checksums in package: %w", err)
+	}
+
 	if err := db.Object().DeleteInPackage(ctx, packageID); err != nil {
 		return fmt.Errorf("deleting objects in package: %w", err)
 	}
 
+	if err := db.ObjectVersion().DeleteInPackage(ctx, packageID); err != nil {
+		return fmt.Errorf("deleting object versions in package: %w", err)
+	}
+
+	if err := db.Quota().DeletePackageUsage(ctx, packageID); err != nil {
+		return fmt.Errorf("deleting package usage: %w", err)
+	}
+
+	if _, err := ctx.Exec("delete from PackageSnapshot where PackageID = ?", packageID); err != nil {
+		return fmt.Errorf("deleting package snapshot: %w", err)
+	}
+
 	_, err = db.StoragePackage().SetAllPackageDeleted(ctx, packageID)
 	if err != nil {
 		return fmt.Errorf("set storage package deleted failed, err: %w", err)
@@ -173,3 +202,22 @@ func (*PackageDB) ChangeState(ctx SQLContext, packageID cdssdk.PackageID, state
 	_, err := ctx.Exec("update Package set State = ? where PackageID = ?", state, packageID)
 	return err
 }
+
+// 将Package标记为另一个Package的快照，快照中的对象不能被修改
+func (*PackageDB) MarkSnapshot(ctx SQLContext, packageID cdssdk.PackageID, srcPackageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("insert into PackageSnapshot(PackageID, SourcePackageID, CreateTime) values(?,?,?)", packageID, srcPackageID, time.Now())
+	return err
+}
+
+func (*PackageDB) IsSnapshot(ctx SQLContext, packageID cdssdk.PackageID) (bool, error) {
+	var pkgID cdssdk.PackageID
+	err := sqlx.Get(ctx, &pkgID, "select PackageID from PackageSnapshot where PackageID = ?", packageID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,270 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/math2"
+	"gitlink.org.cn/cloudream/common/utils/sort2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+type QuotaDB struct {
+	*DB
+}
+
+func (db *DB) Quota() *QuotaDB {
+	return &QuotaDB{DB: db}
+}
+
+// 查询用户的配额，没有设置配额时返回的Quota中所有字段都为nil
+func (*QuotaDB) GetUserQuota(ctx SQLContext, userID cdssdk.UserID) (model.Quota, error) {
+	var ret model.Quota
+	err := sqlx.Get(ctx, &ret, "select MaxLogicalBytes, MaxPhysicalBytes, MaxObjectCount from UserQuota where UserID = ?", userID)
+	if err == sql.ErrNoRows {
+		return model.Quota{}, nil
+	}
+	return ret, err
+}
+
+func (*QuotaDB) SetUserQuota(ctx SQLContext, userID cdssdk.UserID, quota model.Quota) error {
+	_, err := ctx.Exec("insert into UserQuota(UserID, MaxLogicalBytes, MaxPhysicalBytes, MaxObjectCount) values(?,?,?,?) as new"+
+		" on duplicate key update MaxLogicalBytes=new.MaxLogicalBytes, MaxPhysicalBytes=new.MaxPhysicalBytes, MaxObjectCount=new.MaxObjectCount",
+		userID, quota.MaxLogicalBytes, quota.MaxPhysicalBytes, quota.MaxObjectCount)
+	return err
+}
+
+// 查询桶的配额，没有设置配额时返回的Quota中所有字段都为nil
+func (*QuotaDB) GetBucketQuota(ctx SQLContext, bucketID cdssdk.BucketID) (model.Quota, error) {
+	var ret model.Quota
+	err := sqlx.Get(ctx, &ret, "select MaxLogicalBytes, MaxPhysicalBytes, MaxObjectCount from BucketQuota where BucketID = ?", bucketID)
+	if err == sql.ErrNoRows {
+		return model.Quota{}, nil
+	}
+	return ret, err
+}
+
+func (*QuotaDB) SetBucketQuota(ctx SQLContext, bucketID cdssdk.BucketID, quota model.Quota) error {
+	_, err := ctx.Exec("insert into BucketQuota(BucketID, MaxLogicalBytes, MaxPhysicalBytes, MaxObjectCount) values(?,?,?,?) as new"+
+		" on duplicate key update MaxLogicalBytes=new.MaxLogicalBytes, MaxPhysicalBytes=new.MaxPhysicalBytes, MaxObjectCount=new.MaxObjectCount",
+		bucketID, quota.MaxLogicalBytes, quota.MaxPhysicalBytes, quota.MaxObjectCount)
+	return err
+}
+
+func (*QuotaDB) DeleteBucketQuota(ctx SQLContext, bucketID cdssdk.BucketID) error {
+	_, err := ctx.Exec("delete from BucketQuota where BucketID = ?", bucketID)
+	return err
+}
+
+type objectSizeAndRedundancy struct {
+	Size       int64                   `db:"Size"`
+	Redundancy model.RedundancyWarpper `db:"Redundancy"`
+}
+
+// 重新统计Package中的对象以及对象历史版本的用量，覆盖原有的用量计数。会扫描整个Package，
+// 只用于修复用量计数，修改对象时应该使用AddObjectsUsage和AddVersionsUsage增量更新
+func (*QuotaDB) RefreshPackageUsage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	var objs []objectSizeAndRedundancy
+	err := sqlx.Select(ctx, &objs, "select Size, Redundancy from Object where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("getting objects: %w", err)
+	}
+
+	var vers []objectSizeAndRedundancy
+	err = sqlx.Select(ctx, &vers, "select Size, Redundancy from ObjectVersion where PackageID = ?", packageID)
+	if err != nil {
+		return fmt.Errorf("getting object versions: %w", err)
+	}
+
+	usage := summaryUsage(objs)
+	verUsage := summaryUsage(vers)
+	usage.LogicalBytes += verUsage.LogicalBytes
+	usage.PhysicalBytes += verUsage.PhysicalBytes
+
+	_, err = ctx.Exec("replace into PackageUsage(PackageID, LogicalBytes, PhysicalBytes, ObjectCount) values(?,?,?,?)",
+		packageID, usage.LogicalBytes, usage.PhysicalBytes, usage.ObjectCount)
+	return err
+}
+
+// 按照对象的变化增量更新所在Package的用量计数。added是新增的对象，removed是被删除或者被覆盖的对象，
+// 被修改的对象需要同时出现在两者中。应该在修改了对象之后、在同一个事务中调用
+func (db *QuotaDB) AddObjectsUsage(ctx SQLContext, added []cdssdk.Object, removed []cdssdk.Object) error {
+	deltas := make(map[cdssdk.PackageID]*model.StorageUsage)
+	addUsageDelta(deltas, added, 1, true)
+	addUsageDelta(deltas, removed, -1, true)
+	return db.applyUsageDeltas(ctx, deltas)
+}
+
+// 将新保存的历史版本的用量计入所在Package的用量计数，历史版本不计入对象数量
+func (db *QuotaDB) AddVersionsUsage(ctx SQLContext, archived []cdssdk.Object) error {
+	deltas := make(map[cdssdk.PackageID]*model.StorageUsage)
+	addUsageDelta(deltas, archived, 1, false)
+	return db.applyUsageDeltas(ctx, deltas)
+}
+
+// 复制Package的对象和历史版本时，用量计数也直接复制
+func (*QuotaDB) CopyPackageUsage(ctx SQLContext, srcPackageID cdssdk.PackageID, dstPackageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("insert into PackageUsage(PackageID, LogicalBytes, PhysicalBytes, ObjectCount)"+
+		" select ?, LogicalBytes, PhysicalBytes, ObjectCount from PackageUsage where PackageID = ?",
+		dstPackageID, srcPackageID)
+	return err
+}
+
+func addUsageDelta(deltas map[cdssdk.PackageID]*model.StorageUsage, objs []cdssdk.Object, sign int64, countObject bool) {
+	for _, obj := range objs {
+		d, ok := deltas[obj.PackageID]
+		if !ok {
+			d = &model.StorageUsage{}
+			deltas[obj.PackageID] = d
+		}
+
+		d.LogicalBytes += sign * obj.Size
+		d.PhysicalBytes += sign * PhysicalSize(obj.Size, obj.Redundancy)
+		if countObject {
+			d.ObjectCount += sign
+		}
+	}
+}
+
+func (*QuotaDB) applyUsageDeltas(ctx SQLContext, deltas map[cdssdk.PackageID]*model.StorageUsage) error {
+	// 按PackageID的顺序更新，避免多个事务之间死锁
+	pkgIDs := sort2.Sort(lo.Keys(deltas), func(l, r cdssdk.PackageID) int { return sort2.Cmp(l, r) })
+	for _, pkgID := range pkgIDs {
+		d := deltas[pkgID]
+		if *d == (model.StorageUsage{}) {
+			continue
+		}
+
+		_, err := ctx.Exec("insert into PackageUsage(PackageID, LogicalBytes, PhysicalBytes, ObjectCount) values(?,?,?,?) as new"+
+			" on duplicate key update LogicalBytes = PackageUsage.LogicalBytes + new.LogicalBytes,"+
+			" PhysicalBytes = PackageUsage.PhysicalBytes + new.PhysicalBytes, ObjectCount = PackageUsage.ObjectCount + new.ObjectCount",
+			pkgID, d.LogicalBytes, d.PhysicalBytes, d.ObjectCount)
+		if err != nil {
+			return fmt.Errorf("updating package %v usage: %w", pkgID, err)
+		}
+	}
+
+	return nil
+}
+
+func (*QuotaDB) DeletePackageUsage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("delete from PackageUsage where PackageID = ?", packageID)
+	return err
+}
+
+// 统计桶内所有正常状态的Package的用量
+func (*QuotaDB) GetBucketUsage(ctx SQLContext, bucketID cdssdk.BucketID) (model.StorageUsage, error) {
+	var ret model.StorageUsage
+	err := sqlx.Get(ctx, &ret,
+		"select coalesce(sum(LogicalBytes), 0) as LogicalBytes, coalesce(sum(PhysicalBytes), 0) as PhysicalBytes, coalesce(sum(ObjectCount), 0) as ObjectCount"+
+			" from PackageUsage, Package where PackageUsage.PackageID = Package.PackageID and Package.BucketID = ? and Package.State = ?",
+		bucketID, cdssdk.PackageStateNormal)
+	return ret, err
+}
+
+// 统计用户创建的所有桶的用量
+func (*QuotaDB) GetUserUsage(ctx SQLContext, userID cdssdk.UserID) (model.StorageUsage, error) {
+	var ret model.StorageUsage
+	err := sqlx.Get(ctx, &ret,
+		"select coalesce(sum(LogicalBytes), 0) as LogicalBytes, coalesce(sum(PhysicalBytes), 0) as PhysicalBytes, coalesce(sum(ObjectCount), 0) as ObjectCount"+
+			" from PackageUsage, Package, Bucket where PackageUsage.PackageID = Package.PackageID and Package.BucketID = Bucket.BucketID and"+
+			" Bucket.CreatorID = ? and Package.State = ?",
+		userID, cdssdk.PackageStateNormal)
+	return ret, err
+}
+
+// 检查Package所在的桶以及桶的创建者的用量是否超出了配额，超出时返回的错误包装了ErrQuotaExceeded。
+// 应该在添加对象或者修改冗余策略、并且更新了用量计数之后，在同一个事务中调用，使得超出配额时整个修改能被回滚
+func (db *QuotaDB) CheckPackage(ctx SQLContext, packageID cdssdk.PackageID) error {
+	pkg, err := db.Package().GetByID(ctx, packageID)
+	if err != nil {
+		return fmt.Errorf("getting package: %w", err)
+	}
+
+	bkt, err := db.Bucket().GetByID(ctx, pkg.BucketID)
+	if err != nil {
+		return fmt.Errorf("getting bucket: %w", err)
+	}
+
+	bktQuota, err := db.GetBucketQuota(ctx, bkt.BucketID)
+	if err != nil {
+		return fmt.Errorf("getting bucket quota: %w", err)
+	}
+	if isQuotaLimited(bktQuota) {
+		usage, err := db.GetBucketUsage(ctx, bkt.BucketID)
+		if err != nil {
+			return fmt.Errorf("getting bucket usage: %w", err)
+		}
+
+		if err := checkQuota(bktQuota, usage); err != nil {
+			return fmt.Errorf("bucket %v: %w", bkt.BucketID, err)
+		}
+	}
+
+	userQuota, err := db.GetUserQuota(ctx, bkt.CreatorID)
+	if err != nil {
+		return fmt.Errorf("getting user quota: %w", err)
+	}
+	if isQuotaLimited(userQuota) {
+		usage, err := db.GetUserUsage(ctx, bkt.CreatorID)
+		if err != nil {
+			return fmt.Errorf("getting user usage: %w", err)
+		}
+
+		if err := checkQuota(userQuota, usage); err != nil {
+			return fmt.Errorf("user %v: %w", bkt.CreatorID, err)
+		}
+	}
+
+	return nil
+}
+
+func isQuotaLimited(quota model.Quota) bool {
+	return quota.MaxLogicalBytes != nil || quota.MaxPhysicalBytes != nil || quota.MaxObjectCount != nil
+}
+
+func checkQuota(quota model.Quota, usage model.StorageUsage) error {
+	if quota.MaxLogicalBytes != nil && usage.LogicalBytes > *quota.MaxLogicalBytes {
+		return fmt.Errorf("%w: logical bytes %d > %d", ErrQuotaExceeded, usage.LogicalBytes, *quota.MaxLogicalBytes)
+	}
+
+	if quota.MaxPhysicalBytes != nil && usage.PhysicalBytes > *quota.MaxPhysicalBytes {
+		return fmt.Errorf("%w: physical bytes %d > %d", ErrQuotaExceeded, usage.PhysicalBytes, *quota.MaxPhysicalBytes)
+	}
+
+	if quota.MaxObjectCount != nil && usage.ObjectCount > *quota.MaxObjectCount {
+		return fmt.Errorf("%w: object count %d > %d", ErrQuotaExceeded, usage.ObjectCount, *quota.MaxObjectCount)
+	}
+
+	return nil
+}
+
+func summaryUsage(objs []objectSizeAndRedundancy) model.StorageUsage {
+	var usage model.StorageUsage
+	for _, obj := range objs {
+		usage.LogicalBytes += obj.Size
+		usage.PhysicalBytes += PhysicalSize(obj.Size, obj.Redundancy.Value)
+		usage.ObjectCount++
+	}
+	return usage
+}
+
+// 计算一个对象按照冗余策略存储后实际占用的空间大小
+func PhysicalSize(size int64, red cdssdk.Redundancy) int64 {
+	switch red := red.(type) {
+	case *cdssdk.RepRedundancy:
+		return size * int64(red.RepCount)
+	case *cdssdk.ECRedundancy:
+		return math2.CeilDiv(size*int64(red.N), int64(red.K))
+	case *cdssdk.LRCRedundancy:
+		return math2.CeilDiv(size*int64(red.N), int64(red.K))
+	}
+
+	return size
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type RedundancyPlanDB struct {
+	*DB
+}
+
+func (db *DB) RedundancyPlan() *RedundancyPlanDB {
+	return &RedundancyPlanDB{DB: db}
+}
+
+func (*RedundancyPlanDB) Create(ctx SQLContext, plan model.RedundancyPlan) (model.RedundancyPlanID, error) {
+	objs, err := serder.ObjectToJSONEx(plan.Objects)
+	if err != nil {
+		return 0, fmt.Errorf("serializing objects: %w", err)
+	}
+
+	ret, err := ctx.Exec("insert into RedundancyPlan(PackageID, State, ObjectCount, TotalSize, TransferBytes, OldStoredBytes, NewStoredBytes, Objects, CreateTime, UpdateTime) values(?,?,?,?,?,?,?,?,?,?)",
+		plan.PackageID, plan.State, plan.ObjectCount, plan.TotalSize, plan.TransferBytes, plan.OldStoredBytes, plan.NewStoredBytes, string(objs), plan.CreateTime, plan.UpdateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return model.RedundancyPlanID(id), nil
+}
+
+func (*RedundancyPlanDB) GetByID(ctx SQLContext, planID model.RedundancyPlanID) (model.RedundancyPlan, error) {
+	var ret model.TempRedundancyPlan
+	err := sqlx.Get(ctx, &ret, "select * from RedundancyPlan where PlanID = ?", planID)
+	return ret.ToRedundancyPlan(), err
+}
+
+// 分页查询计划，最新的计划在前，不包含每个对象的修改计划。packageID为0、state为空时不作为过滤条件
+func (*RedundancyPlanDB) List(ctx SQLContext, packageID cdssdk.PackageID, state string, offset int, limit int) ([]model.RedundancyPlan, error) {
+	sql := "select PlanID, PackageID, State, ObjectCount, TotalSize, TransferBytes, OldStoredBytes, NewStoredBytes, CreateTime, UpdateTime from RedundancyPlan where 1 = 1"
+	var args []any
+	if packageID != 0 {
+		sql += " and PackageID = ?"
+		args = append(args, packageID)
+	}
+	if state != "" {
+		sql += " and State = ?"
+		args = append(args, state)
+	}
+	sql += " order by PlanID desc limit ?, ?"
+	args = append(args, offset, limit)
+
+	var ret []model.RedundancyPlan
+	err := sqlx.Select(ctx, &ret, sql, args...)
+	return ret, err
+}
+
+// 批准等待中或者执行失败的计划，返回计划是否被批准
+func (*RedundancyPlanDB) Approve(ctx SQLContext, planID model.RedundancyPlanID) (bool, error) {
+	ret, err := ctx.Exec("update RedundancyPlan set State = ?, UpdateTime = ? where PlanID = ? and State in (?, ?)",
+		model.RedundancyPlanStateApproved, time.Now(), planID, model.RedundancyPlanStatePending, model.RedundancyPlanStateFailed)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 撤销批准，计划重新回到等待批准的状态。用于批准后没能提交执行任务的情况
+func (*RedundancyPlanDB) Unapprove(ctx SQLContext, planID model.RedundancyPlanID) error {
+	_, err := ctx.Exec("update RedundancyPlan set State = ?, UpdateTime = ? where PlanID = ? and State = ?",
+		model.RedundancyPlanStatePending, time.Now(), planID, model.RedundancyPlanStateApproved)
+	return err
+}
+
+// 记录已批准的计划的执行结果，state为Executed或者Failed
+func (*RedundancyPlanDB) SetFinished(ctx SQLContext, planID model.RedundancyPlanID, state string) error {
+	_, err := ctx.Exec("update RedundancyPlan set State = ?, UpdateTime = ? where PlanID = ? and State = ?",
+		state, time.Now(), planID, model.RedundancyPlanStateApproved)
+	return err
+}
+
+// 删除Package所有等待批准的计划，定期检查生成新的计划之前使用，避免同一个Package堆积多个计划
+func (*RedundancyPlanDB) DeletePendingByPackageID(ctx SQLContext, packageID cdssdk.PackageID) error {
+	_, err := ctx.Exec("delete from RedundancyPlan where PackageID = ? and State = ?", packageID, model.RedundancyPlanStatePending)
+	return err
+}
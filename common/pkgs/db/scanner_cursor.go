@@ -0,0 +1,31 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ScannerCursorDB struct {
+	*DB
+}
+
+func (db *DB) ScannerCursor() *ScannerCursorDB {
+	return &ScannerCursorDB{DB: db}
+}
+
+// 查询上一次扫描到的最后一个ID，没有记录时返回0
+func (*ScannerCursorDB) Get(ctx SQLContext, name string) (int64, error) {
+	var ret int64
+	err := sqlx.Get(ctx, &ret, "select LastID from ScannerCursor where Name = ?", name)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return ret, err
+}
+
+func (*ScannerCursorDB) Set(ctx SQLContext, name string, lastID int64) error {
+	_, err := ctx.Exec("replace into ScannerCursor(Name, LastID, UpdateTime) values(?,?,?)", name, lastID, time.Now())
+	return err
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ScannerInstanceDB struct {
+	*DB
+}
+
+func (db *DB) ScannerInstance() *ScannerInstanceDB {
+	return &ScannerInstanceDB{DB: db}
+}
+
+func (*ScannerInstanceDB) Heartbeat(ctx SQLContext, instanceID string) error {
+	_, err := ctx.Exec("replace into ScannerInstance(InstanceID, HeartbeatTime) values(?,?)", instanceID, time.Now())
+	return err
+}
+
+// 查询在after之后有过心跳的实例的数量
+func (*ScannerInstanceDB) CountAlive(ctx SQLContext, after time.Time) (int, error) {
+	var ret int
+	err := sqlx.Get(ctx, &ret, "select count(*) from ScannerInstance where HeartbeatTime > ?", after)
+	return ret, err
+}
+
+func (*ScannerInstanceDB) DeleteDead(ctx SQLContext, before time.Time) error {
+	_, err := ctx.Exec("delete from ScannerInstance where HeartbeatTime < ?", before)
+	return err
+}
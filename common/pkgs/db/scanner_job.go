@@ -0,0 +1,158 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type ScannerJobDB struct {
+	*DB
+}
+
+func (db *DB) ScannerJob() *ScannerJobDB {
+	return &ScannerJobDB{DB: db}
+}
+
+func (*ScannerJobDB) Create(ctx SQLContext, job model.ScannerJob) (model.ScannerJobID, error) {
+	ret, err := ctx.Exec("insert into ScannerJob(Type, Content, IsEmergency, DontMerge, Shard, Owner, Epoch, State, Attempts, LastError, CreateTime, UpdateTime) values(?,?,?,?,?,?,?,?,?,?,?,?)",
+		job.Type, job.Content, job.IsEmergency, job.DontMerge, job.Shard, job.Owner, job.Epoch, job.State, job.Attempts, job.LastError, job.CreateTime, job.UpdateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return model.ScannerJobID(id), nil
+}
+
+func (*ScannerJobDB) GetByID(ctx SQLContext, jobID model.ScannerJobID) (model.ScannerJob, error) {
+	var ret model.ScannerJob
+	err := sqlx.Get(ctx, &ret, "select * from ScannerJob where JobID = ?", jobID)
+	return ret, err
+}
+
+// 查询这些分片中所有等待中的任务，按创建顺序排列
+func (*ScannerJobDB) GetPendingInShards(ctx SQLContext, shards []int) ([]model.ScannerJob, error) {
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	stmt, args, err := sqlx.In("select * from ScannerJob where State = ? and Shard in (?) order by JobID", model.ScannerJobStatePending, shards)
+	if err != nil {
+		return nil, err
+	}
+	stmt = ctx.Rebind(stmt)
+
+	var ret []model.ScannerJob
+	err = sqlx.Select(ctx, &ret, stmt, args...)
+	return ret, err
+}
+
+// 分页查询任务，最新的任务在前。state和typ为空时不作为过滤条件
+func (*ScannerJobDB) List(ctx SQLContext, state string, typ string, offset int, limit int) ([]model.ScannerJob, error) {
+	sql := "select * from ScannerJob where 1 = 1"
+	var args []any
+	if state != "" {
+		sql += " and State = ?"
+		args = append(args, state)
+	}
+	if typ != "" {
+		sql += " and Type = ?"
+		args = append(args, typ)
+	}
+	sql += " order by JobID desc limit ?, ?"
+	args = append(args, offset, limit)
+
+	var ret []model.ScannerJob
+	err := sqlx.Select(ctx, &ret, sql, args...)
+	return ret, err
+}
+
+// 将等待中的任务设置为由owner执行中，并增加执行次数。任务不处于等待状态时（比如已经被取消，或者被其他实例执行了），
+// 或者owner在epoch纪元的分片租约已经失效时返回false
+func (*ScannerJobDB) Start(ctx SQLContext, jobID model.ScannerJobID, owner string, epoch int64) (bool, error) {
+	now := time.Now()
+	ret, err := ctx.Exec("update ScannerJob set State = ?, Owner = ?, Epoch = ?, Attempts = Attempts + 1, UpdateTime = ? where JobID = ? and State = ?"+
+		" and exists (select * from ScannerShard where ScannerShard.Shard = ScannerJob.Shard and ScannerShard.Owner = ? and ScannerShard.Epoch = ? and LeaseExpire > ?)",
+		model.ScannerJobStateRunning, owner, epoch, now, jobID, model.ScannerJobStatePending, owner, epoch, now)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 设置执行中的任务的结果。state为Pending时代表任务将被重新执行。
+// 只有开始执行任务的owner和纪元才能设置，任务已经被分片的新拥有者重新执行时返回false
+func (*ScannerJobDB) Finish(ctx SQLContext, jobID model.ScannerJobID, owner string, epoch int64, state string, lastErr string) (bool, error) {
+	ret, err := ctx.Exec("update ScannerJob set State = ?, LastError = ?, UpdateTime = ? where JobID = ? and State = ? and Owner = ? and Epoch = ?",
+		state, lastErr, time.Now(), jobID, model.ScannerJobStateRunning, owner, epoch)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 将分片中由之前的纪元开始执行的任务重置为等待中，以便重新执行。这些任务的执行者已经失去了分片
+func (*ScannerJobDB) ResetRunningOfOldEpochs(ctx SQLContext, shard int, epoch int64) error {
+	_, err := ctx.Exec("update ScannerJob set State = ?, UpdateTime = ? where State = ? and Shard = ? and Epoch < ?",
+		model.ScannerJobStatePending, time.Now(), model.ScannerJobStateRunning, shard, epoch)
+	return err
+}
+
+// 更新等待中的任务的内容，用于其他任务合并进来之后
+func (*ScannerJobDB) UpdateContent(ctx SQLContext, jobID model.ScannerJobID, content string) error {
+	_, err := ctx.Exec("update ScannerJob set Content = ?, UpdateTime = ? where JobID = ? and State = ?",
+		content, time.Now(), jobID, model.ScannerJobStatePending)
+	return err
+}
+
+// 等待中的任务被合并到了其他任务中
+func (*ScannerJobDB) SetMerged(ctx SQLContext, jobID model.ScannerJobID) error {
+	_, err := ctx.Exec("update ScannerJob set State = ?, UpdateTime = ? where JobID = ? and State = ?",
+		model.ScannerJobStateMerged, time.Now(), jobID, model.ScannerJobStatePending)
+	return err
+}
+
+// 取消等待中的任务，返回任务是否被取消
+func (*ScannerJobDB) Cancel(ctx SQLContext, jobID model.ScannerJobID) (bool, error) {
+	ret, err := ctx.Exec("update ScannerJob set State = ?, UpdateTime = ? where JobID = ? and State = ?",
+		model.ScannerJobStateCanceled, time.Now(), jobID, model.ScannerJobStatePending)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 将失败或者被取消的任务重新设置为等待中，并清空执行次数，返回任务是否被重置
+func (*ScannerJobDB) Retry(ctx SQLContext, jobID model.ScannerJobID) (bool, error) {
+	ret, err := ctx.Exec("update ScannerJob set State = ?, Attempts = 0, UpdateTime = ? where JobID = ? and (State = ? or State = ?)",
+		model.ScannerJobStatePending, time.Now(), jobID, model.ScannerJobStateFailed, model.ScannerJobStateCanceled)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 删除在before之前就已经结束的任务，返回删除的数量
+func (*ScannerJobDB) DeleteFinishedBefore(ctx SQLContext, before time.Time) (int64, error) {
+	ret, err := ctx.Exec("delete from ScannerJob where State <> ? and State <> ? and UpdateTime < ?",
+		model.ScannerJobStatePending, model.ScannerJobStateRunning, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return ret.RowsAffected()
+}
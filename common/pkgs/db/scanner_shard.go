@@ -0,0 +1,74 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ScannerShardDB struct {
+	*DB
+}
+
+func (db *DB) ScannerShard() *ScannerShardDB {
+	return &ScannerShardDB{DB: db}
+}
+
+// 获得分片之后记录租约，返回新的纪元。每次获得分片纪元都会加一，之前的拥有者续约或者执行任务时会因为纪元不同而失败。
+// 只有租约已经过期或者本来就属于owner时才能获得，否则返回错误
+func (*ScannerShardDB) Claim(ctx SQLContext, shard int, owner string, expire time.Time) (int64, error) {
+	ret, err := ctx.Exec("insert ignore into ScannerShard(Shard, Owner, Epoch, LeaseExpire) values(?,?,1,?)", shard, owner, expire)
+	if err != nil {
+		return 0, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if cnt == 0 {
+		ret, err = ctx.Exec("update ScannerShard set Owner = ?, Epoch = Epoch + 1, LeaseExpire = ? where Shard = ? and (LeaseExpire < ? or Owner = ?)",
+			owner, expire, shard, time.Now(), owner)
+		if err != nil {
+			return 0, err
+		}
+
+		cnt, err = ret.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		if cnt == 0 {
+			return 0, fmt.Errorf("lease of shard %d is still held by other instance", shard)
+		}
+	}
+
+	var epoch int64
+	err = sqlx.Get(ctx, &epoch, "select Epoch from ScannerShard where Shard = ? and Owner = ?", shard, owner)
+	return epoch, err
+}
+
+// 续约，返回false代表分片已经被其他实例获得
+func (*ScannerShardDB) Renew(ctx SQLContext, shard int, owner string, epoch int64, expire time.Time) (bool, error) {
+	ret, err := ctx.Exec("update ScannerShard set LeaseExpire = ? where Shard = ? and Owner = ? and Epoch = ?", expire, shard, owner, epoch)
+	if err != nil {
+		return false, err
+	}
+
+	cnt, err := ret.RowsAffected()
+	return cnt > 0, err
+}
+
+// 检查租约是否依然有效
+func (*ScannerShardDB) IsValid(ctx SQLContext, shard int, owner string, epoch int64) (bool, error) {
+	var cnt int
+	err := sqlx.Get(ctx, &cnt, "select count(*) from ScannerShard where Shard = ? and Owner = ? and Epoch = ? and LeaseExpire > ?", shard, owner, epoch, time.Now())
+	return cnt > 0, err
+}
+
+// 主动放弃租约
+func (*ScannerShardDB) Release(ctx SQLContext, shard int, owner string, epoch int64) error {
+	_, err := ctx.Exec("update ScannerShard set LeaseExpire = ? where Shard = ? and Owner = ? and Epoch = ?", time.Now(), shard, owner, epoch)
+	return err
+}
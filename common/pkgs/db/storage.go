@@ -23,9 +23,10 @@ func (db *StorageDB) GetByID(ctx SQLContext, stgID cdssdk.StorageID) (model.Stor
 	return stg, err
 }
 
-func (db *StorageDB) BatchGetAllStorageIDs(ctx SQLContext, start int, count int) ([]cdssdk.StorageID, error) {
+// 按StorageID的顺序分批查询，只查询StorageID大于afterID的Storage
+func (db *StorageDB) BatchGetAllStorageIDs(ctx SQLContext, afterID cdssdk.StorageID, count int) ([]cdssdk.StorageID, error) {
 	var ret []cdssdk.StorageID
-	err := sqlx.Select(ctx, &ret, "select StorageID from Storage limit ?, ?", start, count)
+	err := sqlx.Select(ctx, &ret, "select StorageID from Storage where StorageID > ? order by StorageID limit ?", afterID, count)
 	return ret, err
 }
 
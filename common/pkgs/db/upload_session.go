@@ -0,0 +1,94 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type UploadSessionDB struct {
+	*DB
+}
+
+func (db *DB) UploadSession() *UploadSessionDB {
+	return &UploadSessionDB{DB: db}
+}
+
+func (*UploadSessionDB) Create(ctx SQLContext, userID cdssdk.UserID, packageID cdssdk.PackageID, path string, nodeID cdssdk.NodeID, createTime time.Time) (model.UploadSessionID, error) {
+	ret, err := ctx.Exec("insert into UploadSession(UserID, PackageID, Path, NodeID, CreateTime, UpdateTime) values(?,?,?,?,?,?)",
+		userID, packageID, path, nodeID, createTime, createTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := ret.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return model.UploadSessionID(id), nil
+}
+
+func (*UploadSessionDB) GetByID(ctx SQLContext, sessionID model.UploadSessionID) (model.UploadSession, error) {
+	var ret model.UploadSession
+	err := sqlx.Get(ctx, &ret, "select * from UploadSession where SessionID = ?", sessionID)
+	return ret, err
+}
+
+// GetUserSession 获取会话，如果会话不属于此用户，则不会获得结果
+func (*UploadSessionDB) GetUserSession(ctx SQLContext, userID cdssdk.UserID, sessionID model.UploadSessionID) (model.UploadSession, error) {
+	var ret model.UploadSession
+	err := sqlx.Get(ctx, &ret, "select * from UploadSession where SessionID = ? and UserID = ?", sessionID, userID)
+	return ret, err
+}
+
+// 查询最后一次活动时间早于指定时间的会话
+func (*UploadSessionDB) BatchGetInactive(ctx SQLContext, before time.Time, count int) ([]model.UploadSession, error) {
+	var ret []model.UploadSession
+	err := sqlx.Select(ctx, &ret, "select * from UploadSession where UpdateTime < ? order by SessionID asc limit ?", before, count)
+	return ret, err
+}
+
+func (*UploadSessionDB) Touch(ctx SQLContext, sessionID model.UploadSessionID, updateTime time.Time) error {
+	_, err := ctx.Exec("update UploadSession set UpdateTime = ? where SessionID = ?", updateTime, sessionID)
+	return err
+}
+
+// 删除会话以及它所有的分段
+func (*UploadSessionDB) Delete(ctx SQLContext, sessionID model.UploadSessionID) error {
+	_, err := ctx.Exec("delete from UploadSessionPart where SessionID = ?", sessionID)
+	if err != nil {
+		return err
+	}
+
+	_, err = ctx.Exec("delete from UploadSession where SessionID = ?", sessionID)
+	return err
+}
+
+// 添加一个分段，已经存在相同序号的分段时会覆盖
+func (*UploadSessionDB) UpsertPart(ctx SQLContext, part model.UploadSessionPart) error {
+	_, err := ctx.Exec("insert into UploadSessionPart values(?,?,?,?,?) as new"+
+		" on duplicate key update Size = new.Size, FileHash = new.FileHash, UploadTime = new.UploadTime",
+		part.SessionID, part.PartNumber, part.Size, part.FileHash, part.UploadTime)
+	return err
+}
+
+// 获取会话的所有分段，按分段序号排序
+func (*UploadSessionDB) GetParts(ctx SQLContext, sessionID model.UploadSessionID) ([]model.UploadSessionPart, error) {
+	var ret []model.UploadSessionPart
+	err := sqlx.Select(ctx, &ret, "select * from UploadSessionPart where SessionID = ? order by PartNumber asc", sessionID)
+	return ret, err
+}
+
+// 获取暂存在指定节点上的所有分段
+func (*UploadSessionDB) GetPartsByNodeID(ctx SQLContext, nodeID cdssdk.NodeID) ([]model.UploadSessionPart, error) {
+	var ret []model.UploadSessionPart
+	err := sqlx.Select(ctx, &ret,
+		"select UploadSessionPart.* from UploadSessionPart, UploadSession where"+
+			" UploadSessionPart.SessionID = UploadSession.SessionID and"+
+			" UploadSession.NodeID = ?",
+		nodeID)
+	return ret, err
+}
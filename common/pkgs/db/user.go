@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"github.com/jmoiron/sqlx"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
@@ -19,3 +21,36 @@ func (db *UserDB) GetByID(ctx SQLContext, userID cdssdk.UserID) (model.User, err
 	err := sqlx.Get(ctx, &ret, "select * from User where UserID = ?", userID)
 	return ret, err
 }
+
+func (*UserDB) SetPasswordHash(ctx SQLContext, userID cdssdk.UserID, passwordHash string) error {
+	_, err := ctx.Exec("update User set PasswordHash = ? where UserID = ?", passwordHash, userID)
+	return err
+}
+
+func (*UserDB) CreateToken(ctx SQLContext, token model.UserToken) error {
+	_, err := ctx.Exec("insert into UserToken(TokenHash, UserID, ExpireTime) values(?,?,?)", token.TokenHash, token.UserID, token.ExpireTime)
+	return err
+}
+
+// 查询没有过期的登录凭证
+func (*UserDB) GetToken(ctx SQLContext, tokenHash string, now time.Time) (model.UserToken, error) {
+	var ret model.UserToken
+	err := sqlx.Get(ctx, &ret, "select * from UserToken where TokenHash = ? and ExpireTime > ?", tokenHash, now)
+	return ret, err
+}
+
+func (*UserDB) DeleteToken(ctx SQLContext, tokenHash string) error {
+	_, err := ctx.Exec("delete from UserToken where TokenHash = ?", tokenHash)
+	return err
+}
+
+// 删除用户所有的登录凭证，修改密码后使用
+func (*UserDB) DeleteUserTokens(ctx SQLContext, userID cdssdk.UserID) error {
+	_, err := ctx.Exec("delete from UserToken where UserID = ?", userID)
+	return err
+}
+
+func (*UserDB) DeleteExpiredTokens(ctx SQLContext, now time.Time) error {
+	_, err := ctx.Exec("delete from UserToken where ExpireTime <= ?", now)
+	return err
+}
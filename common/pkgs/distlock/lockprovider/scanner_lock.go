@@ -0,0 +1,132 @@
+package lockprovider
+
+import (
+	"fmt"
+
+	"gitlink.org.cn/cloudream/common/pkgs/distlock"
+	"gitlink.org.cn/cloudream/common/utils/lo2"
+)
+
+const (
+	ScannerLockPathPrefix  = "Scanner"
+	ScannerTargetPathIndex = 1
+	// 多个Scanner实例之间互斥的所有权锁，比如Leader和分片
+	ScannerOwnLock = "Own"
+)
+
+type ScannerLock struct {
+	targetLocks map[string]*ScannerTargetLock
+	dummyLock   *ScannerTargetLock
+}
+
+func NewScannerLock() *ScannerLock {
+	return &ScannerLock{
+		targetLocks: make(map[string]*ScannerTargetLock),
+		dummyLock:   NewScannerTargetLock(),
+	}
+}
+
+// CanLock 判断这个锁能否锁定成功
+func (l *ScannerLock) CanLock(lock distlock.Lock) error {
+	targetLock, ok := l.targetLocks[lock.Path[ScannerTargetPathIndex]]
+	if !ok {
+		// 不能直接返回nil，因为如果锁数据的格式不对，也不能获取锁。
+		// 这里使用一个空Provider来进行检查。
+		return l.dummyLock.CanLock(lock)
+	}
+
+	return targetLock.CanLock(lock)
+}
+
+// 锁定。在内部可以不用判断能否加锁，外部需要保证调用此函数前调用了CanLock进行检查
+func (l *ScannerLock) Lock(reqID string, lock distlock.Lock) error {
+	target := lock.Path[ScannerTargetPathIndex]
+
+	targetLock, ok := l.targetLocks[target]
+	if !ok {
+		targetLock = NewScannerTargetLock()
+		l.targetLocks[target] = targetLock
+	}
+
+	return targetLock.Lock(reqID, lock)
+}
+
+// 解锁
+func (l *ScannerLock) Unlock(reqID string, lock distlock.Lock) error {
+	target := lock.Path[ScannerTargetPathIndex]
+
+	targetLock, ok := l.targetLocks[target]
+	if !ok {
+		return nil
+	}
+
+	return targetLock.Unlock(reqID, lock)
+}
+
+// GetTargetString 将锁对象序列化为字符串，方便存储到ETCD
+func (l *ScannerLock) GetTargetString(target any) (string, error) {
+	tar := target.(StringLockTarget)
+	return StringLockTargetToString(&tar)
+}
+
+// ParseTargetString 解析字符串格式的锁对象数据
+func (l *ScannerLock) ParseTargetString(targetStr string) (any, error) {
+	return StringLockTargetFromString(targetStr)
+}
+
+// Clear 清除内部所有状态
+func (l *ScannerLock) Clear() {
+	l.targetLocks = make(map[string]*ScannerTargetLock)
+}
+
+type ScannerTargetLock struct {
+	ownReqIDs []string
+
+	lockCompatibilityTable *LockCompatibilityTable
+}
+
+func NewScannerTargetLock() *ScannerTargetLock {
+	compTable := &LockCompatibilityTable{}
+
+	scannerLock := ScannerTargetLock{
+		lockCompatibilityTable: compTable,
+	}
+
+	compTable.
+		Column(ScannerOwnLock, func() bool { return len(scannerLock.ownReqIDs) > 0 })
+
+	uncp := LockUncompatible()
+
+	compTable.MustRow(uncp)
+
+	return &scannerLock
+}
+
+// CanLock 判断这个锁能否锁定成功
+func (l *ScannerTargetLock) CanLock(lock distlock.Lock) error {
+	return l.lockCompatibilityTable.Test(lock)
+}
+
+// 锁定
+func (l *ScannerTargetLock) Lock(reqID string, lock distlock.Lock) error {
+	switch lock.Name {
+	case ScannerOwnLock:
+		l.ownReqIDs = append(l.ownReqIDs, reqID)
+	default:
+		return fmt.Errorf("unknow lock name: %s", lock.Name)
+	}
+
+	return nil
+}
+
+// 解锁
+func (l *ScannerTargetLock) Unlock(reqID string, lock distlock.Lock) error {
+	switch lock.Name {
+	case ScannerOwnLock:
+		l.ownReqIDs = lo2.Remove(l.ownReqIDs, reqID)
+	default:
+		return fmt.Errorf("unknow lock name: %s", lock.Name)
+	}
+
+	return nil
+}
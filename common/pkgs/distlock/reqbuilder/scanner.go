@@ -0,0 +1,36 @@
+package reqbuilder
+
+import (
+	"strconv"
+
+	"gitlink.org.cn/cloudream/common/pkgs/distlock"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/lockprovider"
+)
+
+type ScannerLockReqBuilder struct {
+	*LockRequestBuilder
+}
+
+func (b *LockRequestBuilder) Scanner() *ScannerLockReqBuilder {
+	return &ScannerLockReqBuilder{LockRequestBuilder: b}
+}
+
+// 成为Scanner集群的Leader
+func (b *ScannerLockReqBuilder) Leader() *ScannerLockReqBuilder {
+	b.locks = append(b.locks, distlock.Lock{
+		Path:   []string{lockprovider.ScannerLockPathPrefix, "Leader"},
+		Name:   lockprovider.ScannerOwnLock,
+		Target: *lockprovider.NewStringLockTarget(),
+	})
+	return b
+}
+
+// 获得一个分片的所有权
+func (b *ScannerLockReqBuilder) Shard(shard int) *ScannerLockReqBuilder {
+	b.locks = append(b.locks, distlock.Lock{
+		Path:   []string{lockprovider.ScannerLockPathPrefix, "Shard" + strconv.Itoa(shard)},
+		Name:   lockprovider.ScannerOwnLock,
+		Target: *lockprovider.NewStringLockTarget(),
+	})
+	return b
+}
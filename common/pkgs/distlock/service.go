@@ -26,6 +26,8 @@ func initProviders() []distlock.PathProvider {
 
 	provs = append(provs, initStorageLockProviders()...)
 
+	provs = append(provs, initScannerLockProviders()...)
+
 	return provs
 }
 
@@ -59,3 +61,9 @@ func initStorageLockProviders() []distlock.PathProvider {
 		distlock.NewPathProvider(lockprovider.NewStorageLock(), lockprovider.StorageLockPathPrefix, trie.WORD_ANY),
 	}
 }
+
+func initScannerLockProviders() []distlock.PathProvider {
+	return []distlock.PathProvider{
+		distlock.NewPathProvider(lockprovider.NewScannerLock(), lockprovider.ScannerLockPathPrefix, trie.WORD_ANY),
+	}
+}
@@ -3,8 +3,16 @@ package downloader
 type Config struct {
 	// EC模式的Object的条带缓存数量
 	MaxStripCacheCount int `json:"maxStripCacheCount"`
-	// 当到下载节点的延迟高于这个值时，该节点在评估时会有更高的分数惩罚，单位：ms
-	HighLatencyNodeMs float64 `json:"highLatencyNodeMs"`
 	// EC模式下，每个Object的条带的预取数量，最少为1
 	ECStripPrefetchCount int `json:"ecStripPrefetchCount"`
+	// EC模式下，如果某个块的下载速度低于这个值，就额外下载一个其他的块，使用最先下载到的K个块解码，单位：KB/s
+	ECHedgeMinThroughputKBs float64 `json:"ecHedgeMinThroughputKBs"`
+	// 检查各个块的下载速度的时间间隔，单位：ms
+	ECHedgeCheckIntervalMs int `json:"ecHedgeCheckIntervalMs"`
+	// 磁盘缓存，用于在多次下载同一个文件时复用已经下载过的EC条带
+	DiskCache DiskCacheConfig `json:"diskCache"`
+	// 下载整个Package时，在读取当前对象的同时预取后面多少个对象，为0时不预取
+	PackagePrefetchCount int `json:"packagePrefetchCount"`
+	// 预取的对象最多占用多少内存，单位：MB
+	PackagePrefetchMemoryMB int64 `json:"packagePrefetchMemoryMB"`
 }
@@ -0,0 +1,393 @@
+package downloader
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+)
+
+const (
+	DiskCacheEvictionLRU = "LRU"
+	DiskCacheEvictionLFU = "LFU"
+)
+
+const (
+	diskCacheIndexFileName = "index.json"
+	diskCacheDataDirName   = "data"
+	// 缓存内容变化超过这个次数后立刻保存一次索引
+	diskCacheSaveIndexChanges = 64
+	// 有变化时保存索引的时间间隔
+	diskCacheSaveIndexInterval = 30 * time.Second
+)
+
+type DiskCacheConfig struct {
+	// 缓存目录，为空时不启用磁盘缓存
+	Dir string `json:"dir"`
+	// 缓存文件的总大小上限，单位：MB
+	MaxSizeMB int64 `json:"maxSizeMB"`
+	// 淘汰策略，LRU或者LFU，默认为LRU
+	Eviction string `json:"eviction"`
+}
+
+type diskCacheKey struct {
+	FileHash string
+	Offset   int64
+}
+
+type diskCacheEntry struct {
+	FileHash   string    `json:"fileHash"`
+	Offset     int64     `json:"offset"`
+	Size       int64     `json:"size"`
+	CRC32      uint32    `json:"crc32"`
+	Hits       int64     `json:"hits"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+type diskCacheIndex struct {
+	Entries []diskCacheEntry `json:"entries"`
+}
+
+// 保存在磁盘上的文件分段缓存，以文件的哈希值和分段在文件中的位置为键，可以在进程重启之后继续使用。
+//
+// 每个分段保存为一个单独的文件，先写入临时文件再重命名，索引文件也是如此，因此进程崩溃不会留下不完整的数据。
+// 索引里记录了每个分段的大小和CRC32，启动时会删除与索引不一致的文件，读取时也会检查数据是否被损坏
+type DiskCache struct {
+	cfg       DiskCacheConfig
+	maxSize   int64
+	lock      sync.Mutex
+	entries   map[diskCacheKey]*diskCacheEntry
+	totalSize int64
+	changes   int
+	saveLock  sync.Mutex
+	closed    chan any
+	closeOnce sync.Once
+}
+
+func NewDiskCache(cfg DiskCacheConfig) (*DiskCache, error) {
+	if cfg.Eviction == "" {
+		cfg.Eviction = DiskCacheEvictionLRU
+	}
+	if cfg.Eviction != DiskCacheEvictionLRU && cfg.Eviction != DiskCacheEvictionLFU {
+		return nil, fmt.Errorf("unknown eviction policy: %s", cfg.Eviction)
+	}
+	if cfg.MaxSizeMB <= 0 {
+		return nil, fmt.Errorf("max size must be greater than 0")
+	}
+
+	c := &DiskCache{
+		cfg:     cfg,
+		maxSize: cfg.MaxSizeMB * 1024 * 1024,
+		entries: make(map[diskCacheKey]*diskCacheEntry),
+		closed:  make(chan any),
+	}
+
+	err := os.MkdirAll(filepath.Join(cfg.Dir, diskCacheDataDirName), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("creating cache dir: %w", err)
+	}
+
+	err = c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	go c.serve()
+	return c, nil
+}
+
+// 获取缓存的分段，数据被损坏时会删除这个分段并返回false
+func (c *DiskCache) Get(fileHash string, offset int64) ([]byte, bool) {
+	key := diskCacheKey{FileHash: fileHash, Offset: offset}
+
+	c.lock.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		c.lock.Unlock()
+		return nil, false
+	}
+	entryCopy := *entry
+	c.lock.Unlock()
+
+	data, err := os.ReadFile(c.dataPath(key))
+	if err == nil && int64(len(data)) == entryCopy.Size && crc32.ChecksumIEEE(data) == entryCopy.CRC32 {
+		c.lock.Lock()
+		// 读取期间可能已经被替换或者淘汰了
+		if cur, ok := c.entries[key]; ok && cur.CRC32 == entryCopy.CRC32 {
+			cur.Hits++
+			cur.LastAccess = time.Now()
+			c.changes++
+		}
+		c.lock.Unlock()
+		return data, true
+	}
+
+	c.lock.Lock()
+	// 读取期间被淘汰的话不算损坏
+	if cur, ok := c.entries[key]; ok && cur.CRC32 == entryCopy.CRC32 {
+		logger.Warnf("disk cache of file %v at %v is broken, remove it", fileHash, offset)
+		c.removeEntry(key)
+	}
+	c.lock.Unlock()
+	return nil, false
+}
+
+func (c *DiskCache) Put(fileHash string, offset int64, data []byte) {
+	size := int64(len(data))
+	if size > c.maxSize {
+		return
+	}
+
+	key := diskCacheKey{FileHash: fileHash, Offset: offset}
+
+	c.lock.Lock()
+	if _, ok := c.entries[key]; ok {
+		c.lock.Unlock()
+		return
+	}
+	for c.totalSize+size > c.maxSize && len(c.entries) > 0 {
+		c.removeEntry(c.chooseVictim())
+	}
+	c.lock.Unlock()
+
+	err := writeFileAtomic(c.dataPath(key), data)
+	if err != nil {
+		logger.Warnf("writing disk cache of file %v at %v: %s", fileHash, offset, err.Error())
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// 写入期间其他协程可能也添加了同样的分段，数据是一样的，只需要记录一次
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+
+	c.entries[key] = &diskCacheEntry{
+		FileHash:   fileHash,
+		Offset:     offset,
+		Size:       size,
+		CRC32:      crc32.ChecksumIEEE(data),
+		LastAccess: time.Now(),
+	}
+	c.totalSize += size
+	c.changes++
+
+	if c.changes >= diskCacheSaveIndexChanges {
+		go c.saveIndex()
+	}
+}
+
+// 停止后台任务，并保存索引
+func (c *DiskCache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.saveIndex()
+	})
+}
+
+func (c *DiskCache) serve() {
+	ticker := time.NewTicker(diskCacheSaveIndexInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.saveIndex()
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// 需要在加锁的情况下调用
+func (c *DiskCache) chooseVictim() diskCacheKey {
+	var victim *diskCacheEntry
+	for _, e := range c.entries {
+		if victim == nil {
+			victim = e
+			continue
+		}
+
+		if c.cfg.Eviction == DiskCacheEvictionLFU && e.Hits != victim.Hits {
+			if e.Hits < victim.Hits {
+				victim = e
+			}
+			continue
+		}
+
+		if e.LastAccess.Before(victim.LastAccess) {
+			victim = e
+		}
+	}
+
+	return diskCacheKey{FileHash: victim.FileHash, Offset: victim.Offset}
+}
+
+// 需要在加锁的情况下调用
+func (c *DiskCache) removeEntry(key diskCacheKey) {
+	entry, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	delete(c.entries, key)
+	c.totalSize -= entry.Size
+	c.changes++
+
+	err := os.Remove(c.dataPath(key))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warnf("removing disk cache file: %s", err.Error())
+	}
+}
+
+func (c *DiskCache) saveIndex() {
+	c.saveLock.Lock()
+	defer c.saveLock.Unlock()
+
+	c.lock.Lock()
+	if c.changes == 0 {
+		c.lock.Unlock()
+		return
+	}
+
+	var idx diskCacheIndex
+	for _, e := range c.entries {
+		idx.Entries = append(idx.Entries, *e)
+	}
+	c.changes = 0
+	c.lock.Unlock()
+
+	data, err := serder.ObjectToJSON(idx)
+	if err != nil {
+		logger.Warnf("encoding disk cache index: %s", err.Error())
+		return
+	}
+
+	err = writeFileAtomic(filepath.Join(c.cfg.Dir, diskCacheIndexFileName), data)
+	if err != nil {
+		logger.Warnf("saving disk cache index: %s", err.Error())
+	}
+}
+
+// 加载索引，并删除与索引不一致的文件
+func (c *DiskCache) load() error {
+	data, err := os.ReadFile(filepath.Join(c.cfg.Dir, diskCacheIndexFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading index file: %w", err)
+	}
+
+	var idx diskCacheIndex
+	if err == nil {
+		err = serder.JSONToObject(data, &idx)
+		if err != nil {
+			// 索引文件是原子写入的，一般不会损坏，即使损坏了也只是丢失缓存
+			logger.Warnf("decoding disk cache index, all cached data will be dropped: %s", err.Error())
+			idx = diskCacheIndex{}
+		}
+	}
+
+	for _, e := range idx.Entries {
+		entry := e
+		key := diskCacheKey{FileHash: entry.FileHash, Offset: entry.Offset}
+
+		info, err := os.Stat(c.dataPath(key))
+		if err != nil || info.Size() != entry.Size {
+			continue
+		}
+
+		c.entries[key] = &entry
+		c.totalSize += entry.Size
+	}
+
+	// 保存索引时崩溃留下的临时文件
+	tmpIdxes, _ := filepath.Glob(filepath.Join(c.cfg.Dir, diskCacheIndexFileName+".tmp*"))
+	for _, f := range tmpIdxes {
+		os.Remove(f)
+	}
+
+	dataDir := filepath.Join(c.cfg.Dir, diskCacheDataDirName)
+	err = filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		key, ok := parseDiskCacheFileName(info.Name())
+		if ok {
+			if _, exists := c.entries[key]; exists {
+				return nil
+			}
+		}
+
+		// 不在索引中的文件，包括崩溃时留下的临时文件
+		return os.Remove(path)
+	})
+	if err != nil {
+		return fmt.Errorf("cleaning cache dir: %w", err)
+	}
+
+	// 配置的大小可能变小了
+	for c.totalSize > c.maxSize && len(c.entries) > 0 {
+		c.removeEntry(c.chooseVictim())
+	}
+
+	return nil
+}
+
+func (c *DiskCache) dataPath(key diskCacheKey) string {
+	sub := key.FileHash
+	if len(sub) > 2 {
+		sub = sub[len(sub)-2:]
+	}
+
+	return filepath.Join(c.cfg.Dir, diskCacheDataDirName, sub, fmt.Sprintf("%s_%d", key.FileHash, key.Offset))
+}
+
+func parseDiskCacheFileName(name string) (diskCacheKey, bool) {
+	idx := strings.LastIndex(name, "_")
+	if idx < 0 {
+		return diskCacheKey{}, false
+	}
+
+	offset, err := strconv.ParseInt(name[idx+1:], 10, 64)
+	if err != nil {
+		return diskCacheKey{}, false
+	}
+
+	return diskCacheKey{FileHash: name[:idx], Offset: offset}, true
+}
+
+// 先写入临时文件再重命名，保证文件要么是完整的，要么不存在
+func writeFileAtomic(path string, data []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,64 @@
+package downloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_DiskCache(t *testing.T) {
+	Convey("超过大小上限时淘汰最久未使用的分段", t, func() {
+		c, err := NewDiskCache(DiskCacheConfig{Dir: t.TempDir(), MaxSizeMB: 1})
+		So(err, ShouldBeNil)
+		defer c.Close()
+
+		half := make([]byte, 512*1024)
+		c.Put("Qm1", 0, half)
+		c.Put("Qm1", int64(len(half)), half)
+		_, ok := c.Get("Qm1", 0)
+		So(ok, ShouldBeTrue)
+
+		c.Put("Qm2", 0, half)
+		_, ok = c.Get("Qm1", int64(len(half)))
+		So(ok, ShouldBeFalse)
+		_, ok = c.Get("Qm1", 0)
+		So(ok, ShouldBeTrue)
+		_, ok = c.Get("Qm2", 0)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("重启后恢复索引，并丢弃损坏和不在索引中的文件", t, func() {
+		dir := t.TempDir()
+		c, err := NewDiskCache(DiskCacheConfig{Dir: dir, MaxSizeMB: 1})
+		So(err, ShouldBeNil)
+
+		c.Put("Qm1", 0, []byte("hello"))
+		c.Put("Qm1", 5, []byte("world"))
+		c.Close()
+
+		// 索引保存之后才写入的文件
+		orphan := c.dataPath(diskCacheKey{FileHash: "Qm2", Offset: 0})
+		So(writeFileAtomic(orphan, []byte("orphan")), ShouldBeNil)
+		// 大小不变但内容被损坏的文件
+		So(os.WriteFile(c.dataPath(diskCacheKey{FileHash: "Qm1", Offset: 5}), []byte("w0rld"), 0644), ShouldBeNil)
+
+		c2, err := NewDiskCache(DiskCacheConfig{Dir: dir, MaxSizeMB: 1})
+		So(err, ShouldBeNil)
+		defer c2.Close()
+
+		data, ok := c2.Get("Qm1", 0)
+		So(ok, ShouldBeTrue)
+		So(string(data), ShouldEqual, "hello")
+
+		_, ok = c2.Get("Qm1", 5)
+		So(ok, ShouldBeFalse)
+
+		_, err = os.Stat(orphan)
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp*"))
+		So(tmps, ShouldBeEmpty)
+	})
+}
@@ -6,23 +6,29 @@ import (
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"gitlink.org.cn/cloudream/common/pkgs/iterator"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
 )
 
 const (
-	DefaultMaxStripCacheCount = 128
+	DefaultMaxStripCacheCount      = 128
+	DefaultECHedgeMinThroughputKBs = 512
+	DefaultECHedgeCheckIntervalMs  = 500
+	DefaultPackagePrefetchMemoryMB = 256
 )
 
 type DownloadIterator = iterator.Iterator[*Downloading]
 
 type DownloadReqeust struct {
-	ObjectID cdssdk.ObjectID
-	Offset   int64
-	Length   int64
+	ObjectID  cdssdk.ObjectID
+	VersionID model.ObjectVersionID // 不为0时下载对象的指定历史版本，此时忽略ObjectID
+	Offset    int64
+	Length    int64
 }
 
 type downloadReqeust2 struct {
@@ -37,49 +43,110 @@ type Downloading struct {
 }
 
 type Downloader struct {
-	strips *StripCache
-	conn   *connectivity.Collector
-	cfg    Config
+	strips    *StripCache
+	diskCache *DiskCache // 没有配置磁盘缓存时为nil
+	conn      *connectivity.Collector
+	cfg       Config
 }
 
 func NewDownloader(cfg Config, conn *connectivity.Collector) Downloader {
 	if cfg.MaxStripCacheCount == 0 {
 		cfg.MaxStripCacheCount = DefaultMaxStripCacheCount
 	}
+	if cfg.ECHedgeMinThroughputKBs == 0 {
+		cfg.ECHedgeMinThroughputKBs = DefaultECHedgeMinThroughputKBs
+	}
+	if cfg.ECHedgeCheckIntervalMs == 0 {
+		cfg.ECHedgeCheckIntervalMs = DefaultECHedgeCheckIntervalMs
+	}
+	if cfg.PackagePrefetchMemoryMB == 0 {
+		cfg.PackagePrefetchMemoryMB = DefaultPackagePrefetchMemoryMB
+	}
 
 	ch, _ := lru.New[ECStripKey, ObjectECStrip](cfg.MaxStripCacheCount)
+
+	var diskCache *DiskCache
+	if cfg.DiskCache.Dir != "" {
+		c, err := NewDiskCache(cfg.DiskCache)
+		if err != nil {
+			logger.Warnf("new disk cache failed, disk cache will be disabled, err: %s", err.Error())
+		} else {
+			diskCache = c
+		}
+	}
+
 	return Downloader{
-		strips: ch,
-		conn:   conn,
-		cfg:    cfg,
+		strips:    ch,
+		diskCache: diskCache,
+		conn:      conn,
+		cfg:       cfg,
+	}
+}
+
+// 保存磁盘缓存的索引。Close之后仍然可以下载，但不再使用磁盘缓存
+func (d *Downloader) Close() {
+	if d.diskCache != nil {
+		d.diskCache.Close()
+		d.diskCache = nil
 	}
 }
 
 func (d *Downloader) DownloadObjects(reqs []DownloadReqeust) DownloadIterator {
+	return d.DownloadUserObjects(0, reqs)
+}
+
+// 以指定用户的身份下载对象，用户无权访问的对象会被当作不存在
+func (d *Downloader) DownloadUserObjects(userID cdssdk.UserID, reqs []DownloadReqeust) DownloadIterator {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
 		return iterator.FuseError[*Downloading](fmt.Errorf("new coordinator client: %w", err))
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
-	objIDs := make([]cdssdk.ObjectID, len(reqs))
-	for i, req := range reqs {
-		objIDs[i] = req.ObjectID
+	if len(reqs) == 0 {
+		return iterator.Empty[*Downloading]()
 	}
 
-	if len(objIDs) == 0 {
-		return iterator.Empty[*Downloading]()
+	var objIDs []cdssdk.ObjectID
+	var verIDs []model.ObjectVersionID
+	for _, req := range reqs {
+		if req.VersionID != 0 {
+			verIDs = append(verIDs, req.VersionID)
+		} else {
+			objIDs = append(objIDs, req.ObjectID)
+		}
 	}
 
-	objDetails, err := coorCli.GetObjectDetails(coormq.ReqGetObjectDetails(objIDs))
-	if err != nil {
-		return iterator.FuseError[*Downloading](fmt.Errorf("request to coordinator: %w", err))
+	var objDetails []*stgmod.ObjectDetail
+	if len(objIDs) > 0 {
+		getObjs, err := coorCli.GetObjectDetails(coormq.ReqGetUserObjectDetails(userID, objIDs))
+		if err != nil {
+			return iterator.FuseError[*Downloading](fmt.Errorf("request to coordinator: %w", err))
+		}
+		objDetails = getObjs.Objects
 	}
 
+	var verDetails []*stgmod.ObjectDetail
+	if len(verIDs) > 0 {
+		getVers, err := coorCli.GetObjectVersionDetails(coormq.ReqGetUserObjectVersionDetails(userID, verIDs))
+		if err != nil {
+			return iterator.FuseError[*Downloading](fmt.Errorf("request to coordinator: %w", err))
+		}
+		verDetails = getVers.Objects
+	}
+
+	// 两种查询的结果都与请求的顺序一致，按顺序依次取出即可
 	req2s := make([]downloadReqeust2, len(reqs))
 	for i, req := range reqs {
+		var detail *stgmod.ObjectDetail
+		if req.VersionID != 0 {
+			detail, verDetails = verDetails[0], verDetails[1:]
+		} else {
+			detail, objDetails = objDetails[0], objDetails[1:]
+		}
+
 		req2s[i] = downloadReqeust2{
-			Detail: objDetails.Objects[i],
+			Detail: detail,
 			Raw:    req,
 		}
 	}
@@ -112,7 +179,12 @@ func (d *Downloader) DownloadPackage(pkgID cdssdk.PackageID) DownloadIterator {
 		}
 	}
 
-	return NewDownloadObjectIterator(d, req2s)
+	iter := NewDownloadObjectIterator(d, req2s)
+	if d.cfg.PackagePrefetchCount <= 0 {
+		return iter
+	}
+
+	return newPrefetchIterator(iter, req2s, d.cfg.PackagePrefetchCount, d.cfg.PackagePrefetchMemoryMB*1024*1024)
 }
 
 type ObjectECStrip struct {
@@ -6,7 +6,6 @@ import (
 	"io"
 	"math"
 	"reflect"
-	"time"
 
 	"github.com/samber/lo"
 
@@ -18,7 +17,6 @@ import (
 	"gitlink.org.cn/cloudream/common/utils/io2"
 	"gitlink.org.cn/cloudream/common/utils/math2"
 	"gitlink.org.cn/cloudream/common/utils/sort2"
-	"gitlink.org.cn/cloudream/storage/common/consts"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock"
@@ -228,8 +226,11 @@ func (iter *DownloadObjectIterator) downloadECObject(req downloadReqeust2, ecRed
 				totalReadLen = math2.Min(req.Raw.Length, totalReadLen)
 			}
 
+			// 除了最优的K个块，其他的块也交给StripIterator，在某个块下载过慢时用来补位
+			_, allBlocks := iter.getMinReadingBlockSolution(allNodes, ecRed.N)
+
 			firstStripIndex := readPos / int64(ecRed.K) / int64(ecRed.ChunkSize)
-			stripIter := NewStripIterator(req.Detail.Object, blocks, ecRed, firstStripIndex, iter.downloader.strips, iter.downloader.cfg.ECStripPrefetchCount)
+			stripIter := NewStripIterator(req.Detail.Object, allBlocks, ecRed, firstStripIndex, iter.downloader.strips, iter.downloader.diskCache, iter.downloader.conn, iter.downloader.cfg)
 			defer stripIter.Close()
 
 			for totalReadLen > 0 {
@@ -357,22 +358,9 @@ func (iter *DownloadObjectIterator) getMinReadingObjectSolution(sortedNodes []*D
 }
 
 func (iter *DownloadObjectIterator) getNodeDistance(node cdssdk.Node) float64 {
-	if stgglb.Local.NodeID != nil {
-		if node.NodeID == *stgglb.Local.NodeID {
-			return consts.NodeDistanceSameNode
-		}
-	}
-
-	if node.LocationID == stgglb.Local.LocationID {
-		return consts.NodeDistanceSameLocation
-	}
-
-	c := iter.downloader.conn.Get(node.NodeID)
-	if c == nil || c.Delay == nil || *c.Delay > time.Duration(float64(time.Millisecond)*iter.downloader.cfg.HighLatencyNodeMs) {
-		return consts.NodeDistanceHighLatencyNode
-	}
-
-	return consts.NodeDistanceOther
+	score := iter.downloader.conn.Score(node)
+	logger.Debugf("score of node %v: %+v", node.NodeID, score)
+	return score.Total
 }
 
 func (iter *DownloadObjectIterator) downloadFromNode(node *cdssdk.Node, req downloadReqeust2) (io.ReadCloser, error) {
@@ -399,5 +387,14 @@ func (iter *DownloadObjectIterator) downloadFromNode(node *cdssdk.Node, req down
 	exec := plans.Execute()
 	go exec.Wait(context.TODO())
 
-	return exec.BeginRead(strHandle)
+	str, err := exec.BeginRead(strHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := iter.downloader.conn
+	conn.BeginTransfer(node.NodeID)
+	return io2.AfterReadClosedOnce(str, func(closer io.ReadCloser) {
+		conn.EndTransfer(node.NodeID)
+	}), nil
 }
@@ -0,0 +1,206 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"gitlink.org.cn/cloudream/common/pkgs/iterator"
+)
+
+// 在调用者读取当前对象的同时，提前开始下载后面的对象，并把数据读取到内存中。
+// 返回对象的顺序与不预取时相同，某个对象下载失败时，只有迭代到这个对象时才会返回错误
+type prefetchIterator struct {
+	inner       *DownloadObjectIterator
+	reqs        []downloadReqeust2
+	maxCount    int
+	memoryLimit int64
+
+	queue     []*prefetchEntry
+	nextIndex int
+
+	lock       sync.Mutex
+	memoryUsed int64
+}
+
+type prefetchEntry struct {
+	req         downloadReqeust2
+	downloading *Downloading
+	err         error
+	// 为true表示数据不会预先读取到内存中，直接返回原始的文件流
+	passthrough bool
+	data        []byte
+	readErr     error
+	done        chan any
+}
+
+func newPrefetchIterator(inner *DownloadObjectIterator, reqs []downloadReqeust2, maxCount int, memoryLimit int64) *prefetchIterator {
+	return &prefetchIterator{
+		inner:       inner,
+		reqs:        reqs,
+		maxCount:    maxCount,
+		memoryLimit: memoryLimit,
+	}
+}
+
+func (i *prefetchIterator) MoveNext() (*Downloading, error) {
+	i.fill()
+
+	if len(i.queue) == 0 {
+		return nil, iterator.ErrNoMoreItem
+	}
+
+	entry := i.queue[0]
+	i.queue = i.queue[1:]
+
+	// 取出一个之后，队列有了空位，可以继续预取
+	i.fill()
+
+	if entry.err != nil {
+		return nil, entry.err
+	}
+
+	if entry.passthrough {
+		return entry.downloading, nil
+	}
+
+	<-entry.done
+	if entry.readErr != nil {
+		i.release(entry.req)
+		return nil, fmt.Errorf("downloading object %v: %w", entry.downloading.Object.ObjectID, entry.readErr)
+	}
+
+	dl := *entry.downloading
+	dl.File = &prefetchedFile{
+		reader: bytes.NewReader(entry.data),
+		onDone: func() { i.release(entry.req) },
+	}
+	return &dl, nil
+}
+
+func (i *prefetchIterator) Close() {
+	for _, entry := range i.queue {
+		if entry.err != nil || entry.passthrough {
+			if entry.downloading != nil && entry.downloading.File != nil {
+				entry.downloading.File.Close()
+			}
+			continue
+		}
+
+		// 关闭文件流来中断正在进行的读取
+		entry.downloading.File.Close()
+		<-entry.done
+		i.release(entry.req)
+	}
+	i.queue = nil
+
+	i.inner.Close()
+}
+
+// 按顺序启动后续对象的下载，直到达到预取数量或者内存上限。
+// 队列为空时，即使对象超过了内存上限也会启动下载，但不会预先读取它的数据
+func (i *prefetchIterator) fill() {
+	for len(i.queue) < i.maxCount && i.nextIndex < len(i.reqs) {
+		req := i.reqs[i.nextIndex]
+		size := prefetchSize(req)
+
+		passthrough := false
+		if !i.tryAcquire(size) {
+			if len(i.queue) > 0 {
+				return
+			}
+			passthrough = true
+		}
+
+		dl, err := i.inner.MoveNext()
+		i.nextIndex++
+
+		entry := &prefetchEntry{
+			req:         req,
+			downloading: dl,
+			err:         err,
+			passthrough: passthrough,
+			done:        make(chan any),
+		}
+		i.queue = append(i.queue, entry)
+
+		if err != nil || dl.File == nil {
+			if !passthrough {
+				i.release(req)
+			}
+			// 没有数据需要读取的对象直接原样返回
+			entry.passthrough = true
+			close(entry.done)
+			continue
+		}
+
+		if passthrough {
+			close(entry.done)
+			continue
+		}
+
+		go func() {
+			defer close(entry.done)
+			defer entry.downloading.File.Close()
+
+			buf := bytes.NewBuffer(make([]byte, 0, size))
+			_, entry.readErr = io.Copy(buf, entry.downloading.File)
+			entry.data = buf.Bytes()
+		}()
+	}
+}
+
+func (i *prefetchIterator) tryAcquire(size int64) bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.memoryUsed+size > i.memoryLimit {
+		return false
+	}
+
+	i.memoryUsed += size
+	return true
+}
+
+func (i *prefetchIterator) release(req downloadReqeust2) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	i.memoryUsed -= prefetchSize(req)
+}
+
+func prefetchSize(req downloadReqeust2) int64 {
+	if req.Detail == nil {
+		return 0
+	}
+
+	size := req.Detail.Object.Size - req.Raw.Offset
+	if req.Raw.Length >= 0 && req.Raw.Length < size {
+		size = req.Raw.Length
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// 数据已经在内存中的文件。数据被读完或者文件被关闭时归还占用的内存额度
+type prefetchedFile struct {
+	reader   *bytes.Reader
+	onDone   func()
+	doneOnce sync.Once
+}
+
+func (f *prefetchedFile) Read(p []byte) (int, error) {
+	n, err := f.reader.Read(p)
+	if err == io.EOF {
+		f.doneOnce.Do(f.onDone)
+	}
+	return n, err
+}
+
+func (f *prefetchedFile) Close() error {
+	f.doneOnce.Do(f.onDone)
+	return nil
+}
@@ -2,14 +2,20 @@ package downloader
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/samber/lo"
 	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
 	"gitlink.org.cn/cloudream/common/pkgs/iterator"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ec"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
 )
@@ -30,10 +36,15 @@ type StripIterator struct {
 	red                 *cdssdk.ECRedundancy
 	curStripIndex       int64
 	cache               *StripCache
+	diskCache           *DiskCache
+	conn                *connectivity.Collector
+	cfg                 Config
 	dataChan            chan dataChanEntry
 	downloadingDone     chan any
 	downloadingDoneOnce sync.Once
 	inited              bool
+	// 打开一个编码块从指定条带开始的数据流
+	openBlock func(ctx context.Context, blk downloadBlock, beginStripIndex int64) (io.ReadCloser, error)
 }
 
 type dataChanEntry struct {
@@ -42,7 +53,10 @@ type dataChanEntry struct {
 	Error    error
 }
 
-func NewStripIterator(object cdssdk.Object, blocks []downloadBlock, red *cdssdk.ECRedundancy, beginStripIndex int64, cache *StripCache, maxPrefetch int) *StripIterator {
+// blocks是对象所有可用的块，按优先级排序，且Index各不相同。
+// 默认只下载前K个块，其他块在有块下载过慢或者失败时使用
+func NewStripIterator(object cdssdk.Object, blocks []downloadBlock, red *cdssdk.ECRedundancy, beginStripIndex int64, cache *StripCache, diskCache *DiskCache, conn *connectivity.Collector, cfg Config) *StripIterator {
+	maxPrefetch := cfg.ECStripPrefetchCount
 	if maxPrefetch <= 0 {
 		maxPrefetch = 1
 	}
@@ -53,9 +67,13 @@ func NewStripIterator(object cdssdk.Object, blocks []downloadBlock, red *cdssdk.
 		red:             red,
 		curStripIndex:   beginStripIndex,
 		cache:           cache,
+		diskCache:       diskCache,
+		conn:            conn,
+		cfg:             cfg,
 		dataChan:        make(chan dataChanEntry, maxPrefetch-1),
 		downloadingDone: make(chan any),
 	}
+	iter.openBlock = iter.openBlockFromNode
 
 	return iter
 }
@@ -110,33 +128,61 @@ func (s *StripIterator) Close() {
 }
 
 func (s *StripIterator) downloading() {
-	ft := ioswitch2.NewFromTo()
-	for _, b := range s.blocks {
-		ft.AddFrom(ioswitch2.NewFromNode(b.Block.FileHash, &b.Node, b.Block.Index))
-	}
-
-	toExec, hd := ioswitch2.NewToDriverWithRange(-1, exec.Range{
-		Offset: s.curStripIndex * int64(s.red.ChunkSize*s.red.K),
-	})
-	ft.AddTo(toExec)
-
-	parser := parser.NewParser(*s.red)
-	plans := exec.NewPlanBuilder()
-	err := parser.Parse(ft, plans)
+	rs, err := ec.NewRs(s.red.K, s.red.N)
 	if err != nil {
-		s.sendToDataChan(dataChanEntry{Error: err})
+		s.sendToDataChan(dataChanEntry{Error: fmt.Errorf("new rs: %w", err)})
 		return
 	}
-	exec := plans.Execute()
 
 	ctx, cancel := context.WithCancel(context.Background())
-	go exec.Wait(ctx)
 	defer cancel()
 
-	str, err := exec.BeginRead(hd)
-	if err != nil {
-		s.sendToDataChan(dataChanEntry{Error: err})
-		return
+	maxPrefetch := s.cfg.ECStripPrefetchCount
+	if maxPrefetch <= 0 {
+		maxPrefetch = 1
+	}
+	chunkChan := make(chan blockChunk, len(s.blocks)*maxPrefetch)
+	readers := make([]*blockReader, len(s.blocks))
+
+	// 按顺序启动下一个还没有开始下载的块，返回false说明已经没有可用的块了
+	startNextReader := func(stripIndex int64) bool {
+		for i, b := range s.blocks {
+			if readers[i] != nil {
+				continue
+			}
+
+			readers[i] = s.startBlockReader(ctx, i, b, stripIndex, maxPrefetch, chunkChan)
+			return true
+		}
+		return false
+	}
+
+	// 已经拿到的分块数量加上还可能拿到的分块数量
+	reachableCnt := func(chunks [][]byte) int {
+		cnt := 0
+		for _, rd := range readers {
+			if rd != nil && (chunks[rd.block.Block.Index] != nil || !rd.stopped) {
+				cnt++
+			}
+		}
+		return cnt
+	}
+
+	checkInterval := time.Duration(s.cfg.ECHedgeCheckIntervalMs) * time.Millisecond
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	// 提前到达的后续条带的分块
+	pendings := make(map[int64][][]byte)
+	// 分块在pendings中的块。每个分块占用了读取它的块的一个令牌，条带被发送之后才能归还，
+	// 否则速度快的块会在速度慢的块拖住当前条带时不停地读取后续的分块
+	holders := make(map[int64][]*blockReader)
+	finishStrip := func(stripIndex int64) {
+		for _, rd := range holders[stripIndex] {
+			rd.release()
+		}
+		delete(holders, stripIndex)
+		delete(pendings, stripIndex)
 	}
 
 	curStripIndex := s.curStripIndex
@@ -159,6 +205,7 @@ loop:
 				if !s.sendToDataChan(dataChanEntry{Data: item.Data, Position: stripBytesPos}) {
 					break loop
 				}
+				finishStrip(curStripIndex)
 				curStripIndex++
 				continue
 
@@ -168,31 +215,110 @@ loop:
 			}
 		}
 
-		dataBuf := make([]byte, int64(s.red.K*s.red.ChunkSize))
-		n, err := io.ReadFull(str, dataBuf)
-		if err == io.ErrUnexpectedEOF {
-			s.cache.Add(stripKey, ObjectECStrip{
-				Data:           dataBuf,
-				ObjectFileHash: s.object.FileHash,
-			})
+		// 磁盘缓存以文件的Hash为键，因此不会读到其他版本的数据
+		if s.diskCache != nil {
+			data, ok := s.diskCache.Get(s.object.FileHash, stripBytesPos)
+			if ok && len(data) == s.red.K*s.red.ChunkSize {
+				s.cache.Add(stripKey, ObjectECStrip{
+					Data:           data,
+					ObjectFileHash: s.object.FileHash,
+				})
 
-			s.sendToDataChan(dataChanEntry{Data: dataBuf[:n], Position: stripBytesPos})
-			s.sendToDataChan(dataChanEntry{Error: io.EOF})
-			break loop
+				if !s.sendStrip(data, stripBytesPos) {
+					break loop
+				}
+				finishStrip(curStripIndex)
+				curStripIndex++
+				continue
+			}
+		}
+
+		chunks, ok := pendings[curStripIndex]
+		if !ok {
+			chunks = make([][]byte, s.red.N)
+			pendings[curStripIndex] = chunks
+		}
+
+		for lo.CountBy(chunks, func(c []byte) bool { return c != nil }) < s.red.K {
+			// 需要下载时才启动最优的K个块的下载，之后有块下载失败时再依次启动其他块
+			for reachableCnt(chunks) < s.red.K {
+				if !startNextReader(curStripIndex) {
+					s.sendToDataChan(dataChanEntry{Error: fmt.Errorf("no enough blocks to reconstruct the strip %v, want %d, get only %d", curStripIndex, s.red.K, reachableCnt(chunks))})
+					break loop
+				}
+			}
+
+			select {
+			case c := <-chunkChan:
+				rd := readers[c.ReaderIndex]
+				if c.Error != nil {
+					if c.Error != io.EOF {
+						logger.Warnf("downloading block %v of object %v from node %v: %v", rd.block.Block.Index, s.object.ObjectID, rd.block.Node.NodeID, c.Error)
+					}
+					rd.stopped = true
+					continue
+				}
+
+				// 已经解码过的条带的分块直接丢弃，立刻归还令牌
+				if c.StripIndex < curStripIndex {
+					rd.release()
+					continue
+				}
+
+				stripChunks, ok := pendings[c.StripIndex]
+				if !ok {
+					stripChunks = make([][]byte, s.red.N)
+					pendings[c.StripIndex] = stripChunks
+				}
+				stripChunks[rd.block.Block.Index] = c.Data
+				holders[c.StripIndex] = append(holders[c.StripIndex], rd)
+
+			case <-ticker.C:
+				// 为每个还没有下载到当前条带、且速度过慢的块额外启动一个块的下载
+				for _, rd := range readers {
+					if rd == nil || rd.stopped || rd.hedged || chunks[rd.block.Block.Index] != nil {
+						continue
+					}
+
+					if !rd.isSlow(checkInterval, s.cfg.ECHedgeMinThroughputKBs*1024) {
+						continue
+					}
+
+					if !startNextReader(curStripIndex) {
+						break
+					}
+					rd.hedged = true
+					logger.Debugf("block %v of object %v from node %v is too slow, start downloading an extra block", rd.block.Block.Index, s.object.ObjectID, rd.block.Node.NodeID)
+				}
+
+			case <-s.downloadingDone:
+				break loop
+			}
 		}
+
+		err := rs.ReconstructData(chunks)
 		if err != nil {
-			s.sendToDataChan(dataChanEntry{Error: err})
+			s.sendToDataChan(dataChanEntry{Error: fmt.Errorf("reconstructing strip %v: %w", curStripIndex, err)})
 			break loop
 		}
 
+		dataBuf := make([]byte, 0, s.red.K*s.red.ChunkSize)
+		for i := 0; i < s.red.K; i++ {
+			dataBuf = append(dataBuf, chunks[i]...)
+		}
+
 		s.cache.Add(stripKey, ObjectECStrip{
 			Data:           dataBuf,
 			ObjectFileHash: s.object.FileHash,
 		})
+		if s.diskCache != nil {
+			s.diskCache.Put(s.object.FileHash, stripBytesPos, dataBuf)
+		}
 
-		if !s.sendToDataChan(dataChanEntry{Data: dataBuf, Position: stripBytesPos}) {
+		if !s.sendStrip(dataBuf, stripBytesPos) {
 			break loop
 		}
+		finishStrip(curStripIndex)
 
 		curStripIndex++
 	}
@@ -200,6 +326,144 @@ loop:
 	close(s.dataChan)
 }
 
+type blockChunk struct {
+	ReaderIndex int
+	StripIndex  int64
+	Data        []byte
+	Error       error
+}
+
+// 从一个节点上按分块顺序读取一个编码块
+type blockReader struct {
+	block     downloadBlock
+	startTime time.Time
+	readBytes atomic.Int64
+	// 花费在读取数据上的时间，不包括等待令牌的时间
+	readNanos atomic.Int64
+	// 当前正在进行的读取的开始时间，为0表示没有在读取
+	readingSince atomic.Int64
+	// 每读取一个分块需要消耗一个令牌，用于限制预读的分块数量
+	tokens  chan any
+	stopped bool
+	hedged  bool
+}
+
+func (r *blockReader) release() {
+	r.tokens <- nil
+}
+
+// 读取时间超过检查间隔之后，平均速度低于minThroughput的块被认为是过慢的
+func (r *blockReader) isSlow(checkInterval time.Duration, minThroughput float64) bool {
+	elapsed := time.Duration(r.readNanos.Load())
+	if since := r.readingSince.Load(); since != 0 {
+		elapsed += time.Since(time.Unix(0, since))
+	}
+	if elapsed < checkInterval {
+		return false
+	}
+
+	return float64(r.readBytes.Load())/elapsed.Seconds() < minThroughput
+}
+
+func (s *StripIterator) startBlockReader(ctx context.Context, readerIndex int, blk downloadBlock, beginStripIndex int64, maxPrefetch int, chunkChan chan<- blockChunk) *blockReader {
+	rd := &blockReader{
+		block:     blk,
+		startTime: time.Now(),
+		tokens:    make(chan any, maxPrefetch),
+	}
+	for i := 0; i < maxPrefetch; i++ {
+		rd.tokens <- nil
+	}
+
+	go func() {
+		s.conn.BeginTransfer(blk.Node.NodeID)
+		defer s.conn.EndTransfer(blk.Node.NodeID)
+
+		sendErr := func(err error) {
+			select {
+			case chunkChan <- blockChunk{ReaderIndex: readerIndex, Error: err}:
+			case <-ctx.Done():
+			}
+		}
+
+		str, err := s.openBlock(ctx, blk, beginStripIndex)
+		if err != nil {
+			sendErr(err)
+			return
+		}
+		defer str.Close()
+
+		for stripIndex := beginStripIndex; ; stripIndex++ {
+			select {
+			case <-rd.tokens:
+			case <-ctx.Done():
+				return
+			}
+
+			readStart := time.Now()
+			rd.readingSince.Store(readStart.UnixNano())
+			buf := make([]byte, s.red.ChunkSize)
+			n, err := io.ReadFull(str, buf)
+			rd.readingSince.Store(0)
+			rd.readNanos.Add(int64(time.Since(readStart)))
+			if err == io.EOF {
+				sendErr(io.EOF)
+				return
+			}
+			// 编码块的长度总是分块大小的整数倍，不过为了保险，不足的部分按0处理
+			if err != nil && err != io.ErrUnexpectedEOF {
+				sendErr(err)
+				return
+			}
+
+			if stripIndex == beginStripIndex {
+				s.conn.RecordLatency(blk.Node.NodeID, time.Since(rd.startTime))
+			} else {
+				s.conn.RecordThroughput(blk.Node.NodeID, int64(n), time.Since(readStart))
+			}
+			rd.readBytes.Add(int64(n))
+
+			select {
+			case chunkChan <- blockChunk{ReaderIndex: readerIndex, StripIndex: stripIndex, Data: buf}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return rd
+}
+
+func (s *StripIterator) openBlockFromNode(ctx context.Context, blk downloadBlock, beginStripIndex int64) (io.ReadCloser, error) {
+	ft := ioswitch2.NewFromTo()
+	ft.AddFrom(ioswitch2.NewFromNode(blk.Block.FileHash, &blk.Node, blk.Block.Index))
+	toExec, hd := ioswitch2.NewToDriverWithRange(blk.Block.Index, exec.Range{
+		Offset: beginStripIndex * int64(s.red.ChunkSize),
+	})
+	ft.AddTo(toExec)
+
+	plans := exec.NewPlanBuilder()
+	err := parser.NewParser(*s.red).Parse(ft, plans)
+	if err != nil {
+		return nil, err
+	}
+	exec := plans.Execute()
+	go exec.Wait(ctx)
+
+	return exec.BeginRead(hd)
+}
+
+// 发送一个完整的条带，最后一个条带末尾填充的数据会被去掉。返回false表示需要停止下载
+func (s *StripIterator) sendStrip(data []byte, stripBytesPos int64) bool {
+	if stripBytesPos+int64(len(data)) >= s.object.Size {
+		s.sendToDataChan(dataChanEntry{Data: data[:s.object.Size-stripBytesPos], Position: stripBytesPos})
+		s.sendToDataChan(dataChanEntry{Error: io.EOF})
+		return false
+	}
+
+	return s.sendToDataChan(dataChanEntry{Data: data, Position: stripBytesPos})
+}
+
 func (s *StripIterator) sendToDataChan(entry dataChanEntry) bool {
 	select {
 	case s.dataChan <- entry:
@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	. "github.com/smartystreets/goconvey/convey"
+	"gitlink.org.cn/cloudream/common/pkgs/iterator"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/connectivity"
+)
+
+// 模拟一个编码块的数据流，每次读取之前等待delay，并记录读取了多少字节
+type fakeBlockStream struct {
+	remain    int64
+	delay     time.Duration
+	readBytes *atomic.Int64
+}
+
+func (s *fakeBlockStream) Read(p []byte) (int, error) {
+	if s.remain == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(s.delay)
+
+	n := int64(len(p))
+	if n > s.remain {
+		n = s.remain
+	}
+	s.remain -= n
+	s.readBytes.Add(n)
+	return int(n), nil
+}
+
+func (s *fakeBlockStream) Close() error {
+	return nil
+}
+
+func Test_StripIteratorPrefetch(t *testing.T) {
+	Convey("有一个块很慢时，其他块预读的分块数量依然有上限", t, func() {
+		const stripCount = 64
+		const prefetch = 2
+		red := &cdssdk.ECRedundancy{K: 2, N: 3, ChunkSize: 16}
+		obj := cdssdk.Object{ObjectID: 1, FileHash: "Qm1", Size: stripCount * int64(red.K*red.ChunkSize)}
+		blocks := []downloadBlock{
+			{Node: cdssdk.Node{NodeID: 1}, Block: stgmod.ObjectBlock{ObjectID: 1, Index: 0, NodeID: 1}},
+			{Node: cdssdk.Node{NodeID: 2}, Block: stgmod.ObjectBlock{ObjectID: 1, Index: 1, NodeID: 2}},
+		}
+
+		cache, _ := lru.New[ECStripKey, ObjectECStrip](stripCount)
+		conn := connectivity.NewCollector(&connectivity.Config{TestInterval: 3600}, nil)
+		defer conn.Close()
+
+		iter := NewStripIterator(obj, blocks, red, 0, cache, nil, &conn, Config{
+			ECStripPrefetchCount:   prefetch,
+			ECHedgeCheckIntervalMs: 1000,
+		})
+		defer iter.Close()
+
+		var fastRead atomic.Int64
+		var slowRead atomic.Int64
+		iter.openBlock = func(ctx context.Context, blk downloadBlock, beginStripIndex int64) (io.ReadCloser, error) {
+			str := &fakeBlockStream{remain: stripCount * int64(red.ChunkSize), readBytes: &fastRead}
+			if blk.Block.Index == 1 {
+				str.delay = 2 * time.Millisecond
+				str.readBytes = &slowRead
+			}
+			return str, nil
+		}
+
+		consumed := int64(0)
+		maxAhead := int64(0)
+		for {
+			_, err := iter.MoveNext()
+			if err == iterator.ErrNoMoreItem {
+				break
+			}
+			So(err, ShouldBeNil)
+			consumed++
+
+			ahead := fastRead.Load()/int64(red.ChunkSize) - consumed
+			if ahead > maxAhead {
+				maxAhead = ahead
+			}
+		}
+
+		So(consumed, ShouldEqual, stripCount)
+		// 最多有prefetch个条带在等待被取走，以及prefetch个分块在等待解码
+		So(maxAhead, ShouldBeLessThanOrEqualTo, 2*prefetch)
+	})
+}
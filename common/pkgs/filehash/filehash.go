@@ -0,0 +1,192 @@
+package filehash
+
+import (
+	"crypto/sha256"
+	"io"
+	"math/big"
+)
+
+// 与IPFS默认参数（CIDv0、256KB定长分块、balanced布局、每个节点最多174个链接）相同
+const (
+	ChunkSize       = 256 * 1024
+	MaxLinksPerNode = 174
+)
+
+const unixfsTypeFile = 2
+
+// CalculateIPFSHash 在本地计算文件导入IPFS后得到的哈希值（CIDv0），不需要连接IPFS
+func CalculateIPFSHash(r io.Reader) (string, error) {
+	b := &dagBuilder{r: r}
+	b.readChunk()
+	if b.err != nil {
+		return "", b.err
+	}
+
+	// 空文件
+	if b.done() {
+		return b.newLeaf().cid(), nil
+	}
+
+	root := b.newLeaf()
+	for depth := 1; !b.done(); depth++ {
+		root = b.fillNode([]dagNode{root}, depth)
+	}
+	if b.err != nil {
+		return "", b.err
+	}
+
+	return root.cid(), nil
+}
+
+type dagNode struct {
+	multihash []byte
+	fileSize  uint64
+	// 节点以及它的所有子节点序列化之后的总大小
+	cumulativeSize uint64
+}
+
+func (n dagNode) cid() string {
+	return base58Encode(n.multihash)
+}
+
+type dagBuilder struct {
+	r    io.Reader
+	next []byte
+	err  error
+}
+
+func (b *dagBuilder) readChunk() {
+	buf := make([]byte, ChunkSize)
+	n, err := io.ReadFull(b.r, buf)
+	if err == io.EOF {
+		b.next = nil
+		return
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		b.err = err
+		b.next = nil
+		return
+	}
+
+	b.next = buf[:n]
+}
+
+func (b *dagBuilder) done() bool {
+	return b.next == nil
+}
+
+func (b *dagBuilder) newLeaf() dagNode {
+	data := b.next
+	b.readChunk()
+
+	node := encodePBNode(nil, encodeUnixFSData(data, uint64(len(data)), nil))
+	return dagNode{
+		multihash:      sha256Multihash(node),
+		fileSize:       uint64(len(data)),
+		cumulativeSize: uint64(len(node)),
+	}
+}
+
+func (b *dagBuilder) fillNode(children []dagNode, depth int) dagNode {
+	for len(children) < MaxLinksPerNode && !b.done() {
+		if depth == 1 {
+			children = append(children, b.newLeaf())
+		} else {
+			children = append(children, b.fillNode(nil, depth-1))
+		}
+	}
+
+	var fileSize uint64
+	var cumSize uint64
+	blockSizes := make([]uint64, len(children))
+	for i, c := range children {
+		fileSize += c.fileSize
+		cumSize += c.cumulativeSize
+		blockSizes[i] = c.fileSize
+	}
+
+	node := encodePBNode(children, encodeUnixFSData(nil, fileSize, blockSizes))
+	return dagNode{
+		multihash:      sha256Multihash(node),
+		fileSize:       fileSize,
+		cumulativeSize: cumSize + uint64(len(node)),
+	}
+}
+
+// dag-pb格式要求Links字段（2）在Data字段（1）之前
+func encodePBNode(links []dagNode, data []byte) []byte {
+	var buf []byte
+	for _, l := range links {
+		var lnk []byte
+		lnk = appendBytesField(lnk, 1, l.multihash)
+		lnk = appendBytesField(lnk, 2, nil)
+		lnk = appendVarintField(lnk, 3, l.cumulativeSize)
+
+		buf = appendBytesField(buf, 2, lnk)
+	}
+	buf = appendBytesField(buf, 1, data)
+	return buf
+}
+
+func encodeUnixFSData(data []byte, fileSize uint64, blockSizes []uint64) []byte {
+	var buf []byte
+	buf = appendVarintField(buf, 1, unixfsTypeFile)
+	if len(data) > 0 {
+		buf = appendBytesField(buf, 2, data)
+	}
+	buf = appendVarintField(buf, 3, fileSize)
+	for _, s := range blockSizes {
+		buf = appendVarintField(buf, 4, s)
+	}
+	return buf
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendVarintField(buf []byte, field int, v uint64) []byte {
+	buf = appendVarint(buf, uint64(field<<3))
+	return appendVarint(buf, v)
+}
+
+func appendBytesField(buf []byte, field int, data []byte) []byte {
+	buf = appendVarint(buf, uint64(field<<3|2))
+	buf = appendVarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
+
+// 0x12表示sha2-256，0x20表示摘要长度32
+func sha256Multihash(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return append([]byte{0x12, 0x20}, sum[:]...)
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func base58Encode(data []byte) string {
+	num := new(big.Int).SetBytes(data)
+	base := big.NewInt(58)
+	mod := new(big.Int)
+
+	var ret []byte
+	for num.Sign() > 0 {
+		num.DivMod(num, base, mod)
+		ret = append(ret, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		ret = append(ret, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+		ret[i], ret[j] = ret[j], ret[i]
+	}
+	return string(ret)
+}
@@ -0,0 +1,93 @@
+package filehash
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_CalculateIPFSHash(t *testing.T) {
+	Convey("空文件", t, func() {
+		hash, err := CalculateIPFSHash(strings.NewReader(""))
+		So(err, ShouldBeNil)
+		So(hash, ShouldEqual, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH")
+	})
+
+	Convey("只有一个分块的文件", t, func() {
+		hash, err := CalculateIPFSHash(strings.NewReader("hello world\n"))
+		So(err, ShouldBeNil)
+		So(hash, ShouldEqual, "QmT78zSuBmuS4z925WZfrqQ1qHaJ56DQaTfyMUF7F8ff5o")
+	})
+}
+
+func Test_CalculateIPFSHashMultiChunk(t *testing.T) {
+	data := make([]byte, ChunkSize*(MaxLinksPerNode+1)+100)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	leaves := func(data []byte) []dagNode {
+		var ret []dagNode
+		for len(data) > 0 {
+			n := ChunkSize
+			if n > len(data) {
+				n = len(data)
+			}
+			ret = append(ret, refLeaf(data[:n]))
+			data = data[n:]
+		}
+		return ret
+	}
+
+	Convey("只有一层链接的文件", t, func() {
+		d := data[:ChunkSize*2+100]
+		hash, err := CalculateIPFSHash(bytes.NewReader(d))
+		So(err, ShouldBeNil)
+		So(hash, ShouldEqual, refParent(leaves(d)).cid())
+	})
+
+	Convey("正好填满一个节点的文件", t, func() {
+		d := data[:ChunkSize*MaxLinksPerNode]
+		hash, err := CalculateIPFSHash(bytes.NewReader(d))
+		So(err, ShouldBeNil)
+		So(hash, ShouldEqual, refParent(leaves(d)).cid())
+	})
+
+	Convey("需要两层链接的文件", t, func() {
+		// balanced布局：第一个子节点链接了MaxLinksPerNode个分块，剩下的分块放在第二个子节点中，即使只有一个分块也不会直接链接到根节点
+		ls := leaves(data)
+		So(len(ls), ShouldEqual, MaxLinksPerNode+2)
+
+		root := refParent([]dagNode{refParent(ls[:MaxLinksPerNode]), refParent(ls[MaxLinksPerNode:])})
+		hash, err := CalculateIPFSHash(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+		So(hash, ShouldEqual, root.cid())
+	})
+}
+
+func refLeaf(data []byte) dagNode {
+	node := encodePBNode(nil, encodeUnixFSData(data, uint64(len(data)), nil))
+	return dagNode{
+		multihash:      sha256Multihash(node),
+		fileSize:       uint64(len(data)),
+		cumulativeSize: uint64(len(node)),
+	}
+}
+
+func refParent(children []dagNode) dagNode {
+	var fileSize, cumSize uint64
+	var blockSizes []uint64
+	for _, c := range children {
+		fileSize += c.fileSize
+		cumSize += c.cumulativeSize
+		blockSizes = append(blockSizes, c.fileSize)
+	}
+
+	node := encodePBNode(children, encodeUnixFSData(nil, fileSize, blockSizes))
+	return dagNode{
+		multihash:      sha256Multihash(node),
+		fileSize:       fileSize,
+		cumulativeSize: cumSize + uint64(len(node)),
+	}
+}
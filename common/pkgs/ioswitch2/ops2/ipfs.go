@@ -12,6 +12,7 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/utils/io2"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/filehash"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
 )
 
@@ -60,6 +61,7 @@ func (o *IPFSRead) String() string {
 type IPFSWrite struct {
 	Input    *exec.StreamVar `json:"input"`
 	FileHash *exec.StringVar `json:"fileHash"`
+	HashOnly bool            `json:"hashOnly"` // 只计算文件哈希，不写入IPFS
 }
 
 func (o *IPFSWrite) Execute(ctx context.Context, e *exec.Executor) error {
@@ -68,6 +70,10 @@ func (o *IPFSWrite) Execute(ctx context.Context, e *exec.Executor) error {
 		WithField("FileHashVar", o.FileHash.ID).
 		Debugf("ipfs write op")
 
+	if o.HashOnly {
+		return o.calcHash(ctx, e)
+	}
+
 	ipfsCli, err := stgglb.IPFSPool.Acquire()
 	if err != nil {
 		return fmt.Errorf("new ipfs client: %w", err)
@@ -90,8 +96,25 @@ func (o *IPFSWrite) Execute(ctx context.Context, e *exec.Executor) error {
 	return nil
 }
 
+func (o *IPFSWrite) calcHash(ctx context.Context, e *exec.Executor) error {
+	err := e.BindVars(ctx, o.Input)
+	if err != nil {
+		return err
+	}
+	defer o.Input.Stream.Close()
+
+	o.FileHash.Value, err = filehash.CalculateIPFSHash(o.Input.Stream)
+	if err != nil {
+		return fmt.Errorf("calculating file hash: %w", err)
+	}
+
+	e.PutVars(o.FileHash)
+
+	return nil
+}
+
 func (o *IPFSWrite) String() string {
-	return fmt.Sprintf("IPFSWrite %v -> %v", o.Input.ID, o.FileHash.ID)
+	return fmt.Sprintf("IPFSWrite(hashOnly=%v) %v -> %v", o.HashOnly, o.Input.ID, o.FileHash.ID)
 }
 
 type IPFSReadType struct {
@@ -118,6 +141,7 @@ func (t *IPFSReadType) String(node *dag.Node) string {
 type IPFSWriteType struct {
 	FileHashStoreKey string
 	Range            exec.Range
+	HashOnly         bool
 }
 
 func (t *IPFSWriteType) InitNode(node *dag.Node) {
@@ -129,6 +153,7 @@ func (t *IPFSWriteType) GenerateOp(op *dag.Node) (exec.Op, error) {
 	return &IPFSWrite{
 		Input:    op.InputStreams[0].Var,
 		FileHash: op.OutputValues[0].Var.(*exec.StringVar),
+		HashOnly: t.HashOnly,
 	}, nil
 }
 
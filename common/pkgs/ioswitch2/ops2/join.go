@@ -2,15 +2,20 @@ package ops2
 
 import (
 	"context"
+	"fmt"
 	"io"
 
+	"github.com/samber/lo"
 	"gitlink.org.cn/cloudream/common/pkgs/future"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/dag"
 	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/utils"
 	"gitlink.org.cn/cloudream/common/utils/io2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
 )
 
 func init() {
-	// OpUnion.AddT((*Join)(nil))
+	exec.UseOp[*Join]()
 }
 
 type Join struct {
@@ -43,3 +48,37 @@ func (o *Join) Execute(ctx context.Context, e *exec.Executor) error {
 
 	return fut.Wait(ctx)
 }
+
+func (o *Join) String() string {
+	return fmt.Sprintf(
+		"Join(length=%v), (%v) -> %v",
+		o.Length,
+		utils.FormatVarIDs(o.Inputs),
+		o.Output.ID,
+	)
+}
+
+// 按顺序首尾拼接所有输入流
+type JoinType struct {
+	InputCount int
+	Length     int64
+}
+
+func (t *JoinType) InitNode(node *dag.Node) {
+	dag.NodeDeclareInputStream(node, t.InputCount)
+	dag.NodeNewOutputStream(node, &ioswitch2.VarProps{})
+}
+
+func (t *JoinType) GenerateOp(op *dag.Node) (exec.Op, error) {
+	return &Join{
+		Inputs: lo.Map(op.InputStreams, func(v *dag.StreamVar, idx int) *exec.StreamVar {
+			return v.Var
+		}),
+		Output: op.OutputStreams[0].Var,
+		Length: t.Length,
+	}, nil
+}
+
+func (t *JoinType) String(node *dag.Node) string {
+	return fmt.Sprintf("Join[%v]%v%v", t.Length, formatStreamIO(node), formatValueIO(node))
+}
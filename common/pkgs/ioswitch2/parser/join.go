@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/dag"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/plan"
+	"gitlink.org.cn/cloudream/common/pkgs/ipfs"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/ops2"
+)
+
+// 在节点上将多个文件按顺序拼接成一个新文件，数据不会离开这个节点。
+// 新文件的哈希会存储在fileHashStoreKey中
+func (p *DefaultParser) JoinFiles(node cdssdk.Node, fileHashes []string, length int64, fileHashStoreKey string, blder *exec.PlanBuilder) error {
+	if len(fileHashes) == 0 {
+		return fmt.Errorf("no file to join")
+	}
+
+	ctx := ParseContext{DAG: dag.NewGraph()}
+
+	joinNode, _ := dag.NewNode(ctx.DAG, &ops2.JoinType{
+		InputCount: len(fileHashes),
+		Length:     length,
+	}, &ioswitch2.NodeProps{})
+	joinNode.Env.ToEnvWorker(&ioswitch2.AgentWorker{Node: node})
+	joinNode.Env.Pinned = true
+
+	for i, hash := range fileHashes {
+		rd, _ := dag.NewNode(ctx.DAG, &ops2.IPFSReadType{
+			FileHash: hash,
+			Option: ipfs.ReadOption{
+				Offset: 0,
+				Length: -1,
+			},
+		}, &ioswitch2.NodeProps{})
+		rd.Env.ToEnvWorker(&ioswitch2.AgentWorker{Node: node})
+		rd.Env.Pinned = true
+
+		rd.OutputStreams[0].To(joinNode, i)
+	}
+
+	wr, _ := dag.NewNode(ctx.DAG, &ops2.IPFSWriteType{
+		FileHashStoreKey: fileHashStoreKey,
+	}, &ioswitch2.NodeProps{})
+	wr.Env.ToEnvWorker(&ioswitch2.AgentWorker{Node: node})
+	wr.Env.Pinned = true
+
+	joinNode.OutputStreams[0].To(wr, 0)
+
+	p.storeIPFSWriteResult(&ctx)
+
+	return plan.Generate(ctx.DAG, blder)
+}
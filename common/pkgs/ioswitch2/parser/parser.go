@@ -277,6 +277,7 @@ func (p *DefaultParser) buildToNode(ctx *ParseContext, ft *ioswitch2.FromTo, t i
 		n, _ := dag.NewNode(ctx.DAG, &ops2.IPFSWriteType{
 			FileHashStoreKey: t.FileHashStoreKey,
 			Range:            t.Range,
+			HashOnly:         t.HashOnly,
 		}, &ioswitch2.NodeProps{
 			To: t,
 		})
@@ -90,6 +90,7 @@ type ToNode struct {
 	DataIndex        int
 	Range            exec.Range
 	FileHashStoreKey string
+	HashOnly         bool // 只在节点上计算数据的哈希值，不写入IPFS
 }
 
 func NewToNode(node cdssdk.Node, dataIndex int, fileHashStoreKey string) *ToNode {
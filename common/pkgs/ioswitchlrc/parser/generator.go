@@ -20,12 +20,17 @@ type GenerateContext struct {
 
 // 输入一个完整文件，从这个完整文件产生任意文件块（也可再产生完整文件）。
 func Encode(fr ioswitchlrc.From, toes []ioswitchlrc.To, blder *exec.PlanBuilder) error {
+	return EncodeWith(cdssdk.DefaultLRCRedundancy, fr, toes, blder)
+}
+
+// 同Encode，但使用指定的LRC参数
+func EncodeWith(red cdssdk.LRCRedundancy, fr ioswitchlrc.From, toes []ioswitchlrc.To, blder *exec.PlanBuilder) error {
 	if fr.GetDataIndex() != -1 {
 		return fmt.Errorf("from data is not a complete file")
 	}
 
 	ctx := GenerateContext{
-		LRC:  cdssdk.DefaultLRCRedundancy,
+		LRC:  red,
 		DAG:  dag.NewGraph(),
 		Toes: toes,
 	}
@@ -123,8 +128,13 @@ func buildDAGEncode(ctx *GenerateContext, fr ioswitchlrc.From, toes []ioswitchlr
 
 // 提供数据块+编码块中的k个块，重建任意块，包括完整文件。
 func ReconstructAny(frs []ioswitchlrc.From, toes []ioswitchlrc.To, blder *exec.PlanBuilder) error {
+	return ReconstructAnyWith(cdssdk.DefaultLRCRedundancy, frs, toes, blder)
+}
+
+// 同ReconstructAny，但使用指定的LRC参数
+func ReconstructAnyWith(red cdssdk.LRCRedundancy, frs []ioswitchlrc.From, toes []ioswitchlrc.To, blder *exec.PlanBuilder) error {
 	ctx := GenerateContext{
-		LRC:  cdssdk.DefaultLRCRedundancy,
+		LRC:  red,
 		DAG:  dag.NewGraph(),
 		Toes: toes,
 	}
@@ -121,6 +121,7 @@ func buildToNode(ctx *GenerateContext, t ioswitchlrc.To) (*dag.Node, error) {
 		n, _ := dag.NewNode(ctx.DAG, &ops2.IPFSWriteType{
 			FileHashStoreKey: t.FileHashStoreKey,
 			Range:            t.Range,
+			HashOnly:         t.HashOnly,
 		}, &ioswitchlrc.NodeProps{
 			To: t,
 		})
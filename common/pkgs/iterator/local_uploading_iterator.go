@@ -13,12 +13,20 @@ type LocalUploadingIterator struct {
 	pathRoot     string
 	filePathes   []string
 	currentIndex int
+	sha256s      map[string]string
+	md5s         map[string]string
 }
 
 type IterUploadingObject struct {
 	Path string
 	Size int64
 	File io.ReadCloser
+	// 可选，用户期望的文件校验和（十六进制），上传时会检查，不一致则上传失败
+	SHA256 string
+	MD5    string
+	// 可选，对象的元数据
+	ContentType  string
+	UserMetadata map[string]string
 }
 
 func NewUploadingObjectIterator(pathRoot string, filePathes []string) *LocalUploadingIterator {
@@ -28,6 +36,12 @@ func NewUploadingObjectIterator(pathRoot string, filePathes []string) *LocalUplo
 	}
 }
 
+// 设置文件期望的校验和，键为上传后的对象路径。没有设置校验和的文件不检查
+func (i *LocalUploadingIterator) SetChecksums(sha256s map[string]string, md5s map[string]string) {
+	i.sha256s = sha256s
+	i.md5s = md5s
+}
+
 func (i *LocalUploadingIterator) MoveNext() (*IterUploadingObject, error) {
 	if i.currentIndex >= len(i.filePathes) {
 		return nil, ErrNoMoreItem
@@ -51,10 +65,13 @@ func (i *LocalUploadingIterator) doMove() (*IterUploadingObject, error) {
 		return nil, err
 	}
 
+	objPath := strings.TrimPrefix(filepath.ToSlash(path), i.pathRoot+"/")
 	return &IterUploadingObject{
-		Path: strings.TrimPrefix(filepath.ToSlash(path), i.pathRoot+"/"),
-		Size: info.Size(),
-		File: file,
+		Path:   objPath,
+		Size:   info.Size(),
+		File:   file,
+		SHA256: i.sha256s[objPath],
+		MD5:    i.md5s[objPath],
 	}, nil
 }
 
@@ -55,7 +55,7 @@ type GetUserBuckets struct {
 }
 type GetUserBucketsResp struct {
 	mq.MessageBodyBase
-	Buckets []model.Bucket `json:"buckets"`
+	Buckets []model.BucketDetail `json:"buckets"`
 }
 
 func NewGetUserBuckets(userID cdssdk.UserID) *GetUserBuckets {
@@ -63,7 +63,7 @@ func NewGetUserBuckets(userID cdssdk.UserID) *GetUserBuckets {
 		UserID: userID,
 	}
 }
-func NewGetUserBucketsResp(buckets []model.Bucket) *GetUserBucketsResp {
+func NewGetUserBucketsResp(buckets []model.BucketDetail) *GetUserBucketsResp {
 	return &GetUserBucketsResp{
 		Buckets: buckets,
 	}
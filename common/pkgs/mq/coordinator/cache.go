@@ -16,6 +16,7 @@ var _ = Register(Service.CachePackageMoved)
 
 type CachePackageMoved struct {
 	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
 	PackageID cdssdk.PackageID `json:"packageID"`
 	NodeID    cdssdk.NodeID    `json:"nodeID"`
 }
@@ -23,8 +24,9 @@ type CachePackageMovedResp struct {
 	mq.MessageBodyBase
 }
 
-func NewCachePackageMoved(packageID cdssdk.PackageID, nodeID cdssdk.NodeID) *CachePackageMoved {
+func NewCachePackageMoved(userID cdssdk.UserID, packageID cdssdk.PackageID, nodeID cdssdk.NodeID) *CachePackageMoved {
 	return &CachePackageMoved{
+		UserID:    userID,
 		PackageID: packageID,
 		NodeID:    nodeID,
 	}
@@ -41,6 +43,7 @@ var _ = Register(Service.CacheRemovePackage)
 
 type CacheRemovePackage struct {
 	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
 	PackageID cdssdk.PackageID `json:"packageID"`
 	NodeID    cdssdk.NodeID    `json:"nodeID"`
 }
@@ -48,8 +51,9 @@ type CacheRemovePackageResp struct {
 	mq.MessageBodyBase
 }
 
-func ReqCacheRemoveMovedPackage(packageID cdssdk.PackageID, nodeID cdssdk.NodeID) *CacheRemovePackage {
+func ReqCacheRemoveMovedPackage(userID cdssdk.UserID, packageID cdssdk.PackageID, nodeID cdssdk.NodeID) *CacheRemovePackage {
 	return &CacheRemovePackage{
+		UserID:    userID,
 		PackageID: packageID,
 		NodeID:    nodeID,
 	}
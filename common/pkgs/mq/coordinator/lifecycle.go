@@ -0,0 +1,96 @@
+package coordinator
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type LifecycleService interface {
+	AddLifecycleRule(msg *AddLifecycleRule) (*AddLifecycleRuleResp, *mq.CodeMessage)
+
+	GetBucketLifecycleRules(msg *GetBucketLifecycleRules) (*GetBucketLifecycleRulesResp, *mq.CodeMessage)
+
+	DeleteLifecycleRule(msg *DeleteLifecycleRule) (*DeleteLifecycleRuleResp, *mq.CodeMessage)
+}
+
+// 为桶添加一条生命周期规则，规则中的RuleID和CreateTime会被忽略
+var _ = Register(Service.AddLifecycleRule)
+
+type AddLifecycleRule struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID       `json:"userID"`
+	Rule   model.LifecycleRule `json:"rule"`
+}
+type AddLifecycleRuleResp struct {
+	mq.MessageBodyBase
+	RuleID model.LifecycleRuleID `json:"ruleID"`
+}
+
+func ReqAddLifecycleRule(userID cdssdk.UserID, rule model.LifecycleRule) *AddLifecycleRule {
+	return &AddLifecycleRule{
+		UserID: userID,
+		Rule:   rule,
+	}
+}
+func RespAddLifecycleRule(ruleID model.LifecycleRuleID) *AddLifecycleRuleResp {
+	return &AddLifecycleRuleResp{
+		RuleID: ruleID,
+	}
+}
+func (client *Client) AddLifecycleRule(msg *AddLifecycleRule) (*AddLifecycleRuleResp, error) {
+	return mq.Request(Service.AddLifecycleRule, client.rabbitCli, msg)
+}
+
+// 查询桶的所有生命周期规则
+var _ = Register(Service.GetBucketLifecycleRules)
+
+type GetBucketLifecycleRules struct {
+	mq.MessageBodyBase
+	UserID   cdssdk.UserID   `json:"userID"`
+	BucketID cdssdk.BucketID `json:"bucketID"`
+}
+type GetBucketLifecycleRulesResp struct {
+	mq.MessageBodyBase
+	Rules []model.LifecycleRule `json:"rules"`
+}
+
+func ReqGetBucketLifecycleRules(userID cdssdk.UserID, bucketID cdssdk.BucketID) *GetBucketLifecycleRules {
+	return &GetBucketLifecycleRules{
+		UserID:   userID,
+		BucketID: bucketID,
+	}
+}
+func RespGetBucketLifecycleRules(rules []model.LifecycleRule) *GetBucketLifecycleRulesResp {
+	return &GetBucketLifecycleRulesResp{
+		Rules: rules,
+	}
+}
+func (client *Client) GetBucketLifecycleRules(msg *GetBucketLifecycleRules) (*GetBucketLifecycleRulesResp, error) {
+	return mq.Request(Service.GetBucketLifecycleRules, client.rabbitCli, msg)
+}
+
+// 删除一条生命周期规则
+var _ = Register(Service.DeleteLifecycleRule)
+
+type DeleteLifecycleRule struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID         `json:"userID"`
+	RuleID model.LifecycleRuleID `json:"ruleID"`
+}
+type DeleteLifecycleRuleResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqDeleteLifecycleRule(userID cdssdk.UserID, ruleID model.LifecycleRuleID) *DeleteLifecycleRule {
+	return &DeleteLifecycleRule{
+		UserID: userID,
+		RuleID: ruleID,
+	}
+}
+func RespDeleteLifecycleRule() *DeleteLifecycleRuleResp {
+	return &DeleteLifecycleRuleResp{}
+}
+func (client *Client) DeleteLifecycleRule(msg *DeleteLifecycleRule) (*DeleteLifecycleRuleResp, error) {
+	return mq.Request(Service.DeleteLifecycleRule, client.rabbitCli, msg)
+}
@@ -1,6 +1,8 @@
 package coordinator
 
 import (
+	"time"
+
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 
@@ -11,6 +13,8 @@ import (
 type ObjectService interface {
 	GetPackageObjects(msg *GetPackageObjects) (*GetPackageObjectsResp, *mq.CodeMessage)
 
+	ListObjects(msg *ListObjects) (*ListObjectsResp, *mq.CodeMessage)
+
 	GetPackageObjectDetails(msg *GetPackageObjectDetails) (*GetPackageObjectDetailsResp, *mq.CodeMessage)
 
 	GetObjectDetails(msg *GetObjectDetails) (*GetObjectDetailsResp, *mq.CodeMessage)
@@ -23,9 +27,71 @@ type ObjectService interface {
 
 	DeleteObjects(msg *DeleteObjects) (*DeleteObjectsResp, *mq.CodeMessage)
 
+	FindExistingFiles(msg *FindExistingFiles) (*FindExistingFilesResp, *mq.CodeMessage)
+
+	GetObjectChecksums(msg *GetObjectChecksums) (*GetObjectChecksumsResp, *mq.CodeMessage)
+
 	GetDatabaseAll(msg *GetDatabaseAll) (*GetDatabaseAllResp, *mq.CodeMessage)
 }
 
+// 分页列出Package中满足条件的对象，结果按照路径升序
+var _ = Register(Service.ListObjects)
+
+type ListObjects struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
+	PackageID cdssdk.PackageID `json:"packageID"`
+	ListObjectsOption
+}
+type ListObjectsOption struct {
+	Prefix string `json:"prefix"`
+	// 不为空时，路径中Prefix之后第一个Delimiter（包括）之前的部分相同的对象会被合并为一个CommonPrefix返回
+	Delimiter string `json:"delimiter"`
+	// path.Match格式的模式，需要与对象的完整路径匹配
+	Glob          string     `json:"glob"`
+	MinSize       *int64     `json:"minSize"`
+	MaxSize       *int64     `json:"maxSize"`
+	UpdatedAfter  *time.Time `json:"updatedAfter"`
+	UpdatedBefore *time.Time `json:"updatedBefore"`
+	// 对象的MIME类型需要以ContentType开头
+	ContentType string `json:"contentType"`
+	// 对象的用户元数据需要包含所有这些键值对
+	Metadata map[string]string `json:"metadata"`
+	// 一次最多返回的对象和CommonPrefix的总数，为0或者超过上限时使用上限
+	MaxKeys int `json:"maxKeys"`
+	// 上一次查询返回的NextContinuationToken，为空则从头开始查询
+	ContinuationToken string `json:"continuationToken"`
+	// 只返回路径大于StartAfter的对象，ContinuationToken不为空时忽略
+	StartAfter string `json:"startAfter"`
+}
+type ListObjectsResp struct {
+	mq.MessageBodyBase
+	Objects        []model.Object         `json:"objects"`
+	Metadatas      []model.ObjectMetadata `json:"metadatas"` // 只包含返回的对象中设置了元数据的对象
+	CommonPrefixes []string               `json:"commonPrefixes"`
+	// 为空代表已经没有更多的结果。一次查询扫描的对象数量有上限，因此结果数量少于MaxKeys时也可能不为空
+	NextContinuationToken string `json:"nextContinuationToken"`
+}
+
+func ReqListObjects(userID cdssdk.UserID, packageID cdssdk.PackageID, opt ListObjectsOption) *ListObjects {
+	return &ListObjects{
+		UserID:            userID,
+		PackageID:         packageID,
+		ListObjectsOption: opt,
+	}
+}
+func RespListObjects(objects []model.Object, metadatas []model.ObjectMetadata, commonPrefixes []string, nextToken string) *ListObjectsResp {
+	return &ListObjectsResp{
+		Objects:               objects,
+		Metadatas:             metadatas,
+		CommonPrefixes:        commonPrefixes,
+		NextContinuationToken: nextToken,
+	}
+}
+func (client *Client) ListObjects(msg *ListObjects) (*ListObjectsResp, error) {
+	return mq.Request(Service.ListObjects, client.rabbitCli, msg)
+}
+
 // 查询Package中的所有Object，返回的Objects会按照ObjectID升序
 var _ = Register(Service.GetPackageObjects)
 
@@ -36,7 +102,8 @@ type GetPackageObjects struct {
 }
 type GetPackageObjectsResp struct {
 	mq.MessageBodyBase
-	Objects []model.Object `json:"objects"`
+	Objects   []model.Object         `json:"objects"`
+	Metadatas []model.ObjectMetadata `json:"metadatas"` // 只包含设置了元数据的对象，按照ObjectID升序
 }
 
 func NewGetPackageObjects(userID cdssdk.UserID, packageID cdssdk.PackageID) *GetPackageObjects {
@@ -45,9 +112,10 @@ func NewGetPackageObjects(userID cdssdk.UserID, packageID cdssdk.PackageID) *Get
 		PackageID: packageID,
 	}
 }
-func NewGetPackageObjectsResp(objects []model.Object) *GetPackageObjectsResp {
+func NewGetPackageObjectsResp(objects []model.Object, metadatas []model.ObjectMetadata) *GetPackageObjectsResp {
 	return &GetPackageObjectsResp{
-		Objects: objects,
+		Objects:   objects,
+		Metadatas: metadatas,
 	}
 }
 func (client *Client) GetPackageObjects(msg *GetPackageObjects) (*GetPackageObjectsResp, error) {
@@ -85,6 +153,7 @@ var _ = Register(Service.GetObjectDetails)
 
 type GetObjectDetails struct {
 	mq.MessageBodyBase
+	UserID    cdssdk.UserID     `json:"userID"` // 不为0时，用户无权访问的对象也会返回nil
 	ObjectIDs []cdssdk.ObjectID `json:"objectIDs"`
 }
 type GetObjectDetailsResp struct {
@@ -97,6 +166,12 @@ func ReqGetObjectDetails(objectIDs []cdssdk.ObjectID) *GetObjectDetails {
 		ObjectIDs: objectIDs,
 	}
 }
+func ReqGetUserObjectDetails(userID cdssdk.UserID, objectIDs []cdssdk.ObjectID) *GetObjectDetails {
+	return &GetObjectDetails{
+		UserID:    userID,
+		ObjectIDs: objectIDs,
+	}
+}
 func RespGetObjectDetails(objects []*stgmod.ObjectDetail) *GetObjectDetailsResp {
 	return &GetObjectDetailsResp{
 		Objects: objects,
@@ -140,8 +215,48 @@ var _ = Register(Service.UpdateObjectInfos)
 
 type UpdateObjectInfos struct {
 	mq.MessageBodyBase
-	UserID    cdssdk.UserID           `json:"userID"`
-	Updatings []cdssdk.UpdatingObject `json:"updatings"`
+	UserID    cdssdk.UserID            `json:"userID"`
+	Updatings []cdssdk.UpdatingObject  `json:"updatings"`
+	Metadatas []UpdatingObjectMetadata `json:"metadatas"`
+}
+
+const (
+	// 使用新的元数据替换对象原有的所有元数据
+	UpdatingMetadataModeSet = "Set"
+	// 将新的元数据合并到对象原有的元数据中
+	UpdatingMetadataModeMerge = "Merge"
+)
+
+type UpdatingObjectMetadata struct {
+	ObjectID cdssdk.ObjectID `json:"objectID"`
+	Mode     string          `json:"mode"`
+	// 为nil时，Set模式下会清空MIME类型，Merge模式下不修改
+	ContentType *string `json:"contentType"`
+	// 值为nil的键会被删除
+	UserMetadata map[string]*string `json:"userMetadata"`
+}
+
+func (u *UpdatingObjectMetadata) ApplyTo(meta *stgmod.ObjectMetadata) {
+	if u.Mode == UpdatingMetadataModeSet {
+		meta.ContentType = ""
+		meta.UserMetadata = nil
+	}
+
+	if u.ContentType != nil {
+		meta.ContentType = *u.ContentType
+	}
+
+	for k, v := range u.UserMetadata {
+		if v == nil {
+			delete(meta.UserMetadata, k)
+			continue
+		}
+
+		if meta.UserMetadata == nil {
+			meta.UserMetadata = make(stgmod.UserMetadata)
+		}
+		meta.UserMetadata[k] = *v
+	}
 }
 
 type UpdateObjectInfosResp struct {
@@ -149,10 +264,11 @@ type UpdateObjectInfosResp struct {
 	Successes []cdssdk.ObjectID `json:"successes"`
 }
 
-func ReqUpdateObjectInfos(userID cdssdk.UserID, updatings []cdssdk.UpdatingObject) *UpdateObjectInfos {
+func ReqUpdateObjectInfos(userID cdssdk.UserID, updatings []cdssdk.UpdatingObject, metadatas []UpdatingObjectMetadata) *UpdateObjectInfos {
 	return &UpdateObjectInfos{
 		UserID:    userID,
 		Updatings: updatings,
+		Metadatas: metadatas,
 	}
 }
 func RespUpdateObjectInfos(successes []cdssdk.ObjectID) *UpdateObjectInfosResp {
@@ -218,3 +334,67 @@ func RespDeleteObjects() *DeleteObjectsResp {
 func (client *Client) DeleteObjects(msg *DeleteObjects) (*DeleteObjectsResp, error) {
 	return mq.Request(Service.DeleteObjects, client.rabbitCli, msg)
 }
+
+// 查询用户可用的节点上已经存在的文件，用于上传时去重。只返回能找到的文件，以及可以直接复用的冗余策略和编码块。
+// 只会匹配用户自己可以访问的对象，只知道文件哈希不能获得其他用户的数据
+var _ = Register(Service.FindExistingFiles)
+
+type FindExistingFiles struct {
+	mq.MessageBodyBase
+	UserID     cdssdk.UserID     `json:"userID"`
+	FileHashes []string          `json:"fileHashes"`
+	Redundancy cdssdk.Redundancy `json:"redundancy"` // 上传时要使用的冗余策略，只复用冗余策略相同的编码块
+}
+type FindExistingFilesResp struct {
+	mq.MessageBodyBase
+	Files []ExistingFile `json:"files"`
+}
+type ExistingFile struct {
+	FileHash   string               `json:"fileHash"`
+	Redundancy cdssdk.Redundancy    `json:"redundancy"`
+	Blocks     []stgmod.ObjectBlock `json:"blocks"`
+}
+
+func ReqFindExistingFiles(userID cdssdk.UserID, fileHashes []string, redundancy cdssdk.Redundancy) *FindExistingFiles {
+	return &FindExistingFiles{
+		UserID:     userID,
+		FileHashes: fileHashes,
+		Redundancy: redundancy,
+	}
+}
+func RespFindExistingFiles(files []ExistingFile) *FindExistingFilesResp {
+	return &FindExistingFilesResp{
+		Files: files,
+	}
+}
+func (client *Client) FindExistingFiles(msg *FindExistingFiles) (*FindExistingFilesResp, error) {
+	return mq.Request(Service.FindExistingFiles, client.rabbitCli, msg)
+}
+
+// 查询对象上传时计算出的校验和，没有记录的对象不会返回
+var _ = Register(Service.GetObjectChecksums)
+
+type GetObjectChecksums struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID     `json:"userID"`
+	ObjectIDs []cdssdk.ObjectID `json:"objectIDs"`
+}
+type GetObjectChecksumsResp struct {
+	mq.MessageBodyBase
+	Checksums []model.ObjectChecksum `json:"checksums"`
+}
+
+func ReqGetObjectChecksums(userID cdssdk.UserID, objectIDs []cdssdk.ObjectID) *GetObjectChecksums {
+	return &GetObjectChecksums{
+		UserID:    userID,
+		ObjectIDs: objectIDs,
+	}
+}
+func RespGetObjectChecksums(checksums []model.ObjectChecksum) *GetObjectChecksumsResp {
+	return &GetObjectChecksumsResp{
+		Checksums: checksums,
+	}
+}
+func (client *Client) GetObjectChecksums(msg *GetObjectChecksums) (*GetObjectChecksumsResp, error) {
+	return mq.Request(Service.GetObjectChecksums, client.rabbitCli, msg)
+}
@@ -0,0 +1,137 @@
+package coordinator
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type ObjectVersionService interface {
+	SetPackageVersioning(msg *SetPackageVersioning) (*SetPackageVersioningResp, *mq.CodeMessage)
+
+	GetObjectVersions(msg *GetObjectVersions) (*GetObjectVersionsResp, *mq.CodeMessage)
+
+	GetObjectVersionDetails(msg *GetObjectVersionDetails) (*GetObjectVersionDetailsResp, *mq.CodeMessage)
+
+	RestoreObjectVersion(msg *RestoreObjectVersion) (*RestoreObjectVersionResp, *mq.CodeMessage)
+}
+
+// 开启或关闭Package的多版本功能
+var _ = Register(Service.SetPackageVersioning)
+
+type SetPackageVersioning struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
+	PackageID cdssdk.PackageID `json:"packageID"`
+	Enabled   bool             `json:"enabled"`
+}
+type SetPackageVersioningResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqSetPackageVersioning(userID cdssdk.UserID, packageID cdssdk.PackageID, enabled bool) *SetPackageVersioning {
+	return &SetPackageVersioning{
+		UserID:    userID,
+		PackageID: packageID,
+		Enabled:   enabled,
+	}
+}
+func RespSetPackageVersioning() *SetPackageVersioningResp {
+	return &SetPackageVersioningResp{}
+}
+func (client *Client) SetPackageVersioning(msg *SetPackageVersioning) (*SetPackageVersioningResp, error) {
+	return mq.Request(Service.SetPackageVersioning, client.rabbitCli, msg)
+}
+
+// 查询指定路径的对象的所有历史版本，结果按照版本从新到旧排序，不包含当前版本
+var _ = Register(Service.GetObjectVersions)
+
+type GetObjectVersions struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
+	PackageID cdssdk.PackageID `json:"packageID"`
+	Path      string           `json:"path"`
+}
+type GetObjectVersionsResp struct {
+	mq.MessageBodyBase
+	Versions []model.ObjectVersion `json:"versions"`
+}
+
+func ReqGetObjectVersions(userID cdssdk.UserID, packageID cdssdk.PackageID, path string) *GetObjectVersions {
+	return &GetObjectVersions{
+		UserID:    userID,
+		PackageID: packageID,
+		Path:      path,
+	}
+}
+func RespGetObjectVersions(versions []model.ObjectVersion) *GetObjectVersionsResp {
+	return &GetObjectVersionsResp{
+		Versions: versions,
+	}
+}
+func (client *Client) GetObjectVersions(msg *GetObjectVersions) (*GetObjectVersionsResp, error) {
+	return mq.Request(Service.GetObjectVersions, client.rabbitCli, msg)
+}
+
+// 获取多个历史版本以及它们的分块详细信息，返回的Objects与VersionIDs一一对应
+var _ = Register(Service.GetObjectVersionDetails)
+
+type GetObjectVersionDetails struct {
+	mq.MessageBodyBase
+	UserID     cdssdk.UserID           `json:"userID"` // 不为0时，用户无权访问的版本也会返回nil
+	VersionIDs []model.ObjectVersionID `json:"versionIDs"`
+}
+type GetObjectVersionDetailsResp struct {
+	mq.MessageBodyBase
+	Objects []*stgmod.ObjectDetail `json:"objects"` // 如果没有查询到某个ID对应的信息，则此数组对应位置为nil
+}
+
+func ReqGetObjectVersionDetails(versionIDs []model.ObjectVersionID) *GetObjectVersionDetails {
+	return &GetObjectVersionDetails{
+		VersionIDs: versionIDs,
+	}
+}
+func ReqGetUserObjectVersionDetails(userID cdssdk.UserID, versionIDs []model.ObjectVersionID) *GetObjectVersionDetails {
+	return &GetObjectVersionDetails{
+		UserID:     userID,
+		VersionIDs: versionIDs,
+	}
+}
+func RespGetObjectVersionDetails(objects []*stgmod.ObjectDetail) *GetObjectVersionDetailsResp {
+	return &GetObjectVersionDetailsResp{
+		Objects: objects,
+	}
+}
+func (client *Client) GetObjectVersionDetails(msg *GetObjectVersionDetails) (*GetObjectVersionDetailsResp, error) {
+	return mq.Request(Service.GetObjectVersionDetails, client.rabbitCli, msg)
+}
+
+// 将历史版本恢复为对象的当前版本
+var _ = Register(Service.RestoreObjectVersion)
+
+type RestoreObjectVersion struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID         `json:"userID"`
+	VersionID model.ObjectVersionID `json:"versionID"`
+}
+type RestoreObjectVersionResp struct {
+	mq.MessageBodyBase
+	Object cdssdk.Object `json:"object"`
+}
+
+func ReqRestoreObjectVersion(userID cdssdk.UserID, versionID model.ObjectVersionID) *RestoreObjectVersion {
+	return &RestoreObjectVersion{
+		UserID:    userID,
+		VersionID: versionID,
+	}
+}
+func RespRestoreObjectVersion(object cdssdk.Object) *RestoreObjectVersionResp {
+	return &RestoreObjectVersionResp{
+		Object: object,
+	}
+}
+func (client *Client) RestoreObjectVersion(msg *RestoreObjectVersion) (*RestoreObjectVersionResp, error) {
+	return mq.Request(Service.RestoreObjectVersion, client.rabbitCli, msg)
+}
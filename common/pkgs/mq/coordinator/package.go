@@ -6,6 +6,7 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 )
 
@@ -20,6 +21,8 @@ type PackageService interface {
 
 	DeletePackage(msg *DeletePackage) (*DeletePackageResp, *mq.CodeMessage)
 
+	ClonePackage(msg *ClonePackage) (*ClonePackageResp, *mq.CodeMessage)
+
 	GetPackageCachedNodes(msg *GetPackageCachedNodes) (*GetPackageCachedNodesResp, *mq.CodeMessage)
 
 	GetPackageLoadedNodes(msg *GetPackageLoadedNodes) (*GetPackageLoadedNodesResp, *mq.CodeMessage)
@@ -132,6 +135,15 @@ type AddObjectEntry struct {
 	FileHash   string        `json:"fileHash"`
 	UploadTime time.Time     `json:"uploadTime"` // 开始上传文件的时间
 	NodeID     cdssdk.NodeID `json:"nodeID"`
+	// 上传时就已经按冗余策略编码好的对象需要填写以下两个字段，此时NodeID字段无效。Blocks中的ObjectID不需要填写
+	Redundancy cdssdk.Redundancy    `json:"redundancy"`
+	Blocks     []stgmod.ObjectBlock `json:"blocks"`
+	// 上传时计算出的完整文件的校验和，可以为空
+	SHA256 string `json:"sha256"`
+	MD5    string `json:"md5"`
+	// 用户设置的对象元数据，可以为空
+	ContentType  string            `json:"contentType"`
+	UserMetadata map[string]string `json:"userMetadata"`
 }
 
 func NewUpdatePackage(packageID cdssdk.PackageID, adds []AddObjectEntry, deletes []cdssdk.ObjectID) *UpdatePackage {
@@ -155,6 +167,16 @@ func NewAddObjectEntry(path string, size int64, fileHash string, uploadTime time
 		NodeID:     nodeID,
 	}
 }
+func NewAddEncodedObjectEntry(path string, size int64, fileHash string, uploadTime time.Time, red cdssdk.Redundancy, blocks []stgmod.ObjectBlock) AddObjectEntry {
+	return AddObjectEntry{
+		Path:       path,
+		Size:       size,
+		FileHash:   fileHash,
+		UploadTime: uploadTime,
+		Redundancy: red,
+		Blocks:     blocks,
+	}
+}
 func (client *Client) UpdatePackage(msg *UpdatePackage) (*UpdatePackageResp, error) {
 	return mq.Request(Service.UpdatePackage, client.rabbitCli, msg)
 }
@@ -184,6 +206,48 @@ func (client *Client) DeletePackage(msg *DeletePackage) (*DeletePackageResp, err
 	return mq.Request(Service.DeletePackage, client.rabbitCli, msg)
 }
 
+// 复制Package的所有对象的元数据到一个新的Package中，新旧Package共用同样的文件
+var _ = Register(Service.ClonePackage)
+
+type ClonePackage struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
+	PackageID cdssdk.PackageID `json:"packageID"`
+	BucketID  cdssdk.BucketID  `json:"bucketID"` // 新Package所在的Bucket
+	Name      string           `json:"name"`
+	Snapshot  bool             `json:"snapshot"` // 是否将新Package作为只读的快照
+}
+type ClonePackageResp struct {
+	mq.MessageBodyBase
+	Package cdssdk.Package `json:"package"`
+}
+
+func ReqClonePackage(userID cdssdk.UserID, packageID cdssdk.PackageID, bucketID cdssdk.BucketID, name string) *ClonePackage {
+	return &ClonePackage{
+		UserID:    userID,
+		PackageID: packageID,
+		BucketID:  bucketID,
+		Name:      name,
+	}
+}
+func ReqSnapshotPackage(userID cdssdk.UserID, packageID cdssdk.PackageID, bucketID cdssdk.BucketID, name string) *ClonePackage {
+	return &ClonePackage{
+		UserID:    userID,
+		PackageID: packageID,
+		BucketID:  bucketID,
+		Name:      name,
+		Snapshot:  true,
+	}
+}
+func RespClonePackage(pkg cdssdk.Package) *ClonePackageResp {
+	return &ClonePackageResp{
+		Package: pkg,
+	}
+}
+func (client *Client) ClonePackage(msg *ClonePackage) (*ClonePackageResp, error) {
+	return mq.Request(Service.ClonePackage, client.rabbitCli, msg)
+}
+
 // 根据PackageID获取object分布情况
 var _ = Register(Service.GetPackageCachedNodes)
 
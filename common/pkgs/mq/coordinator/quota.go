@@ -0,0 +1,125 @@
+package coordinator
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type QuotaService interface {
+	GetUserQuota(msg *GetUserQuota) (*GetUserQuotaResp, *mq.CodeMessage)
+
+	GetBucketQuota(msg *GetBucketQuota) (*GetBucketQuotaResp, *mq.CodeMessage)
+
+	SetUserQuota(msg *SetUserQuota) (*SetUserQuotaResp, *mq.CodeMessage)
+
+	SetBucketQuota(msg *SetBucketQuota) (*SetBucketQuotaResp, *mq.CodeMessage)
+}
+
+// 查询用户的配额以及当前用量
+var _ = Register(Service.GetUserQuota)
+
+type GetUserQuota struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID `json:"userID"`
+}
+type GetUserQuotaResp struct {
+	mq.MessageBodyBase
+	Quota model.Quota        `json:"quota"`
+	Usage model.StorageUsage `json:"usage"`
+}
+
+func ReqGetUserQuota(userID cdssdk.UserID) *GetUserQuota {
+	return &GetUserQuota{
+		UserID: userID,
+	}
+}
+func RespGetUserQuota(quota model.Quota, usage model.StorageUsage) *GetUserQuotaResp {
+	return &GetUserQuotaResp{
+		Quota: quota,
+		Usage: usage,
+	}
+}
+func (client *Client) GetUserQuota(msg *GetUserQuota) (*GetUserQuotaResp, error) {
+	return mq.Request(Service.GetUserQuota, client.rabbitCli, msg)
+}
+
+// 查询桶的配额以及当前用量
+var _ = Register(Service.GetBucketQuota)
+
+type GetBucketQuota struct {
+	mq.MessageBodyBase
+	UserID   cdssdk.UserID   `json:"userID"`
+	BucketID cdssdk.BucketID `json:"bucketID"`
+}
+type GetBucketQuotaResp struct {
+	mq.MessageBodyBase
+	Quota model.Quota        `json:"quota"`
+	Usage model.StorageUsage `json:"usage"`
+}
+
+func ReqGetBucketQuota(userID cdssdk.UserID, bucketID cdssdk.BucketID) *GetBucketQuota {
+	return &GetBucketQuota{
+		UserID:   userID,
+		BucketID: bucketID,
+	}
+}
+func RespGetBucketQuota(quota model.Quota, usage model.StorageUsage) *GetBucketQuotaResp {
+	return &GetBucketQuotaResp{
+		Quota: quota,
+		Usage: usage,
+	}
+}
+func (client *Client) GetBucketQuota(msg *GetBucketQuota) (*GetBucketQuotaResp, error) {
+	return mq.Request(Service.GetBucketQuota, client.rabbitCli, msg)
+}
+
+// 设置用户的配额，只应该由管理员调用
+var _ = Register(Service.SetUserQuota)
+
+type SetUserQuota struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID `json:"userID"`
+	Quota  model.Quota   `json:"quota"`
+}
+type SetUserQuotaResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqSetUserQuota(userID cdssdk.UserID, quota model.Quota) *SetUserQuota {
+	return &SetUserQuota{
+		UserID: userID,
+		Quota:  quota,
+	}
+}
+func RespSetUserQuota() *SetUserQuotaResp {
+	return &SetUserQuotaResp{}
+}
+func (client *Client) SetUserQuota(msg *SetUserQuota) (*SetUserQuotaResp, error) {
+	return mq.Request(Service.SetUserQuota, client.rabbitCli, msg)
+}
+
+// 设置桶的配额，只应该由管理员调用
+var _ = Register(Service.SetBucketQuota)
+
+type SetBucketQuota struct {
+	mq.MessageBodyBase
+	BucketID cdssdk.BucketID `json:"bucketID"`
+	Quota    model.Quota     `json:"quota"`
+}
+type SetBucketQuotaResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqSetBucketQuota(bucketID cdssdk.BucketID, quota model.Quota) *SetBucketQuota {
+	return &SetBucketQuota{
+		BucketID: bucketID,
+		Quota:    quota,
+	}
+}
+func RespSetBucketQuota() *SetBucketQuotaResp {
+	return &SetBucketQuotaResp{}
+}
+func (client *Client) SetBucketQuota(msg *SetBucketQuota) (*SetBucketQuotaResp, error) {
+	return mq.Request(Service.SetBucketQuota, client.rabbitCli, msg)
+}
@@ -13,13 +13,23 @@ type Service interface {
 
 	CacheService
 
+	LifecycleService
+
 	NodeService
 
 	ObjectService
 
+	ObjectVersionService
+
 	PackageService
 
+	QuotaService
+
 	StorageService
+
+	UploadSessionService
+
+	UserService
 }
 
 type Server struct {
@@ -12,6 +12,8 @@ import (
 type StorageService interface {
 	GetStorage(msg *GetStorage) (*GetStorageResp, *mq.CodeMessage)
 
+	GetStorageInfo(msg *GetStorageInfo) (*GetStorageInfoResp, *mq.CodeMessage)
+
 	GetStorageByName(msg *GetStorageByName) (*GetStorageByNameResp, *mq.CodeMessage)
 
 	StoragePackageLoaded(msg *StoragePackageLoaded) (*StoragePackageLoadedResp, *mq.CodeMessage)
@@ -47,6 +49,32 @@ func (client *Client) GetStorage(msg *GetStorage) (*GetStorageResp, error) {
 	return mq.Request(Service.GetStorage, client.rabbitCli, msg)
 }
 
+// 获取Storage信息，不检查用户权限，只供服务内部使用
+var _ = Register(Service.GetStorageInfo)
+
+type GetStorageInfo struct {
+	mq.MessageBodyBase
+	StorageID cdssdk.StorageID `json:"storageID"`
+}
+type GetStorageInfoResp struct {
+	mq.MessageBodyBase
+	Storage model.Storage `json:"storage"`
+}
+
+func ReqGetStorageInfo(storageID cdssdk.StorageID) *GetStorageInfo {
+	return &GetStorageInfo{
+		StorageID: storageID,
+	}
+}
+func RespGetStorageInfo(stg model.Storage) *GetStorageInfoResp {
+	return &GetStorageInfoResp{
+		Storage: stg,
+	}
+}
+func (client *Client) GetStorageInfo(msg *GetStorageInfo) (*GetStorageInfoResp, error) {
+	return mq.Request(Service.GetStorageInfo, client.rabbitCli, msg)
+}
+
 var _ = Register(Service.GetStorageByName)
 
 type GetStorageByName struct {
@@ -0,0 +1,162 @@
+package coordinator
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type UploadSessionService interface {
+	CreateUploadSession(msg *CreateUploadSession) (*CreateUploadSessionResp, *mq.CodeMessage)
+
+	GetUploadSession(msg *GetUploadSession) (*GetUploadSessionResp, *mq.CodeMessage)
+
+	AddUploadSessionPart(msg *AddUploadSessionPart) (*AddUploadSessionPartResp, *mq.CodeMessage)
+
+	CompleteUploadSession(msg *CompleteUploadSession) (*CompleteUploadSessionResp, *mq.CodeMessage)
+
+	DeleteUploadSession(msg *DeleteUploadSession) (*DeleteUploadSessionResp, *mq.CodeMessage)
+}
+
+// 创建一个分段上传会话
+var _ = Register(Service.CreateUploadSession)
+
+type CreateUploadSession struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID    `json:"userID"`
+	PackageID cdssdk.PackageID `json:"packageID"`
+	Path      string           `json:"path"`
+	NodeID    cdssdk.NodeID    `json:"nodeID"`
+}
+type CreateUploadSessionResp struct {
+	mq.MessageBodyBase
+	Session model.UploadSession `json:"session"`
+}
+
+func ReqCreateUploadSession(userID cdssdk.UserID, packageID cdssdk.PackageID, path string, nodeID cdssdk.NodeID) *CreateUploadSession {
+	return &CreateUploadSession{
+		UserID:    userID,
+		PackageID: packageID,
+		Path:      path,
+		NodeID:    nodeID,
+	}
+}
+func RespCreateUploadSession(session model.UploadSession) *CreateUploadSessionResp {
+	return &CreateUploadSessionResp{
+		Session: session,
+	}
+}
+func (client *Client) CreateUploadSession(msg *CreateUploadSession) (*CreateUploadSessionResp, error) {
+	return mq.Request(Service.CreateUploadSession, client.rabbitCli, msg)
+}
+
+// 获取会话信息以及已经上传的分段
+var _ = Register(Service.GetUploadSession)
+
+type GetUploadSession struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID         `json:"userID"`
+	SessionID model.UploadSessionID `json:"sessionID"`
+}
+type GetUploadSessionResp struct {
+	mq.MessageBodyBase
+	Session model.UploadSession       `json:"session"`
+	Parts   []model.UploadSessionPart `json:"parts"`
+}
+
+func ReqGetUploadSession(userID cdssdk.UserID, sessionID model.UploadSessionID) *GetUploadSession {
+	return &GetUploadSession{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+}
+func RespGetUploadSession(session model.UploadSession, parts []model.UploadSessionPart) *GetUploadSessionResp {
+	return &GetUploadSessionResp{
+		Session: session,
+		Parts:   parts,
+	}
+}
+func (client *Client) GetUploadSession(msg *GetUploadSession) (*GetUploadSessionResp, error) {
+	return mq.Request(Service.GetUploadSession, client.rabbitCli, msg)
+}
+
+// 记录一个已经上传到暂存节点的分段
+var _ = Register(Service.AddUploadSessionPart)
+
+type AddUploadSessionPart struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID           `json:"userID"`
+	Part   model.UploadSessionPart `json:"part"`
+}
+type AddUploadSessionPartResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqAddUploadSessionPart(userID cdssdk.UserID, part model.UploadSessionPart) *AddUploadSessionPart {
+	return &AddUploadSessionPart{
+		UserID: userID,
+		Part:   part,
+	}
+}
+func RespAddUploadSessionPart() *AddUploadSessionPartResp {
+	return &AddUploadSessionPartResp{}
+}
+func (client *Client) AddUploadSessionPart(msg *AddUploadSessionPart) (*AddUploadSessionPartResp, error) {
+	return mq.Request(Service.AddUploadSessionPart, client.rabbitCli, msg)
+}
+
+// 完成上传，在同一个事务中添加对象并删除会话
+var _ = Register(Service.CompleteUploadSession)
+
+type CompleteUploadSession struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID         `json:"userID"`
+	SessionID model.UploadSessionID `json:"sessionID"`
+	Object    AddObjectEntry        `json:"object"`
+}
+type CompleteUploadSessionResp struct {
+	mq.MessageBodyBase
+	Object cdssdk.Object `json:"object"`
+}
+
+func ReqCompleteUploadSession(userID cdssdk.UserID, sessionID model.UploadSessionID, obj AddObjectEntry) *CompleteUploadSession {
+	return &CompleteUploadSession{
+		UserID:    userID,
+		SessionID: sessionID,
+		Object:    obj,
+	}
+}
+func RespCompleteUploadSession(obj cdssdk.Object) *CompleteUploadSessionResp {
+	return &CompleteUploadSessionResp{
+		Object: obj,
+	}
+}
+func (client *Client) CompleteUploadSession(msg *CompleteUploadSession) (*CompleteUploadSessionResp, error) {
+	return mq.Request(Service.CompleteUploadSession, client.rabbitCli, msg)
+}
+
+// 删除会话，已经上传的分段之后会被AgentCacheGC清理
+var _ = Register(Service.DeleteUploadSession)
+
+type DeleteUploadSession struct {
+	mq.MessageBodyBase
+	UserID    cdssdk.UserID         `json:"userID"`
+	SessionID model.UploadSessionID `json:"sessionID"`
+}
+type DeleteUploadSessionResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqDeleteUploadSession(userID cdssdk.UserID, sessionID model.UploadSessionID) *DeleteUploadSession {
+	return &DeleteUploadSession{
+		UserID:    userID,
+		SessionID: sessionID,
+	}
+}
+func RespDeleteUploadSession() *DeleteUploadSessionResp {
+	return &DeleteUploadSessionResp{}
+}
+func (client *Client) DeleteUploadSession(msg *DeleteUploadSession) (*DeleteUploadSessionResp, error) {
+	return mq.Request(Service.DeleteUploadSession, client.rabbitCli, msg)
+}
@@ -0,0 +1,156 @@
+package coordinator
+
+import (
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+)
+
+type UserService interface {
+	AuthUser(msg *AuthUser) (*AuthUserResp, *mq.CodeMessage)
+
+	IssueUserToken(msg *IssueUserToken) (*IssueUserTokenResp, *mq.CodeMessage)
+
+	VerifyUserToken(msg *VerifyUserToken) (*VerifyUserTokenResp, *mq.CodeMessage)
+
+	RevokeUserToken(msg *RevokeUserToken) (*RevokeUserTokenResp, *mq.CodeMessage)
+
+	ChangeUserPassword(msg *ChangeUserPassword) (*ChangeUserPasswordResp, *mq.CodeMessage)
+}
+
+// 校验用户的ID与密码
+var _ = Register(Service.AuthUser)
+
+type AuthUser struct {
+	mq.MessageBodyBase
+	UserID   cdssdk.UserID `json:"userID"`
+	Password string        `json:"password"`
+}
+type AuthUserResp struct {
+	mq.MessageBodyBase
+	Admin bool `json:"admin"`
+}
+
+func ReqAuthUser(userID cdssdk.UserID, password string) *AuthUser {
+	return &AuthUser{
+		UserID:   userID,
+		Password: password,
+	}
+}
+func RespAuthUser(admin bool) *AuthUserResp {
+	return &AuthUserResp{
+		Admin: admin,
+	}
+}
+func (client *Client) AuthUser(msg *AuthUser) (*AuthUserResp, error) {
+	return mq.Request(Service.AuthUser, client.rabbitCli, msg)
+}
+
+// 校验用户的ID与密码，通过后签发一个登录凭证，之后可以使用凭证代替密码
+var _ = Register(Service.IssueUserToken)
+
+type IssueUserToken struct {
+	mq.MessageBodyBase
+	UserID        cdssdk.UserID `json:"userID"`
+	Password      string        `json:"password"`
+	ExpireSeconds int           `json:"expireSeconds"`
+}
+type IssueUserTokenResp struct {
+	mq.MessageBodyBase
+	Token      string    `json:"token"`
+	ExpireTime time.Time `json:"expireTime"`
+}
+
+func ReqIssueUserToken(userID cdssdk.UserID, password string, expireSeconds int) *IssueUserToken {
+	return &IssueUserToken{
+		UserID:        userID,
+		Password:      password,
+		ExpireSeconds: expireSeconds,
+	}
+}
+func RespIssueUserToken(token string, expireTime time.Time) *IssueUserTokenResp {
+	return &IssueUserTokenResp{
+		Token:      token,
+		ExpireTime: expireTime,
+	}
+}
+func (client *Client) IssueUserToken(msg *IssueUserToken) (*IssueUserTokenResp, error) {
+	return mq.Request(Service.IssueUserToken, client.rabbitCli, msg)
+}
+
+// 检查登录凭证是否有效，返回凭证所属的用户
+var _ = Register(Service.VerifyUserToken)
+
+type VerifyUserToken struct {
+	mq.MessageBodyBase
+	Token string `json:"token"`
+}
+type VerifyUserTokenResp struct {
+	mq.MessageBodyBase
+	UserID cdssdk.UserID `json:"userID"`
+}
+
+func ReqVerifyUserToken(token string) *VerifyUserToken {
+	return &VerifyUserToken{
+		Token: token,
+	}
+}
+func RespVerifyUserToken(userID cdssdk.UserID) *VerifyUserTokenResp {
+	return &VerifyUserTokenResp{
+		UserID: userID,
+	}
+}
+func (client *Client) VerifyUserToken(msg *VerifyUserToken) (*VerifyUserTokenResp, error) {
+	return mq.Request(Service.VerifyUserToken, client.rabbitCli, msg)
+}
+
+// 使登录凭证失效
+var _ = Register(Service.RevokeUserToken)
+
+type RevokeUserToken struct {
+	mq.MessageBodyBase
+	Token string `json:"token"`
+}
+type RevokeUserTokenResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqRevokeUserToken(token string) *RevokeUserToken {
+	return &RevokeUserToken{
+		Token: token,
+	}
+}
+func RespRevokeUserToken() *RevokeUserTokenResp {
+	return &RevokeUserTokenResp{}
+}
+func (client *Client) RevokeUserToken(msg *RevokeUserToken) (*RevokeUserTokenResp, error) {
+	return mq.Request(Service.RevokeUserToken, client.rabbitCli, msg)
+}
+
+// 修改用户密码，修改后用户已有的登录凭证都会失效
+var _ = Register(Service.ChangeUserPassword)
+
+type ChangeUserPassword struct {
+	mq.MessageBodyBase
+	UserID      cdssdk.UserID `json:"userID"`
+	OldPassword string        `json:"oldPassword"`
+	NewPassword string        `json:"newPassword"`
+}
+type ChangeUserPasswordResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqChangeUserPassword(userID cdssdk.UserID, oldPassword string, newPassword string) *ChangeUserPassword {
+	return &ChangeUserPassword{
+		UserID:      userID,
+		OldPassword: oldPassword,
+		NewPassword: newPassword,
+	}
+}
+func RespChangeUserPassword() *ChangeUserPasswordResp {
+	return &ChangeUserPasswordResp{}
+}
+func (client *Client) ChangeUserPassword(msg *ChangeUserPassword) (*ChangeUserPasswordResp, error) {
+	return mq.Request(Service.ChangeUserPassword, client.rabbitCli, msg)
+}
@@ -0,0 +1,18 @@
+package event
+
+import cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+type CheckBucketLifecycle struct {
+	EventBase
+	BucketID cdssdk.BucketID `json:"bucketID"`
+}
+
+func NewCheckBucketLifecycle(bucketID cdssdk.BucketID) *CheckBucketLifecycle {
+	return &CheckBucketLifecycle{
+		BucketID: bucketID,
+	}
+}
+
+func init() {
+	Register[*CheckBucketLifecycle]()
+}
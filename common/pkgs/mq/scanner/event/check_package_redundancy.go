@@ -5,6 +5,8 @@ import cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 type CheckPackageRedundancy struct {
 	EventBase
 	PackageID cdssdk.PackageID `json:"packageIDs"`
+	// 是否由定期检查发起。配置了需要批准时，定期检查只生成等待批准的计划，不直接修改
+	Routine bool `json:"routine"`
 }
 
 func NewCheckPackageRedundancy(packageID cdssdk.PackageID) *CheckPackageRedundancy {
@@ -13,6 +15,13 @@ func NewCheckPackageRedundancy(packageID cdssdk.PackageID) *CheckPackageRedundan
 	}
 }
 
+func NewRoutineCheckPackageRedundancy(packageID cdssdk.PackageID) *CheckPackageRedundancy {
+	return &CheckPackageRedundancy{
+		PackageID: packageID,
+		Routine:   true,
+	}
+}
+
 func init() {
 	Register[*CheckPackageRedundancy]()
 }
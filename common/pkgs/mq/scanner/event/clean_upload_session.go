@@ -0,0 +1,13 @@
+package event
+
+type CleanUploadSession struct {
+	EventBase
+}
+
+func NewCleanUploadSession() *CleanUploadSession {
+	return &CleanUploadSession{}
+}
+
+func init() {
+	Register[*CleanUploadSession]()
+}
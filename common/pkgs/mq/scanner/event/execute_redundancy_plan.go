@@ -0,0 +1,24 @@
+package event
+
+import (
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+// 执行已经批准的冗余策略修改计划
+type ExecuteRedundancyPlan struct {
+	EventBase
+	PlanID    model.RedundancyPlanID `json:"planID"`
+	PackageID cdssdk.PackageID       `json:"packageID"`
+}
+
+func NewExecuteRedundancyPlan(planID model.RedundancyPlanID, packageID cdssdk.PackageID) *ExecuteRedundancyPlan {
+	return &ExecuteRedundancyPlan{
+		PlanID:    planID,
+		PackageID: packageID,
+	}
+}
+
+func init() {
+	Register[*ExecuteRedundancyPlan]()
+}
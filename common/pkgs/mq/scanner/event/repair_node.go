@@ -0,0 +1,18 @@
+package event
+
+import cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+type RepairNode struct {
+	EventBase
+	NodeID cdssdk.NodeID `json:"nodeID"`
+}
+
+func NewRepairNode(nodeID cdssdk.NodeID) *RepairNode {
+	return &RepairNode{
+		NodeID: nodeID,
+	}
+}
+
+func init() {
+	Register[*RepairNode]()
+}
@@ -0,0 +1,18 @@
+package event
+
+import cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+
+type ScrubPackage struct {
+	EventBase
+	PackageID cdssdk.PackageID `json:"packageID"`
+}
+
+func NewScrubPackage(packageID cdssdk.PackageID) *ScrubPackage {
+	return &ScrubPackage{
+		PackageID: packageID,
+	}
+}
+
+func init() {
+	Register[*ScrubPackage]()
+}
@@ -0,0 +1,92 @@
+package scanner
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type JobService interface {
+	ListJobs(msg *ListJobs) (*ListJobsResp, *mq.CodeMessage)
+
+	RetryJob(msg *RetryJob) (*RetryJobResp, *mq.CodeMessage)
+
+	CancelJob(msg *CancelJob) (*CancelJobResp, *mq.CodeMessage)
+}
+
+// 查询Scanner中的任务，State和Type为空时不作为过滤条件
+var _ = Register(Service.ListJobs)
+
+type ListJobs struct {
+	mq.MessageBodyBase
+	State  string `json:"state"`
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+}
+type ListJobsResp struct {
+	mq.MessageBodyBase
+	Jobs []model.ScannerJob `json:"jobs"`
+}
+
+func ReqListJobs(state string, typ string, offset int, limit int) *ListJobs {
+	return &ListJobs{
+		State:  state,
+		Type:   typ,
+		Offset: offset,
+		Limit:  limit,
+	}
+}
+func RespListJobs(jobs []model.ScannerJob) *ListJobsResp {
+	return &ListJobsResp{
+		Jobs: jobs,
+	}
+}
+func (client *Client) ListJobs(msg *ListJobs) (*ListJobsResp, error) {
+	return mq.Request(Service.ListJobs, client.rabbitCli, msg)
+}
+
+// 重新执行失败或者被取消的任务
+var _ = Register(Service.RetryJob)
+
+type RetryJob struct {
+	mq.MessageBodyBase
+	JobID model.ScannerJobID `json:"jobID"`
+}
+type RetryJobResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqRetryJob(jobID model.ScannerJobID) *RetryJob {
+	return &RetryJob{
+		JobID: jobID,
+	}
+}
+func RespRetryJob() *RetryJobResp {
+	return &RetryJobResp{}
+}
+func (client *Client) RetryJob(msg *RetryJob) (*RetryJobResp, error) {
+	return mq.Request(Service.RetryJob, client.rabbitCli, msg)
+}
+
+// 取消等待中的任务
+var _ = Register(Service.CancelJob)
+
+type CancelJob struct {
+	mq.MessageBodyBase
+	JobID model.ScannerJobID `json:"jobID"`
+}
+type CancelJobResp struct {
+	mq.MessageBodyBase
+}
+
+func ReqCancelJob(jobID model.ScannerJobID) *CancelJob {
+	return &CancelJob{
+		JobID: jobID,
+	}
+}
+func RespCancelJob() *CancelJobResp {
+	return &CancelJobResp{}
+}
+func (client *Client) CancelJob(msg *CancelJob) (*CancelJobResp, error) {
+	return mq.Request(Service.CancelJob, client.rabbitCli, msg)
+}
@@ -0,0 +1,33 @@
+package scanner
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type NodeRepairService interface {
+	GetNodeRepairs(msg *GetNodeRepairs) (*GetNodeRepairsResp, *mq.CodeMessage)
+}
+
+// 查询不可用节点的数据修复进度
+var _ = Register(Service.GetNodeRepairs)
+
+type GetNodeRepairs struct {
+	mq.MessageBodyBase
+}
+type GetNodeRepairsResp struct {
+	mq.MessageBodyBase
+	Repairs []model.NodeRepair `json:"repairs"`
+}
+
+func ReqGetNodeRepairs() *GetNodeRepairs {
+	return &GetNodeRepairs{}
+}
+func RespGetNodeRepairs(repairs []model.NodeRepair) *GetNodeRepairsResp {
+	return &GetNodeRepairsResp{
+		Repairs: repairs,
+	}
+}
+func (client *Client) GetNodeRepairs(msg *GetNodeRepairs) (*GetNodeRepairsResp, error) {
+	return mq.Request(Service.GetNodeRepairs, client.rabbitCli, msg)
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+)
+
+type RedundancyPlanService interface {
+	PreviewPackageRedundancy(msg *PreviewPackageRedundancy) (*PreviewPackageRedundancyResp, *mq.CodeMessage)
+
+	GetRedundancyPlan(msg *GetRedundancyPlan) (*GetRedundancyPlanResp, *mq.CodeMessage)
+
+	ListRedundancyPlans(msg *ListRedundancyPlans) (*ListRedundancyPlansResp, *mq.CodeMessage)
+
+	ApproveRedundancyPlan(msg *ApproveRedundancyPlan) (*ApproveRedundancyPlanResp, *mq.CodeMessage)
+}
+
+// 计算Package的冗余策略修改计划，但不执行。Save为true时保存计划，之后可以批准执行
+var _ = Register(Service.PreviewPackageRedundancy)
+
+type PreviewPackageRedundancy struct {
+	mq.MessageBodyBase
+	PackageID cdssdk.PackageID `json:"packageID"`
+	Save      bool             `json:"save"`
+}
+type PreviewPackageRedundancyResp struct {
+	mq.MessageBodyBase
+	Plan model.RedundancyPlan `json:"plan"`
+}
+
+func ReqPreviewPackageRedundancy(packageID cdssdk.PackageID, save bool) *PreviewPackageRedundancy {
+	return &PreviewPackageRedundancy{
+		PackageID: packageID,
+		Save:      save,
+	}
+}
+func RespPreviewPackageRedundancy(plan model.RedundancyPlan) *PreviewPackageRedundancyResp {
+	return &PreviewPackageRedundancyResp{
+		Plan: plan,
+	}
+}
+func (client *Client) PreviewPackageRedundancy(msg *PreviewPackageRedundancy) (*PreviewPackageRedundancyResp, error) {
+	return mq.Request(Service.PreviewPackageRedundancy, client.rabbitCli, msg)
+}
+
+// 查询保存的计划
+var _ = Register(Service.GetRedundancyPlan)
+
+type GetRedundancyPlan struct {
+	mq.MessageBodyBase
+	PlanID model.RedundancyPlanID `json:"planID"`
+}
+type GetRedundancyPlanResp struct {
+	mq.MessageBodyBase
+	Plan model.RedundancyPlan `json:"plan"`
+}
+
+func ReqGetRedundancyPlan(planID model.RedundancyPlanID) *GetRedundancyPlan {
+	return &GetRedundancyPlan{
+		PlanID: planID,
+	}
+}
+func RespGetRedundancyPlan(plan model.RedundancyPlan) *GetRedundancyPlanResp {
+	return &GetRedundancyPlanResp{
+		Plan: plan,
+	}
+}
+func (client *Client) GetRedundancyPlan(msg *GetRedundancyPlan) (*GetRedundancyPlanResp, error) {
+	return mq.Request(Service.GetRedundancyPlan, client.rabbitCli, msg)
+}
+
+// 分页查询保存的计划，不包含每个对象的修改计划。PackageID为0、State为空时不作为过滤条件
+var _ = Register(Service.ListRedundancyPlans)
+
+type ListRedundancyPlans struct {
+	mq.MessageBodyBase
+	PackageID cdssdk.PackageID `json:"packageID"`
+	State     string           `json:"state"`
+	Offset    int              `json:"offset"`
+	Limit     int              `json:"limit"`
+}
+type ListRedundancyPlansResp struct {
+	mq.MessageBodyBase
+	Plans []model.RedundancyPlan `json:"plans"`
+}
+
+func ReqListRedundancyPlans(packageID cdssdk.PackageID, state string, offset int, limit int) *ListRedundancyPlans {
+	return &ListRedundancyPlans{
+		PackageID: packageID,
+		State:     state,
+		Offset:    offset,
+		Limit:     limit,
+	}
+}
+func RespListRedundancyPlans(plans []model.RedundancyPlan) *ListRedundancyPlansResp {
+	return &ListRedundancyPlansResp{
+		Plans: plans,
+	}
+}
+func (client *Client) ListRedundancyPlans(msg *ListRedundancyPlans) (*ListRedundancyPlansResp, error) {
+	return mq.Request(Service.ListRedundancyPlans, client.rabbitCli, msg)
+}
+
+// 批准等待中的计划，计划会作为任务执行
+var _ = Register(Service.ApproveRedundancyPlan)
+
+type ApproveRedundancyPlan struct {
+	mq.MessageBodyBase
+	PlanID model.RedundancyPlanID `json:"planID"`
+}
+type ApproveRedundancyPlanResp struct {
+	mq.MessageBodyBase
+	JobID model.ScannerJobID `json:"jobID"`
+}
+
+func ReqApproveRedundancyPlan(planID model.RedundancyPlanID) *ApproveRedundancyPlan {
+	return &ApproveRedundancyPlan{
+		PlanID: planID,
+	}
+}
+func RespApproveRedundancyPlan(jobID model.ScannerJobID) *ApproveRedundancyPlanResp {
+	return &ApproveRedundancyPlanResp{
+		JobID: jobID,
+	}
+}
+func (client *Client) ApproveRedundancyPlan(msg *ApproveRedundancyPlan) (*ApproveRedundancyPlanResp, error) {
+	return mq.Request(Service.ApproveRedundancyPlan, client.rabbitCli, msg)
+}
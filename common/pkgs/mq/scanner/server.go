@@ -8,6 +8,12 @@ import (
 // Service 协调端接口
 type Service interface {
 	EventService
+
+	NodeRepairService
+
+	JobService
+
+	RedundancyPlanService
 }
 type Server struct {
 	service   Service
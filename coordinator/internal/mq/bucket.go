@@ -31,7 +31,7 @@ func (svc *Service) GetBucketByName(msg *coormq.GetBucketByName) (*coormq.GetBuc
 }
 
 func (svc *Service) GetUserBuckets(msg *coormq.GetUserBuckets) (*coormq.GetUserBucketsResp, *mq.CodeMessage) {
-	buckets, err := svc.db.Bucket().GetUserBuckets(svc.db.SQLCtx(), msg.UserID)
+	buckets, err := svc.db.Bucket().GetUserBucketDetails(svc.db.SQLCtx(), msg.UserID)
 
 	if err != nil {
 		logger.WithField("UserID", msg.UserID).
@@ -13,14 +13,13 @@ import (
 
 func (svc *Service) CachePackageMoved(msg *coormq.CachePackageMoved) (*coormq.CachePackageMovedResp, *mq.CodeMessage) {
 	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
-		_, err := svc.db.Package().GetByID(tx, msg.PackageID)
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
 		if err != nil {
-			return fmt.Errorf("getting package by id: %w", err)
+			return fmt.Errorf("getting user package: %w", err)
 		}
 
-		_, err = svc.db.Node().GetByID(tx, msg.NodeID)
-		if err != nil {
-			return fmt.Errorf("getting node by id: %w", err)
+		if ok, _ := svc.db.Node().IsAvailable(tx, msg.UserID, msg.NodeID); !ok {
+			return fmt.Errorf("node is not available to user")
 		}
 
 		err = svc.db.PinnedObject().CreateFromPackage(tx, msg.PackageID, msg.NodeID)
@@ -31,7 +30,7 @@ func (svc *Service) CachePackageMoved(msg *coormq.CachePackageMoved) (*coormq.Ca
 		return nil
 	})
 	if err != nil {
-		logger.WithField("PackageID", msg.PackageID).WithField("NodeID", msg.NodeID).Warn(err.Error())
+		logger.WithField("UserID", msg.UserID).WithField("PackageID", msg.PackageID).WithField("NodeID", msg.NodeID).Warn(err.Error())
 		return nil, mq.Failed(errorcode.OperationFailed, "create package pinned objects failed")
 	}
 
@@ -40,14 +39,13 @@ func (svc *Service) CachePackageMoved(msg *coormq.CachePackageMoved) (*coormq.Ca
 
 func (svc *Service) CacheRemovePackage(msg *coormq.CacheRemovePackage) (*coormq.CacheRemovePackageResp, *mq.CodeMessage) {
 	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
-		_, err := svc.db.Package().GetByID(tx, msg.PackageID)
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
 		if err != nil {
-			return fmt.Errorf("getting package by id: %w", err)
+			return fmt.Errorf("getting user package: %w", err)
 		}
 
-		_, err = svc.db.Node().GetByID(tx, msg.NodeID)
-		if err != nil {
-			return fmt.Errorf("getting node by id: %w", err)
+		if ok, _ := svc.db.Node().IsAvailable(tx, msg.UserID, msg.NodeID); !ok {
+			return fmt.Errorf("node is not available to user")
 		}
 
 		err = svc.db.PinnedObject().DeleteInPackageAtNode(tx, msg.PackageID, msg.NodeID)
@@ -58,7 +56,7 @@ func (svc *Service) CacheRemovePackage(msg *coormq.CacheRemovePackage) (*coormq.
 		return nil
 	})
 	if err != nil {
-		logger.WithField("PackageID", msg.PackageID).WithField("NodeID", msg.NodeID).Warn(err.Error())
+		logger.WithField("UserID", msg.UserID).WithField("PackageID", msg.PackageID).WithField("NodeID", msg.NodeID).Warn(err.Error())
 		return nil, mq.Failed(errorcode.OperationFailed, "remove pinned package failed")
 	}
 
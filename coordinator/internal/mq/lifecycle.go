@@ -0,0 +1,130 @@
+package mq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+func (svc *Service) AddLifecycleRule(msg *coormq.AddLifecycleRule) (*coormq.AddLifecycleRuleResp, *mq.CodeMessage) {
+	rule := msg.Rule
+	rule.CreateTime = time.Now()
+
+	if err := checkLifecycleRule(rule); err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("BucketID", rule.BucketID).
+			Warnf("invalid lifecycle rule: %s", err.Error())
+		return nil, mq.Failed(errorcode.BadArgument, err.Error())
+	}
+
+	var ruleID model.LifecycleRuleID
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		isAvai, _ := svc.db.Bucket().IsAvailable(tx, rule.BucketID, msg.UserID)
+		if !isAvai {
+			return fmt.Errorf("bucket is not avaiable to the user")
+		}
+
+		var err error
+		ruleID, err = svc.db.Lifecycle().Create(tx, rule)
+		if err != nil {
+			return fmt.Errorf("creating lifecycle rule: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("BucketID", rule.BucketID).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "add lifecycle rule failed")
+	}
+
+	return mq.ReplyOK(coormq.RespAddLifecycleRule(ruleID))
+}
+
+func (svc *Service) GetBucketLifecycleRules(msg *coormq.GetBucketLifecycleRules) (*coormq.GetBucketLifecycleRulesResp, *mq.CodeMessage) {
+	var rules []model.LifecycleRule
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		isAvai, _ := svc.db.Bucket().IsAvailable(tx, msg.BucketID, msg.UserID)
+		if !isAvai {
+			return fmt.Errorf("bucket is not avaiable to the user")
+		}
+
+		var err error
+		rules, err = svc.db.Lifecycle().GetByBucketID(tx, msg.BucketID)
+		if err != nil {
+			return fmt.Errorf("getting lifecycle rules: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("BucketID", msg.BucketID).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get bucket lifecycle rules failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetBucketLifecycleRules(rules))
+}
+
+func (svc *Service) DeleteLifecycleRule(msg *coormq.DeleteLifecycleRule) (*coormq.DeleteLifecycleRuleResp, *mq.CodeMessage) {
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		rule, err := svc.db.Lifecycle().GetByID(tx, msg.RuleID)
+		if err != nil {
+			return err
+		}
+
+		isAvai, _ := svc.db.Bucket().IsAvailable(tx, rule.BucketID, msg.UserID)
+		if !isAvai {
+			return fmt.Errorf("bucket is not avaiable to the user")
+		}
+
+		return svc.db.Lifecycle().Delete(tx, msg.RuleID)
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("RuleID", msg.RuleID).
+			Warn(err.Error())
+
+		if err == sql.ErrNoRows {
+			return nil, mq.Failed(errorcode.DataNotFound, "lifecycle rule not found")
+		}
+		return nil, mq.Failed(errorcode.OperationFailed, "delete lifecycle rule failed")
+	}
+
+	return mq.ReplyOK(coormq.RespDeleteLifecycleRule())
+}
+
+func checkLifecycleRule(rule model.LifecycleRule) error {
+	if rule.Days < 0 {
+		return fmt.Errorf("days must not be negative")
+	}
+
+	switch rule.Action {
+	case model.LifecycleActionExpire, model.LifecycleActionUnpin:
+		if rule.Redundancy != nil {
+			return fmt.Errorf("redundancy is only allowed in %s rule", model.LifecycleActionTransition)
+		}
+
+	case model.LifecycleActionTransition:
+		switch rule.Redundancy.(type) {
+		case *cdssdk.RepRedundancy, *cdssdk.ECRedundancy, *cdssdk.LRCRedundancy:
+		default:
+			return fmt.Errorf("%s rule must have a rep, ec or lrc redundancy", model.LifecycleActionTransition)
+		}
+
+	default:
+		return fmt.Errorf("unknown lifecycle action %s", rule.Action)
+	}
+
+	return nil
+}
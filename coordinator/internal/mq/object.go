@@ -2,7 +2,12 @@ package mq
 
 import (
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/samber/lo"
@@ -10,13 +15,18 @@ import (
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
 	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/serder"
 	"gitlink.org.cn/cloudream/common/utils/sort2"
+	"gitlink.org.cn/cloudream/storage/common/consts"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
 )
 
 func (svc *Service) GetPackageObjects(msg *coormq.GetPackageObjects) (*coormq.GetPackageObjectsResp, *mq.CodeMessage) {
 	var objs []cdssdk.Object
+	var metas []model.ObjectMetadata
 	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
 		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
 		if err != nil {
@@ -28,6 +38,11 @@ func (svc *Service) GetPackageObjects(msg *coormq.GetPackageObjects) (*coormq.Ge
 			return fmt.Errorf("getting package objects: %w", err)
 		}
 
+		metas, err = svc.db.ObjectMetadata().GetPackageMetadatas(tx, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting package object metadatas: %w", err)
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -37,7 +52,7 @@ func (svc *Service) GetPackageObjects(msg *coormq.GetPackageObjects) (*coormq.Ge
 		return nil, mq.Failed(errorcode.OperationFailed, "get package objects failed")
 	}
 
-	return mq.ReplyOK(coormq.NewGetPackageObjectsResp(objs))
+	return mq.ReplyOK(coormq.NewGetPackageObjectsResp(objs, metas))
 }
 
 func (svc *Service) GetPackageObjectDetails(msg *coormq.GetPackageObjectDetails) (*coormq.GetPackageObjectDetailsResp, *mq.CodeMessage) {
@@ -141,6 +156,28 @@ func (svc *Service) GetObjectDetails(msg *coormq.GetObjectDetails) (*coormq.GetO
 			details[objIDIdx].PinnedAt = append(details[objIDIdx].PinnedAt, pinneds[pinIdx].NodeID)
 			pinIdx++
 		}
+
+		// 每个对象最多只有一条元数据记录
+		metas, err := svc.db.ObjectMetadata().BatchGetByObjectID(tx, msg.ObjectIDs)
+		if err != nil {
+			return fmt.Errorf("batch get object metadatas: %w", err)
+		}
+
+		objIDIdx = 0
+		metaIdx := 0
+		for objIDIdx < len(msg.ObjectIDs) && metaIdx < len(metas) {
+			if details[objIDIdx] == nil || msg.ObjectIDs[objIDIdx] < metas[metaIdx].ObjectID {
+				objIDIdx++
+				continue
+			}
+
+			details[objIDIdx].Metadata = &metas[metaIdx]
+			metaIdx++
+		}
+
+		if msg.UserID != 0 {
+			return svc.filterUserObjectDetails(tx, msg.UserID, details)
+		}
 		return nil
 	})
 
@@ -152,13 +189,51 @@ func (svc *Service) GetObjectDetails(msg *coormq.GetObjectDetails) (*coormq.GetO
 	return mq.ReplyOK(coormq.RespGetObjectDetails(details))
 }
 
+// 将用户无权访问的对象设置为nil
+func (svc *Service) filterUserObjectDetails(tx *sqlx.Tx, userID cdssdk.UserID, details []*stgmod.ObjectDetail) error {
+	avaiPkgs := make(map[cdssdk.PackageID]bool)
+	for i, detail := range details {
+		if detail == nil {
+			continue
+		}
+
+		avai, ok := avaiPkgs[detail.Object.PackageID]
+		if !ok {
+			var err error
+			avai, err = svc.db.Package().IsAvailable(tx, userID, detail.Object.PackageID)
+			if err != nil {
+				return fmt.Errorf("checking package available: %w", err)
+			}
+			avaiPkgs[detail.Object.PackageID] = avai
+		}
+
+		if !avai {
+			details[i] = nil
+		}
+	}
+
+	return nil
+}
+
 func (svc *Service) UpdateObjectRedundancy(msg *coormq.UpdateObjectRedundancy) (*coormq.UpdateObjectRedundancyResp, *mq.CodeMessage) {
 	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
-		return svc.db.Object().BatchUpdateRedundancy(tx, msg.Updatings)
+		err := svc.db.Object().BatchUpdateRedundancy(tx, msg.Updatings)
+		if err != nil {
+			return err
+		}
+
+		// 冗余策略变化会改变对象实际占用的空间
+		objIDs := lo.Map(msg.Updatings, func(obj coormq.UpdatingObjectRedundancy, _ int) cdssdk.ObjectID { return obj.ObjectID })
+		objs, err := svc.db.Object().BatchGet(tx, objIDs)
+		if err != nil {
+			return fmt.Errorf("batch getting objects: %w", err)
+		}
+
+		return svc.checkPackagesQuota(tx, lo.Map(objs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
 	})
 	if err != nil {
 		logger.Warnf("batch updating redundancy: %s", err.Error())
-		return nil, mq.Failed(errorcode.OperationFailed, "batch update redundancy failed")
+		return nil, failedWithQuota(err, "batch update redundancy failed")
 	}
 
 	return mq.ReplyOK(coormq.RespUpdateObjectRedundancy())
@@ -180,6 +255,14 @@ func (svc *Service) UpdateObjectInfos(msg *coormq.UpdateObjectInfos) (*coormq.Up
 		if err != nil {
 			return fmt.Errorf("batch getting objects: %w", err)
 		}
+		err = svc.checkObjectsAvailable(tx, msg.UserID, oldObjs)
+		if err != nil {
+			return err
+		}
+		err = svc.checkPackagesWritable(tx, lo.Map(oldObjs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
 		oldObjIDs := make([]cdssdk.ObjectID, len(oldObjs))
 		for i, obj := range oldObjs {
 			oldObjIDs[i] = obj.ObjectID
@@ -196,23 +279,143 @@ func (svc *Service) UpdateObjectInfos(msg *coormq.UpdateObjectInfos) (*coormq.Up
 			avaiUpdatings[i].ApplyTo(&newObjs[i])
 		}
 
+		err = svc.db.ObjectVersion().ArchiveObjects(tx, oldObjs[:len(newObjs)])
+		if err != nil {
+			return fmt.Errorf("archiving objects: %w", err)
+		}
+
 		err = svc.db.Object().BatchUpsertByPackagePath(tx, newObjs)
 		if err != nil {
 			return fmt.Errorf("batch create or update: %w", err)
 		}
 
+		err = svc.db.Quota().AddObjectsUsage(tx, newObjs, oldObjs[:len(newObjs)])
+		if err != nil {
+			return fmt.Errorf("updating package usage: %w", err)
+		}
+
+		// 修改对象会产生历史版本，也可能改变对象大小
+		err = svc.checkPackagesQuota(tx, lo.Map(newObjs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
+
 		sucs = lo.Map(newObjs, func(obj cdssdk.Object, _ int) cdssdk.ObjectID { return obj.ObjectID })
+
+		metaSucs, err := svc.updateObjectMetadatas(tx, msg.UserID, msg.Metadatas)
+		if err != nil {
+			return err
+		}
+		sucs = lo.Uniq(append(sucs, metaSucs...))
 		return nil
 	})
 
 	if err != nil {
-		logger.Warnf("batch updating objects: %s", err.Error())
-		return nil, mq.Failed(errorcode.OperationFailed, "batch update objects failed")
+		logger.WithField("UserID", msg.UserID).Warnf("batch updating objects: %s", err.Error())
+		return nil, failedWithQuota(err, "batch update objects failed")
 	}
 
 	return mq.ReplyOK(coormq.RespUpdateObjectInfos(sucs))
 }
 
+// 按顺序应用对元数据的修改，返回修改成功的对象ID。修改后没有任何元数据的对象会删除它的元数据记录
+func (svc *Service) updateObjectMetadatas(tx *sqlx.Tx, userID cdssdk.UserID, updatings []coormq.UpdatingObjectMetadata) ([]cdssdk.ObjectID, error) {
+	if len(updatings) == 0 {
+		return nil, nil
+	}
+
+	for _, u := range updatings {
+		if u.Mode != coormq.UpdatingMetadataModeSet && u.Mode != coormq.UpdatingMetadataModeMerge {
+			return nil, fmt.Errorf("unknow metadata updating mode: %s", u.Mode)
+		}
+	}
+
+	objIDs := lo.Uniq(lo.Map(updatings, func(u coormq.UpdatingObjectMetadata, _ int) cdssdk.ObjectID { return u.ObjectID }))
+	objs, err := svc.db.Object().BatchGet(tx, objIDs)
+	if err != nil {
+		return nil, fmt.Errorf("batch getting objects: %w", err)
+	}
+	err = svc.checkObjectsAvailable(tx, userID, objs)
+	if err != nil {
+		return nil, err
+	}
+	err = svc.checkPackagesWritable(tx, lo.Map(objs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+	if err != nil {
+		return nil, err
+	}
+
+	oldMetas, err := svc.db.ObjectMetadata().BatchGetByObjectID(tx, objIDs)
+	if err != nil {
+		return nil, fmt.Errorf("batch getting object metadatas: %w", err)
+	}
+
+	metas := make(map[cdssdk.ObjectID]*model.ObjectMetadata)
+	for _, obj := range objs {
+		metas[obj.ObjectID] = &model.ObjectMetadata{ObjectID: obj.ObjectID}
+	}
+	for i := range oldMetas {
+		if _, ok := metas[oldMetas[i].ObjectID]; ok {
+			metas[oldMetas[i].ObjectID] = &oldMetas[i]
+		}
+	}
+
+	var sucs []cdssdk.ObjectID
+	for _, u := range updatings {
+		meta, ok := metas[u.ObjectID]
+		if !ok {
+			continue
+		}
+
+		u.ApplyTo(meta)
+		sucs = append(sucs, u.ObjectID)
+	}
+
+	var upserts []model.ObjectMetadata
+	var deletes []cdssdk.ObjectID
+	for _, obj := range objs {
+		meta := metas[obj.ObjectID]
+		if meta.ContentType == "" && len(meta.UserMetadata) == 0 {
+			deletes = append(deletes, obj.ObjectID)
+		} else {
+			upserts = append(upserts, *meta)
+		}
+	}
+
+	err = svc.db.ObjectMetadata().BatchUpsert(tx, upserts)
+	if err != nil {
+		return nil, fmt.Errorf("batch upserting object metadatas: %w", err)
+	}
+
+	err = svc.db.ObjectMetadata().BatchDeleteByObjectID(tx, deletes)
+	if err != nil {
+		return nil, fmt.Errorf("batch deleting object metadatas: %w", err)
+	}
+
+	return lo.Uniq(sucs), nil
+}
+
+// 检查用户是否拥有这些对象所在的Package
+func (svc *Service) checkObjectsAvailable(tx *sqlx.Tx, userID cdssdk.UserID, objs []cdssdk.Object) error {
+	checked := make(map[cdssdk.PackageID]bool)
+	for _, obj := range objs {
+		if checked[obj.PackageID] {
+			continue
+		}
+
+		ok, err := svc.db.Package().IsAvailable(tx, userID, obj.PackageID)
+		if err != nil {
+			return fmt.Errorf("checking package available: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("package %v is not available to user", obj.PackageID)
+		}
+
+		checked[obj.PackageID] = true
+	}
+
+	return nil
+}
+
 // 根据objIDs从objs中挑选Object。
 // len(objs) >= len(objIDs)
 func pickByObjectIDs[T any](objs []T, objIDs []cdssdk.ObjectID, getID func(T) cdssdk.ObjectID) (picked []T, notFound []T) {
@@ -250,6 +453,14 @@ func (svc *Service) MoveObjects(msg *coormq.MoveObjects) (*coormq.MoveObjectsRes
 		if err != nil {
 			return fmt.Errorf("batch getting objects: %w", err)
 		}
+		err = svc.checkObjectsAvailable(tx, msg.UserID, oldObjs)
+		if err != nil {
+			return err
+		}
+		err = svc.checkPackagesWritable(tx, lo.Map(oldObjs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
 		oldObjIDs := make([]cdssdk.ObjectID, len(oldObjs))
 		for i, obj := range oldObjs {
 			oldObjIDs[i] = obj.ObjectID
@@ -275,6 +486,11 @@ func (svc *Service) MoveObjects(msg *coormq.MoveObjects) (*coormq.MoveObjectsRes
 			}
 		}
 
+		err = svc.checkPackagesWritable(tx, lo.Map(pkgIDChangedObjs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
+
 		var newObjs []cdssdk.Object
 		// 对于PackageID发生变化的对象，需要检查目标Package内是否存在同Path的对象
 		ensuredObjs, err := svc.ensurePackageChangedObjects(tx, msg.UserID, pkgIDChangedObjs)
@@ -290,17 +506,37 @@ func (svc *Service) MoveObjects(msg *coormq.MoveObjects) (*coormq.MoveObjectsRes
 		}
 		newObjs = append(newObjs, ensuredObjs...)
 
+		// 移动前的对象保存为原来路径上的历史版本
+		oldObjMap := lo.SliceToMap(oldObjs, func(obj cdssdk.Object) (cdssdk.ObjectID, cdssdk.Object) { return obj.ObjectID, obj })
+		movedOldObjs := lo.Map(newObjs, func(obj cdssdk.Object, _ int) cdssdk.Object { return oldObjMap[obj.ObjectID] })
+		err = svc.db.ObjectVersion().ArchiveObjects(tx, movedOldObjs)
+		if err != nil {
+			return fmt.Errorf("archiving objects: %w", err)
+		}
+
 		err = svc.db.Object().BatchUpert(tx, newObjs)
 		if err != nil {
 			return fmt.Errorf("batch create or update: %w", err)
 		}
 
+		// 原来所在的Package的用量减少，目标Package的用量增加
+		err = svc.db.Quota().AddObjectsUsage(tx, newObjs, movedOldObjs)
+		if err != nil {
+			return fmt.Errorf("updating package usage: %w", err)
+		}
+
+		// 对象移动到其他Package后，目标Package所在的桶的用量会增加
+		err = svc.checkPackagesQuota(tx, lo.Map(pkgIDChangedObjs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
+
 		sucs = lo.Map(newObjs, func(obj cdssdk.Object, _ int) cdssdk.ObjectID { return obj.ObjectID })
 		return nil
 	})
 	if err != nil {
 		logger.Warn(err.Error())
-		return nil, mq.Failed(errorcode.OperationFailed, "move objects failed")
+		return nil, failedWithQuota(err, "move objects failed")
 	}
 
 	return mq.ReplyOK(coormq.RespMoveObjects(sucs))
@@ -414,7 +650,26 @@ func (svc *Service) ensurePathChangedObjects(tx *sqlx.Tx, userID cdssdk.UserID,
 
 func (svc *Service) DeleteObjects(msg *coormq.DeleteObjects) (*coormq.DeleteObjectsResp, *mq.CodeMessage) {
 	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
-		err := svc.db.Object().BatchDelete(tx, msg.ObjectIDs)
+		// 开启了多版本的Package中的对象被删除时，保留它的最后一个版本
+		objs, err := svc.db.Object().BatchGet(tx, msg.ObjectIDs)
+		if err != nil {
+			return fmt.Errorf("batch getting objects: %w", err)
+		}
+		err = svc.checkObjectsAvailable(tx, msg.UserID, objs)
+		if err != nil {
+			return err
+		}
+		err = svc.checkPackagesWritable(tx, lo.Map(objs, func(obj cdssdk.Object, _ int) cdssdk.PackageID { return obj.PackageID }))
+		if err != nil {
+			return err
+		}
+
+		err = svc.db.ObjectVersion().ArchiveObjects(tx, objs)
+		if err != nil {
+			return fmt.Errorf("archiving objects: %w", err)
+		}
+
+		err = svc.db.Object().BatchDelete(tx, msg.ObjectIDs)
 		if err != nil {
 			return fmt.Errorf("batch deleting objects: %w", err)
 		}
@@ -429,12 +684,339 @@ func (svc *Service) DeleteObjects(msg *coormq.DeleteObjects) (*coormq.DeleteObje
 			return fmt.Errorf("batch deleting pinned objects: %w", err)
 		}
 
+		err = svc.db.ObjectMetadata().BatchDeleteByObjectID(tx, msg.ObjectIDs)
+		if err != nil {
+			return fmt.Errorf("batch deleting object metadatas: %w", err)
+		}
+
+		err = svc.db.ObjectChecksum().BatchDeleteByObjectID(tx, msg.ObjectIDs)
+		if err != nil {
+			return fmt.Errorf("batch deleting object checksums: %w", err)
+		}
+
+		err = svc.db.CorruptBlock().BatchDeleteByObjectID(tx, msg.ObjectIDs)
+		if err != nil {
+			return fmt.Errorf("batch deleting corrupt blocks: %w", err)
+		}
+
+		err = svc.db.Quota().AddObjectsUsage(tx, nil, objs)
+		if err != nil {
+			return fmt.Errorf("updating package usage: %w", err)
+		}
+
 		return nil
 	})
 	if err != nil {
-		logger.Warnf("batch deleting objects: %s", err.Error())
+		logger.WithField("UserID", msg.UserID).Warnf("batch deleting objects: %s", err.Error())
 		return nil, mq.Failed(errorcode.OperationFailed, "batch delete objects failed")
 	}
 
 	return mq.ReplyOK(coormq.RespDeleteObjects())
 }
+
+func (svc *Service) FindExistingFiles(msg *coormq.FindExistingFiles) (*coormq.FindExistingFilesResp, *mq.CodeMessage) {
+	var files []coormq.ExistingFile
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		nodes, err := svc.db.Node().GetUserNodes(tx, msg.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user nodes: %w", err)
+		}
+
+		availNodes := make(map[cdssdk.NodeID]bool)
+		for _, node := range nodes {
+			if node.State != consts.NodeStateUnavailable {
+				availNodes[node.NodeID] = true
+			}
+		}
+
+		for _, hash := range lo.Uniq(msg.FileHashes) {
+			file, err := svc.findExistingFile(tx, msg.UserID, hash, msg.Redundancy, availNodes)
+			if err != nil {
+				return err
+			}
+
+			if file != nil {
+				files = append(files, *file)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "find existing files failed")
+	}
+
+	return mq.ReplyOK(coormq.RespFindExistingFiles(files))
+}
+
+// 只在用户自己可以访问的对象中查找，要求对象的冗余策略与上传时使用的相同，并且所有编码块都在可用节点上。
+// 上传时不编码的情况下，还可以使用缓存了完整文件的节点
+func (svc *Service) findExistingFile(tx *sqlx.Tx, userID cdssdk.UserID, fileHash string, red cdssdk.Redundancy, availNodes map[cdssdk.NodeID]bool) (*coormq.ExistingFile, error) {
+	objs, err := svc.db.Object().GetUserObjectsByFileHash(tx, userID, fileHash, 10)
+	if err != nil {
+		return nil, fmt.Errorf("getting objects by file hash: %w", err)
+	}
+	// 用户没有这个文件时，不能仅凭文件哈希就使用其他用户的数据
+	if len(objs) == 0 {
+		return nil, nil
+	}
+
+	blocks, err := svc.db.ObjectBlock().BatchGetByObjectID(tx, lo.Map(objs, func(obj cdssdk.Object, idx int) cdssdk.ObjectID { return obj.ObjectID }))
+	if err != nil {
+		return nil, fmt.Errorf("batch getting object blocks: %w", err)
+	}
+
+	objBlocks := lo.GroupBy(blocks, func(blk stgmod.ObjectBlock) cdssdk.ObjectID { return blk.ObjectID })
+	for _, obj := range objs {
+		if !reflect.DeepEqual(obj.Redundancy, red) {
+			continue
+		}
+
+		blks := objBlocks[obj.ObjectID]
+		if len(blks) == 0 {
+			continue
+		}
+
+		if lo.EveryBy(blks, func(blk stgmod.ObjectBlock) bool { return availNodes[blk.NodeID] }) {
+			return &coormq.ExistingFile{
+				FileHash:   fileHash,
+				Redundancy: obj.Redundancy,
+				Blocks:     blks,
+			}, nil
+		}
+	}
+
+	if _, ok := red.(*cdssdk.NoneRedundancy); !ok {
+		return nil, nil
+	}
+
+	cachingNodes, err := svc.db.Cache().GetCachingFileNodes(tx, fileHash)
+	if err != nil {
+		return nil, fmt.Errorf("getting caching file nodes: %w", err)
+	}
+
+	for _, node := range cachingNodes {
+		if availNodes[node.NodeID] {
+			return &coormq.ExistingFile{
+				FileHash:   fileHash,
+				Redundancy: cdssdk.NewNoneRedundancy(),
+				Blocks: []stgmod.ObjectBlock{{
+					Index:    0,
+					NodeID:   node.NodeID,
+					FileHash: fileHash,
+				}},
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (svc *Service) GetObjectChecksums(msg *coormq.GetObjectChecksums) (*coormq.GetObjectChecksumsResp, *mq.CodeMessage) {
+	var checksums []model.ObjectChecksum
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		objs, err := svc.db.Object().BatchGet(tx, lo.Uniq(msg.ObjectIDs))
+		if err != nil {
+			return fmt.Errorf("batch getting objects: %w", err)
+		}
+		err = svc.checkObjectsAvailable(tx, msg.UserID, objs)
+		if err != nil {
+			return err
+		}
+
+		checksums, err = svc.db.ObjectChecksum().BatchGetByObjectID(tx, lo.Map(objs, func(obj cdssdk.Object, _ int) cdssdk.ObjectID { return obj.ObjectID }))
+		if err != nil {
+			return fmt.Errorf("batch getting object checksums: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warnf("getting object checksums: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get object checksums failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetObjectChecksums(checksums))
+}
+
+const (
+	ListObjectsMaxKeys   = 1000
+	listObjectsBatchSize = 1000
+	// 一次查询最多扫描的对象数量。过滤条件很严格时，即使结果不足MaxKeys个，扫描到这个数量后也会返回，并返回继续查询的位置
+	listObjectsMaxScanRows = 10 * listObjectsBatchSize
+)
+
+// 分页时的位置信息，编码后作为ContinuationToken返回给调用者
+type listObjectsToken struct {
+	Marker string `json:"marker"`
+	// 上一页最后返回的是CommonPrefix时，下一页需要跳过此前缀下的所有对象
+	SkipPrefix string `json:"skipPrefix,omitempty"`
+}
+
+func (svc *Service) ListObjects(msg *coormq.ListObjects) (*coormq.ListObjectsResp, *mq.CodeMessage) {
+	maxKeys := msg.MaxKeys
+	if maxKeys <= 0 || maxKeys > ListObjectsMaxKeys {
+		maxKeys = ListObjectsMaxKeys
+	}
+
+	if msg.Glob != "" {
+		if _, err := path.Match(msg.Glob, ""); err != nil {
+			return nil, mq.Failed(errorcode.BadArgument, "invalid glob pattern")
+		}
+	}
+
+	var token listObjectsToken
+	if msg.ContinuationToken != "" {
+		data, err := base64.RawURLEncoding.DecodeString(msg.ContinuationToken)
+		if err != nil {
+			return nil, mq.Failed(errorcode.BadArgument, "invalid continuation token")
+		}
+		if err := serder.JSONToObject(data, &token); err != nil {
+			return nil, mq.Failed(errorcode.BadArgument, "invalid continuation token")
+		}
+	} else {
+		token.Marker = msg.StartAfter
+	}
+
+	var objs []cdssdk.Object
+	var metas []model.ObjectMetadata
+	var commonPrefixes []string
+	var nextToken *listObjectsToken
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting package by id: %w", err)
+		}
+
+		// 模式中不含通配符的开头部分也可以用来缩小查询范围
+		filter := mydb.ObjectListFilter{
+			Prefix:        msg.Prefix,
+			Marker:        token.Marker,
+			MinSize:       msg.MinSize,
+			MaxSize:       msg.MaxSize,
+			UpdatedAfter:  msg.UpdatedAfter,
+			UpdatedBefore: msg.UpdatedBefore,
+		}
+		if globPrefix := globLiteralPrefix(msg.Glob); strings.HasPrefix(globPrefix, filter.Prefix) {
+			filter.Prefix = globPrefix
+		}
+
+		skipPrefix := token.SkipPrefix
+		lastToken := token
+		scannedRows := 0
+		for {
+			batch, err := svc.db.Object().ListPackageObjects(tx, msg.PackageID, filter, listObjectsBatchSize)
+			if err != nil {
+				return fmt.Errorf("listing package objects: %w", err)
+			}
+
+			batchMetas, err := svc.db.ObjectMetadata().BatchGetByObjectID(tx, lo.Map(batch, func(obj cdssdk.Object, idx int) cdssdk.ObjectID { return obj.ObjectID }))
+			if err != nil {
+				return fmt.Errorf("batch getting object metadatas: %w", err)
+			}
+			metaMap := make(map[cdssdk.ObjectID]model.ObjectMetadata)
+			for _, m := range batchMetas {
+				metaMap[m.ObjectID] = m
+			}
+
+			// 遇到CommonPrefix时，直接从这个前缀之后开始重新查询，跳过前缀下的其他对象
+			skipped := false
+			for _, obj := range batch {
+				filter.Marker = obj.Path
+
+				if skipPrefix != "" && strings.HasPrefix(obj.Path, skipPrefix) {
+					continue
+				}
+
+				if !strings.HasPrefix(obj.Path, msg.Prefix) {
+					continue
+				}
+
+				meta, hasMeta := metaMap[obj.ObjectID]
+				if !matchListObjectsOption(obj, meta, msg.ListObjectsOption) {
+					continue
+				}
+
+				if len(objs)+len(commonPrefixes) >= maxKeys {
+					nextToken = &lastToken
+					return nil
+				}
+
+				if msg.Delimiter != "" {
+					if idx := strings.Index(obj.Path[len(msg.Prefix):], msg.Delimiter); idx >= 0 {
+						prefix := obj.Path[:len(msg.Prefix)+idx+len(msg.Delimiter)]
+						commonPrefixes = append(commonPrefixes, prefix)
+						skipPrefix = prefix
+						lastToken = listObjectsToken{Marker: prefix + string(utf8.MaxRune), SkipPrefix: prefix}
+						filter.Marker = lastToken.Marker
+						skipped = true
+						break
+					}
+				}
+
+				objs = append(objs, obj)
+				if hasMeta {
+					metas = append(metas, meta)
+				}
+				lastToken = listObjectsToken{Marker: obj.Path}
+			}
+
+			if !skipped && len(batch) < listObjectsBatchSize {
+				return nil
+			}
+
+			// 已经扫描过的对象都处理完了，下一次从最后扫描的位置继续
+			scannedRows += len(batch)
+			if scannedRows >= listObjectsMaxScanRows {
+				nextToken = &listObjectsToken{Marker: filter.Marker, SkipPrefix: skipPrefix}
+				return nil
+			}
+		}
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).WithField("PackageID", msg.PackageID).Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "list objects failed")
+	}
+
+	nextTokenStr := ""
+	if nextToken != nil {
+		data, err := serder.ObjectToJSON(nextToken)
+		if err != nil {
+			logger.Warnf("encoding continuation token: %s", err.Error())
+			return nil, mq.Failed(errorcode.OperationFailed, "list objects failed")
+		}
+		nextTokenStr = base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	return mq.ReplyOK(coormq.RespListObjects(objs, metas, commonPrefixes, nextTokenStr))
+}
+
+func matchListObjectsOption(obj cdssdk.Object, meta model.ObjectMetadata, opt coormq.ListObjectsOption) bool {
+	if opt.Glob != "" {
+		if ok, _ := path.Match(opt.Glob, obj.Path); !ok {
+			return false
+		}
+	}
+
+	if opt.ContentType != "" && !strings.HasPrefix(meta.ContentType, opt.ContentType) {
+		return false
+	}
+
+	for k, v := range opt.Metadata {
+		if mv, ok := meta.UserMetadata[k]; !ok || mv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 返回模式中第一个通配符之前的部分
+func globLiteralPrefix(glob string) string {
+	idx := strings.IndexAny(glob, `*?[\`)
+	if idx < 0 {
+		return glob
+	}
+	return glob[:idx]
+}
@@ -0,0 +1,137 @@
+package mq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+func (svc *Service) SetPackageVersioning(msg *coormq.SetPackageVersioning) (*coormq.SetPackageVersioningResp, *mq.CodeMessage) {
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+
+		return svc.db.ObjectVersion().SetPackageVersioning(tx, msg.PackageID, msg.Enabled)
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "set package versioning failed")
+	}
+
+	return mq.ReplyOK(coormq.RespSetPackageVersioning())
+}
+
+func (svc *Service) GetObjectVersions(msg *coormq.GetObjectVersions) (*coormq.GetObjectVersionsResp, *mq.CodeMessage) {
+	var versions []model.ObjectVersion
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+
+		versions, err = svc.db.ObjectVersion().GetByPackagePath(tx, msg.PackageID, msg.Path)
+		if err != nil {
+			return fmt.Errorf("getting object versions: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			WithField("Path", msg.Path).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get object versions failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetObjectVersions(versions))
+}
+
+func (svc *Service) GetObjectVersionDetails(msg *coormq.GetObjectVersionDetails) (*coormq.GetObjectVersionDetailsResp, *mq.CodeMessage) {
+	details := make([]*stgmod.ObjectDetail, len(msg.VersionIDs))
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		for i, id := range msg.VersionIDs {
+			detail, err := svc.db.ObjectVersion().GetDetail(tx, id)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("getting object version %v detail: %w", id, err)
+			}
+
+			details[i] = &detail
+		}
+
+		if msg.UserID != 0 {
+			return svc.filterUserObjectDetails(tx, msg.UserID, details)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get object version details failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetObjectVersionDetails(details))
+}
+
+func (svc *Service) RestoreObjectVersion(msg *coormq.RestoreObjectVersion) (*coormq.RestoreObjectVersionResp, *mq.CodeMessage) {
+	var obj cdssdk.Object
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		ver, err := svc.db.ObjectVersion().GetByID(tx, msg.VersionID)
+		if err != nil {
+			return err
+		}
+
+		_, err = svc.db.Package().GetUserPackage(tx, msg.UserID, ver.Object.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+
+		err = svc.checkPackagesWritable(tx, []cdssdk.PackageID{ver.Object.PackageID})
+		if err != nil {
+			return err
+		}
+
+		obj, err = svc.db.ObjectVersion().Restore(tx, msg.VersionID)
+		if err != nil {
+			return fmt.Errorf("restoring object version: %w", err)
+		}
+
+		err = svc.db.Quota().CheckPackage(tx, obj.PackageID)
+		if err != nil {
+			return fmt.Errorf("checking quota: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("VersionID", msg.VersionID).
+			Warn(err.Error())
+
+		if err == sql.ErrNoRows {
+			return nil, mq.Failed(errorcode.DataNotFound, "object version not found")
+		}
+		if errors.Is(err, mydb.ErrObjectVersionDegraded) {
+			return nil, mq.Failed(errorcode.OperationFailed, "object version is degraded")
+		}
+		return nil, failedWithQuota(err, "restore object version failed")
+	}
+
+	return mq.ReplyOK(coormq.RespRestoreObjectVersion(obj))
+}
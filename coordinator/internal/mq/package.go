@@ -6,6 +6,7 @@ import (
 	"sort"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
 	"gitlink.org.cn/cloudream/common/consts/errorcode"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
@@ -15,11 +16,16 @@ import (
 )
 
 func (svc *Service) GetPackage(msg *coormq.GetPackage) (*coormq.GetPackageResp, *mq.CodeMessage) {
-	pkg, err := svc.db.Package().GetByID(svc.db.SQLCtx(), msg.PackageID)
+	pkg, err := svc.db.Package().GetUserPackage(svc.db.SQLCtx(), msg.UserID, msg.PackageID)
 	if err != nil {
-		logger.WithField("PackageID", msg.PackageID).
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
 			Warnf("get package: %s", err.Error())
 
+		if err == sql.ErrNoRows {
+			return nil, mq.Failed(errorcode.DataNotFound, "package not found")
+		}
+
 		return nil, mq.Failed(errorcode.OperationFailed, "get package failed")
 	}
 
@@ -84,11 +90,25 @@ func (svc *Service) UpdatePackage(msg *coormq.UpdatePackage) (*coormq.UpdatePack
 			return fmt.Errorf("getting package by id: %w", err)
 		}
 
+		err = svc.checkPackagesWritable(tx, []cdssdk.PackageID{msg.PackageID})
+		if err != nil {
+			return err
+		}
+
 		// 先执行删除操作
 		if len(msg.Deletes) > 0 {
+			delObjs, err := svc.db.Object().BatchGet(tx, msg.Deletes)
+			if err != nil {
+				return fmt.Errorf("getting deleting objects: %w", err)
+			}
+
 			if err := svc.db.Object().BatchDelete(tx, msg.Deletes); err != nil {
 				return fmt.Errorf("deleting objects: %w", err)
 			}
+
+			if err := svc.db.Quota().AddObjectsUsage(tx, nil, delObjs); err != nil {
+				return fmt.Errorf("updating package usage: %w", err)
+			}
 		}
 
 		// 再执行添加操作
@@ -98,13 +118,18 @@ func (svc *Service) UpdatePackage(msg *coormq.UpdatePackage) (*coormq.UpdatePack
 				return fmt.Errorf("adding objects: %w", err)
 			}
 			added = ad
+
+			err = svc.db.Quota().CheckPackage(tx, msg.PackageID)
+			if err != nil {
+				return fmt.Errorf("checking quota: %w", err)
+			}
 		}
 
 		return nil
 	})
 	if err != nil {
 		logger.WithField("PackageID", msg.PackageID).Warn(err.Error())
-		return nil, mq.Failed(errorcode.OperationFailed, "update package failed")
+		return nil, failedWithQuota(err, "update package failed")
 	}
 
 	return mq.ReplyOK(coormq.NewUpdatePackageResp(added))
@@ -141,6 +166,88 @@ func (svc *Service) DeletePackage(msg *coormq.DeletePackage) (*coormq.DeletePack
 	return mq.ReplyOK(coormq.NewDeletePackageResp())
 }
 
+func (svc *Service) ClonePackage(msg *coormq.ClonePackage) (*coormq.ClonePackageResp, *mq.CodeMessage) {
+	var pkg cdssdk.Package
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		srcPkg, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+		if srcPkg.State != cdssdk.PackageStateNormal {
+			return fmt.Errorf("package is not in normal state")
+		}
+
+		isAvai, _ := svc.db.Bucket().IsAvailable(tx, msg.BucketID, msg.UserID)
+		if !isAvai {
+			return fmt.Errorf("bucket is not avaiable to the user")
+		}
+
+		pkgID, err := svc.db.Package().Create(tx, msg.BucketID, msg.Name)
+		if err != nil {
+			return fmt.Errorf("creating package: %w", err)
+		}
+
+		err = svc.db.Object().CopyPackageObjects(tx, msg.PackageID, pkgID)
+		if err != nil {
+			return fmt.Errorf("copying package objects: %w", err)
+		}
+
+		err = svc.db.ObjectVersion().CopyPackageVersions(tx, msg.PackageID, pkgID)
+		if err != nil {
+			return fmt.Errorf("copying package object versions: %w", err)
+		}
+
+		err = svc.db.Quota().CopyPackageUsage(tx, msg.PackageID, pkgID)
+		if err != nil {
+			return fmt.Errorf("copying package usage: %w", err)
+		}
+
+		if msg.Snapshot {
+			err = svc.db.Package().MarkSnapshot(tx, pkgID, msg.PackageID)
+			if err != nil {
+				return fmt.Errorf("marking package as snapshot: %w", err)
+			}
+		}
+
+		err = svc.db.Quota().CheckPackage(tx, pkgID)
+		if err != nil {
+			return fmt.Errorf("checking quota: %w", err)
+		}
+
+		pkg, err = svc.db.Package().GetByID(tx, pkgID)
+		if err != nil {
+			return fmt.Errorf("getting package by id: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			WithField("BucketID", msg.BucketID).
+			WithField("Name", msg.Name).
+			Warn(err.Error())
+		return nil, failedWithQuota(err, "clone package failed")
+	}
+
+	return mq.ReplyOK(coormq.RespClonePackage(pkg))
+}
+
+// 快照Package中的对象不能被修改，需要在修改对象的同一个事务中调用
+func (svc *Service) checkPackagesWritable(tx *sqlx.Tx, pkgIDs []cdssdk.PackageID) error {
+	for _, pkgID := range lo.Uniq(pkgIDs) {
+		isSnapshot, err := svc.db.Package().IsSnapshot(tx, pkgID)
+		if err != nil {
+			return fmt.Errorf("checking package %v is snapshot: %w", pkgID, err)
+		}
+		if isSnapshot {
+			return fmt.Errorf("package %v is a read-only snapshot", pkgID)
+		}
+	}
+
+	return nil
+}
+
 func (svc *Service) GetPackageCachedNodes(msg *coormq.GetPackageCachedNodes) (*coormq.GetPackageCachedNodesResp, *mq.CodeMessage) {
 	isAva, err := svc.db.Package().IsAvailable(svc.db.SQLCtx(), msg.UserID, msg.PackageID)
 	if err != nil {
@@ -196,6 +303,20 @@ func (svc *Service) GetPackageCachedNodes(msg *coormq.GetPackageCachedNodes) (*c
 }
 
 func (svc *Service) GetPackageLoadedNodes(msg *coormq.GetPackageLoadedNodes) (*coormq.GetPackageLoadedNodesResp, *mq.CodeMessage) {
+	isAva, err := svc.db.Package().IsAvailable(svc.db.SQLCtx(), msg.UserID, msg.PackageID)
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			Warnf("check package available failed, err: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "check package available failed")
+	}
+	if !isAva {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			Warnf("package is not available to the user")
+		return nil, mq.Failed(errorcode.OperationFailed, "package is not available to the user")
+	}
+
 	storages, err := svc.db.StoragePackage().FindPackageStorages(svc.db.SQLCtx(), msg.PackageID)
 	if err != nil {
 		logger.WithField("PackageID", msg.PackageID).
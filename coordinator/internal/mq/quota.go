@@ -0,0 +1,128 @@
+package mq
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+func (svc *Service) GetUserQuota(msg *coormq.GetUserQuota) (*coormq.GetUserQuotaResp, *mq.CodeMessage) {
+	var quota model.Quota
+	var usage model.StorageUsage
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		var err error
+		quota, err = svc.db.Quota().GetUserQuota(tx, msg.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user quota: %w", err)
+		}
+
+		usage, err = svc.db.Quota().GetUserUsage(tx, msg.UserID)
+		if err != nil {
+			return fmt.Errorf("getting user usage: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get user quota failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetUserQuota(quota, usage))
+}
+
+func (svc *Service) GetBucketQuota(msg *coormq.GetBucketQuota) (*coormq.GetBucketQuotaResp, *mq.CodeMessage) {
+	var quota model.Quota
+	var usage model.StorageUsage
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		isAvai, _ := svc.db.Bucket().IsAvailable(tx, msg.BucketID, msg.UserID)
+		if !isAvai {
+			return fmt.Errorf("bucket is not avaiable to the user")
+		}
+
+		var err error
+		quota, err = svc.db.Quota().GetBucketQuota(tx, msg.BucketID)
+		if err != nil {
+			return fmt.Errorf("getting bucket quota: %w", err)
+		}
+
+		usage, err = svc.db.Quota().GetBucketUsage(tx, msg.BucketID)
+		if err != nil {
+			return fmt.Errorf("getting bucket usage: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("BucketID", msg.BucketID).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get bucket quota failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetBucketQuota(quota, usage))
+}
+
+func (svc *Service) SetUserQuota(msg *coormq.SetUserQuota) (*coormq.SetUserQuotaResp, *mq.CodeMessage) {
+	err := svc.db.Quota().SetUserQuota(svc.db.SQLCtx(), msg.UserID, msg.Quota)
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warnf("setting user quota: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "set user quota failed")
+	}
+
+	return mq.ReplyOK(coormq.RespSetUserQuota())
+}
+
+func (svc *Service) SetBucketQuota(msg *coormq.SetBucketQuota) (*coormq.SetBucketQuotaResp, *mq.CodeMessage) {
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.Bucket().GetByID(tx, msg.BucketID)
+		if err != nil {
+			return fmt.Errorf("getting bucket: %w", err)
+		}
+
+		return svc.db.Quota().SetBucketQuota(tx, msg.BucketID, msg.Quota)
+	})
+	if err != nil {
+		logger.WithField("BucketID", msg.BucketID).Warnf("setting bucket quota: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "set bucket quota failed")
+	}
+
+	return mq.ReplyOK(coormq.RespSetBucketQuota())
+}
+
+// 检查这些Package的用量是否超出了配额，需要在修改了对象的同一个事务中调用
+func (svc *Service) checkPackagesQuota(tx *sqlx.Tx, pkgIDs []cdssdk.PackageID) error {
+	checked := make(map[cdssdk.PackageID]bool)
+	for _, pkgID := range pkgIDs {
+		if checked[pkgID] {
+			continue
+		}
+
+		err := svc.db.Quota().CheckPackage(tx, pkgID)
+		if err != nil {
+			return fmt.Errorf("checking package %v quota: %w", pkgID, err)
+		}
+
+		checked[pkgID] = true
+	}
+
+	return nil
+}
+
+// 超出配额导致的失败需要告知调用者，其他错误则使用统一的错误信息
+func failedWithQuota(err error, msg string) *mq.CodeMessage {
+	if errors.Is(err, mydb.ErrQuotaExceeded) {
+		return mq.Failed(errorcode.OperationFailed, "quota exceeded")
+	}
+
+	return mq.Failed(errorcode.OperationFailed, msg)
+}
@@ -23,6 +23,22 @@ func (svc *Service) GetStorage(msg *coormq.GetStorage) (*coormq.GetStorageResp,
 	return mq.ReplyOK(coormq.RespGetStorage(stg))
 }
 
+func (svc *Service) GetStorageInfo(msg *coormq.GetStorageInfo) (*coormq.GetStorageInfoResp, *mq.CodeMessage) {
+	stg, err := svc.db.Storage().GetByID(svc.db.SQLCtx(), msg.StorageID)
+	if err != nil {
+		logger.WithField("StorageID", msg.StorageID).
+			Warnf("getting storage: %s", err.Error())
+
+		if err == sql.ErrNoRows {
+			return nil, mq.Failed(errorcode.DataNotFound, "storage not found")
+		}
+
+		return nil, mq.Failed(errorcode.OperationFailed, "get storage failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetStorageInfo(stg))
+}
+
 func (svc *Service) GetStorageByName(msg *coormq.GetStorageByName) (*coormq.GetStorageByNameResp, *mq.CodeMessage) {
 	stg, err := svc.db.Storage().GetUserStorageByName(svc.db.SQLCtx(), msg.UserID, msg.Name)
 	if err != nil {
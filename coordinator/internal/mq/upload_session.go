@@ -0,0 +1,171 @@
+package mq
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+)
+
+func (svc *Service) CreateUploadSession(msg *coormq.CreateUploadSession) (*coormq.CreateUploadSessionResp, *mq.CodeMessage) {
+	var session model.UploadSession
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.Package().GetUserPackage(tx, msg.UserID, msg.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+
+		err = svc.checkPackagesWritable(tx, []cdssdk.PackageID{msg.PackageID})
+		if err != nil {
+			return err
+		}
+
+		sessionID, err := svc.db.UploadSession().Create(tx, msg.UserID, msg.PackageID, msg.Path, msg.NodeID, time.Now())
+		if err != nil {
+			return fmt.Errorf("creating upload session: %w", err)
+		}
+
+		session, err = svc.db.UploadSession().GetByID(tx, sessionID)
+		if err != nil {
+			return fmt.Errorf("getting upload session: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("PackageID", msg.PackageID).
+			WithField("Path", msg.Path).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "create upload session failed")
+	}
+
+	return mq.ReplyOK(coormq.RespCreateUploadSession(session))
+}
+
+func (svc *Service) GetUploadSession(msg *coormq.GetUploadSession) (*coormq.GetUploadSessionResp, *mq.CodeMessage) {
+	var session model.UploadSession
+	var parts []model.UploadSessionPart
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		var err error
+		session, err = svc.db.UploadSession().GetUserSession(tx, msg.UserID, msg.SessionID)
+		if err != nil {
+			return err
+		}
+
+		parts, err = svc.db.UploadSession().GetParts(tx, msg.SessionID)
+		if err != nil {
+			return fmt.Errorf("getting upload session parts: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("SessionID", msg.SessionID).
+			Warnf("get upload session: %s", err.Error())
+
+		if err == sql.ErrNoRows {
+			return nil, mq.Failed(errorcode.DataNotFound, "upload session not found")
+		}
+		return nil, mq.Failed(errorcode.OperationFailed, "get upload session failed")
+	}
+
+	return mq.ReplyOK(coormq.RespGetUploadSession(session, parts))
+}
+
+func (svc *Service) AddUploadSessionPart(msg *coormq.AddUploadSessionPart) (*coormq.AddUploadSessionPartResp, *mq.CodeMessage) {
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.UploadSession().GetUserSession(tx, msg.UserID, msg.Part.SessionID)
+		if err != nil {
+			return fmt.Errorf("getting upload session: %w", err)
+		}
+
+		err = svc.db.UploadSession().UpsertPart(tx, msg.Part)
+		if err != nil {
+			return fmt.Errorf("upserting part: %w", err)
+		}
+
+		return svc.db.UploadSession().Touch(tx, msg.Part.SessionID, msg.Part.UploadTime)
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("SessionID", msg.Part.SessionID).
+			WithField("PartNumber", msg.Part.PartNumber).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "add upload session part failed")
+	}
+
+	return mq.ReplyOK(coormq.RespAddUploadSessionPart())
+}
+
+func (svc *Service) CompleteUploadSession(msg *coormq.CompleteUploadSession) (*coormq.CompleteUploadSessionResp, *mq.CodeMessage) {
+	var added []cdssdk.Object
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		session, err := svc.db.UploadSession().GetUserSession(tx, msg.UserID, msg.SessionID)
+		if err != nil {
+			return fmt.Errorf("getting upload session: %w", err)
+		}
+
+		_, err = svc.db.Package().GetUserPackage(tx, msg.UserID, session.PackageID)
+		if err != nil {
+			return fmt.Errorf("getting user package: %w", err)
+		}
+
+		err = svc.checkPackagesWritable(tx, []cdssdk.PackageID{session.PackageID})
+		if err != nil {
+			return err
+		}
+
+		added, err = svc.db.Object().BatchAdd(tx, session.PackageID, []coormq.AddObjectEntry{msg.Object})
+		if err != nil {
+			return fmt.Errorf("adding object: %w", err)
+		}
+
+		err = svc.db.Quota().CheckPackage(tx, session.PackageID)
+		if err != nil {
+			return fmt.Errorf("checking quota: %w", err)
+		}
+
+		err = svc.db.UploadSession().Delete(tx, msg.SessionID)
+		if err != nil {
+			return fmt.Errorf("deleting upload session: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("SessionID", msg.SessionID).
+			Warn(err.Error())
+		return nil, failedWithQuota(err, "complete upload session failed")
+	}
+
+	return mq.ReplyOK(coormq.RespCompleteUploadSession(added[0]))
+}
+
+func (svc *Service) DeleteUploadSession(msg *coormq.DeleteUploadSession) (*coormq.DeleteUploadSessionResp, *mq.CodeMessage) {
+	err := svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.db.UploadSession().GetUserSession(tx, msg.UserID, msg.SessionID)
+		if err != nil {
+			return fmt.Errorf("getting upload session: %w", err)
+		}
+
+		return svc.db.UploadSession().Delete(tx, msg.SessionID)
+	})
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).
+			WithField("SessionID", msg.SessionID).
+			Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "delete upload session failed")
+	}
+
+	return mq.ReplyOK(coormq.RespDeleteUploadSession())
+}
@@ -0,0 +1,160 @@
+package mq
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultUserTokenExpire = 30 * 24 * time.Hour
+)
+
+var errInvalidPassword = fmt.Errorf("invalid user id or password")
+
+func (svc *Service) AuthUser(msg *coormq.AuthUser) (*coormq.AuthUserResp, *mq.CodeMessage) {
+	user, err := svc.checkUserPassword(svc.db.SQLCtx(), msg.UserID, msg.Password)
+	if err == errInvalidPassword {
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warn(err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get user failed")
+	}
+
+	return mq.ReplyOK(coormq.RespAuthUser(user.Admin))
+}
+
+func (svc *Service) IssueUserToken(msg *coormq.IssueUserToken) (*coormq.IssueUserTokenResp, *mq.CodeMessage) {
+	expire := defaultUserTokenExpire
+	if msg.ExpireSeconds > 0 {
+		expire = time.Duration(msg.ExpireSeconds) * time.Second
+	}
+
+	tokenBytes := make([]byte, 32)
+	_, err := rand.Read(tokenBytes)
+	if err != nil {
+		logger.Warnf("generating token: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "issue token failed")
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	now := time.Now()
+	expireTime := now.Add(expire)
+	err = svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.checkUserPassword(tx, msg.UserID, msg.Password)
+		if err != nil {
+			return err
+		}
+
+		// 顺便清理已经过期的凭证
+		err = svc.db.User().DeleteExpiredTokens(tx, now)
+		if err != nil {
+			return fmt.Errorf("deleting expired tokens: %w", err)
+		}
+
+		return svc.db.User().CreateToken(tx, model.UserToken{
+			TokenHash:  hashUserToken(token),
+			UserID:     msg.UserID,
+			ExpireTime: expireTime,
+		})
+	})
+	if err == errInvalidPassword {
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warnf("issuing token: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "issue token failed")
+	}
+
+	return mq.ReplyOK(coormq.RespIssueUserToken(token, expireTime))
+}
+
+func (svc *Service) VerifyUserToken(msg *coormq.VerifyUserToken) (*coormq.VerifyUserTokenResp, *mq.CodeMessage) {
+	token, err := svc.db.User().GetToken(svc.db.SQLCtx(), hashUserToken(msg.Token), time.Now())
+	if err == sql.ErrNoRows {
+		return nil, mq.Failed(errorcode.OperationFailed, "invalid or expired token")
+	}
+	if err != nil {
+		logger.Warnf("getting token: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "verify token failed")
+	}
+
+	return mq.ReplyOK(coormq.RespVerifyUserToken(token.UserID))
+}
+
+func (svc *Service) RevokeUserToken(msg *coormq.RevokeUserToken) (*coormq.RevokeUserTokenResp, *mq.CodeMessage) {
+	err := svc.db.User().DeleteToken(svc.db.SQLCtx(), hashUserToken(msg.Token))
+	if err != nil {
+		logger.Warnf("deleting token: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "revoke token failed")
+	}
+
+	return mq.ReplyOK(coormq.RespRevokeUserToken())
+}
+
+func (svc *Service) ChangeUserPassword(msg *coormq.ChangeUserPassword) (*coormq.ChangeUserPasswordResp, *mq.CodeMessage) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(msg.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, mq.Failed(errorcode.BadArgument, fmt.Sprintf("invalid new password: %s", err.Error()))
+	}
+
+	err = svc.db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		_, err := svc.checkUserPassword(tx, msg.UserID, msg.OldPassword)
+		if err != nil {
+			return err
+		}
+
+		err = svc.db.User().SetPasswordHash(tx, msg.UserID, string(hash))
+		if err != nil {
+			return fmt.Errorf("setting password hash: %w", err)
+		}
+
+		return svc.db.User().DeleteUserTokens(tx, msg.UserID)
+	})
+	if err == errInvalidPassword {
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+	if err != nil {
+		logger.WithField("UserID", msg.UserID).Warnf("changing password: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "change password failed")
+	}
+
+	return mq.ReplyOK(coormq.RespChangeUserPassword())
+}
+
+// 密码不正确时返回errInvalidPassword
+func (svc *Service) checkUserPassword(ctx mydb.SQLContext, userID cdssdk.UserID, password string) (model.User, error) {
+	user, err := svc.db.User().GetByID(ctx, userID)
+	if err == sql.ErrNoRows {
+		return model.User{}, errInvalidPassword
+	}
+	if err != nil {
+		return model.User{}, fmt.Errorf("getting user: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return model.User{}, errInvalidPassword
+	}
+
+	return user, nil
+}
+
+// 数据库中只保存凭证的哈希，数据库泄露时凭证不能被直接使用
+func hashUserToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
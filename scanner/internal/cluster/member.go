@@ -0,0 +1,435 @@
+package cluster
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/distlock"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
+)
+
+// 代表Leader的分片编号。只应该由一个实例执行的任务属于这个分片
+const LeaderShard = -1
+
+type Config struct {
+	// 分片的数量，所有实例的配置必须相同
+	ShardCount int `json:"shardCount"`
+	// 心跳以及调整分片所有权的间隔
+	HeartbeatSeconds int `json:"heartbeatSeconds"`
+	// 超过这个时间没有心跳的实例被认为已经下线
+	InstanceTimeoutSeconds int `json:"instanceTimeoutSeconds"`
+}
+
+// 多个Scanner实例组成的集群中的一员。
+//
+// Package和节点等按照ID被划分到固定数量的分片中，每个分片同一时间只属于一个实例，由这个实例负责扫描和执行分片内的任务。
+// 分片的所有权通过分布式锁获得，实例崩溃后锁会因为租约过期而被释放，然后由其他实例接手。
+// 获得锁之后还会在数据库中记录分片的租约和纪元，执行每个任务之前都会检查租约，任务的状态也只有当前纪元的拥有者才能修改，
+// 防止失去了分片但还没有察觉的实例继续执行任务。
+// 每个实例最多拥有“分片数/在线实例数”（向上取整）个分片，实例加入时，其他实例会在多出的分片上的任务都结束之后释放它们。
+// 另外还有一个Leader锁，获得它的实例负责执行不分片的任务
+type Member struct {
+	cfg        Config
+	instanceID string
+	db         *mydb.DB
+	distlock   *distlock.Service
+
+	lock      sync.Mutex
+	shards    map[int]*shardLease
+	leader    *shardLease
+	acquiring map[int]bool
+	fairShare int
+}
+
+type shardLease struct {
+	mutex *distlock.Mutex
+	epoch int64
+	// 最后一次成功续约之后租约的到期时间
+	expire time.Time
+	// 等待释放的分片不再开始新的任务，正在执行的任务都结束之后才会释放
+	draining bool
+	// 正在执行的任务数量
+	working int
+}
+
+func (l *shardLease) valid() bool {
+	return l != nil && !l.draining && time.Now().Before(l.expire)
+}
+
+func NewMember(cfg Config, db *mydb.DB, distlock *distlock.Service) *Member {
+	if cfg.ShardCount <= 0 {
+		cfg.ShardCount = 16
+	}
+	if cfg.HeartbeatSeconds <= 0 {
+		cfg.HeartbeatSeconds = 5
+	}
+	if cfg.InstanceTimeoutSeconds <= 0 {
+		cfg.InstanceTimeoutSeconds = 30
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &Member{
+		cfg:        cfg,
+		instanceID: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), rand.Int31()),
+		db:         db,
+		distlock:   distlock,
+		shards:     make(map[int]*shardLease),
+		acquiring:  make(map[int]bool),
+		fairShare:  cfg.ShardCount,
+	}
+}
+
+func (m *Member) InstanceID() string {
+	return m.instanceID
+}
+
+func (m *Member) ShardCount() int {
+	return m.cfg.ShardCount
+}
+
+// 计算一个ID属于哪个分片
+func (m *Member) ShardOf(id int64) int {
+	shard := int(id % int64(m.cfg.ShardCount))
+	if shard < 0 {
+		shard += m.cfg.ShardCount
+	}
+	return shard
+}
+
+// 判断本实例是否拥有这个分片，并且租约还没有过期，shard为LeaderShard时判断本实例是不是Leader。
+// 等待释放的分片不算被拥有
+func (m *Member) Owns(shard int) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.getLease(shard).valid()
+}
+
+func (m *Member) OwnsID(id int64) bool {
+	return m.Owns(m.ShardOf(id))
+}
+
+func (m *Member) IsLeader() bool {
+	return m.Owns(LeaderShard)
+}
+
+// 本实例拥有的分片，按编号从小到大排列，如果是Leader，则第一个是LeaderShard
+func (m *Member) OwnedShards() []int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var shards []int
+	if m.leader.valid() {
+		shards = append(shards, LeaderShard)
+	}
+	for shard, lease := range m.shards {
+		if lease.valid() {
+			shards = append(shards, shard)
+		}
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// 分片当前的纪元，本实例没有拥有这个分片时返回false
+func (m *Member) Epoch(shard int) (int64, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	lease := m.getLease(shard)
+	if !lease.valid() {
+		return 0, false
+	}
+	return lease.epoch, true
+}
+
+// 开始执行分片中的一个任务之前调用，会到数据库中检查租约是否依然有效，返回当前的纪元。
+// 返回true时必须在任务结束后调用EndWork，分片在所有任务结束之前不会被主动释放
+func (m *Member) BeginWork(shard int) (int64, bool) {
+	m.lock.Lock()
+	lease := m.getLease(shard)
+	if !lease.valid() {
+		m.lock.Unlock()
+		return 0, false
+	}
+	lease.working++
+	epoch := lease.epoch
+	m.lock.Unlock()
+
+	ok, err := m.db.ScannerShard().IsValid(m.db.SQLCtx(), shard, m.instanceID, epoch)
+	if err != nil {
+		logger.WithField("Cluster", m.instanceID).Warnf("checking lease of shard %d: %s", shard, err.Error())
+	}
+	if err != nil || !ok {
+		m.EndWork(shard, epoch)
+		return 0, false
+	}
+
+	return epoch, true
+}
+
+// 任务结束，epoch为BeginWork返回的纪元
+func (m *Member) EndWork(shard int, epoch int64) {
+	m.lock.Lock()
+	// 分片可能已经失去之后又重新获得了
+	lease := m.getLease(shard)
+	if lease == nil || lease.epoch != epoch {
+		m.lock.Unlock()
+		return
+	}
+
+	lease.working--
+	if !lease.draining || lease.working > 0 {
+		m.lock.Unlock()
+		return
+	}
+	m.detachShard(shard)
+	m.lock.Unlock()
+
+	m.releaseLease(shard, lease)
+}
+
+// 实例已经下线的判断标准，在这个时间之后有过心跳的实例是在线的
+func (m *Member) AliveAfter() time.Time {
+	return time.Now().Add(-time.Duration(m.cfg.InstanceTimeoutSeconds) * time.Second)
+}
+
+func (m *Member) Serve() {
+	logger.Infof("scanner instance %s started", m.instanceID)
+
+	ticker := time.NewTicker(time.Duration(m.cfg.HeartbeatSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		m.balance()
+		<-ticker.C
+	}
+}
+
+func (m *Member) leaseDuration() time.Duration {
+	return time.Duration(m.cfg.InstanceTimeoutSeconds) * time.Second
+}
+
+// 发送心跳并为拥有的分片续约，然后根据在线实例的数量，释放多出的分片或者尝试获取更多的分片
+func (m *Member) balance() {
+	log := logger.WithField("Cluster", m.instanceID)
+
+	err := m.db.ScannerInstance().Heartbeat(m.db.SQLCtx(), m.instanceID)
+	if err != nil {
+		// 心跳失败时其他实例可能认为本实例已经下线，但分片的所有权依然由锁和租约来保证，所以继续执行
+		log.Warnf("sending heartbeat: %s", err.Error())
+	}
+
+	m.renewLeases()
+
+	alive, err := m.db.ScannerInstance().CountAlive(m.db.SQLCtx(), m.AliveAfter())
+	if err != nil {
+		log.Warnf("counting alive instances: %s", err.Error())
+		return
+	}
+	if alive < 1 {
+		alive = 1
+	}
+	fairShare := (m.cfg.ShardCount + alive - 1) / alive
+
+	// 释放租约需要访问数据库和锁服务，所以先记下来，在m.lock之外进行
+	releasings := make(map[int]*shardLease)
+
+	m.lock.Lock()
+	m.fairShare = fairShare
+
+	var owned []int
+	for shard, lease := range m.shards {
+		if !lease.draining {
+			owned = append(owned, shard)
+		}
+	}
+
+	// 多出的分片中编号最大的几个不再开始新的任务，等任务都结束之后再释放，交给新加入的实例
+	if len(owned) > fairShare {
+		sort.Sort(sort.Reverse(sort.IntSlice(owned)))
+
+		for _, shard := range owned[:len(owned)-fairShare] {
+			lease := m.shards[shard]
+			lease.draining = true
+			if lease.working == 0 {
+				m.detachShard(shard)
+				releasings[shard] = lease
+			} else {
+				log.Infof("shard %d is draining, %d jobs running", shard, lease.working)
+			}
+		}
+		owned = owned[len(owned)-fairShare:]
+	}
+
+	var candidates []int
+	for shard := 0; shard < m.cfg.ShardCount; shard++ {
+		if m.shards[shard] == nil && !m.acquiring[shard] {
+			candidates = append(candidates, shard)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+
+	want := fairShare - len(owned)
+	if want > len(candidates) {
+		want = len(candidates)
+	}
+	if want < 0 {
+		want = 0
+	}
+	for _, shard := range candidates[:want] {
+		m.acquiring[shard] = true
+		go m.acquire(shard)
+	}
+
+	if m.leader == nil && !m.acquiring[LeaderShard] {
+		m.acquiring[LeaderShard] = true
+		go m.acquire(LeaderShard)
+	}
+	m.lock.Unlock()
+
+	for shard, lease := range releasings {
+		m.releaseLease(shard, lease)
+	}
+
+	if m.IsLeader() {
+		// 清理很久没有心跳的实例记录
+		err := m.db.ScannerInstance().DeleteDead(m.db.SQLCtx(), time.Now().Add(-10*time.Duration(m.cfg.InstanceTimeoutSeconds)*time.Second))
+		if err != nil {
+			log.Warnf("deleting dead instances: %s", err.Error())
+		}
+	}
+}
+
+// 为所有拥有的分片续约。分片已经被其他实例获得时立刻放弃，续约失败时租约会在到期后自动失效
+func (m *Member) renewLeases() {
+	log := logger.WithField("Cluster", m.instanceID)
+
+	m.lock.Lock()
+	leases := make(map[int]*shardLease, len(m.shards)+1)
+	for shard, lease := range m.shards {
+		leases[shard] = lease
+	}
+	if m.leader != nil {
+		leases[LeaderShard] = m.leader
+	}
+	m.lock.Unlock()
+
+	for shard, lease := range leases {
+		expire := time.Now().Add(m.leaseDuration())
+		ok, err := m.db.ScannerShard().Renew(m.db.SQLCtx(), shard, m.instanceID, lease.epoch, expire)
+		if err != nil {
+			log.Warnf("renewing lease of shard %d: %s", shard, err.Error())
+			continue
+		}
+
+		m.lock.Lock()
+		// 续约期间分片可能已经被释放了
+		dropped := false
+		if m.getLease(shard) == lease {
+			if ok {
+				lease.expire = expire
+			} else {
+				log.Warnf("shard %d has been taken by other instance", shard)
+				m.detachShard(shard)
+				dropped = true
+			}
+		}
+		m.lock.Unlock()
+
+		if dropped {
+			lease.mutex.Unlock()
+		}
+	}
+}
+
+func (m *Member) getLease(shard int) *shardLease {
+	if shard == LeaderShard {
+		return m.leader
+	}
+	return m.shards[shard]
+}
+
+// 不再认为拥有这个分片，必须在持有m.lock时调用。之后还需要在m.lock之外释放分片的锁
+func (m *Member) detachShard(shard int) {
+	if shard == LeaderShard {
+		m.leader = nil
+	} else {
+		delete(m.shards, shard)
+	}
+}
+
+// 放弃已经detach的分片的租约并释放锁，不能在持有m.lock时调用
+func (m *Member) releaseLease(shard int, lease *shardLease) {
+	err := m.db.ScannerShard().Release(m.db.SQLCtx(), shard, m.instanceID, lease.epoch)
+	if err != nil {
+		logger.WithField("Cluster", m.instanceID).Warnf("releasing lease of shard %d: %s", shard, err.Error())
+	}
+	lease.mutex.Unlock()
+	logger.WithField("Cluster", m.instanceID).Infof("shard %d released", shard)
+}
+
+func (m *Member) acquire(shard int) {
+	log := logger.WithField("Cluster", m.instanceID)
+
+	builder := reqbuilder.NewBuilder()
+	if shard == LeaderShard {
+		builder.Scanner().Leader()
+	} else {
+		builder.Scanner().Shard(shard)
+	}
+
+	// 锁被其他实例持有时会失败，下一轮再尝试
+	mutex, err := builder.MutexLock(m.distlock)
+	if err != nil {
+		m.lock.Lock()
+		delete(m.acquiring, shard)
+		m.lock.Unlock()
+		return
+	}
+
+	m.lock.Lock()
+	// 等待锁的期间可能有新的实例加入，此时已经不需要这个分片了
+	if shard != LeaderShard && len(m.shards) >= m.fairShare {
+		delete(m.acquiring, shard)
+		m.lock.Unlock()
+		mutex.Unlock()
+		return
+	}
+	m.lock.Unlock()
+
+	expire := time.Now().Add(m.leaseDuration())
+	epoch, err := m.db.ScannerShard().Claim(m.db.SQLCtx(), shard, m.instanceID, expire)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.acquiring, shard)
+	if err != nil {
+		log.Warnf("claiming lease of shard %d: %s", shard, err.Error())
+		mutex.Unlock()
+		return
+	}
+
+	lease := &shardLease{
+		mutex:  mutex,
+		epoch:  epoch,
+		expire: expire,
+	}
+
+	if shard == LeaderShard {
+		m.leader = lease
+		log.Infof("became leader, epoch %d", epoch)
+		return
+	}
+
+	m.shards[shard] = lease
+	log.Infof("shard %d acquired, epoch %d", shard, epoch)
+}
@@ -6,15 +6,23 @@ import (
 	c "gitlink.org.cn/cloudream/common/utils/config"
 	db "gitlink.org.cn/cloudream/storage/common/pkgs/db/config"
 	stgmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/cluster"
 )
 
 type Config struct {
-	ECFileSizeThreshold    int64           `json:"ecFileSizeThreshold"`
-	NodeUnavailableSeconds int             `json:"nodeUnavailableSeconds"` // 如果节点上次上报时间超过这个值，则认为节点已经不可用
-	Logger                 log.Config      `json:"logger"`
-	DB                     db.Config       `json:"db"`
-	RabbitMQ               stgmq.Config    `json:"rabbitMQ"`
-	DistLock               distlock.Config `json:"distlock"`
+	ECFileSizeThreshold         int64           `json:"ecFileSizeThreshold"`
+	NodeUnavailableSeconds      int             `json:"nodeUnavailableSeconds"`      // 如果节点上次上报时间超过这个值，则认为节点已经不可用
+	UploadSessionTimeoutSeconds int             `json:"uploadSessionTimeoutSeconds"` // 分段上传会话超过这个时间没有活动，则会被清理
+	NodeRepairGraceSeconds      int             `json:"nodeRepairGraceSeconds"`      // 节点不可用超过这个时间后，开始修复节点上的数据
+	NodeRepairMaxRetry          int             `json:"nodeRepairMaxRetry"`          // 修复有对象失败时，最多重试的次数。每次重试前同样会等待宽限期
+	ScrubSampleCount            int             `json:"scrubSampleCount"`            // 每次巡检一个Package时抽查的对象数量
+	ScrubNodeBytesPerSecond     int64           `json:"scrubNodeBytesPerSecond"`     // 巡检时每个节点每秒最多被读取的数据量，为0代表不限制
+	PolicyFile                  string          `json:"policyFile"`                  // 调度策略文件，相对路径从程序所在目录开始，为空时使用默认策略
+	Cluster                     cluster.Config  `json:"cluster"`                     // 多个Scanner实例之间划分工作的方式
+	Logger                      log.Config      `json:"logger"`
+	DB                          db.Config       `json:"db"`
+	RabbitMQ                    stgmq.Config    `json:"rabbitMQ"`
+	DistLock                    distlock.Config `json:"distlock"`
 }
 
 var cfg Config
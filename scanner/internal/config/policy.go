@@ -0,0 +1,251 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/redpolicy"
+)
+
+const (
+	// 没有配置执行间隔的定时任务使用的间隔
+	DefaultTickIntervalSeconds = 5 * 60
+	// 检查策略文件是否有变化的间隔
+	policyCheckInterval = 10 * time.Second
+)
+
+// Scanner的调度策略，修改策略文件后会自动重新加载，不需要重启Scanner
+type Policy struct {
+	// 定时任务的调度策略，键为定时任务的类型名，比如BatchCheckPackageRedundancy
+	Ticks map[string]TickPolicy `json:"ticks"`
+	// 每个节点上的修改冗余策略、GC等任务的资源额度
+	NodeBudget NodeBudgetPolicy `json:"nodeBudget"`
+	// 对象冗余策略的选择规则。为null时使用默认规则
+	Redundancy *redpolicy.Config `json:"redundancy"`
+	// 为true时，定期检查只为Package生成等待批准的冗余策略修改计划，批准之后才会执行
+	RequireRedundancyApproval bool `json:"requireRedundancyApproval"`
+
+	redundancy *redpolicy.RuleBased
+}
+
+type TickPolicy struct {
+	// 执行间隔，为0时使用默认值
+	IntervalSeconds int `json:"intervalSeconds"`
+	// 允许执行的时间段，格式见TimeWindow，满足任意一个即可。为null时使用默认值，为空数组代表任何时间都可以执行
+	Windows []string `json:"windows"`
+	// 每次扫描的数量，为0时使用默认值
+	BatchSize int `json:"batchSize"`
+	// 暂停这个定时任务
+	Disabled bool `json:"disabled"`
+
+	windows []TimeWindow
+}
+
+// 判断此时是否可以执行定时任务
+func (p *TickPolicy) InWindow(t time.Time) bool {
+	if len(p.windows) == 0 {
+		return true
+	}
+
+	for _, w := range p.windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *TickPolicy) Interval() time.Duration {
+	if p.IntervalSeconds <= 0 {
+		return DefaultTickIntervalSeconds * time.Second
+	}
+	return time.Duration(p.IntervalSeconds) * time.Second
+}
+
+type NodeBudgetPolicy struct {
+	// 每个节点上同时进行的任务数量，为0代表不限制
+	MaxConcurrentTasks int `json:"maxConcurrentTasks"`
+	// 修改冗余策略时每个节点每秒最多写入的数据量，为0代表不限制。紧急任务不受此限制
+	RedundancyBytesPerSecond int64 `json:"redundancyBytesPerSecond"`
+}
+
+// 查询定时任务的调度策略，没有配置的任务使用默认策略
+func (p *Policy) Tick(name string) TickPolicy {
+	if t, ok := p.Ticks[name]; ok {
+		return t
+	}
+	return TickPolicy{}
+}
+
+func (p *Policy) RedundancyPolicy() redpolicy.Policy {
+	return p.redundancy
+}
+
+func DefaultPolicy() *Policy {
+	// 读取大量数据的任务只在凌晨进行
+	nightly := []string{"* 0-6 * * *"}
+
+	p := &Policy{
+		Ticks: map[string]TickPolicy{
+			"BatchCheckPackageRedundancy": {Windows: nightly},
+			"BatchCleanPinned":            {Windows: nightly},
+			"BatchScrubPackage":           {Windows: nightly},
+			"CleanScannerJob":             {IntervalSeconds: 60 * 60},
+		},
+		// 没有冗余的对象和LRC对象都使用默认参数的LRC，其他对象保持不变
+		Redundancy: &redpolicy.Config{
+			Rules: []redpolicy.Rule{
+				{
+					Sources:    []string{redpolicy.TypeNone, redpolicy.TypeLRC},
+					Redundancy: redpolicy.Spec{Type: redpolicy.TypeLRC},
+				},
+			},
+		},
+	}
+	// 默认策略里的表达式都是合法的
+	p.parse()
+	return p
+}
+
+var policy atomic.Pointer[Policy]
+
+func CurrentPolicy() *Policy {
+	p := policy.Load()
+	if p == nil {
+		return DefaultPolicy()
+	}
+	return p
+}
+
+// 加载调度策略，并在策略文件变化时重新加载。没有配置策略文件时使用默认策略
+func InitPolicy() error {
+	policy.Store(DefaultPolicy())
+	if cfg.PolicyFile == "" {
+		return nil
+	}
+
+	path := cfg.PolicyFile
+	if !filepath.IsAbs(path) {
+		// 与配置文件一样，相对路径从程序所在目录开始
+		exe, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("getting executable path: %w", err)
+		}
+		path = filepath.Join(filepath.Dir(exe), path)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("stating policy file: %w", err)
+	}
+
+	p, err := LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+	policy.Store(p)
+
+	go watchPolicy(path, info.ModTime())
+	return nil
+}
+
+// 从文件中读取调度策略，文件中没有配置的项使用默认值
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy file: %w", err)
+	}
+
+	var p Policy
+	err = serder.JSONToObject(data, &p)
+	if err != nil {
+		return nil, fmt.Errorf("decoding policy file: %w", err)
+	}
+
+	def := DefaultPolicy()
+	if p.Ticks == nil {
+		p.Ticks = make(map[string]TickPolicy)
+	}
+	for name, d := range def.Ticks {
+		t, ok := p.Ticks[name]
+		if !ok {
+			p.Ticks[name] = d
+			continue
+		}
+
+		if t.IntervalSeconds == 0 {
+			t.IntervalSeconds = d.IntervalSeconds
+		}
+		if t.Windows == nil {
+			t.Windows = d.Windows
+		}
+		if t.BatchSize == 0 {
+			t.BatchSize = d.BatchSize
+		}
+		p.Ticks[name] = t
+	}
+
+	if p.Redundancy == nil {
+		p.Redundancy = def.Redundancy
+	}
+
+	err = p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *Policy) parse() error {
+	for name, t := range p.Ticks {
+		t.windows = nil
+		for _, expr := range t.Windows {
+			w, err := ParseTimeWindow(expr)
+			if err != nil {
+				return fmt.Errorf("tick %s: %w", name, err)
+			}
+			t.windows = append(t.windows, w)
+		}
+		p.Ticks[name] = t
+	}
+
+	red, err := redpolicy.NewRuleBased(*p.Redundancy)
+	if err != nil {
+		return fmt.Errorf("redundancy: %w", err)
+	}
+	p.redundancy = red
+	return nil
+}
+
+func watchPolicy(path string, modTime time.Time) {
+	ticker := time.NewTicker(policyCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil {
+			logger.Warnf("stating policy file: %s", err.Error())
+			continue
+		}
+		if info.ModTime().Equal(modTime) {
+			continue
+		}
+		modTime = info.ModTime()
+
+		// 新的策略有错误时继续使用原来的策略
+		p, err := LoadPolicy(path)
+		if err != nil {
+			logger.Warnf("reloading policy, the old one will still be used: %s", err.Error())
+			continue
+		}
+
+		policy.Store(p)
+		logger.Infof("policy reloaded")
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func Test_TimeWindow(t *testing.T) {
+	Convey("每天凌晨0点到6点59分", t, func() {
+		w, err := ParseTimeWindow("* 0-6 * * *")
+		So(err, ShouldBeNil)
+
+		So(w.Contains(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)), ShouldBeTrue)
+		So(w.Contains(time.Date(2024, 3, 1, 6, 59, 0, 0, time.Local)), ShouldBeTrue)
+		So(w.Contains(time.Date(2024, 3, 1, 7, 0, 0, 0, time.Local)), ShouldBeFalse)
+		So(w.Contains(time.Date(2024, 3, 1, 23, 30, 0, 0, time.Local)), ShouldBeFalse)
+	})
+
+	Convey("列表、步长和周日", t, func() {
+		// 2024-03-03是周日
+		w, err := ParseTimeWindow("*/15 1,22-23 * * 7")
+		So(err, ShouldBeNil)
+
+		So(w.Contains(time.Date(2024, 3, 3, 1, 30, 0, 0, time.Local)), ShouldBeTrue)
+		So(w.Contains(time.Date(2024, 3, 3, 22, 45, 0, 0, time.Local)), ShouldBeTrue)
+		So(w.Contains(time.Date(2024, 3, 3, 22, 46, 0, 0, time.Local)), ShouldBeFalse)
+		So(w.Contains(time.Date(2024, 3, 4, 1, 30, 0, 0, time.Local)), ShouldBeFalse)
+	})
+
+	Convey("非法的表达式", t, func() {
+		_, err := ParseTimeWindow("* 0-6 * *")
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseTimeWindow("* 0-24 * * *")
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseTimeWindow("* 6-0 * * *")
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseTimeWindow("*/0 * * * *")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func Test_LoadPolicy(t *testing.T) {
+	Convey("文件中没有配置的项使用默认值", t, func() {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		err := os.WriteFile(path, []byte(`{
+			"ticks": {
+				"BatchCheckPackageRedundancy": {"batchSize": 10},
+				"BatchCleanPinned": {"windows": []},
+				"CheckAgentState": {"intervalSeconds": 60, "windows": ["* 8-20 * * 1-5"]}
+			}
+		}`), 0644)
+		So(err, ShouldBeNil)
+
+		p, err := LoadPolicy(path)
+		So(err, ShouldBeNil)
+
+		night := time.Date(2024, 3, 1, 3, 0, 0, 0, time.Local)
+		noon := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+
+		red := p.Tick("BatchCheckPackageRedundancy")
+		So(red.BatchSize, ShouldEqual, 10)
+		So(red.InWindow(night), ShouldBeTrue)
+		So(red.InWindow(noon), ShouldBeFalse)
+
+		pinned := p.Tick("BatchCleanPinned")
+		So(pinned.InWindow(noon), ShouldBeTrue)
+
+		state := p.Tick("CheckAgentState")
+		So(state.Interval(), ShouldEqual, time.Minute)
+		So(state.InWindow(noon), ShouldBeTrue)
+		So(state.InWindow(night), ShouldBeFalse)
+
+		So(p.Tick("CleanScannerJob").Interval(), ShouldEqual, time.Hour)
+		So(p.Tick("Unknown").Interval(), ShouldEqual, DefaultTickIntervalSeconds*time.Second)
+
+		// 没有配置冗余策略的规则时使用默认规则
+		So(p.Redundancy, ShouldResemble, DefaultPolicy().Redundancy)
+		So(p.RedundancyPolicy(), ShouldNotBeNil)
+	})
+
+	Convey("表达式错误时加载失败", t, func() {
+		path := filepath.Join(t.TempDir(), "policy.json")
+		err := os.WriteFile(path, []byte(`{"ticks": {"BatchScrubPackage": {"windows": ["0-6"]}}}`), 0644)
+		So(err, ShouldBeNil)
+
+		_, err = LoadPolicy(path)
+		So(err, ShouldNotBeNil)
+
+		err = os.WriteFile(path, []byte(`{"redundancy": {"default": {"type": "ec", "k": 4, "n": 2}}}`), 0644)
+		So(err, ShouldBeNil)
+
+		_, err = LoadPolicy(path)
+		So(err, ShouldNotBeNil)
+	})
+}
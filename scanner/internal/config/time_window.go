@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 类似cron的时间段表达式，由“分 时 日 月 周”五个字段组成，时间点的各个部分都与对应字段匹配时，即处于时间段内。
+// 每个字段可以是*、数字、范围（1-5）、带步长的范围（*/2、1-10/3）以及用逗号分隔的多个以上内容。
+// 周的取值为0-6，0代表周日，也可以用7代表周日。比如“* 0-6 * * *”代表每天的0点到6点59分
+type TimeWindow struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+}
+
+type timeWindowField struct {
+	min int
+	max int
+}
+
+var timeWindowFields = []timeWindowField{
+	{0, 59}, // 分
+	{0, 23}, // 时
+	{1, 31}, // 日
+	{1, 12}, // 月
+	{0, 7},  // 周
+}
+
+func ParseTimeWindow(expr string) (TimeWindow, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(timeWindowFields) {
+		return TimeWindow{}, fmt.Errorf("time window %q should have %d fields", expr, len(timeWindowFields))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		b, err := parseTimeWindowField(part, timeWindowFields[i])
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("time window %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+
+	// 7和0都代表周日
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return TimeWindow{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+	}, nil
+}
+
+func (w TimeWindow) Contains(t time.Time) bool {
+	return w.minute&(1<<t.Minute()) != 0 &&
+		w.hour&(1<<t.Hour()) != 0 &&
+		w.dom&(1<<t.Day()) != 0 &&
+		w.month&(1<<int(t.Month())) != 0 &&
+		w.dow&(1<<int(t.Weekday())) != 0
+}
+
+func parseTimeWindowField(str string, field timeWindowField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(str, ",") {
+		rng := item
+		step := 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			s, err := strconv.Atoi(item[idx+1:])
+			if err != nil || s <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			rng = item[:idx]
+			step = s
+		}
+
+		begin, end := field.min, field.max
+		if rng != "*" {
+			if idx := strings.Index(rng, "-"); idx >= 0 {
+				b, err1 := strconv.Atoi(rng[:idx])
+				e, err2 := strconv.Atoi(rng[idx+1:])
+				if err1 != nil || err2 != nil {
+					return 0, fmt.Errorf("invalid range %q", item)
+				}
+				begin, end = b, e
+			} else {
+				v, err := strconv.Atoi(rng)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", item)
+				}
+				begin = v
+				// 单个数字加上步长代表从这个数字开始到最大值
+				if step == 1 {
+					end = v
+				}
+			}
+		}
+
+		if begin < field.min || end > field.max || begin > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", item, field.min, field.max)
+		}
+
+		for v := begin; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
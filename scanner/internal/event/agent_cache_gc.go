@@ -8,6 +8,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
 
@@ -48,6 +49,9 @@ func (t *AgentCacheGC) Execute(execCtx ExecuteContext) {
 
 	// TODO unavailable的节点需不需要发送任务？
 
+	release := budget.Acquire([]cdssdk.NodeID{t.NodeID}, PriorityRoutine)
+	defer release()
+
 	mutex, err := reqbuilder.NewBuilder().
 		// 进行GC
 		IPFS().GC(t.NodeID).
@@ -58,6 +62,7 @@ func (t *AgentCacheGC) Execute(execCtx ExecuteContext) {
 	}
 	defer mutex.Unlock()
 
+	// 克隆出来的Package的对象有自己的编码块记录，因此与原Package共用的文件只要还有一条记录引用，就会被保留
 	var allFileHashes []string
 	err = execCtx.Args.DB.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
 		blocks, err := execCtx.Args.DB.ObjectBlock().GetByNodeID(tx, t.NodeID)
@@ -76,6 +81,24 @@ func (t *AgentCacheGC) Execute(execCtx ExecuteContext) {
 			allFileHashes = append(allFileHashes, o.FileHash)
 		}
 
+		// 对象的历史版本也需要保留
+		verBlocks, err := execCtx.Args.DB.ObjectVersion().GetBlocksByNodeID(tx, t.NodeID)
+		if err != nil {
+			return fmt.Errorf("getting object version blocks by node id: %w", err)
+		}
+		for _, b := range verBlocks {
+			allFileHashes = append(allFileHashes, b.FileHash)
+		}
+
+		// 分段上传会话中暂存的分段也不能被清除
+		parts, err := execCtx.Args.DB.UploadSession().GetPartsByNodeID(tx, t.NodeID)
+		if err != nil {
+			return fmt.Errorf("getting upload session parts by node id: %w", err)
+		}
+		for _, p := range parts {
+			allFileHashes = append(allFileHashes, p.FileHash)
+		}
+
 		return nil
 	})
 	if err != nil {
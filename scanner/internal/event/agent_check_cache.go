@@ -69,6 +69,8 @@ func (t *AgentCheckCache) Execute(execCtx ExecuteContext) {
 		t.checkPinnedObject(execCtx, tx, realFileHashes)
 
 		t.checkObjectBlock(execCtx, tx, realFileHashes)
+
+		t.checkObjectVersionBlock(execCtx, tx, realFileHashes)
 		return nil
 	})
 }
@@ -167,6 +169,32 @@ func (t *AgentCheckCache) checkObjectBlock(execCtx ExecuteContext, tx *sqlx.Tx,
 	}
 }
 
+// 对比ObjectVersionBlock表，少了则将历史版本标记为已损坏
+func (t *AgentCheckCache) checkObjectVersionBlock(execCtx ExecuteContext, tx *sqlx.Tx, realFileHashes map[string]bool) {
+	log := logger.WithType[AgentCheckCache]("Event")
+
+	blocks, err := execCtx.Args.DB.ObjectVersion().GetBlocksByNodeID(tx, t.NodeID)
+	if err != nil {
+		log.WithField("NodeID", t.NodeID).Warnf("getting object version blocks by node id: %s", err.Error())
+		return
+	}
+
+	var losts []string
+	for _, b := range blocks {
+		if realFileHashes[b.FileHash] {
+			continue
+		}
+		losts = append(losts, b.FileHash)
+	}
+
+	if len(losts) > 0 {
+		err = execCtx.Args.DB.ObjectVersion().MarkDegradedByNodeFiles(tx, t.NodeID, lo.Uniq(losts))
+		if err != nil {
+			log.Warnf("marking object versions degraded: %s", err.Error())
+		}
+	}
+}
+
 func init() {
 	RegisterMessageConvertor(NewAgentCheckCache)
 }
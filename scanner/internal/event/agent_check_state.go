@@ -6,6 +6,7 @@ import (
 
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	"gitlink.org.cn/cloudream/storage/common/consts"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	agtmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/agent"
@@ -64,7 +65,9 @@ func (t *AgentCheckState) Execute(execCtx ExecuteContext) {
 			err := execCtx.Args.DB.Node().UpdateState(execCtx.Args.DB.SQLCtx(), t.NodeID, consts.NodeStateUnavailable)
 			if err != nil {
 				log.WithField("NodeID", t.NodeID).Warnf("set node state failed, err: %s", err.Error())
+				return
 			}
+			t.onNodeUnavailable(execCtx, node)
 		}
 		return
 	}
@@ -76,7 +79,9 @@ func (t *AgentCheckState) Execute(execCtx ExecuteContext) {
 		err := execCtx.Args.DB.Node().UpdateState(execCtx.Args.DB.SQLCtx(), t.NodeID, consts.NodeStateUnavailable)
 		if err != nil {
 			log.WithField("NodeID", t.NodeID).Warnf("change node state failed, err: %s", err.Error())
+			return
 		}
+		t.onNodeUnavailable(execCtx, node)
 		return
 	}
 
@@ -84,6 +89,25 @@ func (t *AgentCheckState) Execute(execCtx ExecuteContext) {
 	err = execCtx.Args.DB.Node().UpdateState(execCtx.Args.DB.SQLCtx(), t.NodeID, consts.NodeStateNormal)
 	if err != nil {
 		log.WithField("NodeID", t.NodeID).Warnf("change node state failed, err: %s", err.Error())
+		return
+	}
+
+	// 节点在宽限期内恢复了，不需要再修复它上面的数据
+	err = execCtx.Args.DB.NodeRepair().DeleteWaiting(execCtx.Args.DB.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.WithField("NodeID", t.NodeID).Warnf("canceling node repair failed, err: %s", err.Error())
+	}
+}
+
+// 节点刚变为不可用时，创建修复任务。修复会在不可用的时间超过宽限期后由RepairNode执行
+func (t *AgentCheckState) onNodeUnavailable(execCtx ExecuteContext, node cdssdk.Node) {
+	if node.State == consts.NodeStateUnavailable {
+		return
+	}
+
+	err := execCtx.Args.DB.NodeRepair().Start(execCtx.Args.DB.SQLCtx(), t.NodeID, time.Now())
+	if err != nil {
+		logger.WithType[AgentCheckState]("Event").WithField("NodeID", t.NodeID).Warnf("creating node repair failed, err: %s", err.Error())
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
 
@@ -67,6 +68,9 @@ func (t *AgentStorageGC) Execute(execCtx ExecuteContext) {
 		return
 	}
 
+	release := budget.Acquire([]cdssdk.NodeID{getStg.NodeID}, PriorityRoutine)
+	defer release()
+
 	agtCli, err := stgglb.AgentMQPool.Acquire(getStg.NodeID)
 	if err != nil {
 		log.WithField("NodeID", getStg.NodeID).Warnf("create agent client failed, err: %s", err.Error())
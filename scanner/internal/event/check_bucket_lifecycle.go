@@ -0,0 +1,114 @@
+package event
+
+import (
+	"time"
+
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+)
+
+const ExpireObjectBatchSize = 500
+
+// 执行桶的生命周期规则中的Expire和Unpin规则。Transition规则由CheckPackageRedundancy在选择冗余策略时执行
+type CheckBucketLifecycle struct {
+	*scevt.CheckBucketLifecycle
+}
+
+func NewCheckBucketLifecycle(evt *scevt.CheckBucketLifecycle) *CheckBucketLifecycle {
+	return &CheckBucketLifecycle{
+		CheckBucketLifecycle: evt,
+	}
+}
+
+func (t *CheckBucketLifecycle) TryMerge(other Event) bool {
+	event, ok := other.(*CheckBucketLifecycle)
+	if !ok {
+		return false
+	}
+
+	return event.BucketID == t.BucketID
+}
+
+func (t *CheckBucketLifecycle) Execute(execCtx ExecuteContext) {
+	log := logger.WithType[CheckBucketLifecycle]("Event")
+	startTime := time.Now()
+	log.Debugf("begin with %v", logger.FormatStruct(t.CheckBucketLifecycle))
+	defer func() {
+		log.Debugf("end, time: %v", time.Since(startTime))
+	}()
+
+	bkt, err := execCtx.Args.DB.Bucket().GetByID(execCtx.Args.DB.SQLCtx(), t.BucketID)
+	if err != nil {
+		log.Warnf("getting bucket: %s", err.Error())
+		return
+	}
+
+	rules, err := execCtx.Args.DB.Lifecycle().GetByBucketID(execCtx.Args.DB.SQLCtx(), t.BucketID)
+	if err != nil {
+		log.Warnf("getting lifecycle rules: %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, rule := range rules {
+		switch rule.Action {
+		case model.LifecycleActionExpire:
+			t.expireObjects(execCtx, bkt, rule, now)
+
+		case model.LifecycleActionUnpin:
+			cnt, err := execCtx.Args.DB.Lifecycle().DeletePinnedObjectsBefore(execCtx.Args.DB.SQLCtx(), t.BucketID, rule.PathPrefix, rule.Before(now))
+			if err != nil {
+				log.WithField("RuleID", rule.RuleID).Warnf("deleting pinned objects: %s", err.Error())
+				continue
+			}
+			if cnt > 0 {
+				log.WithField("RuleID", rule.RuleID).Infof("%d pinned objects removed", cnt)
+			}
+		}
+	}
+}
+
+// 删除对象使用与用户删除对象相同的接口，使得开启了多版本的Package能保留对象的历史版本
+func (t *CheckBucketLifecycle) expireObjects(execCtx ExecuteContext, bkt cdssdk.Bucket, rule model.LifecycleRule, now time.Time) {
+	log := logger.WithType[CheckBucketLifecycle]("Event").WithField("RuleID", rule.RuleID)
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		log.Warnf("new coordinator client: %s", err.Error())
+		return
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	for {
+		objs, err := execCtx.Args.DB.Lifecycle().BatchGetObjectsBefore(execCtx.Args.DB.SQLCtx(), t.BucketID, rule.PathPrefix, rule.Before(now), ExpireObjectBatchSize)
+		if err != nil {
+			log.Warnf("getting expired objects: %s", err.Error())
+			return
+		}
+		if len(objs) == 0 {
+			return
+		}
+
+		objIDs := lo.Map(objs, func(obj cdssdk.Object, idx int) cdssdk.ObjectID { return obj.ObjectID })
+		_, err = coorCli.DeleteObjects(coormq.ReqDeleteObjects(bkt.CreatorID, objIDs))
+		if err != nil {
+			log.Warnf("requesting to delete objects: %s", err.Error())
+			return
+		}
+
+		log.Infof("%d objects expired", len(objs))
+
+		if len(objs) < ExpireObjectBatchSize {
+			return
+		}
+	}
+}
+
+func init() {
+	RegisterMessageConvertor(NewCheckBucketLifecycle)
+}
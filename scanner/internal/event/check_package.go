@@ -3,6 +3,7 @@ package event
 import (
 	"github.com/samber/lo"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
 )
 
@@ -36,6 +37,17 @@ func (t *CheckPackage) Execute(execCtx ExecuteContext) {
 		if err != nil {
 			log.WithField("PackageID", objID).Warnf("delete unused package failed, err: %s", err.Error())
 		}
+
+		// 顺便校正正常状态的Package的用量计数，也用于给旧数据补上计数
+		pkg, err := execCtx.Args.DB.Package().GetByID(execCtx.Args.DB.SQLCtx(), objID)
+		if err != nil || pkg.State != cdssdk.PackageStateNormal {
+			continue
+		}
+
+		err = execCtx.Args.DB.Quota().RefreshPackageUsage(execCtx.Args.DB.SQLCtx(), objID)
+		if err != nil {
+			log.WithField("PackageID", objID).Warnf("refresh package usage failed, err: %s", err.Error())
+		}
 	}
 }
 
@@ -2,10 +2,13 @@ package event
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"reflect"
 	"strconv"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/samber/lo"
 	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
@@ -13,6 +16,8 @@ import (
 	"gitlink.org.cn/cloudream/common/utils/sort2"
 	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
 	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
 	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
@@ -22,6 +27,7 @@ import (
 	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
 	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/redpolicy"
 )
 
 const (
@@ -51,7 +57,13 @@ func (t *CheckPackageRedundancy) TryMerge(other Event) bool {
 		return false
 	}
 
-	return event.PackageID == t.PackageID
+	if event.PackageID != t.PackageID {
+		return false
+	}
+
+	// 只要有一个不是定期检查发起的，合并后的任务就直接修改
+	t.Routine = t.Routine && event.Routine
+	return true
 }
 
 func (t *CheckPackageRedundancy) Execute(execCtx ExecuteContext) {
@@ -62,36 +74,115 @@ func (t *CheckPackageRedundancy) Execute(execCtx ExecuteContext) {
 		log.Debugf("end, time: %v", time.Since(startTime))
 	}()
 
+	err := t.ExecuteJob(execCtx)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+}
+
+func (t *CheckPackageRedundancy) ExecuteJob(execCtx ExecuteContext) error {
+	decs, err := t.decide(execCtx.Args.DB)
+	if err != nil {
+		return err
+	}
+
+	if t.Routine && config.CurrentPolicy().RequireRedundancyApproval {
+		return t.savePlan(execCtx.Args.DB, decs)
+	}
+
+	_, err = t.applyDecisions(execCtx, decs)
+	return err
+}
+
+// 将修改计划保存为等待批准的计划，替换掉Package之前还没有批准的计划
+func (t *CheckPackageRedundancy) savePlan(db *mydb.DB, decs *redundancyDecisions) error {
+	plan := decs.toPlan(t.PackageID)
+
+	return db.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		err := db.RedundancyPlan().DeletePendingByPackageID(tx, t.PackageID)
+		if err != nil {
+			return fmt.Errorf("deleting pending plans: %w", err)
+		}
+
+		if plan.ObjectCount == 0 {
+			return nil
+		}
+
+		_, err = db.RedundancyPlan().Create(tx, plan)
+		if err != nil {
+			return fmt.Errorf("creating plan: %w", err)
+		}
+
+		return nil
+	})
+}
+
+// 只计算冗余策略的修改计划，不进行修改
+func PlanPackageRedundancy(db *mydb.DB, packageID cdssdk.PackageID) (model.RedundancyPlan, error) {
+	t := NewCheckPackageRedundancy(scevt.NewCheckPackageRedundancy(packageID))
+	decs, err := t.decide(db)
+	if err != nil {
+		return model.RedundancyPlan{}, err
+	}
+
+	return decs.toPlan(packageID), nil
+}
+
+// 为Package中的对象选择的新冗余策略
+type redundancyDecisions struct {
+	// 需要加锁的节点
+	targetNodeIDs []cdssdk.NodeID
+	decisions     []redundancyDecision
+}
+
+type redundancyDecision struct {
+	obj         stgmod.ObjectDetail
+	red         cdssdk.Redundancy
+	uploadNodes []*NodeLoadInfo
+}
+
+func (t *CheckPackageRedundancy) decide(db *mydb.DB) (*redundancyDecisions, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
-		log.Warnf("new coordinator client: %s", err.Error())
-		return
+		return nil, fmt.Errorf("new coordinator client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
 	getObjs, err := coorCli.GetPackageObjectDetails(coormq.ReqGetPackageObjectDetails(t.PackageID))
 	if err != nil {
-		log.Warnf("getting package objects: %s", err.Error())
-		return
+		return nil, fmt.Errorf("getting package objects: %w", err)
 	}
 
 	getLogs, err := coorCli.GetPackageLoadLogDetails(coormq.ReqGetPackageLoadLogDetails(t.PackageID))
 	if err != nil {
-		log.Warnf("getting package load log details: %s", err.Error())
-		return
+		return nil, fmt.Errorf("getting package load log details: %w", err)
+	}
+
+	// 使用Package所在的Bucket的创建者可用的节点来存放数据
+	pkg, err := db.Package().GetByID(db.SQLCtx(), t.PackageID)
+	if err != nil {
+		return nil, fmt.Errorf("getting package: %w", err)
+	}
+
+	bkt, err := db.Bucket().GetByID(db.SQLCtx(), pkg.BucketID)
+	if err != nil {
+		return nil, fmt.Errorf("getting bucket: %w", err)
 	}
 
-	// TODO UserID
-	getNodes, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(1))
+	getNodes, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(bkt.CreatorID))
 	if err != nil {
-		log.Warnf("getting all nodes: %s", err.Error())
-		return
+		return nil, fmt.Errorf("getting all nodes: %w", err)
 	}
 
 	if len(getNodes.Nodes) == 0 {
-		log.Warnf("no available nodes")
-		return
+		return nil, fmt.Errorf("no available nodes")
+	}
+
+	rules, err := db.Lifecycle().GetByBucketID(db.SQLCtx(), bkt.BucketID)
+	if err != nil {
+		return nil, fmt.Errorf("getting lifecycle rules: %w", err)
 	}
+	transRules := lo.Filter(rules, func(rule model.LifecycleRule, idx int) bool { return rule.Action == model.LifecycleActionTransition })
 
 	userAllNodes := make(map[cdssdk.NodeID]*NodeLoadInfo)
 	for _, node := range getNodes.Nodes {
@@ -100,130 +191,311 @@ func (t *CheckPackageRedundancy) Execute(execCtx ExecuteContext) {
 		}
 	}
 
+	// Package整体被加载的次数，作为选择冗余策略的依据
+	var pkgLoadsMonth, pkgLoadsYear int
 	for _, log := range getLogs.Logs {
+		sinceNow := time.Since(log.CreateTime)
+		recentMonth := sinceNow.Hours() < monthHours
+		recentYear := !recentMonth && sinceNow.Hours() < yearHours
+		if recentMonth {
+			pkgLoadsMonth++
+		} else if recentYear {
+			pkgLoadsYear++
+		}
+
 		info, ok := userAllNodes[log.Storage.NodeID]
 		if !ok {
 			continue
 		}
 
-		sinceNow := time.Since(log.CreateTime)
-		if sinceNow.Hours() < monthHours {
+		if recentMonth {
 			info.LoadsRecentMonth++
-		} else if sinceNow.Hours() < yearHours {
+		} else if recentYear {
 			info.LoadsRecentYear++
 		}
 	}
 
-	var changedObjects []coormq.UpdatingObjectRedundancy
+	transRuleNodes := t.chooseNodesForTransitionRules(transRules, userAllNodes)
 
-	defRep := cdssdk.DefaultRepRedundancy
-	defEC := cdssdk.DefaultECRedundancy
+	redPolicy := config.CurrentPolicy().RedundancyPolicy()
+	policyIn := redpolicy.Input{
+		PackageID:        pkg.PackageID,
+		BucketID:         bkt.BucketID,
+		LoadsRecentMonth: pkgLoadsMonth,
+		LoadsRecentYear:  pkgLoadsYear,
+		NodeCount:        len(getNodes.Nodes),
+		LocationCount:    len(lo.Uniq(lo.Map(getNodes.Nodes, func(node cdssdk.Node, idx int) cdssdk.LocationID { return node.LocationID }))),
+	}
 
-	// TODO 目前rep的备份数量固定为2，所以这里直接选出两个节点
-	// TODO 放到chooseRedundancy函数中
-	mostBlockNodeIDs := t.summaryRepObjectBlockNodes(getObjs.Objects, 2)
-	newRepNodes := t.chooseNewNodesForRep(&defRep, userAllNodes)
-	rechoosedRepNodes := t.rechooseNodesForRep(mostBlockNodeIDs, &defRep, userAllNodes)
-	newECNodes := t.chooseNewNodesForEC(&defEC, userAllNodes)
+	// 按副本数量记录的已有副本最多的节点，在需要时才统计
+	mostBlockNodeIDs := make(map[int][]cdssdk.NodeID)
 
-	// 加锁
-	builder := reqbuilder.NewBuilder()
-	for _, node := range newRepNodes {
-		builder.IPFS().Buzy(node.Node.NodeID)
+	decs := &redundancyDecisions{}
+	now := time.Now()
+	for _, obj := range getObjs.Objects {
+		newRed, selectedNodes := t.chooseRedundancy(obj, userAllNodes, transRules, transRuleNodes, redPolicy, policyIn, now)
+		if newRed == nil || !canConvertRedundancy(obj.Object.Redundancy, newRed) {
+			continue
+		}
+
+		// 冗余策略不变时，只有LRC需要重新选择节点，以便补齐丢失的块
+		if _, isLRC := newRed.(*cdssdk.LRCRedundancy); !isLRC && reflect.DeepEqual(obj.Object.Redundancy, newRed) {
+			continue
+		}
+
+		var uploadNodes []*NodeLoadInfo
+		switch newRed := newRed.(type) {
+		case *cdssdk.RepRedundancy:
+			if selectedNodes != nil {
+				uploadNodes = selectedNodes
+			} else if _, ok := obj.Object.Redundancy.(*cdssdk.RepRedundancy); ok {
+				// 已经是多副本的对象，优先选择已经有副本的节点
+				ids, ok := mostBlockNodeIDs[newRed.RepCount]
+				if !ok {
+					ids = t.summaryRepObjectBlockNodes(getObjs.Objects, newRed.RepCount)
+					mostBlockNodeIDs[newRed.RepCount] = ids
+				}
+				uploadNodes = t.rechooseNodesForRep(ids, newRed, userAllNodes)
+			} else {
+				uploadNodes = t.chooseNewNodesForRep(newRed, userAllNodes)
+			}
+
+		case *cdssdk.ECRedundancy:
+			if _, ok := obj.Object.Redundancy.(*cdssdk.ECRedundancy); ok {
+				uploadNodes = t.rechooseNodesForEC(obj, newRed, userAllNodes)
+			} else if selectedNodes != nil {
+				uploadNodes = selectedNodes
+			} else {
+				uploadNodes = t.chooseNewNodesForEC(newRed, userAllNodes)
+			}
+
+		case *cdssdk.LRCRedundancy:
+			// 按目标的参数选择节点，参数不同时，lrcToLRC会重新编码
+			if _, ok := obj.Object.Redundancy.(*cdssdk.LRCRedundancy); ok {
+				uploadNodes = t.rechooseNodesForLRC(obj, newRed, userAllNodes)
+			} else if selectedNodes != nil {
+				uploadNodes = selectedNodes
+			} else {
+				uploadNodes = t.chooseNewNodesForLRC(newRed, userAllNodes)
+			}
+		}
+
+		decs.decisions = append(decs.decisions, redundancyDecision{
+			obj:         obj,
+			red:         newRed,
+			uploadNodes: uploadNodes,
+		})
+		for _, node := range uploadNodes {
+			decs.targetNodeIDs = append(decs.targetNodeIDs, node.Node.NodeID)
+		}
 	}
-	for _, node := range newECNodes {
+	decs.targetNodeIDs = lo.Uniq(decs.targetNodeIDs)
+
+	return decs, nil
+}
+
+// 按照选择的冗余策略逐个修改对象，每个对象修改完成后立刻更新到数据库。返回没有修改成功的对象数量。
+// 每个对象单独加锁，限速的等待在加锁之前进行，并且在处理两个对象之间可以把节点的额度让给紧急任务
+func (t *CheckPackageRedundancy) applyDecisions(execCtx ExecuteContext, decs *redundancyDecisions) (int, error) {
+	log := logger.WithType[CheckPackageRedundancy]("Event")
+
+	if len(decs.decisions) == 0 {
+		return 0, nil
+	}
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return len(decs.decisions), fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	// 先获取节点的额度再加锁，避免在等待额度时占用锁
+	ticket := budget.AcquirePreemptible(decs.targetNodeIDs)
+	defer ticket.Release()
+
+	failedCount := 0
+	for _, dec := range decs.decisions {
+		if ticket.Preempted() {
+			log.Debugf("yield node budget to emergency jobs")
+			ticket.Yield()
+		}
+
+		// 按对象的大小估算写入新节点的数据量，用来限制调整的速度
+		redundancyLimiter.Wait(plannedNewBlockNodeIDs(dec), dec.obj.Object.Size, config.CurrentPolicy().NodeBudget.RedundancyBytesPerSecond)
+
+		err := t.applyDecision(execCtx, coorCli, dec)
+		if err != nil {
+			failedCount++
+			log.WithField("ObjectID", dec.obj.Object.ObjectID).Warnf("%s, its redundancy wont be changed", err.Error())
+		}
+	}
+
+	return failedCount, nil
+}
+
+func (t *CheckPackageRedundancy) applyDecision(execCtx ExecuteContext, coorCli *coormq.Client, dec redundancyDecision) error {
+	// 加锁，直到修改结果更新到数据库之后才释放，防止新写入的块被GC
+	builder := reqbuilder.NewBuilder()
+	for _, node := range dec.uploadNodes {
 		builder.IPFS().Buzy(node.Node.NodeID)
 	}
 	mutex, err := builder.MutexLock(execCtx.Args.DistLock)
 	if err != nil {
-		log.Warnf("acquiring dist lock: %s", err.Error())
-		return
+		return fmt.Errorf("acquiring dist lock: %w", err)
 	}
 	defer mutex.Unlock()
 
-	for _, obj := range getObjs.Objects {
-		var updating *coormq.UpdatingObjectRedundancy
-		var err error
-
-		newRed, selectedNodes := t.chooseRedundancy(obj, userAllNodes)
+	updating, err := t.applyRedundancy(dec.obj, dec.red, dec.uploadNodes)
+	if err != nil {
+		return err
+	}
+	if updating == nil {
+		return nil
+	}
 
-		switch srcRed := obj.Object.Redundancy.(type) {
-		case *cdssdk.NoneRedundancy:
-			switch newRed := newRed.(type) {
-			case *cdssdk.RepRedundancy:
-				log.WithField("ObjectID", obj.Object.ObjectID).Debugf("redundancy: none -> rep")
-				updating, err = t.noneToRep(obj, newRed, newRepNodes)
+	_, err = coorCli.UpdateObjectRedundancy(coormq.ReqUpdateObjectRedundancy([]coormq.UpdatingObjectRedundancy{*updating}))
+	if err != nil {
+		return fmt.Errorf("requesting to change object redundancy: %w", err)
+	}
 
-			case *cdssdk.ECRedundancy:
-				log.WithField("ObjectID", obj.Object.ObjectID).Debugf("redundancy: none -> ec")
-				updating, err = t.noneToEC(obj, newRed, newECNodes)
+	return nil
+}
 
-			case *cdssdk.LRCRedundancy:
-				log.WithField("ObjectID", obj.Object.ObjectID).Debugf("redundancy: none -> lrc")
-				updating, err = t.noneToLRC(obj, newRed, selectedNodes)
-			}
+func (t *CheckPackageRedundancy) applyRedundancy(obj stgmod.ObjectDetail, newRed cdssdk.Redundancy, uploadNodes []*NodeLoadInfo) (*coormq.UpdatingObjectRedundancy, error) {
+	log := logger.WithType[CheckPackageRedundancy]("Event").WithField("ObjectID", obj.Object.ObjectID)
 
+	switch srcRed := obj.Object.Redundancy.(type) {
+	case *cdssdk.NoneRedundancy:
+		switch newRed := newRed.(type) {
 		case *cdssdk.RepRedundancy:
-			switch newRed := newRed.(type) {
-			case *cdssdk.RepRedundancy:
-				updating, err = t.repToRep(obj, srcRed, rechoosedRepNodes)
+			log.Debugf("redundancy: none -> rep")
+			return t.noneToRep(obj, newRed, uploadNodes)
 
-			case *cdssdk.ECRedundancy:
-				log.WithField("ObjectID", obj.Object.ObjectID).Debugf("redundancy: rep -> ec")
-				updating, err = t.repToEC(obj, newRed, newECNodes)
-			}
+		case *cdssdk.ECRedundancy:
+			log.Debugf("redundancy: none -> ec")
+			return t.noneToEC(obj, newRed, uploadNodes)
+
+		case *cdssdk.LRCRedundancy:
+			log.Debugf("redundancy: none -> lrc")
+			return t.noneToLRC(obj, newRed, uploadNodes)
+		}
+
+	case *cdssdk.RepRedundancy:
+		switch newRed := newRed.(type) {
+		case *cdssdk.RepRedundancy:
+			return t.repToRep(obj, newRed, uploadNodes)
 
 		case *cdssdk.ECRedundancy:
-			switch newRed := newRed.(type) {
-			case *cdssdk.RepRedundancy:
-				log.WithField("ObjectID", obj.Object.ObjectID).Debugf("redundancy: ec -> rep")
-				updating, err = t.ecToRep(obj, srcRed, newRed, newRepNodes)
-
-			case *cdssdk.ECRedundancy:
-				uploadNodes := t.rechooseNodesForEC(obj, srcRed, userAllNodes)
-				updating, err = t.ecToEC(obj, srcRed, newRed, uploadNodes)
-			}
+			log.Debugf("redundancy: rep -> ec")
+			return t.repToEC(obj, newRed, uploadNodes)
 
 		case *cdssdk.LRCRedundancy:
-			switch newRed := newRed.(type) {
-			case *cdssdk.LRCRedundancy:
-				uploadNodes := t.rechooseNodesForLRC(obj, srcRed, userAllNodes)
-				updating, err = t.lrcToLRC(obj, srcRed, newRed, uploadNodes)
-			}
+			log.Debugf("redundancy: rep -> lrc")
+			return t.repToLRC(obj, newRed, uploadNodes)
 		}
 
-		if updating != nil {
-			changedObjects = append(changedObjects, *updating)
+	case *cdssdk.ECRedundancy:
+		switch newRed := newRed.(type) {
+		case *cdssdk.RepRedundancy:
+			log.Debugf("redundancy: ec -> rep")
+			return t.ecToRep(obj, srcRed, newRed, uploadNodes)
+
+		case *cdssdk.ECRedundancy:
+			return t.ecToEC(obj, srcRed, newRed, uploadNodes)
 		}
 
-		if err != nil {
-			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("%s, its redundancy wont be changed", err.Error())
+	case *cdssdk.LRCRedundancy:
+		switch newRed := newRed.(type) {
+		case *cdssdk.LRCRedundancy:
+			return t.lrcToLRC(obj, srcRed, newRed, uploadNodes)
 		}
 	}
 
-	if len(changedObjects) == 0 {
-		return
+	return nil, nil
+}
+
+// 调整冗余策略时可能需要写入数据块的节点，即还没有这个对象的块的目标节点
+func plannedNewBlockNodeIDs(dec redundancyDecision) []cdssdk.NodeID {
+	var nodeIDs []cdssdk.NodeID
+	for _, node := range dec.uploadNodes {
+		_, existed := lo.Find(dec.obj.Blocks, func(blk stgmod.ObjectBlock) bool { return blk.NodeID == node.Node.NodeID })
+		if !existed {
+			nodeIDs = append(nodeIDs, node.Node.NodeID)
+		}
 	}
+	return lo.Uniq(nodeIDs)
+}
 
-	_, err = coorCli.UpdateObjectRedundancy(coormq.ReqUpdateObjectRedundancy(changedObjects))
-	if err != nil {
-		log.Warnf("requesting to change object redundancy: %s", err.Error())
-		return
+// 为对象选择冗余策略。对象满足生命周期规则时使用规则中的冗余策略，以及为规则选择的节点，否则由冗余策略的选择规则决定。
+// 返回nil代表保持不变
+func (t *CheckPackageRedundancy) chooseRedundancy(obj stgmod.ObjectDetail, userAllNodes map[cdssdk.NodeID]*NodeLoadInfo, transRules []model.LifecycleRule, transRuleNodes map[model.LifecycleRuleID][]*NodeLoadInfo, redPolicy redpolicy.Policy, policyIn redpolicy.Input, now time.Time) (cdssdk.Redundancy, []*NodeLoadInfo) {
+	// 对象满足多条Transition规则时，使用天数最多的那条
+	var rule *model.LifecycleRule
+	for i := range transRules {
+		if transRules[i].Matches(obj.Object, now) && (rule == nil || transRules[i].Days > rule.Days) {
+			rule = &transRules[i]
+		}
+	}
+	if rule != nil {
+		// 已经是规则要求的冗余策略，不需要修改
+		if reflect.DeepEqual(obj.Object.Redundancy, rule.Redundancy) {
+			return nil, nil
+		}
+
+		switch red := rule.Redundancy.(type) {
+		case *cdssdk.ECRedundancy:
+			if _, ok := obj.Object.Redundancy.(*cdssdk.ECRedundancy); ok {
+				return red, nil
+			}
+
+		case *cdssdk.LRCRedundancy:
+			if _, ok := obj.Object.Redundancy.(*cdssdk.LRCRedundancy); ok {
+				return red, t.rechooseNodesForLRC(obj, red, userAllNodes)
+			}
+		}
+		return rule.Redundancy, transRuleNodes[rule.RuleID]
 	}
+
+	policyIn.Object = obj.Object
+	return redPolicy.Choose(policyIn), nil
 }
 
-func (t *CheckPackageRedundancy) chooseRedundancy(obj stgmod.ObjectDetail, userAllNodes map[cdssdk.NodeID]*NodeLoadInfo) (cdssdk.Redundancy, []*NodeLoadInfo) {
-	switch obj.Object.Redundancy.(type) {
-	case *cdssdk.NoneRedundancy:
-		newLRCNodes := t.chooseNewNodesForLRC(&cdssdk.DefaultLRCRedundancy, userAllNodes)
-		return &cdssdk.DefaultLRCRedundancy, newLRCNodes
+// 判断是否支持将对象从src冗余策略转换为tar
+func canConvertRedundancy(src cdssdk.Redundancy, tar cdssdk.Redundancy) bool {
+	switch src.(type) {
+	case *cdssdk.NoneRedundancy, *cdssdk.RepRedundancy:
+		switch tar.(type) {
+		case *cdssdk.RepRedundancy, *cdssdk.ECRedundancy, *cdssdk.LRCRedundancy:
+			return true
+		}
+
+	case *cdssdk.ECRedundancy:
+		switch tar.(type) {
+		case *cdssdk.RepRedundancy, *cdssdk.ECRedundancy:
+			return true
+		}
 
 	case *cdssdk.LRCRedundancy:
-		newLRCNodes := t.rechooseNodesForLRC(obj, &cdssdk.DefaultLRCRedundancy, userAllNodes)
-		return &cdssdk.DefaultLRCRedundancy, newLRCNodes
+		_, ok := tar.(*cdssdk.LRCRedundancy)
+		return ok
+	}
+	return false
+}
 
+// 为每条Transition规则的目标冗余策略选择节点
+func (t *CheckPackageRedundancy) chooseNodesForTransitionRules(transRules []model.LifecycleRule, userAllNodes map[cdssdk.NodeID]*NodeLoadInfo) map[model.LifecycleRuleID][]*NodeLoadInfo {
+	ruleNodes := make(map[model.LifecycleRuleID][]*NodeLoadInfo)
+	for _, rule := range transRules {
+		switch red := rule.Redundancy.(type) {
+		case *cdssdk.RepRedundancy:
+			ruleNodes[rule.RuleID] = t.chooseNewNodesForRep(red, userAllNodes)
+		case *cdssdk.ECRedundancy:
+			ruleNodes[rule.RuleID] = t.chooseNewNodesForEC(red, userAllNodes)
+		case *cdssdk.LRCRedundancy:
+			ruleNodes[rule.RuleID] = t.chooseNewNodesForLRC(red, userAllNodes)
+		}
 	}
-	return nil, nil
+	return ruleNodes
 }
 
 // 统计每个对象块所在的节点，选出块最多的不超过nodeCnt个节点
@@ -626,6 +898,10 @@ func (t *CheckPackageRedundancy) repToEC(obj stgmod.ObjectDetail, red *cdssdk.EC
 	return t.noneToEC(obj, red, uploadNodes)
 }
 
+func (t *CheckPackageRedundancy) repToLRC(obj stgmod.ObjectDetail, red *cdssdk.LRCRedundancy, uploadNodes []*NodeLoadInfo) (*coormq.UpdatingObjectRedundancy, error) {
+	return t.noneToLRC(obj, red, uploadNodes)
+}
+
 func (t *CheckPackageRedundancy) ecToRep(obj stgmod.ObjectDetail, srcRed *cdssdk.ECRedundancy, tarRed *cdssdk.RepRedundancy, uploadNodes []*NodeLoadInfo) (*coormq.UpdatingObjectRedundancy, error) {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
@@ -798,6 +1074,11 @@ func (t *CheckPackageRedundancy) lrcToLRC(obj stgmod.ObjectDetail, srcRed *cdssd
 
 	blocksGrpByIndex := obj.GroupBlocks()
 
+	// 参数不同时，已有的块不能继续使用，需要按新的参数重新编码
+	if !reflect.DeepEqual(srcRed, tarRed) {
+		return t.reencodeLRC(obj, blocksGrpByIndex, srcRed, tarRed, uploadNodes)
+	}
+
 	var lostBlocks []int
 	var lostBlockGrps []int
 	canGroupReconstruct := true
@@ -832,6 +1113,72 @@ func (t *CheckPackageRedundancy) lrcToLRC(obj stgmod.ObjectDetail, srcRed *cdssd
 	return t.reconstructLRC(obj, blocksGrpByIndex, srcRed, uploadNodes)
 }
 
+// 用原来的块在第一个目标节点上重建出完整文件，然后按新的参数编码，上传到各个目标节点
+func (t *CheckPackageRedundancy) reencodeLRC(obj stgmod.ObjectDetail, grpBlocks []stgmod.GrouppedObjectBlock, srcRed *cdssdk.LRCRedundancy, tarRed *cdssdk.LRCRedundancy, uploadNodes []*NodeLoadInfo) (*coormq.UpdatingObjectRedundancy, error) {
+	var froms []ioswitchlrc.From
+	for _, block := range grpBlocks {
+		if len(block.NodeIDs) > 0 && block.Index < srcRed.M() {
+			froms = append(froms, ioswitchlrc.NewFromNode(block.FileHash, nil, block.Index))
+		}
+
+		if len(froms) == srcRed.K {
+			break
+		}
+	}
+
+	if len(froms) < srcRed.K {
+		return nil, fmt.Errorf("no enough blocks to reconstruct the original file data")
+	}
+
+	execNode := uploadNodes[0].Node
+
+	decodePlans := exec.NewPlanBuilder()
+	fileLen := obj.Object.Size
+	err := lrcparser.ReconstructAnyWith(*srcRed, froms, []ioswitchlrc.To{
+		ioswitchlrc.NewToNodeWithRange(execNode, -1, "file", exec.Range{Offset: 0, Length: &fileLen}),
+	}, decodePlans)
+	if err != nil {
+		return nil, fmt.Errorf("parsing decode plan: %w", err)
+	}
+
+	decodeRet, err := decodePlans.Execute().Wait(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("executing decode plan: %w", err)
+	}
+
+	var toes []ioswitchlrc.To
+	for i := 0; i < tarRed.N; i++ {
+		toes = append(toes, ioswitchlrc.NewToNode(uploadNodes[i].Node, i, fmt.Sprintf("%d", i)))
+	}
+
+	encodePlans := exec.NewPlanBuilder()
+	err = lrcparser.EncodeWith(*tarRed, ioswitchlrc.NewFromNode(decodeRet["file"].(string), &execNode, -1), toes, encodePlans)
+	if err != nil {
+		return nil, fmt.Errorf("parsing encode plan: %w", err)
+	}
+
+	encodeRet, err := encodePlans.Execute().Wait(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("executing encode plan: %w", err)
+	}
+
+	var blocks []stgmod.ObjectBlock
+	for i := 0; i < tarRed.N; i++ {
+		blocks = append(blocks, stgmod.ObjectBlock{
+			ObjectID: obj.Object.ObjectID,
+			Index:    i,
+			NodeID:   uploadNodes[i].Node.NodeID,
+			FileHash: encodeRet[fmt.Sprintf("%d", i)].(string),
+		})
+	}
+
+	return &coormq.UpdatingObjectRedundancy{
+		ObjectID:   obj.Object.ObjectID,
+		Redundancy: tarRed,
+		Blocks:     blocks,
+	}, nil
+}
+
 func (t *CheckPackageRedundancy) groupReconstructLRC(obj stgmod.ObjectDetail, lostBlocks []int, lostBlockGrps []int, grpedBlocks []stgmod.GrouppedObjectBlock, red *cdssdk.LRCRedundancy, uploadNodes []*NodeLoadInfo) (*coormq.UpdatingObjectRedundancy, error) {
 	grped := make(map[int]stgmod.GrouppedObjectBlock)
 	for _, b := range grpedBlocks {
@@ -54,23 +54,27 @@ func (t *CleanPinned) Execute(execCtx ExecuteContext) {
 		log.Debugf("end, time: %v", time.Since(startTime))
 	}()
 
+	err := t.ExecuteJob(execCtx)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+}
+
+func (t *CleanPinned) ExecuteJob(execCtx ExecuteContext) error {
 	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
 	if err != nil {
-		log.Warnf("new coordinator client: %s", err.Error())
-		return
+		return fmt.Errorf("new coordinator client: %w", err)
 	}
 	defer stgglb.CoordinatorMQPool.Release(coorCli)
 
 	getObjs, err := coorCli.GetPackageObjectDetails(coormq.ReqGetPackageObjectDetails(t.PackageID))
 	if err != nil {
-		log.Warnf("getting package objects: %s", err.Error())
-		return
+		return fmt.Errorf("getting package objects: %w", err)
 	}
 
 	getLoadLog, err := coorCli.GetPackageLoadLogDetails(coormq.ReqGetPackageLoadLogDetails(t.PackageID))
 	if err != nil {
-		log.Warnf("getting package load log details: %s", err.Error())
-		return
+		return fmt.Errorf("getting package load log details: %w", err)
 	}
 	readerNodeIDs := lo.Map(getLoadLog.Logs, func(item coormq.PackageLoadLogDetail, idx int) cdssdk.NodeID { return item.Storage.NodeID })
 
@@ -87,8 +91,7 @@ func (t *CleanPinned) Execute(execCtx ExecuteContext) {
 
 	getNodeResp, err := coorCli.GetNodes(coormq.NewGetNodes(lo.Union(allNodeID)))
 	if err != nil {
-		log.Warnf("getting nodes: %s", err.Error())
-		return
+		return fmt.Errorf("getting nodes: %w", err)
 	}
 
 	allNodeInfos := make(map[cdssdk.NodeID]*cdssdk.Node)
@@ -138,10 +141,16 @@ func (t *CleanPinned) Execute(execCtx ExecuteContext) {
 		ecObjectsUpdating = append(ecObjectsUpdating, t.makePlansForECObject(allNodeInfos, solu, obj, planBld, plnningNodeIDs))
 	}
 
+	targetNodeIDs := lo.Keys(plnningNodeIDs)
+	for nodeID := range pinPlans {
+		targetNodeIDs = append(targetNodeIDs, nodeID)
+	}
+	release := budget.Acquire(targetNodeIDs, PriorityRoutine)
+	defer release()
+
 	ioSwRets, err := t.executePlans(execCtx, pinPlans, planBld, plnningNodeIDs)
 	if err != nil {
-		log.Warn(err.Error())
-		return
+		return err
 	}
 
 	// 根据按照方案进行调整的结果，填充更新元数据的命令
@@ -153,10 +162,11 @@ func (t *CleanPinned) Execute(execCtx ExecuteContext) {
 	if len(finalEntries) > 0 {
 		_, err = coorCli.UpdateObjectRedundancy(coormq.ReqUpdateObjectRedundancy(finalEntries))
 		if err != nil {
-			log.Warnf("changing object redundancy: %s", err.Error())
-			return
+			return fmt.Errorf("changing object redundancy: %w", err)
 		}
 	}
+
+	return nil
 }
 
 func (t *CleanPinned) summaryRepObjectBlockNodes(objs []stgmod.ObjectDetail) []cdssdk.NodeID {
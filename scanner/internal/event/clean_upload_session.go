@@ -0,0 +1,80 @@
+package event
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+)
+
+const CleanUploadSessionBatchSize = 500
+
+// 清理长时间没有活动的分段上传会话。会话的分段文件在删除记录后会由AgentCacheGC回收
+type CleanUploadSession struct {
+	*scevt.CleanUploadSession
+}
+
+func NewCleanUploadSession(evt *scevt.CleanUploadSession) *CleanUploadSession {
+	return &CleanUploadSession{
+		CleanUploadSession: evt,
+	}
+}
+
+func (t *CleanUploadSession) TryMerge(other Event) bool {
+	_, ok := other.(*CleanUploadSession)
+	return ok
+}
+
+func (t *CleanUploadSession) Execute(execCtx ExecuteContext) {
+	log := logger.WithType[CleanUploadSession]("Event")
+	startTime := time.Now()
+	log.Debugf("begin")
+	defer func() {
+		log.Debugf("end, time: %v", time.Since(startTime))
+	}()
+
+	before := time.Now().Add(-time.Duration(config.Cfg().UploadSessionTimeoutSeconds) * time.Second)
+
+	for {
+		var sessions []model.UploadSession
+		err := execCtx.Args.DB.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+			var err error
+			sessions, err = execCtx.Args.DB.UploadSession().BatchGetInactive(tx, before, CleanUploadSessionBatchSize)
+			if err != nil {
+				return fmt.Errorf("getting inactive upload sessions: %w", err)
+			}
+
+			for _, s := range sessions {
+				err := execCtx.Args.DB.UploadSession().Delete(tx, s.SessionID)
+				if err != nil {
+					return fmt.Errorf("deleting upload session %v: %w", s.SessionID, err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			log.Warn(err.Error())
+			return
+		}
+
+		for _, s := range sessions {
+			log.WithField("SessionID", s.SessionID).
+				WithField("UserID", s.UserID).
+				Infof("upload session expired, last update at %v", s.UpdateTime)
+		}
+
+		if len(sessions) < CleanUploadSessionBatchSize {
+			return
+		}
+	}
+}
+
+func init() {
+	RegisterMessageConvertor(NewCleanUploadSession)
+}
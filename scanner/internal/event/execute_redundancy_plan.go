@@ -0,0 +1,264 @@
+package event
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+)
+
+// 按照已经批准的计划修改对象的冗余策略。计划生成之后冗余策略或者数据块所在的节点发生了变化的对象会被跳过
+type ExecuteRedundancyPlan struct {
+	*scevt.ExecuteRedundancyPlan
+}
+
+func NewExecuteRedundancyPlan(evt *scevt.ExecuteRedundancyPlan) *ExecuteRedundancyPlan {
+	return &ExecuteRedundancyPlan{
+		ExecuteRedundancyPlan: evt,
+	}
+}
+
+func (t *ExecuteRedundancyPlan) TryMerge(other Event) bool {
+	event, ok := other.(*ExecuteRedundancyPlan)
+	if !ok {
+		return false
+	}
+
+	return event.PlanID == t.PlanID
+}
+
+func (t *ExecuteRedundancyPlan) Execute(execCtx ExecuteContext) {
+	log := logger.WithType[ExecuteRedundancyPlan]("Event")
+	startTime := time.Now()
+	log.Debugf("begin with %v", logger.FormatStruct(t.ExecuteRedundancyPlan))
+	defer func() {
+		log.Debugf("end, time: %v", time.Since(startTime))
+	}()
+
+	err := t.ExecuteJob(execCtx)
+	if err != nil {
+		log.Warn(err.Error())
+	}
+}
+
+func (t *ExecuteRedundancyPlan) ExecuteJob(execCtx ExecuteContext) error {
+	log := logger.WithType[ExecuteRedundancyPlan]("Event").WithField("PlanID", t.PlanID)
+
+	plan, err := execCtx.Args.DB.RedundancyPlan().GetByID(execCtx.Args.DB.SQLCtx(), t.PlanID)
+	if err != nil {
+		return fmt.Errorf("getting plan: %w", err)
+	}
+	if plan.State != model.RedundancyPlanStateApproved {
+		log.Infof("plan is %s, skip it", plan.State)
+		return nil
+	}
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		return fmt.Errorf("new coordinator client: %w", err)
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getObjs, err := coorCli.GetPackageObjectDetails(coormq.ReqGetPackageObjectDetails(plan.PackageID))
+	if err != nil {
+		return fmt.Errorf("getting package objects: %w", err)
+	}
+	objs := make(map[cdssdk.ObjectID]stgmod.ObjectDetail)
+	for _, obj := range getObjs.Objects {
+		objs[obj.Object.ObjectID] = obj
+	}
+
+	var allNodeIDs []cdssdk.NodeID
+	for _, p := range plan.Objects {
+		allNodeIDs = append(allNodeIDs, p.TarNodeIDs...)
+	}
+	getNodes, err := coorCli.GetNodes(coormq.NewGetNodes(lo.Uniq(allNodeIDs)))
+	if err != nil {
+		return fmt.Errorf("getting nodes: %w", err)
+	}
+	nodes := make(map[cdssdk.NodeID]*NodeLoadInfo)
+	for _, node := range getNodes.Nodes {
+		nodes[node.NodeID] = &NodeLoadInfo{Node: node}
+	}
+
+	decs := &redundancyDecisions{}
+	skipped := 0
+	for _, p := range plan.Objects {
+		objLog := log.WithField("ObjectID", p.ObjectID)
+
+		obj, ok := objs[p.ObjectID]
+		if !ok {
+			objLog.Infof("object not found, skip it")
+			skipped++
+			continue
+		}
+
+		if !reflect.DeepEqual(obj.Object.Redundancy, p.SrcRedundancy) || !sameNodeIDs(blockNodeIDs(obj), p.SrcNodeIDs) {
+			objLog.Infof("object changed after the plan was made, skip it")
+			skipped++
+			continue
+		}
+
+		var uploadNodes []*NodeLoadInfo
+		for _, id := range p.TarNodeIDs {
+			node, ok := nodes[id]
+			if !ok {
+				break
+			}
+			uploadNodes = append(uploadNodes, node)
+		}
+		if len(uploadNodes) < len(p.TarNodeIDs) {
+			objLog.Infof("some of the target nodes not found, skip it")
+			skipped++
+			continue
+		}
+
+		decs.decisions = append(decs.decisions, redundancyDecision{
+			obj:         obj,
+			red:         p.TarRedundancy,
+			uploadNodes: uploadNodes,
+		})
+		decs.targetNodeIDs = append(decs.targetNodeIDs, p.TarNodeIDs...)
+	}
+	decs.targetNodeIDs = lo.Uniq(decs.targetNodeIDs)
+
+	chk := NewCheckPackageRedundancy(scevt.NewCheckPackageRedundancy(plan.PackageID))
+	failed, applyErr := chk.applyDecisions(execCtx, decs)
+
+	// 有对象被跳过或者修改失败时，计划不能算执行完成，记录为失败，可以重新批准执行
+	state := model.RedundancyPlanStateExecuted
+	if applyErr != nil || failed > 0 || skipped > 0 {
+		state = model.RedundancyPlanStateFailed
+		log.Warnf("%d objects skipped, %d objects failed", skipped, failed)
+	}
+
+	err = execCtx.Args.DB.RedundancyPlan().SetFinished(execCtx.Args.DB.SQLCtx(), t.PlanID, state)
+	if err != nil {
+		return fmt.Errorf("setting plan %s: %w", state, err)
+	}
+
+	return applyErr
+}
+
+func (d *redundancyDecisions) toPlan(packageID cdssdk.PackageID) model.RedundancyPlan {
+	now := time.Now()
+	plan := model.RedundancyPlan{
+		PackageID:  packageID,
+		State:      model.RedundancyPlanStatePending,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+
+	for _, dec := range d.decisions {
+		p := estimateObjectRedundancyPlan(dec)
+
+		// 冗余策略和数据块所在的节点都不变的对象不需要修改
+		if reflect.DeepEqual(p.SrcRedundancy, p.TarRedundancy) && p.TransferBytes == 0 && sameNodeIDs(p.SrcNodeIDs, sortedNodeIDs(p.TarNodeIDs)) {
+			continue
+		}
+
+		plan.Objects = append(plan.Objects, p)
+		plan.ObjectCount++
+		plan.TotalSize += p.Size
+		plan.TransferBytes += p.TransferBytes
+		plan.OldStoredBytes += p.OldStoredBytes
+		plan.NewStoredBytes += p.NewStoredBytes
+	}
+
+	return plan
+}
+
+// 估算修改冗余策略需要传输的数据量，以及修改前后占用的存储空间
+func estimateObjectRedundancyPlan(dec redundancyDecision) model.ObjectRedundancyPlan {
+	obj := dec.obj
+	p := model.ObjectRedundancyPlan{
+		ObjectID:       obj.Object.ObjectID,
+		Path:           obj.Object.Path,
+		Size:           obj.Object.Size,
+		SrcRedundancy:  obj.Object.Redundancy,
+		TarRedundancy:  dec.red,
+		SrcNodeIDs:     blockNodeIDs(obj),
+		TarNodeIDs:     lo.Map(dec.uploadNodes, func(node *NodeLoadInfo, idx int) cdssdk.NodeID { return node.Node.NodeID }),
+		OldStoredBytes: redundancyBlockSize(obj.Object.Redundancy, obj.Object.Size) * int64(len(obj.Blocks)),
+	}
+
+	// 只有分块方式相同时，已有的块才能直接使用
+	_, srcIsNone := obj.Object.Redundancy.(*cdssdk.NoneRedundancy)
+	_, tarIsRep := dec.red.(*cdssdk.RepRedundancy)
+	sameLayout := reflect.DeepEqual(obj.Object.Redundancy, dec.red) || (srcIsNone && tarIsRep)
+
+	blkSize := redundancyBlockSize(dec.red, obj.Object.Size)
+	hasBlock := func(index int, nodeID cdssdk.NodeID) bool {
+		if !sameLayout {
+			return false
+		}
+		_, ok := lo.Find(obj.Blocks, func(b stgmod.ObjectBlock) bool { return b.Index == index && b.NodeID == nodeID })
+		return ok
+	}
+
+	if tarIsRep {
+		// 多个副本选择了同一个节点时只会保存一次
+		for _, nodeID := range lo.Uniq(p.TarNodeIDs) {
+			p.NewStoredBytes += blkSize
+			if !hasBlock(0, nodeID) {
+				p.TransferBytes += blkSize
+			}
+		}
+		return p
+	}
+
+	for i, nodeID := range p.TarNodeIDs {
+		p.NewStoredBytes += blkSize
+		if !hasBlock(i, nodeID) {
+			p.TransferBytes += blkSize
+		}
+	}
+	return p
+}
+
+// 对象使用这个冗余策略时一个数据块的大小，不考虑编码时的填充
+func redundancyBlockSize(red cdssdk.Redundancy, size int64) int64 {
+	switch red := red.(type) {
+	case *cdssdk.ECRedundancy:
+		return (size + int64(red.K) - 1) / int64(red.K)
+	case *cdssdk.LRCRedundancy:
+		return (size + int64(red.K) - 1) / int64(red.K)
+	}
+	return size
+}
+
+// 保存了对象数据块的节点，从小到大排列
+func blockNodeIDs(obj stgmod.ObjectDetail) []cdssdk.NodeID {
+	return sortedNodeIDs(lo.Map(obj.Blocks, func(b stgmod.ObjectBlock, idx int) cdssdk.NodeID { return b.NodeID }))
+}
+
+func sortedNodeIDs(ids []cdssdk.NodeID) []cdssdk.NodeID {
+	ids = lo.Uniq(ids)
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func sameNodeIDs(a []cdssdk.NodeID, b []cdssdk.NodeID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	RegisterMessageConvertor(NewExecuteRedundancyPlan)
+}
@@ -0,0 +1,456 @@
+package event
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/cluster"
+)
+
+const (
+	// 任务执行失败后自动重试，直到执行次数达到这个值
+	JobMaxAttempts = 3
+	// 每次重试之前等待的时间，会乘以已经执行的次数
+	jobRetryDelay = time.Minute
+	// 从数据库中拉取本实例负责的等待中任务的间隔
+	jobPollInterval = 10 * time.Second
+)
+
+// 能够返回执行结果的事件。作为任务执行时，返回的错误会被记录下来，并且任务会被重新执行
+type JobEvent interface {
+	ExecuteJob(execCtx ExecuteContext) error
+}
+
+// 持久化的任务队列。投递的事件会先记录到数据库中，再由负责任务所在分片的Scanner实例执行，执行的结果也会记录到数据库。
+// 每个实例会定期拉取自己负责的分片中没有完成的任务，因此实例重启或者分片转移到其他实例后，任务也会继续执行
+type JobQueue struct {
+	db       *mydb.DB
+	executor *Executor
+	cluster  *cluster.Member
+
+	lock sync.Mutex
+	// 已经交给Executor但还没有结束的任务，防止拉取任务时重复投递
+	posted map[model.ScannerJobID]bool
+}
+
+func NewJobQueue(db *mydb.DB, executor *Executor, cluster *cluster.Member) *JobQueue {
+	return &JobQueue{
+		db:       db,
+		executor: executor,
+		cluster:  cluster,
+		posted:   make(map[model.ScannerJobID]bool),
+	}
+}
+
+func (q *JobQueue) Post(msg scevt.Event, opt ExecuteOption) (model.ScannerJobID, error) {
+	evt, err := FromMessage(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	content, err := serder.ObjectToJSONEx(msg)
+	if err != nil {
+		return 0, fmt.Errorf("serializing event: %w", err)
+	}
+
+	shard := q.jobShard(msg)
+
+	now := time.Now()
+	jobID, err := q.db.ScannerJob().Create(q.db.SQLCtx(), model.ScannerJob{
+		Type:        eventTypeName(msg),
+		Content:     string(content),
+		IsEmergency: opt.IsEmergency,
+		DontMerge:   opt.DontMerge,
+		Shard:       shard,
+		State:       model.ScannerJobStatePending,
+		CreateTime:  now,
+		UpdateTime:  now,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("creating job: %w", err)
+	}
+
+	// 不属于本实例的任务由负责的实例拉取后执行
+	if q.cluster.Owns(shard) {
+		q.post(jobID, msg, evt, opt)
+	}
+	return jobID, nil
+}
+
+// 投递不记录到数据库的任务。用于定期扫描产生的大量任务：扫描进度已经记录在数据库中，
+// 这些任务即使因为实例重启而丢失，也会在下一轮扫描时重新产生，不需要为每个任务写一条记录
+func (q *JobQueue) PostTransient(msg scevt.Event, opt ExecuteOption) error {
+	evt, err := FromMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	shard := q.jobShard(msg)
+	if !q.cluster.Owns(shard) {
+		return fmt.Errorf("shard %d is not owned by this instance", shard)
+	}
+
+	q.executor.Post(&jobEvent{
+		queue: q,
+		shard: shard,
+		msg:   msg,
+		inner: evt,
+		opt:   opt,
+	}, opt)
+	return nil
+}
+
+// 定期拉取本实例负责的分片中没有完成的任务。执行者已经失去分片的任务会从头开始执行
+func (q *JobQueue) Serve() {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		err := q.poll()
+		if err != nil {
+			logger.Warnf("polling jobs: %s", err.Error())
+		}
+
+		<-ticker.C
+	}
+}
+
+func (q *JobQueue) poll() error {
+	shards := q.cluster.OwnedShards()
+	if len(shards) == 0 {
+		return nil
+	}
+
+	// 之前的纪元中开始执行的任务，执行者已经失去了分片，需要重新执行
+	for _, shard := range shards {
+		epoch, ok := q.cluster.Epoch(shard)
+		if !ok {
+			continue
+		}
+
+		err := q.db.ScannerJob().ResetRunningOfOldEpochs(q.db.SQLCtx(), shard, epoch)
+		if err != nil {
+			return fmt.Errorf("resetting running jobs of shard %d: %w", shard, err)
+		}
+	}
+
+	jobs, err := q.db.ScannerJob().GetPendingInShards(q.db.SQLCtx(), shards)
+	if err != nil {
+		return fmt.Errorf("getting pending jobs: %w", err)
+	}
+
+	cnt := 0
+	for _, job := range jobs {
+		if q.isPosted(job.JobID) {
+			continue
+		}
+
+		err := q.postJob(job)
+		if err != nil {
+			logger.WithField("JobID", job.JobID).Warnf("posting job: %s", err.Error())
+			continue
+		}
+		cnt++
+	}
+
+	if cnt > 0 {
+		logger.Infof("%d pending jobs posted", cnt)
+	}
+	return nil
+}
+
+// 重新执行失败或者被取消的任务
+func (q *JobQueue) Retry(jobID model.ScannerJobID) error {
+	ok, err := q.db.ScannerJob().Retry(q.db.SQLCtx(), jobID)
+	if err != nil {
+		return fmt.Errorf("resetting job: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("job %v not found or is not failed or canceled", jobID)
+	}
+
+	job, err := q.db.ScannerJob().GetByID(q.db.SQLCtx(), jobID)
+	if err != nil {
+		return fmt.Errorf("getting job: %w", err)
+	}
+
+	if !q.cluster.Owns(job.Shard) {
+		return nil
+	}
+	return q.postJob(job)
+}
+
+// 取消等待中的任务。已经开始执行的任务不能取消
+func (q *JobQueue) Cancel(jobID model.ScannerJobID) error {
+	ok, err := q.db.ScannerJob().Cancel(q.db.SQLCtx(), jobID)
+	if err != nil {
+		return fmt.Errorf("canceling job: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("job %v not found or is not pending", jobID)
+	}
+
+	return nil
+}
+
+func (q *JobQueue) postJob(job model.ScannerJob) error {
+	msg, err := serder.JSONToObjectEx[scevt.Event]([]byte(job.Content))
+	if err != nil {
+		return fmt.Errorf("deserializing event: %w", err)
+	}
+
+	evt, err := FromMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	q.post(job.JobID, msg, evt, ExecuteOption{
+		IsEmergency: job.IsEmergency,
+		DontMerge:   job.DontMerge,
+	})
+	return nil
+}
+
+func (q *JobQueue) post(jobID model.ScannerJobID, msg scevt.Event, evt Event, opt ExecuteOption) {
+	q.lock.Lock()
+	q.posted[jobID] = true
+	q.lock.Unlock()
+
+	q.executor.Post(&jobEvent{
+		queue: q,
+		jobID: jobID,
+		msg:   msg,
+		inner: evt,
+		opt:   opt,
+	}, opt)
+}
+
+func (q *JobQueue) isPosted(jobID model.ScannerJobID) bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.posted[jobID]
+}
+
+func (q *JobQueue) done(jobID model.ScannerJobID) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.posted, jobID)
+}
+
+// 计算任务所属的分片。与Package、节点等相关的任务按照它们的ID分片，其他任务由Leader执行
+func (q *JobQueue) jobShard(msg scevt.Event) int {
+	switch msg := msg.(type) {
+	case *scevt.CheckPackageRedundancy:
+		return q.cluster.ShardOf(int64(msg.PackageID))
+	case *scevt.CleanPinned:
+		return q.cluster.ShardOf(int64(msg.PackageID))
+	case *scevt.ScrubPackage:
+		return q.cluster.ShardOf(int64(msg.PackageID))
+	case *scevt.ExecuteRedundancyPlan:
+		return q.cluster.ShardOf(int64(msg.PackageID))
+	case *scevt.AgentCacheGC:
+		return q.cluster.ShardOf(int64(msg.NodeID))
+	case *scevt.AgentCheckCache:
+		return q.cluster.ShardOf(int64(msg.NodeID))
+	case *scevt.AgentCheckState:
+		return q.cluster.ShardOf(int64(msg.NodeID))
+	case *scevt.RepairNode:
+		return q.cluster.ShardOf(int64(msg.NodeID))
+	case *scevt.AgentCheckStorage:
+		return q.cluster.ShardOf(int64(msg.StorageID))
+	case *scevt.AgentStorageGC:
+		return q.cluster.ShardOf(int64(msg.StorageID))
+	case *scevt.CheckBucketLifecycle:
+		return q.cluster.ShardOf(int64(msg.BucketID))
+	}
+	return cluster.LeaderShard
+}
+
+// 包装了一个事件，在事件执行前后更新任务的状态。jobID为0的是不记录到数据库的任务
+type jobEvent struct {
+	queue *JobQueue
+	jobID model.ScannerJobID
+	// 不记录到数据库的任务所属的分片
+	shard int
+	// inner是由msg转换而来的，两者共用同一份数据，合并时对inner的修改也会反映到msg上
+	msg   scevt.Event
+	inner Event
+	opt   ExecuteOption
+}
+
+func (t *jobEvent) TryMerge(other Event) bool {
+	job, ok := other.(*jobEvent)
+	if !ok {
+		return false
+	}
+
+	// 记录到数据库的任务如果合并到了不记录的任务中，执行失败后就不会重试了
+	if t.jobID == 0 && job.jobID != 0 {
+		return false
+	}
+
+	if !t.inner.TryMerge(job.inner) {
+		return false
+	}
+
+	// 合并可能修改了事件的内容，需要更新到数据库中，否则重新拉取任务时会丢失这些修改
+	if t.jobID != 0 {
+		t.saveContent()
+	}
+
+	if job.jobID == 0 {
+		return true
+	}
+
+	err := t.queue.db.ScannerJob().SetMerged(t.queue.db.SQLCtx(), job.jobID)
+	if err != nil {
+		logger.WithField("JobID", job.jobID).Warnf("setting job merged: %s", err.Error())
+	}
+	t.queue.done(job.jobID)
+	return true
+}
+
+func (t *jobEvent) saveContent() {
+	content, err := serder.ObjectToJSONEx(t.msg)
+	if err != nil {
+		logger.WithField("JobID", t.jobID).Warnf("serializing event: %s", err.Error())
+		return
+	}
+
+	err = t.queue.db.ScannerJob().UpdateContent(t.queue.db.SQLCtx(), t.jobID, string(content))
+	if err != nil {
+		logger.WithField("JobID", t.jobID).Warnf("updating job content: %s", err.Error())
+	}
+}
+
+func (t *jobEvent) Execute(execCtx ExecuteContext) {
+	if t.jobID == 0 {
+		t.executeTransient(execCtx)
+		return
+	}
+
+	log := logger.WithField("JobID", t.jobID)
+	jobDB := t.queue.db.ScannerJob()
+
+	retrying := false
+	defer func() {
+		if !retrying {
+			t.queue.done(t.jobID)
+		}
+	}()
+
+	job, err := jobDB.GetByID(t.queue.db.SQLCtx(), t.jobID)
+	if err != nil {
+		// 任务依然是等待状态，下次拉取任务时会重新执行
+		log.Warnf("getting job: %s", err.Error())
+		return
+	}
+	// 等待执行的期间分片可能已经转移到了其他实例，或者正在等待释放
+	epoch, ok := t.queue.cluster.BeginWork(job.Shard)
+	if !ok {
+		log.Debugf("shard %d is not owned by this instance, skip it", job.Shard)
+		return
+	}
+	defer t.queue.cluster.EndWork(job.Shard, epoch)
+
+	owner := t.queue.cluster.InstanceID()
+	ok, err = jobDB.Start(t.queue.db.SQLCtx(), t.jobID, owner, epoch)
+	if err != nil {
+		log.Warnf("starting job: %s", err.Error())
+		return
+	}
+	if !ok {
+		log.Debugf("job is not pending or the lease of shard %d is lost, skip it", job.Shard)
+		return
+	}
+
+	finish := func(state string, lastErr string) bool {
+		ok, err := jobDB.Finish(t.queue.db.SQLCtx(), t.jobID, owner, epoch, state, lastErr)
+		if err != nil {
+			log.Warnf("setting job %s: %s", state, err.Error())
+			return false
+		}
+		if !ok {
+			log.Warnf("job has been taken over by the new owner of shard %d, its result is discarded", job.Shard)
+		}
+		return ok
+	}
+
+	execErr := t.run(execCtx)
+	if execErr == nil {
+		finish(model.ScannerJobStateSucceeded, "")
+		return
+	}
+
+	job, err = jobDB.GetByID(t.queue.db.SQLCtx(), t.jobID)
+	if err != nil {
+		log.Warnf("getting job: %s", err.Error())
+		return
+	}
+
+	if job.Attempts >= JobMaxAttempts {
+		log.Warnf("job failed after %d attempts: %s", job.Attempts, execErr.Error())
+		finish(model.ScannerJobStateFailed, execErr.Error())
+		return
+	}
+
+	log.Warnf("job failed at attempt %d, will retry later: %s", job.Attempts, execErr.Error())
+	if !finish(model.ScannerJobStatePending, execErr.Error()) {
+		return
+	}
+
+	// 等待重试的期间任务依然被标记为已投递，防止被提前拉取
+	retrying = true
+	time.AfterFunc(jobRetryDelay*time.Duration(job.Attempts), func() {
+		if !t.queue.cluster.Owns(job.Shard) {
+			t.queue.done(t.jobID)
+			return
+		}
+		t.queue.post(t.jobID, t.msg, t.inner, t.opt)
+	})
+}
+
+func (t *jobEvent) executeTransient(execCtx ExecuteContext) {
+	// 等待执行的期间分片可能已经转移到了其他实例
+	epoch, ok := t.queue.cluster.BeginWork(t.shard)
+	if !ok {
+		logger.Debugf("shard %d is not owned by this instance, skip transient job", t.shard)
+		return
+	}
+	defer t.queue.cluster.EndWork(t.shard, epoch)
+
+	err := t.run(execCtx)
+	if err != nil {
+		logger.Warnf("transient job %s: %s", reflect.TypeOf(t.inner).Elem().Name(), err.Error())
+	}
+}
+
+func (t *jobEvent) run(execCtx ExecuteContext) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if job, ok := t.inner.(JobEvent); ok {
+		return job.ExecuteJob(execCtx)
+	}
+
+	t.inner.Execute(execCtx)
+	return nil
+}
+
+func eventTypeName(msg scevt.Event) string {
+	typ := reflect.TypeOf(msg)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
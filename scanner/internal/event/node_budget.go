@@ -0,0 +1,205 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+)
+
+type Priority int
+
+const (
+	// 例行的调整，比如修改冗余策略、GC
+	PriorityRoutine Priority = iota
+	// 紧急的任务，比如修复不可用节点上的数据。会比例行任务先获得节点的额度，并且不受带宽限制
+	PriorityEmergency
+)
+
+// 限制每个节点上同时进行的任务的数量，额度的上限在调度策略中配置。
+// 有紧急任务在等待某个节点的额度时，例行任务不能再获得这个节点的额度，以便紧急任务尽快开始执行。
+// 通过AcquirePreemptible获得额度的例行任务还会被通知让出额度
+type nodeBudget struct {
+	lock             sync.Mutex
+	cond             *sync.Cond
+	running          map[cdssdk.NodeID]int
+	waitingEmergency map[cdssdk.NodeID]int
+	preemptibles     map[*BudgetTicket]bool
+}
+
+// 可以被紧急任务抢占的例行任务的额度
+type BudgetTicket struct {
+	budget    *nodeBudget
+	nodeIDs   []cdssdk.NodeID
+	release   func()
+	preempted chan any
+	once      sync.Once
+}
+
+// 有紧急任务在等待这个任务占用的节点的额度。任务应该在处理完当前的对象之后调用Yield
+func (t *BudgetTicket) Preempted() bool {
+	select {
+	case <-t.preempted:
+		return true
+	default:
+		return false
+	}
+}
+
+// 归还额度，等紧急任务获得额度之后再重新获取
+func (t *BudgetTicket) Yield() {
+	t.Release()
+	t.acquire()
+}
+
+func (t *BudgetTicket) acquire() {
+	t.release = t.budget.Acquire(t.nodeIDs, PriorityRoutine)
+
+	t.budget.lock.Lock()
+	t.preempted = make(chan any)
+	t.once = sync.Once{}
+	t.budget.preemptibles[t] = true
+	t.budget.lock.Unlock()
+}
+
+func (t *BudgetTicket) Release() {
+	t.budget.lock.Lock()
+	delete(t.budget.preemptibles, t)
+	t.budget.lock.Unlock()
+
+	t.release()
+}
+
+// 需要在加锁的情况下调用
+func (t *BudgetTicket) preempt() {
+	t.once.Do(func() { close(t.preempted) })
+}
+
+var budget = newNodeBudget()
+
+// 修改冗余策略时写入数据的速度限制
+var redundancyLimiter = newNodeRateLimiter()
+
+func newNodeBudget() *nodeBudget {
+	b := &nodeBudget{
+		running:          make(map[cdssdk.NodeID]int),
+		waitingEmergency: make(map[cdssdk.NodeID]int),
+		preemptibles:     make(map[*BudgetTicket]bool),
+	}
+	b.cond = sync.NewCond(&b.lock)
+	return b
+}
+
+// 获取在这些节点上执行一个任务的额度，会一直等待到所有节点都有额度为止。返回的函数用于归还额度
+func (b *nodeBudget) Acquire(nodeIDs []cdssdk.NodeID, pri Priority) func() {
+	nodeIDs = lo.Uniq(nodeIDs)
+
+	b.lock.Lock()
+	if pri == PriorityEmergency {
+		for _, id := range nodeIDs {
+			b.waitingEmergency[id]++
+		}
+
+		// 通知占用了这些节点的例行任务让出额度
+		if !b.canRun(nodeIDs, pri) {
+			for t := range b.preemptibles {
+				if lo.Some(t.nodeIDs, nodeIDs) {
+					t.preempt()
+				}
+			}
+		}
+	}
+
+	for !b.canRun(nodeIDs, pri) {
+		b.cond.Wait()
+	}
+
+	for _, id := range nodeIDs {
+		if pri == PriorityEmergency {
+			b.waitingEmergency[id]--
+			if b.waitingEmergency[id] == 0 {
+				delete(b.waitingEmergency, id)
+			}
+		}
+		b.running[id]++
+	}
+	b.lock.Unlock()
+
+	return func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		for _, id := range nodeIDs {
+			b.running[id]--
+			if b.running[id] == 0 {
+				delete(b.running, id)
+			}
+		}
+		b.cond.Broadcast()
+	}
+}
+
+// 获取例行任务的额度，有紧急任务在等待这些节点的额度时，任务会通过BudgetTicket.Preempted得到通知
+func (b *nodeBudget) AcquirePreemptible(nodeIDs []cdssdk.NodeID) *BudgetTicket {
+	t := &BudgetTicket{
+		budget:  b,
+		nodeIDs: lo.Uniq(nodeIDs),
+	}
+	t.acquire()
+	return t
+}
+
+// 需要在加锁的情况下调用
+func (b *nodeBudget) canRun(nodeIDs []cdssdk.NodeID, pri Priority) bool {
+	// 每次都读取最新的策略，修改策略后，在下一次有任务归还额度时生效
+	maxTasks := config.CurrentPolicy().NodeBudget.MaxConcurrentTasks
+
+	for _, id := range nodeIDs {
+		if maxTasks > 0 && b.running[id] >= maxTasks {
+			return false
+		}
+
+		if pri == PriorityRoutine && b.waitingEmergency[id] > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 限制从每个节点读取或者向每个节点写入数据的速度
+type nodeRateLimiter struct {
+	lock     sync.Mutex
+	nextFree map[cdssdk.NodeID]time.Time
+}
+
+func newNodeRateLimiter() *nodeRateLimiter {
+	return &nodeRateLimiter{
+		nextFree: make(map[cdssdk.NodeID]time.Time),
+	}
+}
+
+// 在从这些节点各读取（或者写入）size字节的数据之前调用，会一直等待到所有节点都有足够的额度为止。bytesPerSec为0代表不限制
+func (l *nodeRateLimiter) Wait(nodeIDs []cdssdk.NodeID, size int64, bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		return
+	}
+
+	l.lock.Lock()
+	start := time.Now()
+	for _, id := range nodeIDs {
+		if free := l.nextFree[id]; free.After(start) {
+			start = free
+		}
+	}
+
+	cost := time.Duration(float64(size) / float64(bytesPerSec) * float64(time.Second))
+	for _, id := range nodeIDs {
+		l.nextFree[id] = start.Add(cost)
+	}
+	l.lock.Unlock()
+
+	time.Sleep(time.Until(start))
+}
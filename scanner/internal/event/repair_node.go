@@ -0,0 +1,340 @@
+package event
+
+import (
+	"time"
+
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/math2"
+	"gitlink.org.cn/cloudream/common/utils/sort2"
+	"gitlink.org.cn/cloudream/storage/common/consts"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+)
+
+const RepairNodeBatchSize = 100
+
+// 修复不可用节点上的数据：删除节点上的临时副本记录，将在节点上有编码块的历史版本标记为已损坏，然后在其他可用节点上重建节点上的所有编码块/副本。
+// 剩余冗余度越低的对象越先修复。有对象修复失败时，任务会重新等待，直到重试次数用完
+type RepairNode struct {
+	*scevt.RepairNode
+}
+
+func NewRepairNode(evt *scevt.RepairNode) *RepairNode {
+	return &RepairNode{
+		RepairNode: evt,
+	}
+}
+
+func (t *RepairNode) TryMerge(other Event) bool {
+	event, ok := other.(*RepairNode)
+	if !ok {
+		return false
+	}
+
+	return event.NodeID == t.NodeID
+}
+
+func (t *RepairNode) Execute(execCtx ExecuteContext) {
+	log := logger.WithType[RepairNode]("Event")
+	startTime := time.Now()
+	log.Debugf("begin with %v", logger.FormatStruct(t.RepairNode))
+	defer func() {
+		log.Debugf("end, time: %v", time.Since(startTime))
+	}()
+
+	db := execCtx.Args.DB
+
+	node, err := db.Node().GetByID(db.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.Warnf("getting node: %s", err.Error())
+		return
+	}
+
+	// 节点已经恢复，不需要修复了
+	if node.State != consts.NodeStateUnavailable {
+		err := db.NodeRepair().UpdateState(db.SQLCtx(), t.NodeID, model.NodeRepairStateDone)
+		if err != nil {
+			log.Warnf("updating node repair state: %s", err.Error())
+		}
+		return
+	}
+
+	repair, err := db.NodeRepair().GetByNodeID(db.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.Warnf("getting node repair: %s", err.Error())
+		return
+	}
+
+	err = db.NodeRepair().UpdateState(db.SQLCtx(), t.NodeID, model.NodeRepairStateRunning)
+	if err != nil {
+		log.Warnf("updating node repair state: %s", err.Error())
+		return
+	}
+
+	// 临时副本不需要重建，直接删除记录
+	pinneds, err := db.PinnedObject().GetByNodeID(db.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.Warnf("getting pinned objects: %s", err.Error())
+		return
+	}
+	if len(pinneds) > 0 {
+		pinnedObjIDs := lo.Map(pinneds, func(p cdssdk.PinnedObject, idx int) cdssdk.ObjectID { return p.ObjectID })
+		err := db.PinnedObject().NodeBatchDelete(db.SQLCtx(), t.NodeID, pinnedObjIDs)
+		if err != nil {
+			log.Warnf("deleting pinned objects: %s", err.Error())
+			return
+		}
+	}
+
+	// 历史版本的编码块不进行重建，只将它们标记为已损坏，之后不能再恢复
+	err = db.ObjectVersion().MarkDegradedByNode(db.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.Warnf("marking object versions degraded: %s", err.Error())
+		return
+	}
+
+	blocks, err := db.ObjectBlock().GetByNodeID(db.SQLCtx(), t.NodeID)
+	if err != nil {
+		log.Warnf("getting object blocks: %s", err.Error())
+		return
+	}
+	objIDs := lo.Uniq(lo.Map(blocks, func(b stgmod.ObjectBlock, idx int) cdssdk.ObjectID { return b.ObjectID }))
+
+	allNodes, err := db.Node().GetAllNodes(db.SQLCtx())
+	if err != nil {
+		log.Warnf("getting all nodes: %s", err.Error())
+		return
+	}
+	unavailableNodes := make(map[cdssdk.NodeID]bool)
+	for _, n := range allNodes {
+		if n.State == consts.NodeStateUnavailable {
+			unavailableNodes[n.NodeID] = true
+		}
+	}
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		log.Warnf("new coordinator client: %s", err.Error())
+		return
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	var objs []stgmod.ObjectDetail
+	for i := 0; i < len(objIDs); i += RepairNodeBatchSize {
+		getObjs, err := coorCli.GetObjectDetails(coormq.ReqGetObjectDetails(objIDs[i:math2.Min(i+RepairNodeBatchSize, len(objIDs))]))
+		if err != nil {
+			log.Warnf("getting object details: %s", err.Error())
+			return
+		}
+
+		for _, obj := range getObjs.Objects {
+			// 对象可能已经被删除了
+			if obj == nil {
+				continue
+			}
+
+			obj.Blocks = lo.Filter(obj.Blocks, func(b stgmod.ObjectBlock, idx int) bool { return !unavailableNodes[b.NodeID] })
+			objs = append(objs, *obj)
+		}
+	}
+
+	// 剩余冗余度最低的对象最先修复
+	objs = sort2.Sort(objs, func(left, right stgmod.ObjectDetail) int {
+		return remainingMargin(left) - remainingMargin(right)
+	})
+
+	repaired := 0
+	failed := 0
+	bucketCreators := make(map[cdssdk.PackageID]cdssdk.UserID)
+	for i := 0; i < len(objs); i += RepairNodeBatchSize {
+		batch := objs[i:math2.Min(i+RepairNodeBatchSize, len(objs))]
+
+		ok, fail := t.repairObjects(execCtx, coorCli, batch, unavailableNodes, bucketCreators)
+		repaired += ok
+		failed += fail
+
+		err := db.NodeRepair().UpdateProgress(db.SQLCtx(), t.NodeID, len(objs), repaired, failed)
+		if err != nil {
+			log.Warnf("updating node repair progress: %s", err.Error())
+		}
+	}
+
+	if failed == 0 {
+		err = db.NodeRepair().UpdateState(db.SQLCtx(), t.NodeID, model.NodeRepairStateDone)
+		if err != nil {
+			log.Warnf("updating node repair state: %s", err.Error())
+			return
+		}
+
+		log.Infof("node %v repaired, %d objects succeeded", t.NodeID, repaired)
+		return
+	}
+
+	if repair.RetryCount < config.Cfg().NodeRepairMaxRetry {
+		err = db.NodeRepair().Retry(db.SQLCtx(), t.NodeID)
+	} else {
+		err = db.NodeRepair().UpdateState(db.SQLCtx(), t.NodeID, model.NodeRepairStateFailed)
+	}
+	if err != nil {
+		log.Warnf("updating node repair state: %s", err.Error())
+		return
+	}
+
+	log.Warnf("node %v repaired with %d objects succeeded, %d objects failed, retried %d times", t.NodeID, repaired, failed, repair.RetryCount)
+}
+
+// 对象在可用节点上剩余的块数比恢复数据所需要的块数多出的数量，小于0说明数据已经无法恢复
+func remainingMargin(obj stgmod.ObjectDetail) int {
+	switch red := obj.Object.Redundancy.(type) {
+	case *cdssdk.ECRedundancy:
+		return len(lo.UniqBy(obj.Blocks, func(b stgmod.ObjectBlock) int { return b.Index })) - red.K
+	case *cdssdk.LRCRedundancy:
+		return len(lo.UniqBy(obj.Blocks, func(b stgmod.ObjectBlock) int { return b.Index })) - red.K
+	default:
+		return len(obj.Blocks) - 1
+	}
+}
+
+func (t *RepairNode) repairObjects(execCtx ExecuteContext, coorCli *coormq.Client, objs []stgmod.ObjectDetail, unavailableNodes map[cdssdk.NodeID]bool, bucketCreators map[cdssdk.PackageID]cdssdk.UserID) (int, int) {
+	log := logger.WithType[RepairNode]("Event")
+	db := execCtx.Args.DB
+
+	redChecker := NewCheckPackageRedundancy(scevt.NewCheckPackageRedundancy(0))
+
+	skipped := make([]bool, len(objs))
+	userNodes := make(map[cdssdk.UserID]map[cdssdk.NodeID]*NodeLoadInfo)
+	uploadNodes := make([][]*NodeLoadInfo, len(objs))
+	builder := reqbuilder.NewBuilder()
+	for i, obj := range objs {
+		if remainingMargin(obj) < 0 {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("not enough blocks left to repair the object")
+			skipped[i] = true
+			continue
+		}
+
+		userID, ok := bucketCreators[obj.Object.PackageID]
+		if !ok {
+			pkg, err := db.Package().GetByID(db.SQLCtx(), obj.Object.PackageID)
+			if err != nil {
+				log.WithField("PackageID", obj.Object.PackageID).Warnf("getting package: %s", err.Error())
+				skipped[i] = true
+				continue
+			}
+
+			bkt, err := db.Bucket().GetByID(db.SQLCtx(), pkg.BucketID)
+			if err != nil {
+				log.WithField("BucketID", pkg.BucketID).Warnf("getting bucket: %s", err.Error())
+				skipped[i] = true
+				continue
+			}
+
+			userID = bkt.CreatorID
+			bucketCreators[obj.Object.PackageID] = userID
+		}
+
+		nodes, ok := userNodes[userID]
+		if !ok {
+			getNodes, err := db.Node().GetUserNodes(db.SQLCtx(), userID)
+			if err != nil {
+				log.WithField("UserID", userID).Warnf("getting user nodes: %s", err.Error())
+				skipped[i] = true
+				continue
+			}
+
+			nodes = make(map[cdssdk.NodeID]*NodeLoadInfo)
+			for _, node := range getNodes {
+				if !unavailableNodes[node.NodeID] {
+					nodes[node.NodeID] = &NodeLoadInfo{Node: node}
+				}
+			}
+			userNodes[userID] = nodes
+		}
+
+		switch red := obj.Object.Redundancy.(type) {
+		case *cdssdk.RepRedundancy:
+			uploadNodes[i] = redChecker.rechooseNodesForRep(lo.Map(obj.Blocks, func(b stgmod.ObjectBlock, idx int) cdssdk.NodeID { return b.NodeID }), red, nodes)
+		case *cdssdk.ECRedundancy:
+			uploadNodes[i] = redChecker.rechooseNodesForEC(obj, red, nodes)
+		case *cdssdk.LRCRedundancy:
+			uploadNodes[i] = redChecker.rechooseNodesForLRC(obj, red, nodes)
+		}
+
+		for _, node := range uploadNodes[i] {
+			builder.IPFS().Buzy(node.Node.NodeID)
+		}
+	}
+
+	var targetNodeIDs []cdssdk.NodeID
+	for _, nodes := range uploadNodes {
+		for _, node := range nodes {
+			targetNodeIDs = append(targetNodeIDs, node.Node.NodeID)
+		}
+	}
+	// 修复数据是紧急任务，会优先于例行的调整获得节点的额度
+	release := budget.Acquire(targetNodeIDs, PriorityEmergency)
+	defer release()
+
+	mutex, err := builder.MutexLock(execCtx.Args.DistLock)
+	if err != nil {
+		log.Warnf("acquiring dist lock: %s", err.Error())
+		return 0, len(objs)
+	}
+	defer mutex.Unlock()
+
+	var changedObjects []coormq.UpdatingObjectRedundancy
+	for i, obj := range objs {
+		if skipped[i] {
+			continue
+		}
+
+		var updating *coormq.UpdatingObjectRedundancy
+		var err error
+
+		switch red := obj.Object.Redundancy.(type) {
+		case *cdssdk.RepRedundancy:
+			updating, err = redChecker.repToRep(obj, red, uploadNodes[i])
+		case *cdssdk.ECRedundancy:
+			updating, err = redChecker.ecToEC(obj, red, red, uploadNodes[i])
+		case *cdssdk.LRCRedundancy:
+			updating, err = redChecker.lrcToLRC(obj, red, red, uploadNodes[i])
+		default:
+			// 没有冗余的对象只能去掉不可用节点上的记录
+			updating = &coormq.UpdatingObjectRedundancy{
+				ObjectID:   obj.Object.ObjectID,
+				Redundancy: obj.Object.Redundancy,
+				Blocks:     obj.Blocks,
+			}
+		}
+		if err != nil {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("repairing object: %s", err.Error())
+			continue
+		}
+
+		if updating != nil {
+			changedObjects = append(changedObjects, *updating)
+		}
+	}
+
+	if len(changedObjects) == 0 {
+		return 0, len(objs)
+	}
+
+	_, err = coorCli.UpdateObjectRedundancy(coormq.ReqUpdateObjectRedundancy(changedObjects))
+	if err != nil {
+		log.Warnf("requesting to change object redundancy: %s", err.Error())
+		return 0, len(objs)
+	}
+
+	return len(changedObjects), len(objs) - len(changedObjects)
+}
+
+func init() {
+	RegisterMessageConvertor(NewRepairNode)
+}
@@ -0,0 +1,415 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/samber/lo"
+	"gitlink.org.cn/cloudream/common/pkgs/ioswitch/exec"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/math2"
+	stgglb "gitlink.org.cn/cloudream/storage/common/globals"
+	stgmod "gitlink.org.cn/cloudream/storage/common/models"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock/reqbuilder"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitch2/parser"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/ioswitchlrc"
+	lrcparser "gitlink.org.cn/cloudream/storage/common/pkgs/ioswitchlrc/parser"
+	coormq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/coordinator"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+)
+
+const scrubFileResultKey = "file"
+
+// 抽查Package中使用EC或LRC冗余的对象：读取K个块解码出完整文件，同时重新编码出其他的块，
+// 比较它们的哈希值与记录的是否一致。发现损坏的块后将其标记，然后使用ecToEC/lrcToLRC重建。
+// 验证时只在节点上计算哈希值，不会写入任何文件
+type ScrubPackage struct {
+	*scevt.ScrubPackage
+}
+
+func NewScrubPackage(evt *scevt.ScrubPackage) *ScrubPackage {
+	return &ScrubPackage{
+		ScrubPackage: evt,
+	}
+}
+
+func (t *ScrubPackage) TryMerge(other Event) bool {
+	event, ok := other.(*ScrubPackage)
+	if !ok {
+		return false
+	}
+
+	return event.PackageID == t.PackageID
+}
+
+func (t *ScrubPackage) Execute(execCtx ExecuteContext) {
+	log := logger.WithType[ScrubPackage]("Event")
+	startTime := time.Now()
+	log.Debugf("begin with %v", logger.FormatStruct(t.ScrubPackage))
+	defer func() {
+		log.Debugf("end, time: %v", time.Since(startTime))
+	}()
+
+	coorCli, err := stgglb.CoordinatorMQPool.Acquire()
+	if err != nil {
+		log.Warnf("new coordinator client: %s", err.Error())
+		return
+	}
+	defer stgglb.CoordinatorMQPool.Release(coorCli)
+
+	getObjs, err := coorCli.GetPackageObjectDetails(coormq.ReqGetPackageObjectDetails(t.PackageID))
+	if err != nil {
+		log.Warnf("getting package objects: %s", err.Error())
+		return
+	}
+
+	objs := lo.Filter(getObjs.Objects, func(obj stgmod.ObjectDetail, idx int) bool {
+		switch obj.Object.Redundancy.(type) {
+		case *cdssdk.ECRedundancy, *cdssdk.LRCRedundancy:
+			return true
+		}
+		return false
+	})
+	rand.Shuffle(len(objs), func(i, j int) { objs[i], objs[j] = objs[j], objs[i] })
+	if cnt := config.Cfg().ScrubSampleCount; cnt > 0 && len(objs) > cnt {
+		objs = objs[:cnt]
+	}
+	if len(objs) == 0 {
+		return
+	}
+
+	var nodeIDs []cdssdk.NodeID
+	for _, obj := range objs {
+		for _, b := range obj.Blocks {
+			nodeIDs = append(nodeIDs, b.NodeID)
+		}
+	}
+	getNodes, err := coorCli.GetNodes(coormq.NewGetNodes(lo.Uniq(nodeIDs)))
+	if err != nil {
+		log.Warnf("getting nodes: %s", err.Error())
+		return
+	}
+	nodes := make(map[cdssdk.NodeID]*cdssdk.Node)
+	for i := range getNodes.Nodes {
+		nodes[getNodes.Nodes[i].NodeID] = &getNodes.Nodes[i]
+	}
+
+	var repairObjs []stgmod.ObjectDetail
+	for _, obj := range objs {
+		corrupts, err := t.scrubObject(obj, nodes)
+		if err != nil {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("scrubbing object: %s", err.Error())
+			continue
+		}
+		if len(corrupts) == 0 {
+			continue
+		}
+
+		err = execCtx.Args.DB.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+			err := execCtx.Args.DB.CorruptBlock().MarkCorrupt(tx, corrupts)
+			if err != nil {
+				return err
+			}
+
+			// 历史版本可能与对象共用同一个文件，这些版本也一起标记为已损坏
+			for _, c := range corrupts {
+				err := execCtx.Args.DB.ObjectVersion().MarkDegradedByNodeFiles(tx, c.NodeID, []string{c.FileHash})
+				if err != nil {
+					return fmt.Errorf("marking object versions degraded: %w", err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("marking corrupt blocks: %s", err.Error())
+			continue
+		}
+
+		for _, c := range corrupts {
+			log.WithField("ObjectID", c.ObjectID).
+				WithField("Index", c.Index).
+				WithField("NodeID", c.NodeID).
+				Warnf("block %s is corrupt", c.FileHash)
+		}
+
+		obj.Blocks = lo.Reject(obj.Blocks, func(b stgmod.ObjectBlock, idx int) bool {
+			return lo.ContainsBy(corrupts, func(c model.CorruptBlock) bool { return c.Index == b.Index && c.NodeID == b.NodeID })
+		})
+		repairObjs = append(repairObjs, obj)
+	}
+
+	if len(repairObjs) == 0 {
+		return
+	}
+
+	t.repairObjects(execCtx, coorCli, repairObjs)
+}
+
+// 先使用前K个块进行验证，失败时再依次换掉其中一个块，直到找到能解码出正确文件的K个块。
+// 找到后，被换掉的块，以及重新编码后哈希值与记录不一致的块，就是损坏的块
+func (t *ScrubPackage) scrubObject(obj stgmod.ObjectDetail, nodes map[cdssdk.NodeID]*cdssdk.Node) ([]model.CorruptBlock, error) {
+	var k int
+	grps := obj.GroupBlocks()
+	candidates := grps
+	switch red := obj.Object.Redundancy.(type) {
+	case *cdssdk.ECRedundancy:
+		k = red.K
+	case *cdssdk.LRCRedundancy:
+		k = red.K
+		// 只有不属于组校验块的块才能用来解码出完整文件
+		candidates = lo.Filter(grps, func(grp stgmod.GrouppedObjectBlock, idx int) bool { return grp.Index < red.M() })
+	}
+
+	// 每个块只从第一个节点读取，节点信息查询不到的块不参与验证
+	candidates = lo.Filter(candidates, func(grp stgmod.GrouppedObjectBlock, idx int) bool { return nodes[grp.NodeIDs[0]] != nil })
+	if len(candidates) < k {
+		return nil, fmt.Errorf("no enough blocks to scrub, want %d, get only %d", k, len(candidates))
+	}
+
+	subsets := [][]stgmod.GrouppedObjectBlock{candidates[:k]}
+	if len(candidates) > k {
+		for i := 0; i < k; i++ {
+			var subset []stgmod.GrouppedObjectBlock
+			subset = append(subset, candidates[:i]...)
+			subset = append(subset, candidates[i+1:k]...)
+			subset = append(subset, candidates[k])
+			subsets = append(subsets, subset)
+		}
+	}
+
+	blockSize := math2.CeilDiv(obj.Object.Size, int64(k))
+	var failedGrps []stgmod.GrouppedObjectBlock
+	for _, chosen := range subsets {
+		scrubLimiter.Wait(lo.Map(chosen, func(grp stgmod.GrouppedObjectBlock, idx int) cdssdk.NodeID { return grp.NodeIDs[0] }), blockSize, config.Cfg().ScrubNodeBytesPerSecond)
+
+		ret, err := t.decodeAndEncode(obj, chosen, grps, nodes)
+		if err != nil {
+			return nil, err
+		}
+
+		if ret[scrubFileResultKey] != obj.Object.FileHash {
+			failedGrps = append(failedGrps, chosen...)
+			continue
+		}
+
+		corrupts := make(map[string]model.CorruptBlock)
+		addCorrupt := func(grp stgmod.GrouppedObjectBlock, nodeID cdssdk.NodeID) {
+			corrupts[fmt.Sprintf("%d-%d", grp.Index, nodeID)] = model.CorruptBlock{
+				ObjectID:   obj.Object.ObjectID,
+				Index:      grp.Index,
+				NodeID:     nodeID,
+				FileHash:   grp.FileHash,
+				DetectTime: time.Now(),
+			}
+		}
+
+		isChosen := func(grp stgmod.GrouppedObjectBlock) bool {
+			return lo.ContainsBy(chosen, func(c stgmod.GrouppedObjectBlock) bool { return c.Index == grp.Index })
+		}
+
+		for _, grp := range failedGrps {
+			if !isChosen(grp) {
+				addCorrupt(grp, grp.NodeIDs[0])
+			}
+		}
+
+		for _, grp := range grps {
+			if isChosen(grp) {
+				continue
+			}
+
+			if ret[strconv.Itoa(grp.Index)] != grp.FileHash {
+				for _, nodeID := range grp.NodeIDs {
+					addCorrupt(grp, nodeID)
+				}
+			}
+		}
+
+		return lo.Values(corrupts), nil
+	}
+
+	return nil, fmt.Errorf("no %d blocks can be decoded to the original file, there may be more than one corrupt block", k)
+}
+
+// 从chosen中的块解码出完整文件，同时重新编码出grps中其他的块，返回它们的哈希值。
+// 完整文件的哈希值的键为scrubFileResultKey，其他块的为块的Index
+func (t *ScrubPackage) decodeAndEncode(obj stgmod.ObjectDetail, chosen []stgmod.GrouppedObjectBlock, grps []stgmod.GrouppedObjectBlock, nodes map[cdssdk.NodeID]*cdssdk.Node) (map[string]any, error) {
+	execNode := *nodes[chosen[0].NodeIDs[0]]
+	fileRange := exec.Range{Offset: 0, Length: &obj.Object.Size}
+
+	others := lo.Filter(grps, func(grp stgmod.GrouppedObjectBlock, idx int) bool {
+		return !lo.ContainsBy(chosen, func(c stgmod.GrouppedObjectBlock) bool { return c.Index == grp.Index })
+	})
+
+	plans := exec.NewPlanBuilder()
+	switch red := obj.Object.Redundancy.(type) {
+	case *cdssdk.ECRedundancy:
+		ft := ioswitch2.NewFromTo()
+		for _, grp := range chosen {
+			ft.AddFrom(ioswitch2.NewFromNode(grp.FileHash, nodes[grp.NodeIDs[0]], grp.Index))
+		}
+		fileTo := ioswitch2.NewToNodeWithRange(execNode, -1, scrubFileResultKey, fileRange)
+		fileTo.HashOnly = true
+		ft.AddTo(fileTo)
+		for _, grp := range others {
+			blkTo := ioswitch2.NewToNode(execNode, grp.Index, strconv.Itoa(grp.Index))
+			blkTo.HashOnly = true
+			ft.AddTo(blkTo)
+		}
+
+		err := parser.NewParser(*red).Parse(ft, plans)
+		if err != nil {
+			return nil, fmt.Errorf("parsing plan: %w", err)
+		}
+
+	case *cdssdk.LRCRedundancy:
+		var froms []ioswitchlrc.From
+		for _, grp := range chosen {
+			froms = append(froms, ioswitchlrc.NewFromNode(grp.FileHash, nodes[grp.NodeIDs[0]], grp.Index))
+		}
+		fileTo := ioswitchlrc.NewToNodeWithRange(execNode, -1, scrubFileResultKey, fileRange)
+		fileTo.HashOnly = true
+		toes := []ioswitchlrc.To{fileTo}
+		for _, grp := range others {
+			blkTo := ioswitchlrc.NewToNode(execNode, grp.Index, strconv.Itoa(grp.Index))
+			blkTo.HashOnly = true
+			toes = append(toes, blkTo)
+		}
+
+		err := lrcparser.ReconstructAny(froms, toes, plans)
+		if err != nil {
+			return nil, fmt.Errorf("parsing plan: %w", err)
+		}
+	}
+
+	ret, err := plans.Execute().Wait(context.TODO())
+	if err != nil {
+		return nil, fmt.Errorf("executing io plan: %w", err)
+	}
+
+	return ret, nil
+}
+
+// 使用修改冗余策略时的重建流程来补齐被标记为损坏的块。重建时不会选择块损坏的节点，
+// 修复成功后清除对象的损坏块记录
+func (t *ScrubPackage) repairObjects(execCtx ExecuteContext, coorCli *coormq.Client, objs []stgmod.ObjectDetail) {
+	log := logger.WithType[ScrubPackage]("Event")
+
+	// 之前修复失败的对象也会留有损坏块记录，这些节点同样不能选择
+	corruptNodes := make(map[cdssdk.ObjectID][]cdssdk.NodeID)
+	objs = lo.Filter(objs, func(obj stgmod.ObjectDetail, idx int) bool {
+		corrupts, err := execCtx.Args.DB.CorruptBlock().GetByObjectID(execCtx.Args.DB.SQLCtx(), obj.Object.ObjectID)
+		if err != nil {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("getting corrupt blocks: %s", err.Error())
+			return false
+		}
+
+		corruptNodes[obj.Object.ObjectID] = lo.Uniq(lo.Map(corrupts, func(c model.CorruptBlock, idx int) cdssdk.NodeID { return c.NodeID }))
+		return true
+	})
+	if len(objs) == 0 {
+		return
+	}
+
+	pkg, err := execCtx.Args.DB.Package().GetByID(execCtx.Args.DB.SQLCtx(), t.PackageID)
+	if err != nil {
+		log.Warnf("getting package: %s", err.Error())
+		return
+	}
+
+	bkt, err := execCtx.Args.DB.Bucket().GetByID(execCtx.Args.DB.SQLCtx(), pkg.BucketID)
+	if err != nil {
+		log.Warnf("getting bucket: %s", err.Error())
+		return
+	}
+
+	getNodes, err := coorCli.GetUserNodes(coormq.NewGetUserNodes(bkt.CreatorID))
+	if err != nil {
+		log.Warnf("getting all nodes: %s", err.Error())
+		return
+	}
+
+	redChecker := NewCheckPackageRedundancy(scevt.NewCheckPackageRedundancy(t.PackageID))
+
+	uploadNodes := make([][]*NodeLoadInfo, len(objs))
+	builder := reqbuilder.NewBuilder()
+	for i, obj := range objs {
+		availNodes := make(map[cdssdk.NodeID]*NodeLoadInfo)
+		for _, node := range getNodes.Nodes {
+			if !lo.Contains(corruptNodes[obj.Object.ObjectID], node.NodeID) {
+				availNodes[node.NodeID] = &NodeLoadInfo{Node: node}
+			}
+		}
+
+		switch red := obj.Object.Redundancy.(type) {
+		case *cdssdk.ECRedundancy:
+			uploadNodes[i] = redChecker.rechooseNodesForEC(obj, red, availNodes)
+		case *cdssdk.LRCRedundancy:
+			uploadNodes[i] = redChecker.rechooseNodesForLRC(obj, red, availNodes)
+		}
+
+		for _, node := range uploadNodes[i] {
+			builder.IPFS().Buzy(node.Node.NodeID)
+		}
+	}
+
+	mutex, err := builder.MutexLock(execCtx.Args.DistLock)
+	if err != nil {
+		log.Warnf("acquiring dist lock: %s", err.Error())
+		return
+	}
+	defer mutex.Unlock()
+
+	var changedObjects []coormq.UpdatingObjectRedundancy
+	for i, obj := range objs {
+		var updating *coormq.UpdatingObjectRedundancy
+		var err error
+
+		switch red := obj.Object.Redundancy.(type) {
+		case *cdssdk.ECRedundancy:
+			updating, err = redChecker.ecToEC(obj, red, red, uploadNodes[i])
+		case *cdssdk.LRCRedundancy:
+			updating, err = redChecker.lrcToLRC(obj, red, red, uploadNodes[i])
+		}
+		if err != nil {
+			log.WithField("ObjectID", obj.Object.ObjectID).Warnf("repairing object: %s", err.Error())
+			continue
+		}
+
+		if updating != nil {
+			changedObjects = append(changedObjects, *updating)
+		}
+	}
+
+	if len(changedObjects) == 0 {
+		return
+	}
+
+	_, err = coorCli.UpdateObjectRedundancy(coormq.ReqUpdateObjectRedundancy(changedObjects))
+	if err != nil {
+		log.Warnf("requesting to change object redundancy: %s", err.Error())
+		return
+	}
+
+	err = execCtx.Args.DB.DoTx(sql.LevelSerializable, func(tx *sqlx.Tx) error {
+		return execCtx.Args.DB.CorruptBlock().BatchDeleteByObjectID(tx, lo.Map(changedObjects, func(obj coormq.UpdatingObjectRedundancy, idx int) cdssdk.ObjectID { return obj.ObjectID }))
+	})
+	if err != nil {
+		log.Warnf("clearing corrupt blocks: %s", err.Error())
+	}
+}
+
+var scrubLimiter = newNodeRateLimiter()
+
+func init() {
+	RegisterMessageConvertor(NewScrubPackage)
+}
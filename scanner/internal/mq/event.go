@@ -7,14 +7,12 @@ import (
 )
 
 func (svc *Service) PostEvent(msg *scmq.PostEvent) {
-	evt, err := event.FromMessage(msg.Event)
-	if err != nil {
-		logger.Warnf("create event from event message failed, err: %s", err.Error())
-		return
-	}
-
-	svc.eventExecutor.Post(evt, event.ExecuteOption{
+	_, err := svc.jobQueue.Post(msg.Event, event.ExecuteOption{
 		IsEmergency: msg.IsEmergency,
 		DontMerge:   msg.DontMerge,
 	})
+	if err != nil {
+		logger.Warnf("post event as job failed, err: %s", err.Error())
+		return
+	}
 }
@@ -0,0 +1,47 @@
+package mq
+
+import (
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	scmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner"
+)
+
+const (
+	defaultListJobsLimit = 100
+)
+
+func (svc *Service) ListJobs(msg *scmq.ListJobs) (*scmq.ListJobsResp, *mq.CodeMessage) {
+	limit := msg.Limit
+	if limit <= 0 {
+		limit = defaultListJobsLimit
+	}
+
+	jobs, err := svc.db.ScannerJob().List(svc.db.SQLCtx(), msg.State, msg.Type, msg.Offset, limit)
+	if err != nil {
+		logger.Warnf("listing jobs: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "list jobs failed")
+	}
+
+	return mq.ReplyOK(scmq.RespListJobs(jobs))
+}
+
+func (svc *Service) RetryJob(msg *scmq.RetryJob) (*scmq.RetryJobResp, *mq.CodeMessage) {
+	err := svc.jobQueue.Retry(msg.JobID)
+	if err != nil {
+		logger.WithField("JobID", msg.JobID).Warnf("retrying job: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+
+	return mq.ReplyOK(scmq.RespRetryJob())
+}
+
+func (svc *Service) CancelJob(msg *scmq.CancelJob) (*scmq.CancelJobResp, *mq.CodeMessage) {
+	err := svc.jobQueue.Cancel(msg.JobID)
+	if err != nil {
+		logger.WithField("JobID", msg.JobID).Warnf("canceling job: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+
+	return mq.ReplyOK(scmq.RespCancelJob())
+}
@@ -0,0 +1,18 @@
+package mq
+
+import (
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	scmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner"
+)
+
+func (svc *Service) GetNodeRepairs(msg *scmq.GetNodeRepairs) (*scmq.GetNodeRepairsResp, *mq.CodeMessage) {
+	repairs, err := svc.db.NodeRepair().GetAll(svc.db.SQLCtx())
+	if err != nil {
+		logger.Warnf("getting node repairs: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get node repairs failed")
+	}
+
+	return mq.ReplyOK(scmq.RespGetNodeRepairs(repairs))
+}
@@ -0,0 +1,95 @@
+package mq
+
+import (
+	"fmt"
+
+	"gitlink.org.cn/cloudream/common/consts/errorcode"
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/common/pkgs/mq"
+	scmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
+)
+
+const (
+	defaultListRedundancyPlansLimit = 100
+)
+
+func (svc *Service) PreviewPackageRedundancy(msg *scmq.PreviewPackageRedundancy) (*scmq.PreviewPackageRedundancyResp, *mq.CodeMessage) {
+	log := logger.WithField("PackageID", msg.PackageID)
+
+	plan, err := event.PlanPackageRedundancy(svc.db, msg.PackageID)
+	if err != nil {
+		log.Warnf("planning package redundancy: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, err.Error())
+	}
+
+	if msg.Save {
+		planID, err := svc.db.RedundancyPlan().Create(svc.db.SQLCtx(), plan)
+		if err != nil {
+			log.Warnf("saving plan: %s", err.Error())
+			return nil, mq.Failed(errorcode.OperationFailed, "save plan failed")
+		}
+		plan.PlanID = planID
+	}
+
+	return mq.ReplyOK(scmq.RespPreviewPackageRedundancy(plan))
+}
+
+func (svc *Service) GetRedundancyPlan(msg *scmq.GetRedundancyPlan) (*scmq.GetRedundancyPlanResp, *mq.CodeMessage) {
+	plan, err := svc.db.RedundancyPlan().GetByID(svc.db.SQLCtx(), msg.PlanID)
+	if err != nil {
+		logger.WithField("PlanID", msg.PlanID).Warnf("getting plan: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get plan failed")
+	}
+
+	return mq.ReplyOK(scmq.RespGetRedundancyPlan(plan))
+}
+
+func (svc *Service) ListRedundancyPlans(msg *scmq.ListRedundancyPlans) (*scmq.ListRedundancyPlansResp, *mq.CodeMessage) {
+	limit := msg.Limit
+	if limit <= 0 {
+		limit = defaultListRedundancyPlansLimit
+	}
+
+	plans, err := svc.db.RedundancyPlan().List(svc.db.SQLCtx(), msg.PackageID, msg.State, msg.Offset, limit)
+	if err != nil {
+		logger.Warnf("listing plans: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "list plans failed")
+	}
+
+	return mq.ReplyOK(scmq.RespListRedundancyPlans(plans))
+}
+
+func (svc *Service) ApproveRedundancyPlan(msg *scmq.ApproveRedundancyPlan) (*scmq.ApproveRedundancyPlanResp, *mq.CodeMessage) {
+	log := logger.WithField("PlanID", msg.PlanID)
+
+	plan, err := svc.db.RedundancyPlan().GetByID(svc.db.SQLCtx(), msg.PlanID)
+	if err != nil {
+		log.Warnf("getting plan: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "get plan failed")
+	}
+
+	ok, err := svc.db.RedundancyPlan().Approve(svc.db.SQLCtx(), msg.PlanID)
+	if err != nil {
+		log.Warnf("approving plan: %s", err.Error())
+		return nil, mq.Failed(errorcode.OperationFailed, "approve plan failed")
+	}
+	if !ok {
+		return nil, mq.Failed(errorcode.OperationFailed, fmt.Sprintf("plan %v is not pending or failed", msg.PlanID))
+	}
+
+	jobID, err := svc.jobQueue.Post(scevt.NewExecuteRedundancyPlan(plan.PlanID, plan.PackageID), event.ExecuteOption{})
+	if err != nil {
+		log.Warnf("posting job: %s", err.Error())
+
+		// 没有提交执行任务时，计划不会被执行，需要回到等待批准的状态
+		uerr := svc.db.RedundancyPlan().Unapprove(svc.db.SQLCtx(), msg.PlanID)
+		if uerr != nil {
+			log.Warnf("unapproving plan: %s", uerr.Error())
+		}
+		return nil, mq.Failed(errorcode.OperationFailed, "post job failed")
+	}
+
+	return mq.ReplyOK(scmq.RespApproveRedundancyPlan(jobID))
+}
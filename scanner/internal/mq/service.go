@@ -1,15 +1,20 @@
 package mq
 
 import (
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
 )
 
 type Service struct {
 	eventExecutor *event.Executor
+	jobQueue      *event.JobQueue
+	db            *db.DB
 }
 
-func NewService(eventExecutor *event.Executor) *Service {
+func NewService(eventExecutor *event.Executor, jobQueue *event.JobQueue, db *db.DB) *Service {
 	return &Service{
 		eventExecutor: eventExecutor,
+		jobQueue:      jobQueue,
+		db:            db,
 	}
 }
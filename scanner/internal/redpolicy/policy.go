@@ -0,0 +1,144 @@
+package redpolicy
+
+import (
+	"fmt"
+
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+)
+
+// 冗余策略的类型名
+const (
+	TypeKeep = "keep" // 保持对象当前的冗余策略
+	TypeNone = "none"
+	TypeRep  = "rep"
+	TypeEC   = "ec"
+	TypeLRC  = "lrc"
+)
+
+// 为对象选择冗余策略时参考的信息
+type Input struct {
+	Object    cdssdk.Object
+	PackageID cdssdk.PackageID
+	BucketID  cdssdk.BucketID
+	// 最近一个月、一年内（不含最近一个月）Package被加载到存储服务的次数
+	LoadsRecentMonth int
+	LoadsRecentYear  int
+	// 可以存放数据的节点数量，以及这些节点分布在多少个地域中。不同地域的节点不会同时故障
+	NodeCount     int
+	LocationCount int
+}
+
+// 冗余策略的选择方式
+type Policy interface {
+	// 返回对象应该使用的冗余策略，返回nil代表保持当前的冗余策略
+	Choose(in Input) cdssdk.Redundancy
+}
+
+// 配置文件中的冗余策略。除了Type以外的字段为0时使用对应类型的默认参数
+type Spec struct {
+	Type      string `json:"type"`
+	RepCount  int    `json:"repCount"`
+	K         int    `json:"k"`
+	N         int    `json:"n"`
+	ChunkSize int    `json:"chunkSize"`
+	Groups    []int  `json:"groups"` // LRC的分组
+}
+
+// 生成冗余策略，Type为keep时返回nil
+func (s *Spec) Build() (cdssdk.Redundancy, error) {
+	switch s.Type {
+	case TypeKeep:
+		return nil, nil
+
+	case TypeNone:
+		return cdssdk.NewNoneRedundancy(), nil
+
+	case TypeRep:
+		red := cdssdk.DefaultRepRedundancy
+		if s.RepCount > 0 {
+			red.RepCount = s.RepCount
+		}
+		if red.RepCount <= 0 {
+			return nil, fmt.Errorf("rep count must be greater than 0")
+		}
+		return &red, nil
+
+	case TypeEC:
+		red := cdssdk.DefaultECRedundancy
+		if s.K > 0 {
+			red.K = s.K
+		}
+		if s.N > 0 {
+			red.N = s.N
+		}
+		if s.ChunkSize > 0 {
+			red.ChunkSize = s.ChunkSize
+		}
+		if red.K <= 0 || red.N < red.K {
+			return nil, fmt.Errorf("invalid ec parameters k=%d n=%d", red.K, red.N)
+		}
+		return &red, nil
+
+	case TypeLRC:
+		red := cdssdk.DefaultLRCRedundancy
+		// 分组与K、N必须匹配，不能沿用默认的分组
+		if (s.K > 0 || s.N > 0) && len(s.Groups) == 0 {
+			return nil, fmt.Errorf("groups must be set when k or n of lrc is set")
+		}
+		if s.K > 0 {
+			red.K = s.K
+		}
+		if s.N > 0 {
+			red.N = s.N
+		}
+		if s.ChunkSize > 0 {
+			red.ChunkSize = s.ChunkSize
+		}
+		if len(s.Groups) > 0 {
+			red.Groups = s.Groups
+		}
+		if err := checkLRCGroups(red); err != nil {
+			return nil, err
+		}
+		return &red, nil
+	}
+
+	return nil, fmt.Errorf("unknown redundancy type %q", s.Type)
+}
+
+// 分组划分的是K个数据块，每个分组有一个局部校验块，其余的是全局校验块，
+// 所以分组大小之和必须等于K，并且N = K + 全局校验块数 + 分组数
+func checkLRCGroups(red cdssdk.LRCRedundancy) error {
+	if red.K <= 0 || red.N <= red.K {
+		return fmt.Errorf("invalid lrc parameters k=%d n=%d", red.K, red.N)
+	}
+
+	sum := 0
+	for _, g := range red.Groups {
+		if g <= 0 {
+			return fmt.Errorf("invalid lrc group size %d", g)
+		}
+		sum += g
+	}
+
+	globalParities := red.N - red.K - len(red.Groups)
+	if sum != red.K || globalParities < 0 {
+		return fmt.Errorf("lrc groups %v do not match k=%d n=%d", red.Groups, red.K, red.N)
+	}
+	return nil
+}
+
+// 返回冗余策略的类型名
+func TypeOf(red cdssdk.Redundancy) string {
+	switch red.(type) {
+	case *cdssdk.NoneRedundancy:
+		return TypeNone
+	case *cdssdk.RepRedundancy:
+		return TypeRep
+	case *cdssdk.ECRedundancy:
+		return TypeEC
+	case *cdssdk.LRCRedundancy:
+		return TypeLRC
+	}
+	return ""
+}
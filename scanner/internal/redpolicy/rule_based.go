@@ -0,0 +1,103 @@
+package redpolicy
+
+import (
+	"fmt"
+
+	"github.com/samber/lo"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+)
+
+// 基于规则的冗余策略配置
+type Config struct {
+	// 按顺序检查，使用第一条满足条件的规则
+	Rules []Rule `json:"rules"`
+	// 没有规则满足时使用的冗余策略，为null代表保持不变
+	Default *Spec `json:"default"`
+}
+
+// 规则的条件都满足时，对象使用规则中的冗余策略。没有设置的条件不做检查
+type Rule struct {
+	BucketIDs  []cdssdk.BucketID  `json:"bucketIDs"`
+	PackageIDs []cdssdk.PackageID `json:"packageIDs"`
+	// 对象当前的冗余策略类型，比如none、rep
+	Sources []string `json:"sources"`
+	// 对象大小的范围，包含MinSize，不包含MaxSize。MaxSize为0代表不限制
+	MinSize int64 `json:"minSize"`
+	MaxSize int64 `json:"maxSize"`
+	// 最近一个月Package被加载的次数的范围，都包含在内
+	MinMonthLoads *int `json:"minMonthLoads"`
+	MaxMonthLoads *int `json:"maxMonthLoads"`
+	// 可用的节点至少要有这么多个，并且分布在这么多个地域中。节点数量少于冗余策略的块数时，多个块会存放在同一个节点上
+	MinNodes     int  `json:"minNodes"`
+	MinLocations int  `json:"minLocations"`
+	Redundancy   Spec `json:"redundancy"`
+}
+
+func (r *Rule) Matches(in Input) bool {
+	if len(r.BucketIDs) > 0 && !lo.Contains(r.BucketIDs, in.BucketID) {
+		return false
+	}
+
+	if len(r.PackageIDs) > 0 && !lo.Contains(r.PackageIDs, in.PackageID) {
+		return false
+	}
+
+	if len(r.Sources) > 0 && !lo.Contains(r.Sources, TypeOf(in.Object.Redundancy)) {
+		return false
+	}
+
+	if in.Object.Size < r.MinSize || (r.MaxSize > 0 && in.Object.Size >= r.MaxSize) {
+		return false
+	}
+
+	if r.MinMonthLoads != nil && in.LoadsRecentMonth < *r.MinMonthLoads {
+		return false
+	}
+
+	if r.MaxMonthLoads != nil && in.LoadsRecentMonth > *r.MaxMonthLoads {
+		return false
+	}
+
+	return in.NodeCount >= r.MinNodes && in.LocationCount >= r.MinLocations
+}
+
+type RuleBased struct {
+	cfg        Config
+	rules      []cdssdk.Redundancy
+	defaultRed cdssdk.Redundancy
+}
+
+// 检查配置中的冗余策略并生成RuleBased
+func NewRuleBased(cfg Config) (*RuleBased, error) {
+	p := &RuleBased{
+		cfg: cfg,
+	}
+
+	for i, rule := range cfg.Rules {
+		red, err := rule.Redundancy.Build()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		p.rules = append(p.rules, red)
+	}
+
+	if cfg.Default != nil {
+		red, err := cfg.Default.Build()
+		if err != nil {
+			return nil, fmt.Errorf("default: %w", err)
+		}
+		p.defaultRed = red
+	}
+
+	return p, nil
+}
+
+func (p *RuleBased) Choose(in Input) cdssdk.Redundancy {
+	for i, rule := range p.cfg.Rules {
+		if rule.Matches(in) {
+			return p.rules[i]
+		}
+	}
+
+	return p.defaultRed
+}
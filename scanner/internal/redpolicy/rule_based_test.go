@@ -0,0 +1,125 @@
+package redpolicy
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
+	"gitlink.org.cn/cloudream/common/utils/serder"
+)
+
+func loadTestPolicy(t *testing.T, path string) *RuleBased {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg Config
+	err = serder.JSONToObject(data, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewRuleBased(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func Test_RuleBased(t *testing.T) {
+	p := loadTestPolicy(t, "testdata/rules.json")
+
+	none := cdssdk.NewNoneRedundancy()
+	rep := &cdssdk.DefaultRepRedundancy
+	lrc := &cdssdk.DefaultLRCRedundancy
+
+	input := func(bucketID cdssdk.BucketID, red cdssdk.Redundancy, size int64, monthLoads int, nodes int, locations int) Input {
+		return Input{
+			Object:           cdssdk.Object{Redundancy: red, Size: size},
+			PackageID:        1,
+			BucketID:         bucketID,
+			LoadsRecentMonth: monthLoads,
+			NodeCount:        nodes,
+			LocationCount:    locations,
+		}
+	}
+
+	Convey("设置为keep的桶中的对象保持不变", t, func() {
+		So(p.Choose(input(3, none, 1024, 0, 5, 3)), ShouldBeNil)
+	})
+
+	Convey("小文件使用3副本", t, func() {
+		red, ok := p.Choose(input(1, none, 1024, 0, 5, 3)).(*cdssdk.RepRedundancy)
+		So(ok, ShouldBeTrue)
+		So(red.RepCount, ShouldEqual, 3)
+	})
+
+	Convey("经常被访问的大文件使用2副本", t, func() {
+		red, ok := p.Choose(input(1, none, 10<<20, 20, 5, 3)).(*cdssdk.RepRedundancy)
+		So(ok, ShouldBeTrue)
+		So(red.RepCount, ShouldEqual, 2)
+	})
+
+	Convey("没有被访问过的大文件在地域足够时使用EC", t, func() {
+		red, ok := p.Choose(input(1, rep, 10<<20, 0, 5, 3)).(*cdssdk.ECRedundancy)
+		So(ok, ShouldBeTrue)
+		So(red.K, ShouldEqual, 2)
+		So(red.N, ShouldEqual, 3)
+
+		// 地域不够时没有规则满足，也没有默认策略
+		So(p.Choose(input(1, rep, 10<<20, 0, 5, 2)), ShouldBeNil)
+	})
+
+	Convey("节点足够时使用默认参数的LRC", t, func() {
+		red, ok := p.Choose(input(1, lrc, 10<<20, 5, 4, 2)).(*cdssdk.LRCRedundancy)
+		So(ok, ShouldBeTrue)
+		So(red.K, ShouldEqual, cdssdk.DefaultLRCRedundancy.K)
+		So(red.N, ShouldEqual, cdssdk.DefaultLRCRedundancy.N)
+
+		So(p.Choose(input(1, none, 10<<20, 5, 3, 3)), ShouldBeNil)
+	})
+}
+
+func Test_Spec(t *testing.T) {
+	Convey("非法的冗余策略", t, func() {
+		_, err := NewRuleBased(Config{Rules: []Rule{{Redundancy: Spec{Type: TypeEC, K: 3, N: 2}}}})
+		So(err, ShouldNotBeNil)
+
+		_, err = NewRuleBased(Config{Default: &Spec{Type: "raid"}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("LRC的分组需要与K、N匹配", t, func() {
+		// 修改了K、N时必须同时设置分组
+		_, err := (&Spec{Type: TypeLRC, K: 4, N: 7}).Build()
+		So(err, ShouldNotBeNil)
+
+		// 分组大小之和不等于K
+		_, err = (&Spec{Type: TypeLRC, K: 4, N: 7, Groups: []int{3, 3}}).Build()
+		So(err, ShouldNotBeNil)
+
+		// 数据块和局部校验块的数量已经超过了N
+		_, err = (&Spec{Type: TypeLRC, K: 4, N: 5, Groups: []int{2, 2}}).Build()
+		So(err, ShouldNotBeNil)
+
+		red, err := (&Spec{Type: TypeLRC, K: 4, N: 7, Groups: []int{2, 2}}).Build()
+		So(err, ShouldBeNil)
+
+		lrc := red.(*cdssdk.LRCRedundancy)
+		So(lrc.K, ShouldEqual, 4)
+		So(lrc.N, ShouldEqual, 7)
+		So(lrc.Groups, ShouldResemble, []int{2, 2})
+	})
+
+	Convey("没有配置的参数使用默认值", t, func() {
+		red, err := (&Spec{Type: TypeEC, ChunkSize: 4096}).Build()
+		So(err, ShouldBeNil)
+
+		ec := red.(*cdssdk.ECRedundancy)
+		So(ec.K, ShouldEqual, cdssdk.DefaultECRedundancy.K)
+		So(ec.N, ShouldEqual, cdssdk.DefaultECRedundancy.N)
+		So(ec.ChunkSize, ShouldEqual, 4096)
+	})
+}
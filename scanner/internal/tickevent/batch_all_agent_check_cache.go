@@ -30,13 +30,16 @@ func (e *BatchAllAgentCheckCache) Execute(ctx ExecuteContext) {
 			return
 		}
 
+		// 只检查本实例拥有的分片中的节点
+		nodes = lo.Filter(nodes, func(node cdssdk.Node, index int) bool { return ctx.Args.Cluster.OwnsID(int64(node.NodeID)) })
 		e.nodeIDs = lo.Map(nodes, func(node cdssdk.Node, index int) cdssdk.NodeID { return node.NodeID })
 
 		log.Debugf("new check start, get all nodes")
 	}
 
+	size := batchSize("BatchAllAgentCheckCache", AGENT_CHECK_CACHE_BATCH_SIZE)
 	checkedCnt := 0
-	for ; checkedCnt < len(e.nodeIDs) && checkedCnt < AGENT_CHECK_CACHE_BATCH_SIZE; checkedCnt++ {
+	for ; checkedCnt < len(e.nodeIDs) && checkedCnt < size; checkedCnt++ {
 		// nil代表进行全量检查
 		ctx.Args.EventExecutor.Post(event.NewAgentCheckCache(scevt.NewAgentCheckCache(e.nodeIDs[checkedCnt])))
 	}
@@ -7,7 +7,7 @@ import (
 )
 
 type BatchCheckAllPackage struct {
-	lastCheckStart int
+	shardRound int
 }
 
 func NewBatchCheckAllPackage() *BatchCheckAllPackage {
@@ -19,20 +19,15 @@ func (e *BatchCheckAllPackage) Execute(ctx ExecuteContext) {
 	log.Debugf("begin")
 	defer log.Debugf("end")
 
-	packageIDs, err := ctx.Args.DB.Package().BatchGetAllPackageIDs(ctx.Args.DB.SQLCtx(), e.lastCheckStart, CheckPackageBatchSize)
+	size := batchSize("BatchCheckAllPackage", CheckPackageBatchSize)
+	packageIDs, err := nextPackageBatch(ctx, "BatchCheckAllPackage", size, &e.shardRound)
 	if err != nil {
 		log.Warnf("batch get package ids failed, err: %s", err.Error())
 		return
 	}
+	if len(packageIDs) == 0 {
+		return
+	}
 
 	ctx.Args.EventExecutor.Post(event.NewCheckPackage(scevt.NewCheckPackage(packageIDs)))
-
-	// 如果结果的长度小于预期的长度，则认为已经查询了所有，下次从头再来
-	if len(packageIDs) < CheckPackageBatchSize {
-		e.lastCheckStart = 0
-		log.Debugf("all package checked, next time will start check at 0")
-
-	} else {
-		e.lastCheckStart += CheckPackageBatchSize
-	}
 }
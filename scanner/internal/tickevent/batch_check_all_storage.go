@@ -2,6 +2,7 @@ package tickevent
 
 import (
 	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
 )
@@ -9,7 +10,6 @@ import (
 const CHECK_STORAGE_BATCH_SIZE = 5
 
 type BatchCheckAllStorage struct {
-	lastCheckStart int
 }
 
 func NewBatchCheckAllStorage() *BatchCheckAllStorage {
@@ -21,7 +21,9 @@ func (e *BatchCheckAllStorage) Execute(ctx ExecuteContext) {
 	log.Debugf("begin")
 	defer log.Debugf("end")
 
-	storageIDs, err := ctx.Args.DB.Storage().BatchGetAllStorageIDs(ctx.Args.DB.SQLCtx(), e.lastCheckStart, CHECK_STORAGE_BATCH_SIZE)
+	size := batchSize("BatchCheckAllStorage", CHECK_STORAGE_BATCH_SIZE)
+	lastID := loadCursor(ctx, "BatchCheckAllStorage")
+	storageIDs, err := ctx.Args.DB.Storage().BatchGetAllStorageIDs(ctx.Args.DB.SQLCtx(), cdssdk.StorageID(lastID), size)
 	if err != nil {
 		log.Warnf("batch get storage ids failed, err: %s", err.Error())
 		return
@@ -33,11 +35,13 @@ func (e *BatchCheckAllStorage) Execute(ctx ExecuteContext) {
 	}
 
 	// 如果结果的长度小于预期的长度，则认为已经查询了所有，下次从头再来
-	if len(storageIDs) < CHECK_STORAGE_BATCH_SIZE {
-		e.lastCheckStart = 0
-		log.Debugf("all storage checked, next time will start check at 0")
+	if len(storageIDs) < size {
+		lastID = 0
+		log.Debugf("all storage checked, next time will start check from the beginning")
 
 	} else {
-		e.lastCheckStart += CHECK_STORAGE_BATCH_SIZE
+		lastID = int64(storageIDs[len(storageIDs)-1])
 	}
+
+	saveCursor(ctx, "BatchCheckAllStorage", lastID)
 }
@@ -0,0 +1,62 @@
+package tickevent
+
+import (
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/db/model"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	evt "gitlink.org.cn/cloudream/storage/scanner/internal/event"
+)
+
+// 检查所有设置了生命周期规则的桶。Expire和Unpin规则由CheckBucketLifecycle执行，
+// 有对象满足Transition规则的Package则交给CheckPackageRedundancy修改冗余策略
+type BatchCheckBucketLifecycle struct {
+}
+
+func NewBatchCheckBucketLifecycle() *BatchCheckBucketLifecycle {
+	return &BatchCheckBucketLifecycle{}
+}
+
+func (e *BatchCheckBucketLifecycle) Execute(ctx ExecuteContext) {
+	log := logger.WithType[BatchCheckBucketLifecycle]("TickEvent")
+	log.Debugf("begin")
+	defer log.Debugf("end")
+
+	bucketIDs, err := ctx.Args.DB.Lifecycle().GetAllBucketIDs(ctx.Args.DB.SQLCtx())
+	if err != nil {
+		log.Warnf("getting buckets with lifecycle rules: %s", err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, bktID := range bucketIDs {
+		ctx.Args.EventExecutor.Post(evt.NewCheckBucketLifecycle(event.NewCheckBucketLifecycle(bktID)))
+
+		rules, err := ctx.Args.DB.Lifecycle().GetByBucketID(ctx.Args.DB.SQLCtx(), bktID)
+		if err != nil {
+			log.WithField("BucketID", bktID).Warnf("getting lifecycle rules: %s", err.Error())
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.Action != model.LifecycleActionTransition {
+				continue
+			}
+
+			pkgIDs, err := ctx.Args.DB.Lifecycle().GetPackageIDsBefore(ctx.Args.DB.SQLCtx(), bktID, rule.PathPrefix, rule.Before(now))
+			if err != nil {
+				log.WithField("RuleID", rule.RuleID).Warnf("getting packages to transition: %s", err.Error())
+				continue
+			}
+
+			// 由拥有Package所在分片的实例执行
+			for _, pkgID := range pkgIDs {
+				_, err := ctx.Args.JobQueue.Post(event.NewRoutineCheckPackageRedundancy(pkgID), evt.ExecuteOption{})
+				if err != nil {
+					log.Warnf("posting job of package %v: %s", pkgID, err.Error())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package tickevent
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	evt "gitlink.org.cn/cloudream/storage/scanner/internal/event"
+)
+
+type BatchScrubPackage struct {
+	shardRound int
+}
+
+func NewBatchScrubPackage() *BatchScrubPackage {
+	return &BatchScrubPackage{}
+}
+
+func (e *BatchScrubPackage) Execute(ctx ExecuteContext) {
+	log := logger.WithType[BatchScrubPackage]("TickEvent")
+	log.Debugf("begin")
+	defer log.Debugf("end")
+
+	size := batchSize("BatchScrubPackage", CheckPackageBatchSize)
+	packageIDs, err := nextPackageBatch(ctx, "BatchScrubPackage", size, &e.shardRound)
+	if err != nil {
+		log.Warnf("batch get package ids failed, err: %s", err.Error())
+		return
+	}
+
+	for _, id := range packageIDs {
+		err := ctx.Args.JobQueue.PostTransient(event.NewScrubPackage(id), evt.ExecuteOption{})
+		if err != nil {
+			log.Warnf("posting job of package %v: %s", id, err.Error())
+		}
+	}
+}
@@ -25,6 +25,11 @@ func (e *CheckAgentState) Execute(ctx ExecuteContext) {
 	}
 
 	for _, node := range nodes {
+		// 其他节点由拥有它所在分片的实例检查
+		if !ctx.Args.Cluster.OwnsID(int64(node.NodeID)) {
+			continue
+		}
+
 		ctx.Args.EventExecutor.Post(event.NewAgentCheckState(scevt.NewAgentCheckState(node.NodeID)), event.ExecuteOption{
 			IsEmergency: true,
 			DontMerge:   true,
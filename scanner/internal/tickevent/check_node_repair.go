@@ -0,0 +1,41 @@
+package tickevent
+
+import (
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	"gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+	evt "gitlink.org.cn/cloudream/storage/scanner/internal/event"
+)
+
+type CheckNodeRepair struct {
+}
+
+func NewCheckNodeRepair() *CheckNodeRepair {
+	return &CheckNodeRepair{}
+}
+
+func (e *CheckNodeRepair) Execute(ctx ExecuteContext) {
+	log := logger.WithType[CheckNodeRepair]("TickEvent")
+	log.Debugf("begin")
+	defer log.Debugf("end")
+
+	before := time.Now().Add(-time.Duration(config.Cfg().NodeRepairGraceSeconds) * time.Second)
+	repairs, err := ctx.Args.DB.NodeRepair().GetPending(ctx.Args.DB.SQLCtx(), before)
+	if err != nil {
+		log.Warnf("getting pending node repairs: %s", err.Error())
+		return
+	}
+
+	for _, r := range repairs {
+		if !ctx.Args.Cluster.OwnsID(int64(r.NodeID)) {
+			continue
+		}
+
+		// 修复数据比例行的调整更重要，需要优先执行
+		ctx.Args.EventExecutor.Post(evt.NewRepairNode(event.NewRepairNode(r.NodeID)), evt.ExecuteOption{
+			IsEmergency: true,
+		})
+	}
+}
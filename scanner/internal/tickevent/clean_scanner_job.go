@@ -0,0 +1,33 @@
+package tickevent
+
+import (
+	"time"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+)
+
+// 已经结束的任务保留的时间
+const ScannerJobKeepTime = 7 * 24 * time.Hour
+
+type CleanScannerJob struct {
+}
+
+func NewCleanScannerJob() *CleanScannerJob {
+	return &CleanScannerJob{}
+}
+
+func (e *CleanScannerJob) Execute(ctx ExecuteContext) {
+	log := logger.WithType[CleanScannerJob]("TickEvent")
+	log.Debugf("begin")
+	defer log.Debugf("end")
+
+	cnt, err := ctx.Args.DB.ScannerJob().DeleteFinishedBefore(ctx.Args.DB.SQLCtx(), time.Now().Add(-ScannerJobKeepTime))
+	if err != nil {
+		log.Warnf("deleting finished jobs: %s", err.Error())
+		return
+	}
+
+	if cnt > 0 {
+		log.Infof("%d finished jobs deleted", cnt)
+	}
+}
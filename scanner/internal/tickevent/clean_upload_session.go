@@ -0,0 +1,22 @@
+package tickevent
+
+import (
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
+	scevt "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner/event"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
+)
+
+type CleanUploadSession struct {
+}
+
+func NewCleanUploadSession() *CleanUploadSession {
+	return &CleanUploadSession{}
+}
+
+func (e *CleanUploadSession) Execute(ctx ExecuteContext) {
+	log := logger.WithType[CleanUploadSession]("TickEvent")
+	log.Debugf("begin")
+	defer log.Debugf("end")
+
+	ctx.Args.EventExecutor.Post(event.NewCleanUploadSession(scevt.NewCleanUploadSession()))
+}
@@ -0,0 +1,77 @@
+package tickevent
+
+import (
+	"reflect"
+	"time"
+
+	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
+)
+
+// 所有定时任务都以这个间隔被触发，然后再根据调度策略决定是否真正执行，因此这也是定时任务执行间隔的精度
+const ScheduleCheckIntervalMs = 60 * 1000
+
+// 按照调度策略执行定时任务。只有距离上次执行超过了策略中的间隔，并且处于允许执行的时间段内时才会执行，
+// 策略修改后在下一次触发时就会生效
+type scheduledEvent struct {
+	name       string
+	inner      Event
+	leaderOnly bool
+	lastRun    time.Time
+}
+
+func Scheduled(inner Event) Event {
+	return &scheduledEvent{
+		name:  tickName(inner),
+		inner: inner,
+	}
+}
+
+// 与Scheduled相同，但只在Leader实例上执行。用于不能按分片划分的定时任务
+func ScheduledOnLeader(inner Event) Event {
+	return &scheduledEvent{
+		name:       tickName(inner),
+		inner:      inner,
+		leaderOnly: true,
+	}
+}
+
+func (e *scheduledEvent) Execute(ctx ExecuteContext) {
+	pol := config.CurrentPolicy().Tick(e.name)
+	if pol.Disabled {
+		return
+	}
+
+	if e.leaderOnly && !ctx.Args.Cluster.IsLeader() {
+		return
+	}
+
+	now := time.Now()
+	// 触发时间有误差，留出半个触发间隔的余量，防止执行间隔被延长一个触发间隔
+	if now.Sub(e.lastRun) < pol.Interval()-ScheduleCheckIntervalMs*time.Millisecond/2 {
+		return
+	}
+
+	if !pol.InWindow(now) {
+		return
+	}
+
+	e.lastRun = now
+	e.inner.Execute(ctx)
+}
+
+// 查询定时任务每次扫描的数量，策略中没有配置时使用def
+func batchSize(name string, def int) int {
+	size := config.CurrentPolicy().Tick(name).BatchSize
+	if size <= 0 {
+		return def
+	}
+	return size
+}
+
+func tickName(evt Event) string {
+	typ := reflect.TypeOf(evt)
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.Name()
+}
@@ -1,13 +1,20 @@
 package tickevent
 
 import (
+	"fmt"
+
+	"gitlink.org.cn/cloudream/common/pkgs/logger"
 	tickevent "gitlink.org.cn/cloudream/common/pkgs/tickevent"
+	cdssdk "gitlink.org.cn/cloudream/common/sdks/storage"
 	mydb "gitlink.org.cn/cloudream/storage/common/pkgs/db"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/cluster"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
 )
 
 type ExecuteArgs struct {
 	EventExecutor *event.Executor
+	JobQueue      *event.JobQueue
+	Cluster       *cluster.Member
 	DB            *mydb.DB
 }
 
@@ -22,3 +29,55 @@ type Event = tickevent.TickEvent[ExecuteArgs]
 func NewExecutor(args ExecuteArgs) Executor {
 	return tickevent.NewExecutor(args)
 }
+
+// 读取定时扫描任务上一次扫描到的最后一个ID，读取失败时从头开始扫描
+func loadCursor(ctx ExecuteContext, name string) int64 {
+	start, err := ctx.Args.DB.ScannerCursor().Get(ctx.Args.DB.SQLCtx(), name)
+	if err != nil {
+		logger.Warnf("loading cursor of %s: %s", name, err.Error())
+		return 0
+	}
+	return start
+}
+
+func saveCursor(ctx ExecuteContext, name string, lastID int64) {
+	err := ctx.Args.DB.ScannerCursor().Set(ctx.Args.DB.SQLCtx(), name, lastID)
+	if err != nil {
+		logger.Warnf("saving cursor of %s: %s", name, err.Error())
+	}
+}
+
+// 从本实例拥有的分片中取出下一批要扫描的Package。每次调用轮换到下一个分片，每个分片有独立的扫描进度，
+// round记录轮换的位置。本实例没有分片时返回空
+func nextPackageBatch(ctx ExecuteContext, name string, size int, round *int) ([]cdssdk.PackageID, error) {
+	var shards []int
+	for _, shard := range ctx.Args.Cluster.OwnedShards() {
+		if shard != cluster.LeaderShard {
+			shards = append(shards, shard)
+		}
+	}
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	shard := shards[*round%len(shards)]
+	*round++
+
+	cursorName := fmt.Sprintf("%s/%d", name, shard)
+	lastID := loadCursor(ctx, cursorName)
+	packageIDs, err := ctx.Args.DB.Package().BatchGetPackageIDsInShard(ctx.Args.DB.SQLCtx(), shard, ctx.Args.Cluster.ShardCount(), cdssdk.PackageID(lastID), size)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果结果的长度小于预期的长度，则认为已经查询了所有，下次从头再来
+	if len(packageIDs) < size {
+		lastID = 0
+		logger.Debugf("%s: all package in shard %d checked, next time will start from the beginning", name, shard)
+	} else {
+		lastID = int64(packageIDs[len(packageIDs)-1])
+	}
+	saveCursor(ctx, cursorName, lastID)
+
+	return packageIDs, nil
+}
@@ -10,6 +10,7 @@ import (
 	"gitlink.org.cn/cloudream/storage/common/pkgs/distlock"
 	agtrpc "gitlink.org.cn/cloudream/storage/common/pkgs/grpc/agent"
 	scmq "gitlink.org.cn/cloudream/storage/common/pkgs/mq/scanner"
+	"gitlink.org.cn/cloudream/storage/scanner/internal/cluster"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/config"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/event"
 	"gitlink.org.cn/cloudream/storage/scanner/internal/mq"
@@ -29,6 +30,11 @@ func main() {
 		os.Exit(1)
 	}
 
+	err = config.InitPolicy()
+	if err != nil {
+		logger.Warnf("init policy failed, default policy will be used, err: %s", err.Error())
+	}
+
 	db, err := db.NewDB(&config.Cfg().DB)
 	if err != nil {
 		logger.Fatalf("new db failed, err: %s", err.Error())
@@ -48,7 +54,14 @@ func main() {
 	eventExecutor := event.NewExecutor(db, distlockSvc)
 	go serveEventExecutor(&eventExecutor)
 
-	agtSvr, err := scmq.NewServer(mq.NewService(&eventExecutor), &config.Cfg().RabbitMQ)
+	// 多个Scanner实例通过分布式锁划分工作
+	member := cluster.NewMember(config.Cfg().Cluster, db, distlockSvc)
+	go member.Serve()
+
+	jobQueue := event.NewJobQueue(db, &eventExecutor, member)
+	go jobQueue.Serve()
+
+	agtSvr, err := scmq.NewServer(mq.NewService(&eventExecutor, jobQueue, db), &config.Cfg().RabbitMQ)
 	if err != nil {
 		logger.Fatalf("new agent server failed, err: %s", err.Error())
 	}
@@ -59,6 +72,8 @@ func main() {
 
 	tickExecutor := tickevent.NewExecutor(tickevent.ExecuteArgs{
 		EventExecutor: &eventExecutor,
+		JobQueue:      jobQueue,
+		Cluster:       member,
 		DB:            db,
 	})
 	startTickEvent(&tickExecutor)
@@ -113,21 +128,36 @@ func serveDistLock(svc *distlock.Service) {
 }
 
 func startTickEvent(tickExecutor *tickevent.Executor) {
-	// TODO 可以考虑增加配置文件，配置这些任务间隔时间
+	// 所有定时任务都以同样的间隔触发，实际的执行间隔和允许执行的时间段由调度策略决定
+	start := func(evt tickevent.Event, randomStartDelayMs int) {
+		tickExecutor.Start(tickevent.Scheduled(evt), tickevent.ScheduleCheckIntervalMs, tickevent.StartOption{RandomStartDelayMs: randomStartDelayMs})
+	}
+	// 不能按分片划分的定时任务只由Leader执行
+	startOnLeader := func(evt tickevent.Event, randomStartDelayMs int) {
+		tickExecutor.Start(tickevent.ScheduledOnLeader(evt), tickevent.ScheduleCheckIntervalMs, tickevent.StartOption{RandomStartDelayMs: randomStartDelayMs})
+	}
+
+	start(tickevent.NewBatchAllAgentCheckCache(), 60*1000)
+
+	start(tickevent.NewBatchCheckAllPackage(), 60*1000)
+
+	// start(tickevent.NewBatchCheckAllRepCount(), 60*1000)
+
+	startOnLeader(tickevent.NewBatchCheckAllStorage(), 60*1000)
 
-	interval := 5 * 60 * 1000
+	start(tickevent.NewCheckAgentState(), 60*1000)
 
-	tickExecutor.Start(tickevent.NewBatchAllAgentCheckCache(), interval, tickevent.StartOption{RandomStartDelayMs: 60 * 1000})
+	start(tickevent.NewCheckNodeRepair(), 60*1000)
 
-	tickExecutor.Start(tickevent.NewBatchCheckAllPackage(), interval, tickevent.StartOption{RandomStartDelayMs: 60 * 1000})
+	start(tickevent.NewBatchCheckPackageRedundancy(), 20*60*1000)
 
-	// tickExecutor.Start(tickevent.NewBatchCheckAllRepCount(), interval, tickevent.StartOption{RandomStartDelayMs: 60 * 1000})
+	start(tickevent.NewBatchCleanPinned(), 20*60*1000)
 
-	tickExecutor.Start(tickevent.NewBatchCheckAllStorage(), interval, tickevent.StartOption{RandomStartDelayMs: 60 * 1000})
+	startOnLeader(tickevent.NewBatchCheckBucketLifecycle(), 20*60*1000)
 
-	tickExecutor.Start(tickevent.NewCheckAgentState(), 5*60*1000, tickevent.StartOption{RandomStartDelayMs: 60 * 1000})
+	start(tickevent.NewBatchScrubPackage(), 20*60*1000)
 
-	tickExecutor.Start(tickevent.NewBatchCheckPackageRedundancy(), interval, tickevent.StartOption{RandomStartDelayMs: 20 * 60 * 1000})
+	startOnLeader(tickevent.NewCleanUploadSession(), 60*1000)
 
-	tickExecutor.Start(tickevent.NewBatchCleanPinned(), interval, tickevent.StartOption{RandomStartDelayMs: 20 * 60 * 1000})
+	startOnLeader(tickevent.NewCleanScannerJob(), 60*1000)
 }